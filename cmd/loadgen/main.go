@@ -0,0 +1,166 @@
+// File: cmd/loadgen/main.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var (
+	products = []string{"prod-123", "prod-456", "prod-789", "prod-321"}
+	amounts  = []float64{29.99, 49.50, 79.95, 129.00, 249.99}
+	// Mostly USD (the default), with some international orders
+	currencies = []string{"", "", "", "EUR", "GBP"}
+)
+
+type orderRequest struct {
+	UserID    string  `json:"user_id"`
+	ProductID string  `json:"product_id"`
+	Quantity  int     `json:"quantity"`
+	Amount    float64 `json:"amount"`
+	PromoCode string  `json:"promo_code,omitempty"`
+	Currency  string  `json:"currency,omitempty"`
+}
+
+type orderResponse struct {
+	TraceID string `json:"trace_id"`
+}
+
+func main() {
+	target := flag.String("url", "http://localhost:8080/orders", "order endpoint to load")
+	duration := flag.Duration("duration", 30*time.Second, "how long to generate load")
+	rps := flag.Int("rps", 20, "target requests per second")
+	concurrency := flag.Int("concurrency", 8, "number of concurrent workers")
+	invalidRatio := flag.Float64("invalid-ratio", 0.1, "fraction of requests sent with an invalid payload")
+	jsonOut := flag.String("json", "loadgen-report.json", "path of the JSON report (empty to skip)")
+	htmlOut := flag.String("html", "loadgen-report.html", "path of the HTML report (empty to skip)")
+	flag.Parse()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+	ctx, cancelRun := context.WithTimeout(ctx, *duration)
+	defer cancelRun()
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	jobs := make(chan int)
+	results := make(chan Result, *concurrency)
+
+	var wg sync.WaitGroup
+	for w := 0; w < *concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results <- send(client, *target, i, rand.Float64() < *invalidRatio)
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		ticker := time.NewTicker(time.Second / time.Duration(max(*rps, 1)))
+		defer ticker.Stop()
+		for i := 0; ; i++ {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				select {
+				case jobs <- i:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	log.Printf("Generating load against %s for %s at %d rps", *target, *duration, *rps)
+	start := time.Now()
+	var collected []Result
+	for r := range results {
+		collected = append(collected, r)
+	}
+
+	report := BuildReport(*target, start, collected)
+	log.Printf("Sent %d requests: p50=%.1fms p95=%.1fms p99=%.1fms errors=%d",
+		report.Total, report.Latency.P50, report.Latency.P95, report.Latency.P99, report.Errors)
+
+	if *jsonOut != "" {
+		if err := writeFile(*jsonOut, report.WriteJSON); err != nil {
+			log.Fatalf("Failed to write JSON report: %v", err)
+		}
+		log.Printf("JSON report written to %s", *jsonOut)
+	}
+	if *htmlOut != "" {
+		if err := writeFile(*htmlOut, report.WriteHTML); err != nil {
+			log.Fatalf("Failed to write HTML report: %v", err)
+		}
+		log.Printf("HTML report written to %s", *htmlOut)
+	}
+}
+
+func send(client *http.Client, target string, i int, invalid bool) Result {
+	req := orderRequest{
+		UserID:    fmt.Sprintf("user-%d", i),
+		ProductID: products[rand.Intn(len(products))],
+		Quantity:  rand.Intn(4) + 1,
+		Amount:    amounts[rand.Intn(len(amounts))],
+		Currency:  currencies[rand.Intn(len(currencies))],
+	}
+	// Exercise the discount metrics on a share of orders
+	if rand.Intn(5) == 0 {
+		req.PromoCode = "SAVE10"
+	}
+	if invalid {
+		req = orderRequest{ProductID: "prod-invalid", Amount: -42}
+	}
+	body, _ := json.Marshal(req)
+
+	start := time.Now()
+	resp, err := client.Post(target, "application/json", bytes.NewReader(body))
+	result := Result{Start: start, Latency: time.Since(start)}
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer resp.Body.Close()
+
+	result.Status = resp.StatusCode
+	payload, _ := io.ReadAll(resp.Body)
+	result.Latency = time.Since(start)
+
+	var decoded orderResponse
+	if json.Unmarshal(payload, &decoded) == nil {
+		result.TraceID = decoded.TraceID
+	}
+	return result
+}
+
+func writeFile(path string, write func(io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
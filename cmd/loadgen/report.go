@@ -0,0 +1,225 @@
+// File: cmd/loadgen/report.go
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"sort"
+	"time"
+)
+
+// latencyBuckets are the upper bounds (ms) used to group example trace IDs
+var latencyBuckets = []float64{100, 250, 500, 1000, 2500, math.Inf(1)}
+
+const examplesPerBucket = 3
+
+// Result is the outcome of a single request
+type Result struct {
+	Start   time.Time
+	Latency time.Duration
+	Status  int
+	TraceID string
+	Error   string
+}
+
+func (r Result) failed() bool {
+	return r.Error != "" || r.Status >= 400
+}
+
+// Report is the shareable summary of a load test run
+type Report struct {
+	Target      string            `json:"target"`
+	StartedAt   time.Time         `json:"started_at"`
+	DurationSec float64           `json:"duration_sec"`
+	Total       int               `json:"total"`
+	Errors      int               `json:"errors"`
+	Latency     LatencySummary    `json:"latency_ms"`
+	ErrorCounts map[string]int    `json:"error_breakdown"`
+	Throughput  []ThroughputPoint `json:"rps_over_time"`
+	Buckets     []LatencyBucket   `json:"latency_buckets"`
+}
+
+type LatencySummary struct {
+	Min float64 `json:"min"`
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P95 float64 `json:"p95"`
+	P99 float64 `json:"p99"`
+	Max float64 `json:"max"`
+}
+
+type ThroughputPoint struct {
+	Second   int `json:"second"`
+	Requests int `json:"requests"`
+	Errors   int `json:"errors"`
+}
+
+// LatencyBucket holds example trace IDs so slow requests can be opened in Jaeger
+type LatencyBucket struct {
+	Label    string   `json:"label"`
+	Count    int      `json:"count"`
+	TraceIDs []string `json:"example_trace_ids"`
+}
+
+func BuildReport(target string, start time.Time, results []Result) Report {
+	report := Report{
+		Target:      target,
+		StartedAt:   start,
+		Total:       len(results),
+		ErrorCounts: make(map[string]int),
+	}
+	if len(results) == 0 {
+		return report
+	}
+
+	latencies := make([]float64, 0, len(results))
+	buckets := make([]LatencyBucket, len(latencyBuckets))
+	for i, upper := range latencyBuckets {
+		buckets[i].Label = bucketLabel(i, upper)
+	}
+
+	var end time.Time
+	for _, r := range results {
+		ms := float64(r.Latency.Microseconds()) / 1000
+		latencies = append(latencies, ms)
+
+		if finished := r.Start.Add(r.Latency); finished.After(end) {
+			end = finished
+		}
+
+		if r.failed() {
+			report.Errors++
+			report.ErrorCounts[errorKey(r)]++
+		}
+
+		i := sort.SearchFloat64s(latencyBuckets, ms)
+		buckets[i].Count++
+		if r.TraceID != "" && len(buckets[i].TraceIDs) < examplesPerBucket {
+			buckets[i].TraceIDs = append(buckets[i].TraceIDs, r.TraceID)
+		}
+	}
+
+	sort.Float64s(latencies)
+	report.Latency = LatencySummary{
+		Min: latencies[0],
+		P50: percentile(latencies, 0.50),
+		P90: percentile(latencies, 0.90),
+		P95: percentile(latencies, 0.95),
+		P99: percentile(latencies, 0.99),
+		Max: latencies[len(latencies)-1],
+	}
+	report.DurationSec = end.Sub(start).Seconds()
+	report.Throughput = throughput(start, results)
+	report.Buckets = buckets
+	return report
+}
+
+// percentile uses nearest-rank on an already sorted slice
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	rank = max(0, min(rank, len(sorted)-1))
+	return sorted[rank]
+}
+
+func throughput(start time.Time, results []Result) []ThroughputPoint {
+	var points []ThroughputPoint
+	for _, r := range results {
+		second := int(r.Start.Sub(start).Seconds())
+		if second < 0 {
+			second = 0
+		}
+		for len(points) <= second {
+			points = append(points, ThroughputPoint{Second: len(points)})
+		}
+		points[second].Requests++
+		if r.failed() {
+			points[second].Errors++
+		}
+	}
+	return points
+}
+
+func errorKey(r Result) string {
+	if r.Error != "" {
+		return "transport_error"
+	}
+	return fmt.Sprintf("http_%d", r.Status)
+}
+
+func bucketLabel(i int, upper float64) string {
+	if math.IsInf(upper, 1) {
+		return fmt.Sprintf("> %.0fms", latencyBuckets[i-1])
+	}
+	return fmt.Sprintf("<= %.0fms", upper)
+}
+
+func (r Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+func (r Report) WriteHTML(w io.Writer) error {
+	return reportTemplate.Execute(w, r)
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"pct": func(part, total int) float64 {
+		if total == 0 {
+			return 0
+		}
+		return float64(part) / float64(total) * 100
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Load test report - {{.Target}}</title>
+<style>
+body { font-family: sans-serif; margin: 2rem; }
+table { border-collapse: collapse; margin-bottom: 2rem; }
+th, td { border: 1px solid #ccc; padding: 4px 10px; text-align: left; }
+.bar { background: #3274d9; height: 10px; }
+.err { background: #e02f44; height: 10px; }
+</style>
+</head>
+<body>
+<h1>Load test report</h1>
+<p>Target <code>{{.Target}}</code>, started {{.StartedAt.Format "2006-01-02 15:04:05"}}, ran {{printf "%.1f" .DurationSec}}s,
+{{.Total}} requests, {{.Errors}} errors ({{printf "%.1f" (pct .Errors .Total)}}%).</p>
+
+<h2>Latency (ms)</h2>
+<table>
+<tr><th>min</th><th>p50</th><th>p90</th><th>p95</th><th>p99</th><th>max</th></tr>
+<tr><td>{{printf "%.1f" .Latency.Min}}</td><td>{{printf "%.1f" .Latency.P50}}</td><td>{{printf "%.1f" .Latency.P90}}</td>
+<td>{{printf "%.1f" .Latency.P95}}</td><td>{{printf "%.1f" .Latency.P99}}</td><td>{{printf "%.1f" .Latency.Max}}</td></tr>
+</table>
+
+<h2>Latency buckets and example traces</h2>
+<table>
+<tr><th>bucket</th><th>requests</th><th>example trace IDs</th></tr>
+{{range .Buckets}}<tr><td>{{.Label}}</td><td>{{.Count}}</td><td>{{range .TraceIDs}}<code>{{.}}</code> {{end}}</td></tr>
+{{end}}</table>
+
+<h2>Error breakdown</h2>
+<table>
+<tr><th>type</th><th>count</th></tr>
+{{range $k, $v := .ErrorCounts}}<tr><td>{{$k}}</td><td>{{$v}}</td></tr>
+{{else}}<tr><td colspan="2">no errors</td></tr>
+{{end}}</table>
+
+<h2>Requests per second</h2>
+<table>
+<tr><th>second</th><th>requests</th><th>errors</th><th></th></tr>
+{{range .Throughput}}<tr><td>{{.Second}}</td><td>{{.Requests}}</td><td>{{.Errors}}</td>
+<td><div class="bar" style="width: {{.Requests}}0px"></div><div class="err" style="width: {{.Errors}}0px"></div></td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildReport(t *testing.T) {
+	start := time.Now()
+	results := []Result{
+		{Start: start, Latency: 50 * time.Millisecond, Status: 201, TraceID: "fast"},
+		{Start: start.Add(200 * time.Millisecond), Latency: 300 * time.Millisecond, Status: 201, TraceID: "medium"},
+		{Start: start.Add(1100 * time.Millisecond), Latency: 3 * time.Second, Status: 500, TraceID: "slow"},
+		{Start: start.Add(1200 * time.Millisecond), Latency: 10 * time.Millisecond, Status: 400},
+		{Start: start.Add(1300 * time.Millisecond), Latency: 5 * time.Second, Error: "timeout"},
+	}
+
+	report := BuildReport("http://test/orders", start, results)
+
+	if report.Total != 5 {
+		t.Errorf("Expected 5 requests, got %d", report.Total)
+	}
+	if report.Errors != 3 {
+		t.Errorf("Expected 3 errors, got %d", report.Errors)
+	}
+	for _, key := range []string{"http_500", "http_400", "transport_error"} {
+		if report.ErrorCounts[key] != 1 {
+			t.Errorf("Expected 1 %s, got %d", key, report.ErrorCounts[key])
+		}
+	}
+
+	if report.Latency.Min != 10 || report.Latency.Max != 5000 {
+		t.Errorf("Unexpected min/max: %+v", report.Latency)
+	}
+	if report.Latency.P50 != 300 {
+		t.Errorf("Expected p50 of 300ms, got %v", report.Latency.P50)
+	}
+
+	if len(report.Throughput) != 2 || report.Throughput[0].Requests != 2 || report.Throughput[1].Errors != 3 {
+		t.Errorf("Unexpected throughput: %+v", report.Throughput)
+	}
+
+	last := report.Buckets[len(report.Buckets)-1]
+	if last.Count != 2 || len(last.TraceIDs) != 1 || last.TraceIDs[0] != "slow" {
+		t.Errorf("Unexpected slowest bucket: %+v", last)
+	}
+}
+
+func TestReportRendering(t *testing.T) {
+	start := time.Now()
+	report := BuildReport("http://test/orders", start, []Result{
+		{Start: start, Latency: 120 * time.Millisecond, Status: 201, TraceID: "abc123"},
+	})
+
+	var html bytes.Buffer
+	if err := report.WriteHTML(&html); err != nil {
+		t.Fatalf("Failed to render HTML: %v", err)
+	}
+	if !strings.Contains(html.String(), "abc123") {
+		t.Error("HTML report is missing the example trace ID")
+	}
+
+	var js bytes.Buffer
+	if err := report.WriteJSON(&js); err != nil {
+		t.Fatalf("Failed to render JSON: %v", err)
+	}
+	if !strings.Contains(js.String(), `"p99": 120`) {
+		t.Errorf("JSON report is missing latency percentiles: %s", js.String())
+	}
+}
@@ -3,16 +3,44 @@ package main
 
 import (
 	"context"
+	"go-observability-demo/internal/alerting"
+	"go-observability-demo/internal/archive"
+	"go-observability-demo/internal/config"
+	"go-observability-demo/internal/errorreport"
+	"go-observability-demo/internal/featureflags"
+	"go-observability-demo/internal/fx"
+	"go-observability-demo/internal/gctuning"
+	"go-observability-demo/internal/httpclient"
+	"go-observability-demo/internal/inventory"
+	"go-observability-demo/internal/leader"
+	"go-observability-demo/internal/lifecycle"
+	"go-observability-demo/internal/locks"
+	"go-observability-demo/internal/middleware"
+	"go-observability-demo/internal/notifications"
 	"go-observability-demo/internal/observability"
+	"go-observability-demo/internal/orders"
+	"go-observability-demo/internal/pricing"
+	"go-observability-demo/internal/quota"
+	"go-observability-demo/internal/reconcile"
+	"go-observability-demo/internal/redisstore"
+	"go-observability-demo/internal/retention"
 	"go-observability-demo/internal/service"
+	"go-observability-demo/internal/shipping"
+	"go-observability-demo/internal/slo"
 	"log"
+	"net"
 	"net/http"
 	"os"
-	"os/signal"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
 )
 
 func main() {
@@ -20,16 +48,83 @@ func main() {
 
 	// Initialize observability
 	serviceName := getEnv("SERVICE_NAME", "order-service")
-	otelEndpoint := getEnv("OTEL_ENDPOINT", "localhost:4318")
+	otelEndpoint := getEnv("OTEL_ENDPOINT", observability.ExportConfigFromEnv().DefaultEndpoint())
 
-	shutdown, err := observability.InitObservability(ctx, serviceName, otelEndpoint)
+	// Initialize logger
+	logger := observability.NewLogger()
+	lc := lifecycle.New(logger)
+
+	// Optional in-process alerting to a webhook or Slack (ALERT_WEBHOOK_URL),
+	// and anomaly detection on latency and error rate (ALERT_ANOMALY_ENABLED)
+	obsOpts := []observability.Option{observability.WithLogger(logger)}
+	// The trace ratio can be changed at runtime through /admin/sampling
+	sampling, err := observability.NewDynamicSampler(observability.SamplerConfigFromEnv(), logger)
+	if err != nil {
+		log.Fatalf("Invalid trace sampler: %v", err)
+	}
+	obsOpts = append(obsOpts, observability.WithSampler(sampling))
+	if getEnv("RUNTIME_METRICS_ENABLED", "true") == "true" {
+		obsOpts = append(obsOpts, observability.WithRuntimeMetrics())
+	}
+	var alerts *alerting.Watcher
+	if alertCfg := alerting.ConfigFromEnv(); alertCfg.Enabled() {
+		rules := alertCfg.DefaultRules()
+		if anomalies := alertCfg.AnomalyRules(logger); len(anomalies) > 0 {
+			if err := alerting.RegisterAnomalyGauge(otel.Meter("order-service"), anomalies...); err != nil {
+				log.Fatalf("Failed to register anomaly gauge: %v", err)
+			}
+			for _, r := range anomalies {
+				rules = append(rules, r)
+			}
+		}
+		alerts = alerting.NewWatcher(alertCfg, alertCfg.Notifier(logger), logger, rules...)
+		obsOpts = append(obsOpts, observability.WithMetricReader(alerts.Reader()))
+	}
+
+	// Error budgets for POST /orders (SLO_*), computed from the RED metrics
+	var sloTracker *slo.Tracker
+	if sloCfg := slo.ConfigFromEnv(); sloCfg.Enabled() {
+		sloTracker, err = slo.New(sloCfg, otel.Meter("order-service"), logger)
+		if err != nil {
+			log.Fatalf("Failed to initialize SLO tracking: %v", err)
+		}
+		obsOpts = append(obsOpts, observability.WithMetricReader(sloTracker.Reader()))
+	}
+
+	// Optional Prometheus scrape endpoint (OTEL_METRICS_EXPORTER=prometheus),
+	// served on PROMETHEUS_ADDR or at /metrics on the main server
+	metricsExport, err := observability.MetricsExportFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid metrics exporter: %v", err)
+	}
+	var promHandler http.Handler
+	if metricsExport.Prometheus {
+		reader, handler, err := observability.NewPrometheus()
+		if err != nil {
+			log.Fatalf("Failed to initialize Prometheus exporter: %v", err)
+		}
+		obsOpts = append(obsOpts, observability.WithMetricReader(reader))
+		promHandler = handler
+	}
+
+	shutdown, err := observability.InitObservability(ctx, serviceName, otelEndpoint, obsOpts...)
 	if err != nil {
 		log.Fatalf("Failed to initialize observability: %v", err)
 	}
-	defer shutdown(ctx)
+	if alerts != nil {
+		alerts.Start()
+		lc.Register(lifecycle.PhaseDrain, "alert-watcher", 5*time.Second, alerts.Stop)
+	}
+	if sloTracker != nil {
+		sloTracker.Start()
+		lc.Register(lifecycle.PhaseDrain, "slo-tracker", 5*time.Second, sloTracker.Stop)
+	}
 
-	// Initialize logger
-	logger := observability.NewLogger()
+	// Optional GC tuning (GC_PERCENT, GC_MEMORY_LIMIT_RATIO) and GC pause metrics
+	gctuning.Configure(logger)
+	if err := gctuning.RegisterMetrics(otel.Meter("order-service")); err != nil {
+		log.Fatalf("Failed to register GC metrics: %v", err)
+	}
 
 	// Initialize metrics
 	metrics, err := observability.NewMetrics()
@@ -37,50 +132,471 @@ func main() {
 		log.Fatalf("Failed to initialize metrics: %v", err)
 	}
 
+	// Optional Sentry error reporting (enabled by SENTRY_DSN)
+	errorReporter, err := errorreport.New(errorreport.ConfigFromEnv())
+	if err != nil {
+		log.Fatalf("Failed to initialize error reporting: %v", err)
+	}
+
+	// Feature flags from FEATURE_FLAGS, with evaluations traced and counted
+	flags, err := featureflags.New(otel.Meter("order-service"), featureflags.ProviderFromEnv())
+	if err != nil {
+		log.Fatalf("Failed to initialize feature flags: %v", err)
+	}
+
+	// Optional archival of completed orders (ARCHIVE_DIR)
+	var archiver *archive.Archiver
+	if dir := config.String("ARCHIVE_DIR", ""); dir != "" {
+		archiver, err = archive.New(archive.ConfigFromEnv(), archive.FileStore{Dir: dir}, otel.Meter("order-service"), logger)
+		if err != nil {
+			log.Fatalf("Failed to initialize order archive: %v", err)
+		}
+		archiver.Start()
+	}
+
+	// Quota counters and idempotency locks live in process unless Redis is
+	// configured (REDIS_ADDR), which makes them hold across replicas
+	var quotaStore quota.Store = quota.NewMemoryStore()
+	var serviceOpts []service.Option
+	var redisClient *redisstore.Client
+	if redisCfg := redisstore.ConfigFromEnv(); redisCfg.Enabled() {
+		redisClient = redisstore.New(redisCfg)
+		if err := redisClient.Ping(ctx); err != nil {
+			log.Fatalf("Failed to connect to Redis at %s: %v", redisCfg.Addr, err)
+		}
+		quotaStore = redisClient
+		serviceOpts = append(serviceOpts, service.WithLocker(redisClient))
+	}
+
+	// Background jobs that must run on exactly one replica register with
+	// elector.Go; the lease is shared through Redis when it is configured
+	var lease leader.Lease = locks.NewMemoryLocker()
+	if redisClient != nil {
+		lease = redisClient
+	}
+	elector, err := leader.New(leader.ConfigFromEnv(), lease, otel.Meter("order-service"), logger)
+	if err != nil {
+		log.Fatalf("Failed to initialize leader election: %v", err)
+	}
+
+	// Optional per-user/tenant order quotas (QUOTA_ORDERS_PER_WINDOW)
+	var orderQuota *quota.Quota
+	if quotaCfg := quota.ConfigFromEnv(); quotaCfg.Enabled() {
+		orderQuota, err = quota.New(quotaCfg, quotaStore, otel.Meter("order-service"), logger)
+		if err != nil {
+			log.Fatalf("Failed to initialize quotas: %v", err)
+		}
+	}
+
+	// Server-side pricing from the catalog, promo codes, and tax (PRICING_*)
+	pricer, err := pricing.New(pricing.ConfigFromEnv(), otel.Meter("order-service"))
+	if err != nil {
+		log.Fatalf("Failed to initialize pricing: %v", err)
+	}
+
+	// Downstream clients, tuned by <PREFIX>_* and presenting the mTLS
+	// certificate when one is configured
+	newClient := func(name, prefix string) *http.Client {
+		cfg, err := httpclient.ConfigFromEnv(prefix)
+		if err != nil {
+			log.Fatalf("Failed to configure the %s client: %v", name, err)
+		}
+		return httpclient.New(name, cfg, metrics)
+	}
+
+	// Exchange rates for non-USD orders, from FX_RATES_URL or static FX_RATES
+	fxCfg := fx.ConfigFromEnv()
+	fxClient := newClient("fx", "FX_CLIENT")
+	converter, err := fx.New(fxCfg, fxCfg.Source(fxClient), otel.Meter("order-service"), logger)
+	if err != nil {
+		log.Fatalf("Failed to initialize currency conversion: %v", err)
+	}
+	converter.Start()
+
+	// Shipping estimates from SHIPPING_URL, or a simulated service, behind
+	// their own retry policy and circuit breaker
+	shippingCfg := shipping.ConfigFromEnv()
+	var estimator shipping.Estimator = shipping.HTTPEstimator{
+		URL:    shippingCfg.URL,
+		Client: newClient("shipping", "SHIPPING_CLIENT"),
+	}
+	if shippingCfg.URL == "" {
+		if estimator, err = shipping.NewSimulated(); err != nil {
+			log.Fatalf("Invalid shipping fault config: %v", err)
+		}
+	}
+	shippingClient, err := shipping.New(shippingCfg, estimator, otel.Meter("order-service"), logger)
+	if err != nil {
+		log.Fatalf("Failed to initialize shipping client: %v", err)
+	}
+
+	// Stock levels, seeded from INVENTORY_STOCK and managed through the
+	// admin API; products without a level are never out of stock
+	stock, err := inventory.New(inventory.ConfigFromEnv(), otel.Meter("order-service"), logger)
+	if err != nil {
+		log.Fatalf("Failed to initialize inventory: %v", err)
+	}
+
+	// Customer notifications on the channels each user opted into: webhooks
+	// always, email when SMTP_HOST is set, SMS when SMS_GATEWAY_URL is set
+	notifyClient := newClient("notifications", "NOTIFY_CLIENT")
+	channels := []notifications.Channel{notifications.WebhookChannel{Client: notifyClient}}
+	if emailCfg := notifications.EmailConfigFromEnv(); emailCfg.Enabled() {
+		sender, err := notifications.NewEmailSender(emailCfg, otel.Meter("order-service"))
+		if err != nil {
+			log.Fatalf("Failed to initialize email sender: %v", err)
+		}
+		channels = append(channels, notifications.EmailChannel{Sender: sender})
+	}
+	if url := config.String("SMS_GATEWAY_URL", ""); url != "" {
+		channels = append(channels, notifications.SMSChannel{URL: url, Client: notifyClient})
+	}
+	// Bearer token for /admin/* and for reading other users' orders and
+	// notification settings
+	adminToken := config.String("ADMIN_TOKEN", "")
+
+	notifyCfg := notifications.ConfigFromEnv()
+	notifyCfg.AdminToken = adminToken
+	notifier, err := notifications.New(notifyCfg, notifications.NewMemoryPreferences(),
+		otel.Meter("order-service"), logger, channels...)
+	if err != nil {
+		log.Fatalf("Failed to initialize notifications: %v", err)
+	}
+	notifier.Start()
+
+	// Placed orders, shared by the order history and the background jobs
+	orderStore := orders.NewCachedStore(orders.NewMemoryStore(), orders.CacheConfigFromEnv())
+
+	// The store is in memory, so each replica only holds its own orders and
+	// jobs over it run on every replica. Only a shared store would let the
+	// leader run them alone through elector.Go.
+	storeJobsCtx, stopStoreJobs := context.WithCancel(ctx)
+	var storeJobs sync.WaitGroup
+
+	// Payment reconciliation against RECONCILE_GATEWAY_URL, or a simulated
+	// gateway, run over each replica's orders every RECONCILE_INTERVAL
+	reconcileCfg := reconcile.ConfigFromEnv()
+	var gateway reconcile.Gateway = reconcile.HTTPGateway{
+		URL:    reconcileCfg.GatewayURL,
+		Client: newClient("payment-gateway", "RECONCILE_CLIENT"),
+	}
+	if reconcileCfg.GatewayURL == "" {
+		gateway = reconcile.Simulated{Store: orderStore, MismatchRate: config.Float("RECONCILE_SIMULATED_MISMATCH_RATE", 0.01)}
+	}
+	reconciler, err := reconcile.New(reconcileCfg, orderStore, gateway, otel.Meter("order-service"), logger)
+	if err != nil {
+		log.Fatalf("Failed to initialize reconciliation: %v", err)
+	}
+	storeJobs.Go(func() { reconciler.Loop(storeJobsCtx) })
+
+	// Optional anonymization or deletion of old orders (RETENTION_ACTION)
+	if retentionCfg := retention.ConfigFromEnv(); retentionCfg.Enabled() {
+		retentionJob, err := retention.New(retentionCfg, orderStore, otel.Meter("order-service"), logger)
+		if err != nil {
+			log.Fatalf("Failed to initialize retention: %v", err)
+		}
+		storeJobs.Go(func() { retentionJob.Loop(storeJobsCtx) })
+	}
+	elector.Start()
+
+	// The mirror and its canary share MIRROR_SECRET; only requests carrying
+	// it are treated as shadows
+	mirrorCfg := middleware.MirrorConfigFromEnv()
+
 	// Create order service
-	orderService := service.NewOrderService(logger, metrics)
+	orderService, err := service.NewOrderService(logger, metrics, append(serviceOpts,
+		service.WithErrorReporter(errorReporter),
+		service.WithFeatureFlags(flags),
+		service.WithArchiver(archiver),
+		service.WithQuota(orderQuota),
+		service.WithPricing(pricer),
+		service.WithFX(converter),
+		service.WithShipping(shippingClient),
+		service.WithNotifier(notifier),
+		service.WithInventory(stock),
+		service.WithAdminToken(adminToken),
+		service.WithOrderStore(orderStore),
+		service.WithDownstreamClients(newClient("payment", "PAYMENT_CLIENT"), newClient("inventory", "INVENTORY_CLIENT")),
+		service.WithShadowSecret(mirrorCfg.Secret),
+	)...)
+	if err != nil {
+		log.Fatalf("Failed to initialize order service: %v", err)
+	}
 
-	// Setup HTTP routes with otelhttp middleware
+	// Setup HTTP routes with otelhttp middleware. Its HTTP metrics are
+	// turned off: REDMetrics records them for every route, traced or not.
+	// Panics are recorded on the route's span and reported to Sentry.
 	mux := http.NewServeMux()
+	traced := func(h http.Handler, operation string) http.Handler {
+		return otelhttp.NewHandler(errorReporter.Recover(h), operation, otelhttp.WithMeterProvider(metricnoop.NewMeterProvider()))
+	}
+
+	var ordersHandler http.Handler = http.HandlerFunc(orderService.CreateOrderHandler)
 
-	mux.Handle("/orders", otelhttp.NewHandler(
-		http.HandlerFunc(orderService.CreateOrderHandler),
-		"POST /orders",
-	))
+	// Optional mirroring of a share of orders to a canary (MIRROR_URL)
+	var mirror *middleware.Mirror
+	if mirrorCfg.Enabled() {
+		mirror, err = middleware.NewMirror(mirrorCfg, otel.Meter("order-service"))
+		if err != nil {
+			log.Fatalf("Failed to initialize traffic mirroring: %v", err)
+		}
+		ordersHandler = mirror.Wrap(ordersHandler)
+	}
+
+	// Optional adaptive concurrency limiting (sheds with 503 + Retry-After)
+	var limiter *middleware.ConcurrencyLimiter
+	if config.Bool("CONCURRENCY_LIMIT_ENABLED", false) {
+		limiter, err = middleware.NewConcurrencyLimiter(
+			middleware.NewGradientLimiter(middleware.LimiterConfigFromEnv()),
+			otel.Meter("order-service"),
+		)
+		if err != nil {
+			log.Fatalf("Failed to initialize concurrency limiter: %v", err)
+		}
+		// Order creation is interactive; it keeps the capacity that bulk
+		// traffic (X-Request-Priority: low) gives up first
+		ordersHandler = limiter.WrapPriority("/orders", middleware.PriorityHigh, ordersHandler)
+	}
+	// Listings and exports are bulk reads, the first to be shed
+	bulk := func(route string, h http.Handler) http.Handler {
+		if limiter == nil {
+			return h
+		}
+		return limiter.WrapPriority(route, middleware.PriorityLow, h)
+	}
+
+	mux.Handle("/orders", traced(observability.RecordPeerIdentity(ordersHandler), "POST /orders"))
+
+	mux.Handle("/users/{id}/notification-preferences", traced(
+		http.HandlerFunc(notifier.PreferencesHandler), "/users/{id}/notification-preferences"))
+	mux.Handle("GET /orders/{id}/notifications", traced(
+		http.HandlerFunc(notifier.DeliveriesHandler), "GET /orders/{id}/notifications"))
+
+	// Admin API, behind ADMIN_TOKEN when it is set
+	if adminToken == "" {
+		logger.Warn("ADMIN_TOKEN is not set, admin endpoints are unauthenticated")
+	}
+	admin := func(pattern string, h http.HandlerFunc) {
+		mux.Handle(pattern, traced(middleware.RequireAdminToken(adminToken, h), pattern))
+	}
+	// Changes that could make an incident worse are frozen while SLO_FREEZE
+	// is set and an error budget is spent; sampling and log levels stay
+	// adjustable for debugging
+	risky := func(pattern string, h http.HandlerFunc) {
+		admin(pattern, sloTracker.FreezeGate(h).ServeHTTP)
+	}
+	mux.Handle("GET /orders", traced(
+		bulk("/orders", http.HandlerFunc(orderService.ListOrdersHandler)), "GET /orders"))
+	mux.Handle("POST /orders/{id}/cancel", traced(
+		http.HandlerFunc(orderService.CancelOrderHandler), "POST /orders/{id}/cancel"))
+	mux.Handle("GET /orders/{id}", traced(
+		http.HandlerFunc(orderService.GetOrderHandler), "GET /orders/{id}"))
+	mux.Handle("GET /users/{id}/orders", traced(
+		http.HandlerFunc(orderService.ListUserOrdersHandler), "GET /users/{id}/orders"))
+
+	admin("GET /admin/inventory", stock.LevelsHandler)
+	risky("PUT /admin/inventory/{product}", stock.SetHandler)
+	risky("POST /admin/inventory/{product}/adjust", stock.AdjustHandler)
+	risky("/admin/reconciliation", reconciler.ReportsHandler)
+	mux.Handle("GET /admin/orders/export", traced(
+		middleware.RequireAdminToken(adminToken, bulk("/admin/orders/export", http.HandlerFunc(orderService.ExportOrdersHandler))), "GET /admin/orders/export"))
+	admin("/admin/sampling", sampling.Handler)
+	admin("/admin/loglevel", observability.LogLevelHandler(logger))
+
+	// Scrapes are not traced; they would outnumber real requests. Like the
+	// main port, the scrape port is taken over on a SIGHUP restart.
+	listeners := map[string]net.Listener{}
+	var promServer *http.Server
+	if promAddr := config.String("PROMETHEUS_ADDR", ""); promHandler != nil && promAddr != "" {
+		promMux := http.NewServeMux()
+		promMux.Handle("GET /metrics", promHandler)
+		promServer = &http.Server{Addr: promAddr, Handler: promMux, ReadTimeout: 10 * time.Second, WriteTimeout: 10 * time.Second}
+		promLn, _, err := lifecycle.Listen(ctx, "metrics", promAddr)
+		if err != nil {
+			log.Fatalf("Failed to listen on %s: %v", promAddr, err)
+		}
+		listeners["metrics"] = promLn
+		go func() {
+			// Losing scrapes is no reason to stop taking orders
+			if err := promServer.Serve(promLn); err != nil && err != http.ErrServerClosed {
+				logger.Error("Prometheus server failed", "error", err.Error())
+			}
+		}()
+	} else if promHandler != nil {
+		mux.Handle("GET /metrics", promHandler)
+	}
+
+	if sloTracker != nil {
+		mux.Handle("GET /slo/status", traced(http.HandlerFunc(sloTracker.StatusHandler), "GET /slo/status"))
+	}
 
 	mux.Handle("/health", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
 	}))
 
+	// Reject new requests with Retry-After while draining, and count
+	// clients that retry sooner than any Retry-After they were given
+	drainGrace := config.Duration("SHUTDOWN_DRAIN_GRACE", 0)
+	drainGate := middleware.NewDrainGate(drainGrace)
+	retryAfter, err := middleware.NewRetryAfterTracker(otel.Meter("order-service"))
+	if err != nil {
+		log.Fatalf("Failed to initialize Retry-After tracking: %v", err)
+	}
+
+	red, err := middleware.NewREDMetrics(otel.Meter("order-service"))
+	if err != nil {
+		log.Fatalf("Failed to initialize HTTP metrics: %v", err)
+	}
+	conns, err := middleware.NewConnTracker(otel.Meter("order-service"))
+	if err != nil {
+		log.Fatalf("Failed to initialize connection tracking: %v", err)
+	}
+
 	// Create server
 	port := getEnv("PORT", "8080")
 	server := &http.Server{
 		Addr:         ":" + port,
-		Handler:      mux,
+		Handler:      middleware.StripShadowHeader(mirrorCfg.Secret, retryAfter.Wrap(drainGate.Wrap(red.Wrap(mux)))),
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 10 * time.Second,
+		ConnState:    conns.ConnState,
+	}
+
+	// Optional mTLS between the demo services (MTLS_CERT_FILE, MTLS_KEY_FILE, MTLS_CA_FILE)
+	mtlsCfg, mtlsEnabled := observability.MTLSConfigFromEnv()
+	if mtlsEnabled {
+		server.TLSConfig, err = mtlsCfg.ServerTLS()
+		if err != nil {
+			log.Fatalf("Failed to configure mTLS: %v", err)
+		}
+	}
+
+	// Bind the port, or take over the socket of the process that started us
+	// (SIGHUP restart), so a deploy never refuses connections
+	ln, inherited, err := lifecycle.Listen(ctx, lifecycle.MainListener, server.Addr)
+	if err != nil {
+		log.Fatalf("Failed to listen on %s: %v", server.Addr, err)
+	}
+	listeners[lifecycle.MainListener] = ln
+	restarts, err := otel.Meter("order-service").Int64Counter(
+		"server.restarts",
+		metric.WithDescription("Zero-downtime restarts, by side (handoff in the old process, inherited in the new one)"),
+		metric.WithUnit("{restart}"),
+	)
+	if err != nil {
+		log.Fatalf("Failed to create restart counter: %v", err)
+	}
+	if inherited {
+		logger.Info("listener inherited from previous process", "event.name", "server.restart")
+		restarts.Add(ctx, 1, metric.WithAttributes(attribute.String("side", "inherited")))
 	}
 
 	// Start server in goroutine
 	go func() {
-		logger.Info("Server starting", "port", port)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		build := observability.ServiceBuildInfo()
+		logger.Info("Server starting", "port", port, "mtls", mtlsEnabled, "version", build.Version, "revision", build.Revision)
+		serve := func() error { return server.Serve(ln) }
+		if mtlsEnabled {
+			serve = func() error { return server.ServeTLS(ln, "", "") }
+		}
+		if err := serve(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Server failed: %v", err)
 		}
 	}()
+	if inherited {
+		// The previous process drains once we report in
+		if err := lifecycle.Ready(); err != nil {
+			logger.Warn("failed to report readiness to previous process", "error", err.Error())
+		}
+	}
 
-	// Graceful shutdown
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	// Graceful shutdown: drain requests, then flush telemetry. After a
+	// handoff the new process takes the traffic, so there is nothing to
+	// turn away with 503s.
+	var handedOff atomic.Bool
+	lc.Register(lifecycle.PhaseDrain, "drain-gate", drainGrace+time.Second, func(ctx context.Context) error {
+		if handedOff.Load() {
+			return nil
+		}
+		return drainGate.Drain(ctx)
+	})
+	lc.Register(lifecycle.PhaseDrain, "http-server", 20*time.Second, server.Shutdown)
+	lc.Register(lifecycle.PhaseDrain, "notifications", 10*time.Second, notifier.Stop)
+	lc.Register(lifecycle.PhaseDrain, "leader-election", 5*time.Second, elector.Stop)
+	lc.Register(lifecycle.PhaseDrain, "store-jobs", 5*time.Second, func(ctx context.Context) error {
+		stopStoreJobs()
+		done := make(chan struct{})
+		go func() {
+			storeJobs.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	lc.Register(lifecycle.PhaseDrain, "fx-refresh", 5*time.Second, converter.Stop)
+	if mirror != nil {
+		lc.Register(lifecycle.PhaseDrain, "traffic-mirror", 5*time.Second, mirror.Close)
+	}
+	// The archive drains before telemetry flushes, so its last writes are
+	// still traced and counted
+	if archiver != nil {
+		lc.Register(lifecycle.PhaseDrain, "order-archive", 10*time.Second, archiver.Stop)
+	}
+	lc.Register(lifecycle.PhaseFlush, "telemetry", 10*time.Second, shutdown)
+	lc.Register(lifecycle.PhaseFlush, "error-reports", 5*time.Second, errorReporter.Flush)
+	if redisClient != nil {
+		lc.Register(lifecycle.PhaseClose, "redis", 5*time.Second, redisClient.Close)
+	}
+	if promServer != nil {
+		// Kept up through the drain so the last scrapes see it
+		lc.Register(lifecycle.PhaseClose, "prometheus", 5*time.Second, promServer.Shutdown)
+	}
+	lc.Register(lifecycle.PhaseClose, "log-sinks", 5*time.Second, observability.CloseLogSinks)
 
-	logger.Info("Server shutting down")
+	// SIGHUP hands the listeners to a fresh copy of the binary and drains
+	// this one once the copy reports ready; if either fails we keep
+	// serving. SIGUSR1 toggles debug logging.
+	readyTimeout := config.Duration("RESTART_READY_TIMEOUT", 30*time.Second)
+	var sig os.Signal
+	for {
+		sig = lc.Wait(ctx, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGUSR1)
+		if sig == syscall.SIGUSR1 {
+			logger.Warn("log level changed", "signal", sig.String(), "to", observability.ToggleDebugLogging().String())
+			continue
+		}
+		if sig != syscall.SIGHUP {
+			break
+		}
+		child, err := lifecycle.Handoff(listeners)
+		if err != nil {
+			logger.Error("graceful restart failed, continuing to serve", "error", err.Error())
+			continue
+		}
+		readyCtx, cancelReady := context.WithTimeout(ctx, readyTimeout)
+		err = child.WaitReady(readyCtx)
+		cancelReady()
+		if err != nil {
+			logger.Error("new process did not become ready, continuing to serve", "pid", child.Process.Pid, "error", err.Error())
+			child.Process.Kill()
+			child.Process.Wait()
+			continue
+		}
+		handedOff.Store(true)
+		logger.Info("listener handed off to new process", "event.name", "server.restart", "pid", child.Process.Pid)
+		restarts.Add(ctx, 1, metric.WithAttributes(attribute.String("side", "handoff")))
+		break
+	}
+	logger.Info("Server shutting down", "signal", sig.String())
 
 	shutdownCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	if err := server.Shutdown(shutdownCtx); err != nil {
+	if err := lc.Shutdown(shutdownCtx); err != nil {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
 
@@ -0,0 +1,245 @@
+package alerting
+
+import (
+	"context"
+	"fmt"
+	"go-observability-demo/internal/config"
+	"go-observability-demo/internal/observability"
+	"log/slog"
+	"sync"
+	"time"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// Config controls the in-process alert watcher
+type Config struct {
+	WebhookURL string
+	// Format is "slack" for Slack incoming webhooks or "json" for a plain payload
+	Format   string
+	Interval time.Duration
+
+	ErrorRate    float64
+	LatencyP99Ms float64
+	// MaxExamples caps the trace links attached to an alert
+	MaxExamples int
+	// Anomaly adds EWMA/z-score detectors on order latency and error rate
+	Anomaly  bool
+	AnomalyZ float64
+}
+
+func ConfigFromEnv() Config {
+	return Config{
+		WebhookURL:   config.String("ALERT_WEBHOOK_URL", ""),
+		Format:       config.String("ALERT_WEBHOOK_FORMAT", "slack"),
+		Interval:     config.Duration("ALERT_INTERVAL", 30*time.Second),
+		ErrorRate:    config.Float("ALERT_ERROR_RATE", 0.2),
+		LatencyP99Ms: config.Float("ALERT_LATENCY_P99_MS", 1000),
+		MaxExamples:  config.Int("ALERT_MAX_EXAMPLES", 3),
+		Anomaly:      config.Bool("ALERT_ANOMALY_ENABLED", false),
+		AnomalyZ:     config.Float("ALERT_ANOMALY_Z", DefaultAnomalyConfig().Z),
+	}
+}
+
+// Enabled is true with a webhook, or with anomaly detection alone, in which
+// case alerts only go to the log. With OBSERVABILITY_DISABLED there are no
+// metrics to watch, so it is always false.
+func (c Config) Enabled() bool {
+	return (c.WebhookURL != "" || c.Anomaly) && !observability.Disabled()
+}
+
+// Notifier returns the webhook, or a LogNotifier when none is configured
+func (c Config) Notifier(logger *slog.Logger) Notifier {
+	if c.WebhookURL == "" {
+		return LogNotifier{Logger: logger}
+	}
+	return NewWebhook(c.WebhookURL, c.Format)
+}
+
+// DefaultRules watches the order service's error rate and latency, fires
+// while a circuit breaker is open or half-open, and when orders fail to be
+// archived
+func (c Config) DefaultRules() []Rule {
+	return []Rule{
+		&ErrorRateRule{Errors: "errors.total", Successes: "orders.created", Threshold: c.ErrorRate, MinEvents: 10},
+		&LatencyRule{Metric: "orders.duration", Quantile: 0.99, Threshold: c.LatencyP99Ms},
+		&GaugeRule{Metric: "breaker.state", Threshold: 1},
+		&CounterRule{Metric: "archive.failures", Threshold: 1},
+	}
+}
+
+// AnomalyRules detects unusual order latency and error rate, or returns
+// nil when anomaly detection is off
+func (c Config) AnomalyRules(logger *slog.Logger) []*AnomalyRule {
+	if !c.Anomaly {
+		return nil
+	}
+	cfg := DefaultAnomalyConfig()
+	cfg.Z = c.AnomalyZ
+	return []*AnomalyRule{
+		NewAnomalyRule("orders.duration_anomaly", MeanLatency("orders.duration"), cfg, logger),
+		NewAnomalyRule("error_rate_anomaly", ErrorRatio("errors.total", "orders.created"), cfg, logger),
+	}
+}
+
+// Alert is a state change of a rule
+type Alert struct {
+	Rule      string    `json:"rule"`
+	State     string    `json:"state"`
+	Value     float64   `json:"value"`
+	Threshold float64   `json:"threshold"`
+	Time      time.Time `json:"time"`
+	Traces    []string  `json:"traces,omitempty"`
+}
+
+// Notifier delivers alerts
+type Notifier interface {
+	Notify(ctx context.Context, alert Alert) error
+}
+
+// LogNotifier writes alerts to the log, for environments without a webhook
+type LogNotifier struct {
+	Logger *slog.Logger
+}
+
+func (n LogNotifier) Notify(ctx context.Context, alert Alert) error {
+	n.Logger.WarnContext(ctx, alert.summary(),
+		slog.String("event.name", "alerting.alert"),
+		slog.String("rule", alert.Rule),
+		slog.String("state", alert.State),
+		slog.Any("traces", alert.Traces),
+	)
+	return nil
+}
+
+// Watcher evaluates rules against the service's own metrics on an
+// interval and notifies when a rule starts or stops firing. It reads
+// metrics through its own reader, so it needs no collector or Prometheus.
+type Watcher struct {
+	cfg      Config
+	reader   *sdkmetric.ManualReader
+	rules    []Rule
+	notifier Notifier
+	logger   *slog.Logger
+
+	firing map[string]bool
+	stop   chan struct{}
+	done   chan struct{}
+	once   sync.Once
+}
+
+func NewWatcher(cfg Config, notifier Notifier, logger *slog.Logger, rules ...Rule) *Watcher {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 30 * time.Second
+	}
+	return &Watcher{
+		cfg:      cfg,
+		reader:   sdkmetric.NewManualReader(),
+		rules:    rules,
+		notifier: notifier,
+		logger:   logger,
+		firing:   make(map[string]bool),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Reader must be registered with the meter provider, see
+// observability.WithMetricReader
+func (w *Watcher) Reader() sdkmetric.Reader {
+	return w.reader
+}
+
+func (w *Watcher) Start() {
+	go w.run()
+}
+
+// Stop ends the watch loop
+func (w *Watcher) Stop(ctx context.Context) error {
+	w.once.Do(func() { close(w.stop) })
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *Watcher) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			w.Check(context.Background())
+		}
+	}
+}
+
+// Check collects metrics once and evaluates every rule
+func (w *Watcher) Check(ctx context.Context) {
+	var rm metricdata.ResourceMetrics
+	if err := w.reader.Collect(ctx, &rm); err != nil {
+		w.logger.Warn("alert watcher failed to collect metrics", slog.String("error", err.Error()))
+		return
+	}
+
+	for _, rule := range w.rules {
+		res := rule.Evaluate(&rm)
+		name := rule.Name()
+		if res.Firing == w.firing[name] {
+			continue
+		}
+		w.firing[name] = res.Firing
+
+		alert := Alert{
+			Rule:      name,
+			State:     "resolved",
+			Value:     res.Value,
+			Threshold: res.Threshold,
+			Time:      time.Now(),
+		}
+		if res.Firing {
+			alert.State = "firing"
+			alert.Traces = w.traceLinks(res.TraceIDs)
+		}
+
+		if err := w.notifier.Notify(ctx, alert); err != nil {
+			w.logger.Warn("failed to send alert",
+				slog.String("rule", name),
+				slog.String("error", err.Error()),
+			)
+			continue
+		}
+		w.logger.Info("alert sent", slog.String("rule", name), slog.String("state", alert.State))
+	}
+}
+
+// traceLinks renders exemplar traces as links when TRACE_URL_TEMPLATE is
+// set and as bare trace IDs otherwise
+func (w *Watcher) traceLinks(traceIDs []string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, id := range traceIDs {
+		if seen[id] || len(out) >= w.cfg.MaxExamples {
+			continue
+		}
+		seen[id] = true
+		if url := observability.TraceURLForID(id); url != "" {
+			out = append(out, url)
+		} else {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+func (a Alert) summary() string {
+	return fmt.Sprintf("[%s] %s: value %.3g (threshold %.3g)", a.State, a.Rule, a.Value, a.Threshold)
+}
@@ -0,0 +1,236 @@
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+type recordingNotifier struct {
+	mu     sync.Mutex
+	alerts []Alert
+}
+
+func (n *recordingNotifier) Notify(_ context.Context, a Alert) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.alerts = append(n.alerts, a)
+	return nil
+}
+
+func newTestWatcher(t *testing.T, notifier Notifier, rules ...Rule) (*Watcher, metric.Meter) {
+	w := NewWatcher(Config{MaxExamples: 2}, notifier, slog.New(slog.NewTextHandler(io.Discard, nil)), rules...)
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(w.Reader()))
+	t.Cleanup(func() { _ = mp.Shutdown(context.Background()) })
+	return w, mp.Meter("test")
+}
+
+func TestWatcher_ErrorRateFiresAndResolves(t *testing.T) {
+	notifier := &recordingNotifier{}
+	w, meter := newTestWatcher(t, notifier, &ErrorRateRule{
+		Errors: "errors.total", Successes: "orders.created", Threshold: 0.2, MinEvents: 10,
+	})
+	errorsTotal, _ := meter.Int64Counter("errors.total")
+	orders, _ := meter.Int64Counter("orders.created")
+
+	tp := sdktrace.NewTracerProvider()
+	defer tp.Shutdown(context.Background())
+	ctx, span := tp.Tracer("test").Start(context.Background(), "CreateOrder")
+	errorsTotal.Add(ctx, 5)
+	orders.Add(ctx, 5)
+	span.End()
+
+	w.Check(context.Background())
+	if len(notifier.alerts) != 1 || notifier.alerts[0].State != "firing" {
+		t.Fatalf("Expected a firing alert, got %+v", notifier.alerts)
+	}
+	if len(notifier.alerts[0].Traces) != 1 || notifier.alerts[0].Traces[0] != span.SpanContext().TraceID().String() {
+		t.Errorf("Expected the exemplar trace on the alert, got %v", notifier.alerts[0].Traces)
+	}
+
+	// Still firing: no repeat notification
+	errorsTotal.Add(ctx, 5)
+	orders.Add(ctx, 5)
+	w.Check(context.Background())
+	if len(notifier.alerts) != 1 {
+		t.Fatalf("Expected no duplicate notification, got %d", len(notifier.alerts))
+	}
+
+	orders.Add(context.Background(), 50)
+	w.Check(context.Background())
+	if len(notifier.alerts) != 2 || notifier.alerts[1].State != "resolved" {
+		t.Errorf("Expected a resolved alert, got %+v", notifier.alerts)
+	}
+}
+
+func TestLatencyRule_UsesIntervalDelta(t *testing.T) {
+	notifier := &recordingNotifier{}
+	rule := &LatencyRule{Metric: "orders.duration", Quantile: 0.99, Threshold: 1000}
+	w, meter := newTestWatcher(t, notifier, rule)
+	duration, _ := meter.Float64Histogram("orders.duration",
+		metric.WithExplicitBucketBoundaries(100, 500, 1000, 2500, 5000))
+
+	for i := 0; i < 10; i++ {
+		duration.Record(context.Background(), 3000)
+	}
+	w.Check(context.Background())
+	if len(notifier.alerts) != 1 || notifier.alerts[0].Value != 5000 {
+		t.Fatalf("Expected p99 in the 5000ms bucket to fire, got %+v", notifier.alerts)
+	}
+
+	for i := 0; i < 100; i++ {
+		duration.Record(context.Background(), 50)
+	}
+	w.Check(context.Background())
+	if len(notifier.alerts) != 2 || notifier.alerts[1].Value != 100 {
+		t.Errorf("Expected only the latest interval to count, got %+v", notifier.alerts)
+	}
+}
+
+func TestGaugeRule(t *testing.T) {
+	notifier := &recordingNotifier{}
+	w, meter := newTestWatcher(t, notifier, &GaugeRule{Metric: "breaker.state", Threshold: 1})
+	state, _ := meter.Int64Gauge("breaker.state")
+
+	state.Record(context.Background(), 1)
+	w.Check(context.Background())
+	if len(notifier.alerts) != 1 || notifier.alerts[0].Rule != "breaker.state" {
+		t.Errorf("Expected the breaker alert to fire, got %+v", notifier.alerts)
+	}
+}
+
+func TestDefaultRules_WatchBreakers(t *testing.T) {
+	notifier := &recordingNotifier{}
+	w, meter := newTestWatcher(t, notifier, ConfigFromEnv().DefaultRules()...)
+	state, _ := meter.Int64Gauge("breaker.state")
+
+	state.Record(context.Background(), 0)
+	w.Check(context.Background())
+	state.Record(context.Background(), 1)
+	w.Check(context.Background())
+	if len(notifier.alerts) != 1 || notifier.alerts[0].Rule != "breaker.state" {
+		t.Errorf("Expected only the breaker alert once it opens, got %+v", notifier.alerts)
+	}
+}
+
+func TestDefaultRules_WatchArchiveFailures(t *testing.T) {
+	notifier := &recordingNotifier{}
+	w, meter := newTestWatcher(t, notifier, ConfigFromEnv().DefaultRules()...)
+	failures, _ := meter.Int64Counter("archive.failures")
+
+	failures.Add(context.Background(), 1)
+	w.Check(context.Background())
+	if len(notifier.alerts) != 1 || notifier.alerts[0].Rule != "archive.failures" || notifier.alerts[0].State != "firing" {
+		t.Fatalf("Expected the archive alert to fire, got %+v", notifier.alerts)
+	}
+	w.Check(context.Background())
+	if len(notifier.alerts) != 2 || notifier.alerts[1].State != "resolved" {
+		t.Errorf("Expected the alert to resolve without new failures, got %+v", notifier.alerts)
+	}
+}
+
+func TestAnomalyRule_FiresOnLatencySpike(t *testing.T) {
+	notifier := &recordingNotifier{}
+	var logs strings.Builder
+	rule := NewAnomalyRule("orders.duration_anomaly", MeanLatency("orders.duration"), DefaultAnomalyConfig(),
+		slog.New(slog.NewTextHandler(&logs, nil)))
+	w, meter := newTestWatcher(t, notifier, rule)
+	duration, _ := meter.Float64Histogram("orders.duration")
+
+	// Learn a baseline around 100ms
+	for i := 0; i < 20; i++ {
+		duration.Record(context.Background(), 95+float64(i%3)*5)
+		w.Check(context.Background())
+	}
+	if len(notifier.alerts) != 0 {
+		t.Fatalf("Expected no alert while latency is steady, got %+v", notifier.alerts)
+	}
+
+	duration.Record(context.Background(), 400)
+	w.Check(context.Background())
+	if len(notifier.alerts) != 1 || notifier.alerts[0].State != "firing" {
+		t.Fatalf("Expected an anomaly alert, got %+v", notifier.alerts)
+	}
+	if rule.Score() <= 3 {
+		t.Errorf("Expected a z-score above 3, got %v", rule.Score())
+	}
+	if !strings.Contains(logs.String(), "event.name=alerting.anomaly") {
+		t.Errorf("Expected an anomaly log event, got %s", logs.String())
+	}
+
+	// No traffic in an interval is not an anomaly
+	w.Check(context.Background())
+	if len(notifier.alerts) != 2 || notifier.alerts[1].State != "resolved" {
+		t.Errorf("Expected the anomaly to resolve, got %+v", notifier.alerts)
+	}
+}
+
+func TestErrorRatio_UsesIntervalDelta(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer mp.Shutdown(context.Background())
+	errorsTotal, _ := mp.Meter("test").Int64Counter("errors.total")
+	orders, _ := mp.Meter("test").Int64Counter("orders.created")
+	signal := ErrorRatio("errors.total", "orders.created")
+
+	collect := func() (float64, bool) {
+		var rm metricdata.ResourceMetrics
+		reader.Collect(context.Background(), &rm)
+		v, _, ok := signal(&rm)
+		return v, ok
+	}
+
+	errorsTotal.Add(context.Background(), 1)
+	orders.Add(context.Background(), 3)
+	if v, ok := collect(); !ok || v != 0.25 {
+		t.Errorf("Expected 0.25 for the first interval, got %v", v)
+	}
+	orders.Add(context.Background(), 4)
+	if v, ok := collect(); !ok || v != 0 {
+		t.Errorf("Expected 0 for an interval without errors, got %v", v)
+	}
+	if _, ok := collect(); ok {
+		t.Error("Expected no value for an empty interval")
+	}
+}
+
+func TestWebhook_SlackFormat(t *testing.T) {
+	var got map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer server.Close()
+
+	err := NewWebhook(server.URL, "slack").Notify(context.Background(), Alert{
+		Rule: "error_rate", State: "firing", Value: 0.5, Threshold: 0.2,
+		Traces: []string{"http://localhost:16686/trace/abc"},
+	})
+	if err != nil {
+		t.Fatalf("Notify returned %v", err)
+	}
+	if !strings.Contains(got["text"], "error_rate") || !strings.Contains(got["text"], "/trace/abc") {
+		t.Errorf("Unexpected Slack message %q", got["text"])
+	}
+}
+
+func TestConfig_DisabledWithoutObservability(t *testing.T) {
+	cfg := Config{WebhookURL: "http://alerts.example"}
+	if !cfg.Enabled() {
+		t.Fatal("Expected a webhook to enable alerting")
+	}
+	t.Setenv("OBSERVABILITY_DISABLED", "true")
+	if cfg.Enabled() {
+		t.Error("Expected no alerting with OBSERVABILITY_DISABLED")
+	}
+}
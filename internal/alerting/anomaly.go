@@ -0,0 +1,182 @@
+package alerting
+
+import (
+	"context"
+	"encoding/hex"
+	"log/slog"
+	"math"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// Signal turns collected metrics into one value per interval, with
+// example traces. ok is false when the interval had no data.
+type Signal func(rm *metricdata.ResourceMetrics) (value float64, traceIDs []string, ok bool)
+
+// MeanLatency is the average of a histogram over the last interval
+func MeanLatency(metricName string) Signal {
+	var prevSum float64
+	var prevCount uint64
+	return func(rm *metricdata.ResourceMetrics) (float64, []string, bool) {
+		hist, ok := findMetric(rm, metricName).(metricdata.Histogram[float64])
+		if !ok {
+			return 0, nil, false
+		}
+
+		var sum float64
+		var count uint64
+		var traceIDs []string
+		for _, dp := range hist.DataPoints {
+			sum += dp.Sum
+			count += dp.Count
+			for _, ex := range dp.Exemplars {
+				if len(ex.TraceID) > 0 {
+					traceIDs = append(traceIDs, hex.EncodeToString(ex.TraceID))
+				}
+			}
+		}
+
+		deltaSum, deltaCount := sum-prevSum, count-prevCount
+		if count < prevCount {
+			// The provider was reset; start over
+			deltaSum, deltaCount = sum, count
+		}
+		prevSum, prevCount = sum, count
+		if deltaCount == 0 {
+			return 0, nil, false
+		}
+		return deltaSum / float64(deltaCount), traceIDs, true
+	}
+}
+
+// ErrorRatio is errors / (errors + successes) over the last interval
+func ErrorRatio(errorsMetric, successesMetric string) Signal {
+	var prevErrors, prevSuccesses int64
+	return func(rm *metricdata.ResourceMetrics) (float64, []string, bool) {
+		errors, traceIDs := sumInt64(rm, errorsMetric)
+		successes, _ := sumInt64(rm, successesMetric)
+
+		deltaErrors, deltaSuccesses := errors-prevErrors, successes-prevSuccesses
+		prevErrors, prevSuccesses = errors, successes
+		total := deltaErrors + deltaSuccesses
+		if total <= 0 {
+			return 0, nil, false
+		}
+		return float64(deltaErrors) / float64(total), traceIDs, true
+	}
+}
+
+// AnomalyConfig tunes the detector
+type AnomalyConfig struct {
+	// Alpha weights each interval in the moving mean and variance
+	Alpha float64
+	// Z is how many standard deviations above the baseline count as anomalous
+	Z float64
+	// Warmup is the number of intervals learned before anything can fire
+	Warmup int
+	// MinStdDevRatio floors the deviation at a fraction of the mean, so a
+	// very steady signal doesn't flag tiny wobbles
+	MinStdDevRatio float64
+}
+
+func DefaultAnomalyConfig() AnomalyConfig {
+	return AnomalyConfig{Alpha: 0.1, Z: 3, Warmup: 10, MinStdDevRatio: 0.05}
+}
+
+// AnomalyRule learns an exponentially weighted baseline of a signal and
+// fires when an interval lands more than Z standard deviations above it.
+// Only increases are anomalies: lower latency or fewer errors are fine.
+// Every anomalous interval is logged as an alerting.anomaly event, and the
+// latest z-score is exported as the alerting.anomaly.score gauge.
+type AnomalyRule struct {
+	name   string
+	signal Signal
+	cfg    AnomalyConfig
+	logger *slog.Logger
+
+	mu       sync.Mutex
+	mean     float64
+	variance float64
+	samples  int
+	score    float64
+}
+
+func NewAnomalyRule(name string, signal Signal, cfg AnomalyConfig, logger *slog.Logger) *AnomalyRule {
+	return &AnomalyRule{name: name, signal: signal, cfg: cfg, logger: logger}
+}
+
+func (r *AnomalyRule) Name() string { return r.name }
+
+func (r *AnomalyRule) Evaluate(rm *metricdata.ResourceMetrics) Result {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	res := Result{Threshold: r.cfg.Z}
+	value, traceIDs, ok := r.signal(rm)
+	if !ok {
+		r.score = 0
+		return res
+	}
+
+	baseline := r.mean
+	if r.samples >= r.cfg.Warmup {
+		stddev := math.Max(math.Sqrt(r.variance), math.Max(r.cfg.MinStdDevRatio*math.Abs(r.mean), 1e-9))
+		r.score = (value - r.mean) / stddev
+		res.Value = r.score
+		res.Firing = r.score > r.cfg.Z
+	}
+
+	// Incremental EWMA of mean and variance; the first sample seeds the mean
+	if r.samples == 0 {
+		r.mean = value
+	} else {
+		diff := value - r.mean
+		incr := r.cfg.Alpha * diff
+		r.mean += incr
+		r.variance = (1 - r.cfg.Alpha) * (r.variance + diff*incr)
+	}
+	r.samples++
+
+	if res.Firing {
+		res.TraceIDs = traceIDs
+		r.logger.Warn("anomaly detected",
+			slog.String("event.name", "alerting.anomaly"),
+			slog.String("rule", r.name),
+			slog.Float64("value", value),
+			slog.Float64("baseline", baseline),
+			slog.Float64("z_score", r.score),
+		)
+	}
+	return res
+}
+
+// Score returns the z-score of the latest interval
+func (r *AnomalyRule) Score() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.score
+}
+
+// RegisterAnomalyGauge exports every rule's latest z-score as
+// alerting.anomaly.score{rule}
+func RegisterAnomalyGauge(meter metric.Meter, rules ...*AnomalyRule) error {
+	gauge, err := meter.Float64ObservableGauge(
+		"alerting.anomaly.score",
+		metric.WithDescription("Standard deviations between the last interval and the learned baseline"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		for _, r := range rules {
+			o.ObserveFloat64(gauge, r.Score(), metric.WithAttributes(attribute.String("rule", r.Name())))
+		}
+		return nil
+	}, gauge)
+	return err
+}
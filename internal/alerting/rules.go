@@ -0,0 +1,223 @@
+package alerting
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// Result is one rule evaluation
+type Result struct {
+	Value     float64
+	Threshold float64
+	Firing    bool
+	// TraceIDs are exemplar traces that illustrate the problem
+	TraceIDs []string
+}
+
+// Rule evaluates collected metrics. Rules see cumulative data and keep
+// whatever state they need to turn it into a per-interval view.
+type Rule interface {
+	Name() string
+	Evaluate(rm *metricdata.ResourceMetrics) Result
+}
+
+// ErrorRateRule fires when Errors / (Errors + Successes) over the last
+// interval exceeds Threshold
+type ErrorRateRule struct {
+	Errors    string
+	Successes string
+	Threshold float64
+	// MinEvents avoids firing on a single failed request at low traffic
+	MinEvents int64
+
+	prevErrors, prevSuccesses int64
+}
+
+func (r *ErrorRateRule) Name() string { return "error_rate" }
+
+func (r *ErrorRateRule) Evaluate(rm *metricdata.ResourceMetrics) Result {
+	errors, traceIDs := sumInt64(rm, r.Errors)
+	successes, _ := sumInt64(rm, r.Successes)
+
+	deltaErrors := errors - r.prevErrors
+	deltaSuccesses := successes - r.prevSuccesses
+	r.prevErrors, r.prevSuccesses = errors, successes
+
+	res := Result{Threshold: r.Threshold, TraceIDs: traceIDs}
+	if total := deltaErrors + deltaSuccesses; total > 0 {
+		res.Value = float64(deltaErrors) / float64(total)
+		res.Firing = total >= r.MinEvents && res.Value > r.Threshold
+	}
+	return res
+}
+
+// LatencyRule fires when the Quantile of a histogram over the last
+// interval exceeds Threshold. The quantile is the upper bound of the bucket
+// it falls in, which is as precise as bucketed data allows.
+type LatencyRule struct {
+	Metric    string
+	Quantile  float64
+	Threshold float64
+
+	prevCounts []uint64
+}
+
+func (r *LatencyRule) Name() string {
+	return fmt.Sprintf("%s_p%g", r.Metric, r.Quantile*100)
+}
+
+func (r *LatencyRule) Evaluate(rm *metricdata.ResourceMetrics) Result {
+	res := Result{Threshold: r.Threshold}
+
+	hist, ok := findMetric(rm, r.Metric).(metricdata.Histogram[float64])
+	if !ok || len(hist.DataPoints) == 0 {
+		return res
+	}
+
+	bounds := hist.DataPoints[0].Bounds
+	counts := make([]uint64, len(bounds)+1)
+	for _, dp := range hist.DataPoints {
+		for i, c := range dp.BucketCounts {
+			if i < len(counts) {
+				counts[i] += c
+			}
+		}
+		for _, ex := range dp.Exemplars {
+			if ex.Value > r.Threshold && len(ex.TraceID) > 0 {
+				res.TraceIDs = append(res.TraceIDs, hex.EncodeToString(ex.TraceID))
+			}
+		}
+	}
+
+	delta := make([]uint64, len(counts))
+	var total uint64
+	for i := range counts {
+		delta[i] = counts[i]
+		if i < len(r.prevCounts) && counts[i] >= r.prevCounts[i] {
+			delta[i] -= r.prevCounts[i]
+		}
+		total += delta[i]
+	}
+	r.prevCounts = counts
+	if total == 0 {
+		return res
+	}
+
+	rank := uint64(math.Ceil(r.Quantile * float64(total)))
+	var seen uint64
+	for i, c := range delta {
+		seen += c
+		if seen < rank {
+			continue
+		}
+		if i < len(bounds) {
+			res.Value = bounds[i]
+		} else {
+			// Overflow bucket: the best we know is that it exceeds the last bound
+			res.Value = math.Inf(1)
+		}
+		break
+	}
+	res.Firing = res.Value > r.Threshold
+	return res
+}
+
+// GaugeRule fires while any data point of a gauge or up-down counter is at
+// or above Threshold, e.g. a circuit breaker state where 1 means open
+type GaugeRule struct {
+	Metric    string
+	Threshold float64
+}
+
+func (r *GaugeRule) Name() string { return r.Metric }
+
+func (r *GaugeRule) Evaluate(rm *metricdata.ResourceMetrics) Result {
+	res := Result{Threshold: r.Threshold, Value: math.Inf(-1)}
+
+	var values []float64
+	switch data := findMetric(rm, r.Metric).(type) {
+	case metricdata.Gauge[int64]:
+		for _, dp := range data.DataPoints {
+			values = append(values, float64(dp.Value))
+		}
+	case metricdata.Gauge[float64]:
+		for _, dp := range data.DataPoints {
+			values = append(values, dp.Value)
+		}
+	case metricdata.Sum[int64]:
+		for _, dp := range data.DataPoints {
+			values = append(values, float64(dp.Value))
+		}
+	case metricdata.Sum[float64]:
+		for _, dp := range data.DataPoints {
+			values = append(values, dp.Value)
+		}
+	}
+	if len(values) == 0 {
+		res.Value = 0
+		return res
+	}
+
+	for _, v := range values {
+		res.Value = math.Max(res.Value, v)
+	}
+	res.Firing = res.Value >= r.Threshold
+	return res
+}
+
+// CounterRule fires when a counter grew by at least Threshold over the
+// last interval, e.g. any archive write failing
+type CounterRule struct {
+	Metric    string
+	Threshold float64
+
+	prev int64
+}
+
+func (r *CounterRule) Name() string { return r.Metric }
+
+func (r *CounterRule) Evaluate(rm *metricdata.ResourceMetrics) Result {
+	total, traceIDs := sumInt64(rm, r.Metric)
+	delta := total - r.prev
+	r.prev = total
+	return Result{
+		Value:     float64(delta),
+		Threshold: r.Threshold,
+		Firing:    float64(delta) >= r.Threshold,
+		TraceIDs:  traceIDs,
+	}
+}
+
+func findMetric(rm *metricdata.ResourceMetrics, name string) metricdata.Aggregation {
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == name {
+				return m.Data
+			}
+		}
+	}
+	return nil
+}
+
+// sumInt64 totals a counter across attribute sets and returns its exemplar traces
+func sumInt64(rm *metricdata.ResourceMetrics, name string) (int64, []string) {
+	sum, ok := findMetric(rm, name).(metricdata.Sum[int64])
+	if !ok {
+		return 0, nil
+	}
+
+	var total int64
+	var traceIDs []string
+	for _, dp := range sum.DataPoints {
+		total += dp.Value
+		for _, ex := range dp.Exemplars {
+			if len(ex.TraceID) > 0 {
+				traceIDs = append(traceIDs, hex.EncodeToString(ex.TraceID))
+			}
+		}
+	}
+	return total, traceIDs
+}
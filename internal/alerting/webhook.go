@@ -0,0 +1,66 @@
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Webhook posts alerts as JSON, or as a Slack message when Format is "slack"
+type Webhook struct {
+	URL    string
+	Format string
+	Client *http.Client
+}
+
+func NewWebhook(url, format string) *Webhook {
+	return &Webhook{
+		URL:    url,
+		Format: format,
+		Client: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+func (wh *Webhook) Notify(ctx context.Context, alert Alert) error {
+	var payload any = alert
+	if wh.Format == "slack" {
+		payload = slackMessage(alert)
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := wh.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func slackMessage(alert Alert) map[string]string {
+	var b strings.Builder
+	icon := ":rotating_light:"
+	if alert.State == "resolved" {
+		icon = ":white_check_mark:"
+	}
+	b.WriteString(icon + " " + alert.summary())
+	for _, trace := range alert.Traces {
+		b.WriteString("\n• " + trace)
+	}
+	return map[string]string{"text": b.String()}
+}
@@ -0,0 +1,151 @@
+// Package apperr defines the application's error codes. One error value
+// decides the HTTP status, the span status, the error.type metric
+// attribute, and the log level and error_code field, so handlers don't
+// repeat that mapping at every failure.
+package apperr
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Code identifies a kind of failure in responses, logs, and metrics
+type Code string
+
+const (
+	InvalidRequest      Code = "INVALID_REQUEST"
+	Unauthorized        Code = "UNAUTHORIZED"
+	ValidationFailed    Code = "VALIDATION_FAILED"
+	PricingFailed       Code = "PRICING_FAILED"
+	UnsupportedCurrency Code = "UNSUPPORTED_CURRENCY"
+	CurrencyUnavailable Code = "CURRENCY_UNAVAILABLE"
+	QuotaExceeded       Code = "QUOTA_EXCEEDED"
+	DuplicateRequest    Code = "DUPLICATE_REQUEST"
+	DuplicateOrder      Code = "DUPLICATE_ORDER"
+	PaymentDeclined     Code = "PAYMENT_DECLINED"
+	InventoryExhausted  Code = "INVENTORY_EXHAUSTED"
+	OrderNotFound       Code = "ORDER_NOT_FOUND"
+	NotCancellable      Code = "NOT_CANCELLABLE"
+	RefundFailed        Code = "REFUND_FAILED"
+	// Internal is the code of errors that carry none
+	Internal Code = "INTERNAL"
+)
+
+type codeInfo struct {
+	status int
+	// errorType is the error.type metric value; the older values are kept
+	// so existing dashboards and alerts still match
+	errorType string
+	level     slog.Level
+}
+
+var codes = map[Code]codeInfo{
+	InvalidRequest:      {http.StatusBadRequest, "invalid_request", slog.LevelError},
+	Unauthorized:        {http.StatusUnauthorized, "unauthorized", slog.LevelWarn},
+	ValidationFailed:    {http.StatusBadRequest, "validation_error", slog.LevelError},
+	PricingFailed:       {http.StatusBadRequest, "pricing_error", slog.LevelWarn},
+	UnsupportedCurrency: {http.StatusBadRequest, "currency_error", slog.LevelWarn},
+	CurrencyUnavailable: {http.StatusServiceUnavailable, "currency_error", slog.LevelWarn},
+	QuotaExceeded:       {http.StatusTooManyRequests, "quota_exceeded", slog.LevelWarn},
+	DuplicateRequest:    {http.StatusConflict, "duplicate_request", slog.LevelWarn},
+	DuplicateOrder:      {http.StatusConflict, "duplicate_order", slog.LevelWarn},
+	PaymentDeclined:     {http.StatusPaymentRequired, "payment_declined", slog.LevelError},
+	InventoryExhausted:  {http.StatusConflict, "inventory_exhausted", slog.LevelError},
+	OrderNotFound:       {http.StatusNotFound, "not_found", slog.LevelInfo},
+	NotCancellable:      {http.StatusConflict, "not_cancellable", slog.LevelWarn},
+	RefundFailed:        {http.StatusBadGateway, "refund_failed", slog.LevelError},
+	Internal:            {http.StatusInternalServerError, "processing_error", slog.LevelError},
+}
+
+// Codes lists every code, e.g. to prepare metric attributes up front
+func Codes() []Code {
+	out := make([]Code, 0, len(codes))
+	for c := range codes {
+		out = append(out, c)
+	}
+	return out
+}
+
+// HTTPStatus is the response status for c
+func (c Code) HTTPStatus() int {
+	if info, ok := codes[c]; ok {
+		return info.status
+	}
+	return http.StatusInternalServerError
+}
+
+// ErrorType is the error.type metric attribute value for c
+func (c Code) ErrorType() string {
+	if info, ok := codes[c]; ok {
+		return info.errorType
+	}
+	return codes[Internal].errorType
+}
+
+// LogLevel is the level to log failures with code c at
+func (c Code) LogLevel() slog.Level {
+	if info, ok := codes[c]; ok {
+		return info.level
+	}
+	return slog.LevelError
+}
+
+// Attribute is the error.type attribute for c
+func (c Code) Attribute() attribute.KeyValue {
+	return attribute.String("error.type", c.ErrorType())
+}
+
+// Error is a failure with a code. Message is safe to show to clients; Err,
+// the cause, is only logged and recorded on the span.
+type Error struct {
+	Code    Code
+	Message string
+	Err     error
+}
+
+// New returns an error with a client-facing message and no cause
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// Wrap gives err a code. With an empty message, clients see err's text.
+func Wrap(code Code, err error, message string) *Error {
+	return &Error{Code: code, Message: message, Err: err}
+}
+
+func (e *Error) Error() string {
+	switch {
+	case e.Err == nil:
+		return e.Message
+	case e.Message == "":
+		return e.Err.Error()
+	default:
+		return e.Message + ": " + e.Err.Error()
+	}
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// CodeOf returns the code of the first Error in err's chain, or Internal
+func CodeOf(err error) Code {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return Internal
+}
+
+// PublicMessage is the text to show clients for err: the message of an
+// Error that has one, or else err's text, which wraps inner messages the
+// way the handler's fmt.Errorf calls did
+func PublicMessage(err error) string {
+	if e, ok := err.(*Error); ok && e.Message != "" {
+		return e.Message
+	}
+	return err.Error()
+}
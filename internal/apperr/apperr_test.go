@@ -0,0 +1,56 @@
+package apperr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestCodeOf(t *testing.T) {
+	declined := New(PaymentDeclined, "payment declined")
+	err := fmt.Errorf("payment failed: %w", declined)
+
+	if code := CodeOf(err); code != PaymentDeclined {
+		t.Errorf("Expected PAYMENT_DECLINED through the wrap, got %s", code)
+	}
+	if code := CodeOf(errors.New("boom")); code != Internal {
+		t.Errorf("Expected INTERNAL for an uncoded error, got %s", code)
+	}
+	if status := CodeOf(err).HTTPStatus(); status != http.StatusPaymentRequired {
+		t.Errorf("Expected 402, got %d", status)
+	}
+	if got := Code("UNKNOWN").ErrorType(); got != "processing_error" {
+		t.Errorf("Expected unknown codes to count as processing errors, got %s", got)
+	}
+}
+
+func TestMessages(t *testing.T) {
+	cause := errors.New("unexpected EOF")
+	err := Wrap(InvalidRequest, cause, "invalid request")
+
+	if err.Error() != "invalid request: unexpected EOF" {
+		t.Errorf("Expected the cause in Error(), got %q", err.Error())
+	}
+	if got := PublicMessage(err); got != "invalid request" {
+		t.Errorf("Expected the cause hidden from clients, got %q", got)
+	}
+	if !errors.Is(err, cause) {
+		t.Error("Expected Unwrap to expose the cause")
+	}
+	if got := PublicMessage(Wrap(PricingFailed, errors.New("unknown product"), "")); got != "unknown product" {
+		t.Errorf("Expected the cause as the message when there is none, got %q", got)
+	}
+	wrapped := fmt.Errorf("inventory check failed: %w", New(InventoryExhausted, "insufficient inventory"))
+	if got := PublicMessage(wrapped); got != "inventory check failed: insufficient inventory" {
+		t.Errorf("Expected the full wrapped text, got %q", got)
+	}
+}
+
+func TestCodes_AllMapped(t *testing.T) {
+	for _, code := range Codes() {
+		if code.HTTPStatus() < 400 || code.ErrorType() == "" {
+			t.Errorf("Expected %s to map to an error status and type", code)
+		}
+	}
+}
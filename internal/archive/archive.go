@@ -0,0 +1,244 @@
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-observability-demo/internal/config"
+	"log/slog"
+	"path"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// OrderEvent is the archived record of a completed order
+type OrderEvent struct {
+	OrderID     string    `json:"order_id"`
+	UserID      string    `json:"user_id"`
+	ProductID   string    `json:"product_id"`
+	Quantity    int       `json:"quantity"`
+	Amount      float64   `json:"amount"`
+	Currency    string    `json:"currency,omitempty"`
+	TraceID     string    `json:"trace_id,omitempty"`
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+// ObjectStore is the subset of S3/GCS the archiver needs
+type ObjectStore interface {
+	Put(ctx context.Context, key string, data []byte) error
+}
+
+// Config controls batching and object layout
+type Config struct {
+	Prefix   string
+	Interval time.Duration
+	// MaxBuffered bounds memory while the store is unavailable; the oldest
+	// events are dropped beyond it
+	MaxBuffered int
+}
+
+func ConfigFromEnv() Config {
+	return Config{
+		Prefix:      config.String("ARCHIVE_PREFIX", "orders"),
+		Interval:    config.Duration("ARCHIVE_INTERVAL", time.Minute),
+		MaxBuffered: config.Int("ARCHIVE_MAX_BUFFERED", 10000),
+	}
+}
+
+// Archiver batches completed order events and writes them to object
+// storage as gzipped JSONL, partitioned by date and hour for analytics
+// tools. A failed upload keeps the batch for the next run. A nil
+// *Archiver ignores events.
+type Archiver struct {
+	cfg    Config
+	store  ObjectStore
+	logger *slog.Logger
+	tracer trace.Tracer
+
+	bytesWritten metric.Int64Counter
+	events       metric.Int64Counter
+	failures     metric.Int64Counter
+
+	mu      sync.Mutex
+	pending []OrderEvent
+	flushMu sync.Mutex // serializes uploads
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+func New(cfg Config, store ObjectStore, meter metric.Meter, logger *slog.Logger) (*Archiver, error) {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Minute
+	}
+
+	bytesWritten, err := meter.Int64Counter(
+		"archive.bytes_written",
+		metric.WithDescription("Compressed bytes written to object storage"),
+		metric.WithUnit("By"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := meter.Int64Counter(
+		"archive.events",
+		metric.WithDescription("Order events archived or dropped"),
+		metric.WithUnit("{event}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	failures, err := meter.Int64Counter(
+		"archive.failures",
+		metric.WithDescription("Failed archive uploads"),
+		metric.WithUnit("{upload}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Archiver{
+		cfg:          cfg,
+		store:        store,
+		logger:       logger,
+		tracer:       otel.Tracer("order-service/archive"),
+		bytesWritten: bytesWritten,
+		events:       events,
+		failures:     failures,
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}, nil
+}
+
+// Record queues an event for the next flush
+func (a *Archiver) Record(e OrderEvent) {
+	if a == nil {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.pending = append(a.pending, e)
+	a.trimLocked()
+}
+
+// trimLocked drops the oldest events beyond MaxBuffered. Callers must hold a.mu.
+func (a *Archiver) trimLocked() {
+	if a.cfg.MaxBuffered <= 0 || len(a.pending) <= a.cfg.MaxBuffered {
+		return
+	}
+	dropped := len(a.pending) - a.cfg.MaxBuffered
+	a.pending = append(a.pending[:0], a.pending[dropped:]...)
+	a.events.Add(context.Background(), int64(dropped), metric.WithAttributes(attribute.String("outcome", "dropped")))
+}
+
+// Start flushes on the configured interval until Stop is called
+func (a *Archiver) Start() {
+	go func() {
+		defer close(a.done)
+		ticker := time.NewTicker(a.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-a.stop:
+				return
+			case <-ticker.C:
+				_ = a.Flush(context.Background())
+			}
+		}
+	}()
+}
+
+// Stop ends the schedule and uploads whatever is still buffered
+func (a *Archiver) Stop(ctx context.Context) error {
+	a.once.Do(func() { close(a.stop) })
+	select {
+	case <-a.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return a.Flush(ctx)
+}
+
+// Flush uploads all buffered events as one object
+func (a *Archiver) Flush(ctx context.Context) error {
+	a.flushMu.Lock()
+	defer a.flushMu.Unlock()
+
+	a.mu.Lock()
+	batch := a.pending
+	a.pending = nil
+	a.mu.Unlock()
+	if len(batch) == 0 {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	key := path.Join(a.cfg.Prefix,
+		"dt="+now.Format("2006-01-02"),
+		"hour="+now.Format("15"),
+		fmt.Sprintf("%d.jsonl.gz", now.UnixNano()),
+	)
+
+	ctx, span := a.tracer.Start(ctx, "ArchiveOrders", trace.WithAttributes(
+		attribute.String("archive.key", key),
+		attribute.Int("archive.events", len(batch)),
+	))
+	defer span.End()
+
+	data, err := encode(batch)
+	if err == nil {
+		span.SetAttributes(attribute.Int("archive.bytes", len(data)))
+		err = a.store.Put(ctx, key, data)
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "archive upload failed")
+		a.failures.Add(ctx, 1)
+		a.logger.ErrorContext(ctx, "order archive upload failed",
+			slog.String("key", key),
+			slog.Int("events", len(batch)),
+			slog.String("error", err.Error()),
+		)
+		a.requeue(batch)
+		return err
+	}
+
+	a.bytesWritten.Add(ctx, int64(len(data)))
+	a.events.Add(ctx, int64(len(batch)), metric.WithAttributes(attribute.String("outcome", "archived")))
+	return nil
+}
+
+// requeue puts a failed batch back in front of events recorded since
+func (a *Archiver) requeue(batch []OrderEvent) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.pending = append(batch, a.pending...)
+	a.trimLocked()
+}
+
+func encode(batch []OrderEvent) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(zw)
+	for _, e := range batch {
+		if err := enc.Encode(e); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,89 @@
+package archive
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"go-observability-demo/internal/observability/observabilitytest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+)
+
+type failingStore struct{ err error }
+
+func (s failingStore) Put(context.Context, string, []byte) error { return s.err }
+
+func TestArchiver_WritesPartitionedJSONL(t *testing.T) {
+	recorder := observabilitytest.New(t)
+	dir := t.TempDir()
+
+	a, err := New(Config{Prefix: "orders"}, FileStore{Dir: dir}, otel.Meter("test"), recorder.Logger)
+	if err != nil {
+		t.Fatalf("Failed to create archiver: %v", err)
+	}
+	a.Record(OrderEvent{OrderID: "order-1", UserID: "u1"})
+	a.Record(OrderEvent{OrderID: "order-2", UserID: "u2"})
+	if err := a.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush returned %v", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "orders", "dt=*", "hour=*", "*.jsonl.gz"))
+	if len(files) != 1 {
+		t.Fatalf("Expected one partitioned object, got %v", files)
+	}
+
+	f, _ := os.Open(files[0])
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("Object is not gzipped: %v", err)
+	}
+	var ids []string
+	scanner := bufio.NewScanner(zr)
+	for scanner.Scan() {
+		var e OrderEvent
+		json.Unmarshal(scanner.Bytes(), &e)
+		ids = append(ids, e.OrderID)
+	}
+	if strings.Join(ids, ",") != "order-1,order-2" {
+		t.Errorf("Unexpected archived events %v", ids)
+	}
+
+	info, _ := os.Stat(files[0])
+	if got := recorder.Int64Sum(t, "archive.bytes_written"); got != info.Size() {
+		t.Errorf("Expected %d bytes written, got %d", info.Size(), got)
+	}
+	if len(recorder.SpansNamed("ArchiveOrders")) != 1 {
+		t.Error("Expected an ArchiveOrders span")
+	}
+}
+
+func TestArchiver_KeepsBatchOnFailure(t *testing.T) {
+	recorder := observabilitytest.New(t)
+
+	a, _ := New(Config{MaxBuffered: 2}, failingStore{errors.New("bucket unavailable")}, otel.Meter("test"), recorder.Logger)
+	a.Record(OrderEvent{OrderID: "order-1"})
+	a.Record(OrderEvent{OrderID: "order-2"})
+	if err := a.Flush(context.Background()); err == nil {
+		t.Fatal("Expected the upload error")
+	}
+	a.Record(OrderEvent{OrderID: "order-3"})
+
+	if len(a.pending) != 2 || a.pending[0].OrderID != "order-2" || a.pending[1].OrderID != "order-3" {
+		t.Errorf("Expected the newest 2 events to be kept, got %+v", a.pending)
+	}
+	if got := recorder.Int64Sum(t, "archive.failures"); got != 1 {
+		t.Errorf("Expected 1 failure counted, got %d", got)
+	}
+}
+
+func TestArchiver_NilIgnoresEvents(t *testing.T) {
+	var a *Archiver
+	a.Record(OrderEvent{OrderID: "order-1"})
+}
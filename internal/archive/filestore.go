@@ -0,0 +1,27 @@
+package archive
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+)
+
+// FileStore writes objects under a local directory. Point it at a bucket
+// mounted with s3fs/gcsfuse, or use it as-is for the local demo; an SDK
+// backed store only needs to implement ObjectStore.
+type FileStore struct {
+	Dir string
+}
+
+func (s FileStore) Put(_ context.Context, key string, data []byte) error {
+	target := filepath.Join(s.Dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+
+	tmp := target + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, target)
+}
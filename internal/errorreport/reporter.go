@@ -0,0 +1,181 @@
+package errorreport
+
+import (
+	"context"
+	"fmt"
+	"go-observability-demo/internal/config"
+	"go-observability-demo/internal/observability"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Config controls Sentry (or any Sentry-protocol compatible backend) reporting
+type Config struct {
+	DSN                string
+	Environment        string
+	Release            string
+	MaxEventsPerMinute int
+	// Transport overrides how events are delivered, mainly for tests
+	Transport sentry.Transport
+}
+
+func ConfigFromEnv() Config {
+	return Config{
+		DSN:                config.String("SENTRY_DSN", ""),
+		Environment:        config.String("SENTRY_ENVIRONMENT", config.String("ENVIRONMENT", "development")),
+		Release:            config.String("SENTRY_RELEASE", "order-service@"+observability.ServiceBuildInfo().Version),
+		MaxEventsPerMinute: config.Int("SENTRY_MAX_EVENTS_PER_MINUTE", 60),
+	}
+}
+
+// Reporter sends handler errors and panics to Sentry tagged with the active
+// trace, so an error group links straight to the trace that produced it. A
+// Reporter without a DSN is disabled and every method is a no-op.
+type Reporter struct {
+	hub     *sentry.Hub
+	limiter *tokenBucket
+}
+
+func New(cfg Config) (*Reporter, error) {
+	if cfg.DSN == "" {
+		return &Reporter{}, nil
+	}
+
+	client, err := sentry.NewClient(sentry.ClientOptions{
+		Dsn:         cfg.DSN,
+		Environment: cfg.Environment,
+		Release:     cfg.Release,
+		Transport:   cfg.Transport,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sentry client: %w", err)
+	}
+
+	return &Reporter{
+		hub:     sentry.NewHub(client, sentry.NewScope()),
+		limiter: newTokenBucket(cfg.MaxEventsPerMinute, time.Minute),
+	}, nil
+}
+
+func (r *Reporter) Enabled() bool {
+	return r != nil && r.hub != nil
+}
+
+// CaptureError reports err with the trace from ctx, the user, and extra tags.
+// Events beyond the configured rate are dropped.
+func (r *Reporter) CaptureError(ctx context.Context, err error, userID string, tags map[string]string) {
+	if !r.Enabled() || err == nil || !r.limiter.allow() {
+		return
+	}
+
+	hub := r.hub.Clone()
+	hub.WithScope(func(scope *sentry.Scope) {
+		r.applyScope(ctx, scope, userID, tags)
+		hub.CaptureException(err)
+	})
+}
+
+// Recover is middleware that turns panics into a 500 response, marks the
+// active span as failed, and reports the panic
+func (r *Reporter) Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+
+			span := trace.SpanFromContext(req.Context())
+			span.RecordError(fmt.Errorf("panic: %v", rec), trace.WithStackTrace(true))
+			span.SetStatus(codes.Error, "panic")
+
+			if r.Enabled() && r.limiter.allow() {
+				hub := r.hub.Clone()
+				hub.WithScope(func(scope *sentry.Scope) {
+					r.applyScope(req.Context(), scope, "", map[string]string{
+						"http.method": req.Method,
+						"http.route":  req.URL.Path,
+					})
+					hub.Recover(rec)
+				})
+			}
+
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}()
+
+		next.ServeHTTP(w, req)
+	})
+}
+
+// Flush waits for queued events to be delivered
+func (r *Reporter) Flush(ctx context.Context) error {
+	if !r.Enabled() {
+		return nil
+	}
+	if !r.hub.Client().FlushWithContext(ctx) {
+		return fmt.Errorf("failed to flush error reports")
+	}
+	return nil
+}
+
+func (r *Reporter) applyScope(ctx context.Context, scope *sentry.Scope, userID string, tags map[string]string) {
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		scope.SetTag("trace_id", sc.TraceID().String())
+		scope.SetTag("span_id", sc.SpanID().String())
+		scope.SetContext("trace", sentry.Context{
+			"trace_id": sc.TraceID().String(),
+			"span_id":  sc.SpanID().String(),
+		})
+	}
+	if userID != "" {
+		scope.SetUser(sentry.User{ID: userID})
+	}
+	for k, v := range tags {
+		scope.SetTag(k, v)
+	}
+}
+
+// tokenBucket caps how many events are sent so an error storm can't exhaust
+// the Sentry quota
+type tokenBucket struct {
+	mu       sync.Mutex
+	capacity float64
+	tokens   float64
+	rate     float64 // tokens per second
+	last     time.Time
+}
+
+func newTokenBucket(perPeriod int, period time.Duration) *tokenBucket {
+	if perPeriod <= 0 {
+		perPeriod = 1
+	}
+	return &tokenBucket{
+		capacity: float64(perPeriod),
+		tokens:   float64(perPeriod),
+		rate:     float64(perPeriod) / period.Seconds(),
+		last:     time.Now(),
+	}
+}
+
+func (b *tokenBucket) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens = min(b.capacity, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
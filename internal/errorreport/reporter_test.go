@@ -0,0 +1,125 @@
+package errorreport
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+type fakeTransport struct {
+	mu     sync.Mutex
+	events []*sentry.Event
+}
+
+func (f *fakeTransport) Flush(time.Duration) bool              { return true }
+func (f *fakeTransport) FlushWithContext(context.Context) bool { return true }
+func (f *fakeTransport) Configure(sentry.ClientOptions)        {}
+func (f *fakeTransport) Close()                                {}
+func (f *fakeTransport) SendEvent(event *sentry.Event) {
+	f.mu.Lock()
+	f.events = append(f.events, event)
+	f.mu.Unlock()
+}
+
+func (f *fakeTransport) sent() []*sentry.Event {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.events
+}
+
+func newTestReporter(t *testing.T, maxPerMinute int) (*Reporter, *fakeTransport) {
+	transport := &fakeTransport{}
+	r, err := New(Config{
+		DSN:                "https://key@sentry.example.com/1",
+		Release:            "order-service@test",
+		MaxEventsPerMinute: maxPerMinute,
+		Transport:          transport,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create reporter: %v", err)
+	}
+	return r, transport
+}
+
+func TestCaptureError_TagsTraceAndUser(t *testing.T) {
+	r, transport := newTestReporter(t, 10)
+
+	tp := sdktrace.NewTracerProvider()
+	ctx, span := tp.Tracer("test").Start(context.Background(), "CreateOrder")
+	defer span.End()
+
+	r.CaptureError(ctx, errors.New("payment declined"), "user-1", map[string]string{"error.type": "processing_error"})
+
+	events := transport.sent()
+	if len(events) != 1 {
+		t.Fatalf("Expected 1 event, got %d", len(events))
+	}
+	event := events[0]
+	if event.Tags["trace_id"] != span.SpanContext().TraceID().String() {
+		t.Errorf("Expected trace_id tag, got %v", event.Tags)
+	}
+	if event.User.ID != "user-1" || event.Release != "order-service@test" {
+		t.Errorf("Unexpected user/release: %+v %s", event.User, event.Release)
+	}
+}
+
+func TestCaptureError_RateLimited(t *testing.T) {
+	r, transport := newTestReporter(t, 2)
+
+	for i := 0; i < 5; i++ {
+		r.CaptureError(context.Background(), errors.New("boom"), "", nil)
+	}
+
+	if got := len(transport.sent()); got != 2 {
+		t.Errorf("Expected 2 events after rate limiting, got %d", got)
+	}
+}
+
+func TestRecover_ReportsPanic(t *testing.T) {
+	r, transport := newTestReporter(t, 10)
+
+	handler := r.Recover(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		panic("nil map")
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/orders", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected 500, got %d", rec.Code)
+	}
+	if len(transport.sent()) != 1 {
+		t.Errorf("Expected the panic to be reported")
+	}
+}
+
+func TestDisabledReporter(t *testing.T) {
+	r, err := New(Config{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if r.Enabled() {
+		t.Error("Reporter without DSN should be disabled")
+	}
+	r.CaptureError(context.Background(), errors.New("ignored"), "", nil)
+	if err := r.Flush(context.Background()); err != nil {
+		t.Errorf("Flush on disabled reporter failed: %v", err)
+	}
+}
+
+func TestConfigFromEnv_ReleaseFromBuild(t *testing.T) {
+	t.Setenv("SERVICE_VERSION", "v1.4.2")
+	if got := ConfigFromEnv().Release; got != "order-service@v1.4.2" {
+		t.Errorf("Expected the build version as the release, got %q", got)
+	}
+	t.Setenv("SENTRY_RELEASE", "custom")
+	if got := ConfigFromEnv().Release; got != "custom" {
+		t.Errorf("Expected SENTRY_RELEASE to win, got %q", got)
+	}
+}
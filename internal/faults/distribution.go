@@ -0,0 +1,186 @@
+package faults
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Distribution produces simulated latencies for a downstream call
+type Distribution interface {
+	Sample(r *rand.Rand) time.Duration
+	String() string
+}
+
+// Uniform picks a latency evenly between Min and Max
+type Uniform struct {
+	Min, Max time.Duration
+}
+
+func (u Uniform) Sample(r *rand.Rand) time.Duration {
+	if u.Max <= u.Min {
+		return u.Min
+	}
+	return u.Min + time.Duration(r.Int63n(int64(u.Max-u.Min)))
+}
+
+func (u Uniform) String() string {
+	return fmt.Sprintf("uniform:%s,%s", u.Min, u.Max)
+}
+
+// LogNormal is right-skewed around Median, which is how most real service
+// latencies look. Sigma controls the spread of the tail.
+type LogNormal struct {
+	Median time.Duration
+	Sigma  float64
+}
+
+func (l LogNormal) Sample(r *rand.Rand) time.Duration {
+	return time.Duration(float64(l.Median) * math.Exp(l.Sigma*r.NormFloat64()))
+}
+
+func (l LogNormal) String() string {
+	return fmt.Sprintf("lognormal:%s,%g", l.Median, l.Sigma)
+}
+
+// Bimodal mixes a fast and a slow mode, e.g. cache hits and misses
+type Bimodal struct {
+	Fast, Slow Distribution
+	SlowRatio  float64
+}
+
+func (b Bimodal) Sample(r *rand.Rand) time.Duration {
+	if r.Float64() < b.SlowRatio {
+		return b.Slow.Sample(r)
+	}
+	return b.Fast.Sample(r)
+}
+
+func (b Bimodal) String() string {
+	return fmt.Sprintf("bimodal:%s|%s|%g", b.Fast, b.Slow, b.SlowRatio)
+}
+
+// Pareto has a heavy tail starting at Scale; a smaller Shape means a longer
+// tail. Max caps samples so a single draw cannot stall a request forever.
+type Pareto struct {
+	Scale time.Duration
+	Shape float64
+	Max   time.Duration
+}
+
+func (p Pareto) Sample(r *rand.Rand) time.Duration {
+	d := time.Duration(float64(p.Scale) / math.Pow(1-r.Float64(), 1/p.Shape))
+	if p.Max > 0 && d > p.Max {
+		return p.Max
+	}
+	return d
+}
+
+func (p Pareto) String() string {
+	return fmt.Sprintf("pareto:%s,%g,%s", p.Scale, p.Shape, p.Max)
+}
+
+// ParseDistribution parses specs such as:
+//
+//	uniform:30ms,80ms
+//	lognormal:60ms,0.5
+//	pareto:40ms,2.5,2s
+//	bimodal:lognormal:50ms,0.2|lognormal:800ms,0.3|0.1
+func ParseDistribution(spec string) (Distribution, error) {
+	kind, args, ok := strings.Cut(strings.TrimSpace(spec), ":")
+	if !ok {
+		return nil, fmt.Errorf("invalid distribution %q: expected kind:args", spec)
+	}
+
+	switch kind {
+	case "uniform":
+		parts, err := splitArgs(args, 2, 2)
+		if err != nil {
+			return nil, fmt.Errorf("invalid uniform distribution: %w", err)
+		}
+		minD, err := time.ParseDuration(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid uniform min: %w", err)
+		}
+		maxD, err := time.ParseDuration(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid uniform max: %w", err)
+		}
+		if maxD < minD {
+			return nil, fmt.Errorf("invalid uniform distribution: max %s is below min %s", maxD, minD)
+		}
+		return Uniform{Min: minD, Max: maxD}, nil
+
+	case "lognormal":
+		parts, err := splitArgs(args, 2, 2)
+		if err != nil {
+			return nil, fmt.Errorf("invalid lognormal distribution: %w", err)
+		}
+		median, err := time.ParseDuration(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid lognormal median: %w", err)
+		}
+		sigma, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil || sigma < 0 {
+			return nil, fmt.Errorf("invalid lognormal sigma %q", parts[1])
+		}
+		return LogNormal{Median: median, Sigma: sigma}, nil
+
+	case "pareto":
+		parts, err := splitArgs(args, 2, 3)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pareto distribution: %w", err)
+		}
+		scale, err := time.ParseDuration(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid pareto scale: %w", err)
+		}
+		shape, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil || shape <= 0 {
+			return nil, fmt.Errorf("invalid pareto shape %q", parts[1])
+		}
+		p := Pareto{Scale: scale, Shape: shape}
+		if len(parts) == 3 {
+			if p.Max, err = time.ParseDuration(parts[2]); err != nil {
+				return nil, fmt.Errorf("invalid pareto max: %w", err)
+			}
+		}
+		return p, nil
+
+	case "bimodal":
+		parts := strings.Split(args, "|")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid bimodal distribution %q: expected fast|slow|ratio", args)
+		}
+		fast, err := ParseDistribution(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid bimodal fast mode: %w", err)
+		}
+		slow, err := ParseDistribution(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid bimodal slow mode: %w", err)
+		}
+		ratio, err := strconv.ParseFloat(parts[2], 64)
+		if err != nil || ratio < 0 || ratio > 1 {
+			return nil, fmt.Errorf("invalid bimodal slow ratio %q", parts[2])
+		}
+		return Bimodal{Fast: fast, Slow: slow, SlowRatio: ratio}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown distribution kind %q", kind)
+	}
+}
+
+func splitArgs(args string, minArgs, maxArgs int) ([]string, error) {
+	parts := strings.Split(args, ",")
+	if len(parts) < minArgs || len(parts) > maxArgs {
+		return nil, fmt.Errorf("expected %d-%d arguments, got %d", minArgs, maxArgs, len(parts))
+	}
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	return parts, nil
+}
@@ -0,0 +1,123 @@
+package faults
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestParseDistribution(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    string
+		wantErr bool
+	}{
+		{spec: "uniform:30ms,80ms", want: "uniform:30ms,80ms"},
+		{spec: "lognormal:60ms,0.5", want: "lognormal:60ms,0.5"},
+		{spec: "pareto:40ms,2.5,2s", want: "pareto:40ms,2.5,2s"},
+		{spec: "bimodal:lognormal:50ms,0.2|lognormal:800ms,0.3|0.1", want: "bimodal:lognormal:50ms,0.2|lognormal:800ms,0.3|0.1"},
+		{spec: "uniform:80ms,30ms", wantErr: true},
+		{spec: "lognormal:60ms", wantErr: true},
+		{spec: "pareto:40ms,0", wantErr: true},
+		{spec: "bimodal:uniform:1ms,2ms|uniform:3ms,4ms|2", wantErr: true},
+		{spec: "gaussian:10ms", wantErr: true},
+		{spec: "50ms", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			dist, err := ParseDistribution(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error for %q", tt.spec)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if dist.String() != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, dist.String())
+			}
+		})
+	}
+}
+
+func TestDistributionsStayInRange(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+
+	uniform := Uniform{Min: 30 * time.Millisecond, Max: 80 * time.Millisecond}
+	pareto := Pareto{Scale: 40 * time.Millisecond, Shape: 1.5, Max: time.Second}
+	for i := 0; i < 1000; i++ {
+		if d := uniform.Sample(r); d < uniform.Min || d >= uniform.Max {
+			t.Fatalf("Uniform sample %v out of range", d)
+		}
+		if d := pareto.Sample(r); d < pareto.Scale || d > pareto.Max {
+			t.Fatalf("Pareto sample %v out of range", d)
+		}
+	}
+}
+
+func TestLogNormalMedian(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+	dist := LogNormal{Median: 100 * time.Millisecond, Sigma: 0.5}
+
+	below := 0
+	const n = 10000
+	for i := 0; i < n; i++ {
+		if dist.Sample(r) < dist.Median {
+			below++
+		}
+	}
+
+	if ratio := float64(below) / n; ratio < 0.45 || ratio > 0.55 {
+		t.Errorf("Expected about half the samples below the median, got %.2f", ratio)
+	}
+}
+
+func TestInjector(t *testing.T) {
+	inj := NewInjector(1, map[string]Step{
+		"always": {Latency: Uniform{Min: time.Millisecond, Max: time.Millisecond}, FailureRate: 1},
+	})
+
+	d, err := inj.Delay(context.Background(), "always")
+	if err != nil || d != time.Millisecond {
+		t.Errorf("Expected 1ms delay, got %v (%v)", d, err)
+	}
+	if !inj.ShouldFail("always") {
+		t.Error("Expected step with failure rate 1 to fail")
+	}
+	if inj.ShouldFail("unknown") {
+		t.Error("Unknown step should never fail")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	slow := NewInjector(1, map[string]Step{"slow": {Latency: Uniform{Min: time.Hour, Max: time.Hour}}})
+	if _, err := slow.Delay(ctx, "slow"); err == nil {
+		t.Error("Expected cancelled context to interrupt the delay")
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("FAULT_PAYMENT_LATENCY", "pareto:50ms,2")
+	t.Setenv("FAULT_PAYMENT_FAILURE_RATE", "0.25")
+
+	inj, err := FromEnv(map[string]Step{
+		"payment": {Latency: Uniform{Min: time.Millisecond, Max: 2 * time.Millisecond}},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	step, _ := inj.Step("payment")
+	if _, ok := step.Latency.(Pareto); !ok || step.FailureRate != 0.25 {
+		t.Errorf("Env overrides not applied: %+v", step)
+	}
+
+	t.Setenv("FAULT_PAYMENT_FAILURE_RATE", "2")
+	if _, err := FromEnv(map[string]Step{"payment": {}}); err == nil {
+		t.Error("Expected error for failure rate above 1")
+	}
+}
@@ -0,0 +1,107 @@
+package faults
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Step describes how a simulated downstream call behaves
+type Step struct {
+	Latency     Distribution
+	FailureRate float64
+}
+
+// Injector simulates latency and failures for named steps
+type Injector struct {
+	mu    sync.Mutex
+	rng   *rand.Rand
+	steps map[string]Step
+}
+
+func NewInjector(seed int64, steps map[string]Step) *Injector {
+	if steps == nil {
+		steps = make(map[string]Step)
+	}
+	return &Injector{
+		rng:   rand.New(rand.NewSource(seed)),
+		steps: steps,
+	}
+}
+
+// FromEnv starts from defaults and applies FAULT_<STEP>_LATENCY and
+// FAULT_<STEP>_FAILURE_RATE overrides, e.g. FAULT_PAYMENT_LATENCY=lognormal:120ms,0.6
+func FromEnv(defaults map[string]Step) (*Injector, error) {
+	steps := make(map[string]Step, len(defaults))
+	for name, step := range defaults {
+		prefix := "FAULT_" + strings.ToUpper(name) + "_"
+
+		if spec := os.Getenv(prefix + "LATENCY"); spec != "" {
+			dist, err := ParseDistribution(spec)
+			if err != nil {
+				return nil, fmt.Errorf("%sLATENCY: %w", prefix, err)
+			}
+			step.Latency = dist
+		}
+
+		if raw := os.Getenv(prefix + "FAILURE_RATE"); raw != "" {
+			rate, err := strconv.ParseFloat(raw, 64)
+			if err != nil || rate < 0 || rate > 1 {
+				return nil, fmt.Errorf("%sFAILURE_RATE: invalid rate %q", prefix, raw)
+			}
+			step.FailureRate = rate
+		}
+
+		steps[name] = step
+	}
+
+	return NewInjector(time.Now().UnixNano(), steps), nil
+}
+
+// Delay sleeps for a latency drawn from the step's distribution, returning
+// early with the cancellation cause if ctx is cancelled
+func (i *Injector) Delay(ctx context.Context, step string) (time.Duration, error) {
+	s, ok := i.steps[step]
+	if !ok || s.Latency == nil {
+		return 0, nil
+	}
+
+	i.mu.Lock()
+	d := s.Latency.Sample(i.rng)
+	i.mu.Unlock()
+	if d <= 0 {
+		return 0, nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return d, nil
+	case <-ctx.Done():
+		return d, context.Cause(ctx)
+	}
+}
+
+// ShouldFail reports whether this call of the step should fail
+func (i *Injector) ShouldFail(step string) bool {
+	s, ok := i.steps[step]
+	if !ok || s.FailureRate <= 0 {
+		return false
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.rng.Float64() < s.FailureRate
+}
+
+// Step returns the configuration of a step
+func (i *Injector) Step(step string) (Step, bool) {
+	s, ok := i.steps[step]
+	return s, ok
+}
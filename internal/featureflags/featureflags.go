@@ -0,0 +1,105 @@
+package featureflags
+
+import (
+	"context"
+	"fmt"
+	"go-observability-demo/internal/config"
+	"strconv"
+	"strings"
+
+	"github.com/open-feature/go-sdk/openfeature"
+	"github.com/open-feature/go-sdk/openfeature/memprovider"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// NewPaymentProvider routes payments through the new gateway
+const NewPaymentProvider = "new-payment-provider"
+
+const domain = "order-service"
+
+// Flags evaluates feature flags through OpenFeature and records every
+// evaluation as a span event and a counter increment, so a rollout shows
+// up in traces and dashboards. A nil *Flags returns the default for every
+// flag.
+type Flags struct {
+	client *openfeature.Client
+}
+
+func New(meter metric.Meter, provider openfeature.FeatureProvider) (*Flags, error) {
+	evaluations, err := meter.Int64Counter(
+		"feature_flag.evaluations",
+		metric.WithDescription("Feature flag evaluations by flag, variant, and reason"),
+		metric.WithUnit("{evaluation}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := openfeature.SetNamedProviderAndWait(domain, provider); err != nil {
+		return nil, fmt.Errorf("failed to set feature flag provider: %w", err)
+	}
+	client := openfeature.NewClient(domain)
+	client.AddHooks(&telemetryHook{evaluations: evaluations})
+
+	return &Flags{client: client}, nil
+}
+
+// Bool evaluates a boolean flag, targeting userID when it is set
+func (f *Flags) Bool(ctx context.Context, flag string, defaultValue bool, userID string) bool {
+	if f == nil {
+		return defaultValue
+	}
+	return f.client.Boolean(ctx, flag, defaultValue, openfeature.NewEvaluationContext(userID, nil))
+}
+
+// ProviderFromEnv builds a static provider from FEATURE_FLAGS, a list such
+// as "new-payment-provider=true". Values that are not booleans are served
+// as string variants.
+func ProviderFromEnv() openfeature.FeatureProvider {
+	flags := map[string]memprovider.InMemoryFlag{}
+	for _, entry := range config.List("FEATURE_FLAGS", nil) {
+		key, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+
+		var resolved any = value
+		if b, err := strconv.ParseBool(value); err == nil {
+			resolved = b
+		}
+		flags[key] = memprovider.InMemoryFlag{
+			Key:            key,
+			State:          memprovider.Enabled,
+			DefaultVariant: value,
+			Variants:       map[string]any{value: resolved},
+		}
+	}
+	return memprovider.NewInMemoryProvider(flags)
+}
+
+// telemetryHook follows the OpenTelemetry feature flag semantic conventions
+type telemetryHook struct {
+	openfeature.UnimplementedHook
+	evaluations metric.Int64Counter
+}
+
+func (h *telemetryHook) Finally(ctx context.Context, hc openfeature.HookContext, details openfeature.InterfaceEvaluationDetails, _ openfeature.HookHints) {
+	attrs := []attribute.KeyValue{
+		attribute.String("feature_flag.key", hc.FlagKey()),
+		attribute.String("feature_flag.provider.name", hc.ProviderMetadata().Name),
+		attribute.String("feature_flag.result.variant", details.Variant),
+		attribute.String("feature_flag.result.reason", strings.ToLower(string(details.Reason))),
+	}
+	if details.ErrorCode != "" {
+		attrs = append(attrs, attribute.String("error.type", strings.ToLower(string(details.ErrorCode))))
+	}
+	h.evaluations.Add(ctx, 1, metric.WithAttributes(attrs...))
+
+	trace.SpanFromContext(ctx).AddEvent("feature_flag.evaluation", trace.WithAttributes(
+		append(attrs, attribute.String("feature_flag.result.value", fmt.Sprint(details.Value)))...,
+	))
+}
@@ -0,0 +1,54 @@
+package featureflags
+
+import (
+	"context"
+	"go-observability-demo/internal/observability/observabilitytest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+)
+
+func TestFlags_RecordsEvaluations(t *testing.T) {
+	recorder := observabilitytest.New(t)
+	t.Setenv("FEATURE_FLAGS", "new-payment-provider=true, checkout-v2=false")
+
+	flags, err := New(otel.Meter("test"), ProviderFromEnv())
+	if err != nil {
+		t.Fatalf("Failed to create flags: %v", err)
+	}
+
+	ctx, span := otel.Tracer("test").Start(context.Background(), "ProcessPayment")
+	enabled := flags.Bool(ctx, NewPaymentProvider, false, "user-1")
+	checkout := flags.Bool(ctx, "checkout-v2", true, "user-1")
+	missing := flags.Bool(ctx, "unknown-flag", true, "user-1")
+	span.End()
+
+	if !enabled || checkout || !missing {
+		t.Errorf("Unexpected flag values: %v %v %v", enabled, checkout, missing)
+	}
+
+	events := recorder.SpansNamed("ProcessPayment")[0].Events
+	if len(events) != 3 || events[0].Name != "feature_flag.evaluation" {
+		t.Fatalf("Expected 3 feature_flag.evaluation events, got %+v", events)
+	}
+	var errorType string
+	for _, attr := range events[2].Attributes {
+		if attr.Key == "error.type" {
+			errorType = attr.Value.AsString()
+		}
+	}
+	if errorType != "flag_not_found" {
+		t.Errorf("Expected flag_not_found on the unknown flag, got %q", errorType)
+	}
+
+	if got := recorder.Int64Sum(t, "feature_flag.evaluations"); got != 3 {
+		t.Errorf("Expected 3 evaluations counted, got %d", got)
+	}
+}
+
+func TestFlags_NilReturnsDefault(t *testing.T) {
+	var flags *Flags
+	if !flags.Bool(context.Background(), NewPaymentProvider, true, "") {
+		t.Error("A nil Flags should return the default value")
+	}
+}
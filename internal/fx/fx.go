@@ -0,0 +1,353 @@
+package fx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-observability-demo/internal/config"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ReportingCurrency is the currency of catalog prices and payment metrics
+const ReportingCurrency = "USD"
+
+var (
+	ErrUnsupportedCurrency = errors.New("unsupported currency")
+	ErrStaleRates          = errors.New("exchange rates are stale")
+)
+
+// Source returns exchange rates as units of each currency per one unit of
+// ReportingCurrency, e.g. {"EUR": 0.92}
+type Source interface {
+	Rates(ctx context.Context) (map[string]float64, error)
+}
+
+// StaticSource serves fixed rates, for the local demo and tests
+type StaticSource map[string]float64
+
+func (s StaticSource) Rates(context.Context) (map[string]float64, error) {
+	return s, nil
+}
+
+// HTTPSource fetches rates from a JSON API with a "base" and a "rates"
+// object, such as Frankfurter (https://api.frankfurter.app/latest?from=USD)
+type HTTPSource struct {
+	URL    string
+	Client *http.Client
+}
+
+func (s HTTPSource) Rates(ctx context.Context) (map[string]float64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("rates endpoint returned %s", resp.Status)
+	}
+
+	var body struct {
+		Base  string             `json:"base"`
+		Rates map[string]float64 `json:"rates"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode rates: %w", err)
+	}
+	if body.Base != "" && !strings.EqualFold(body.Base, ReportingCurrency) {
+		return nil, fmt.Errorf("rates are based on %s, not %s", body.Base, ReportingCurrency)
+	}
+	return body.Rates, nil
+}
+
+// Config controls where rates come from and how old they may get
+type Config struct {
+	// RatesURL is fetched with HTTPSource; when empty StaticRates are used
+	RatesURL        string
+	StaticRates     map[string]float64
+	RefreshInterval time.Duration
+	// MaxStaleness is how long the last good rates keep being used while
+	// refreshes fail; conversions fail beyond it
+	MaxStaleness time.Duration
+}
+
+// ConfigFromEnv reads FX_RATES_URL, FX_RATES ("EUR=0.92,GBP=0.79"),
+// FX_REFRESH_INTERVAL, and FX_MAX_STALENESS
+func ConfigFromEnv() Config {
+	cfg := Config{
+		RatesURL:        config.String("FX_RATES_URL", ""),
+		StaticRates:     map[string]float64{},
+		RefreshInterval: config.Duration("FX_REFRESH_INTERVAL", 10*time.Minute),
+		MaxStaleness:    config.Duration("FX_MAX_STALENESS", 24*time.Hour),
+	}
+	for _, entry := range config.List("FX_RATES", []string{"EUR=0.92", "GBP=0.79", "JPY=149.50", "CAD=1.37"}) {
+		code, raw, ok := strings.Cut(entry, "=")
+		if rate, err := strconv.ParseFloat(strings.TrimSpace(raw), 64); ok && err == nil && rate > 0 {
+			cfg.StaticRates[strings.ToUpper(strings.TrimSpace(code))] = rate
+		}
+	}
+	return cfg
+}
+
+// Source returns the HTTP source when FX_RATES_URL is set and the static
+// rates otherwise
+func (c Config) Source(client *http.Client) Source {
+	if c.RatesURL != "" {
+		return HTTPSource{URL: c.RatesURL, Client: client}
+	}
+	return StaticSource(c.StaticRates)
+}
+
+// Converter converts amounts using cached rates refreshed in the
+// background. A failed refresh keeps the last good rates until they are
+// older than MaxStaleness; their age is exported as fx.rates.age. A nil
+// *Converter only handles ReportingCurrency.
+type Converter struct {
+	cfg    Config
+	source Source
+	logger *slog.Logger
+	tracer trace.Tracer
+
+	conversions metric.Int64Counter
+	refreshes   metric.Int64Counter
+
+	mu        sync.RWMutex
+	rates     map[string]float64
+	fetchedAt time.Time
+	refreshMu sync.Mutex // one refresh at a time
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+func New(cfg Config, source Source, meter metric.Meter, logger *slog.Logger) (*Converter, error) {
+	if cfg.RefreshInterval <= 0 {
+		cfg.RefreshInterval = 10 * time.Minute
+	}
+	if cfg.MaxStaleness <= 0 {
+		cfg.MaxStaleness = 24 * time.Hour
+	}
+
+	c := &Converter{
+		cfg:    cfg,
+		source: source,
+		logger: logger,
+		tracer: otel.Tracer("order-service/fx"),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	var err error
+	c.conversions, err = meter.Int64Counter(
+		"fx.conversions",
+		metric.WithDescription("Currency conversions, by currency and outcome (converted, stale, unsupported)"),
+		metric.WithUnit("{conversion}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	c.refreshes, err = meter.Int64Counter(
+		"fx.refreshes",
+		metric.WithDescription("Exchange rate refreshes, by outcome"),
+		metric.WithUnit("{refresh}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	age, err := meter.Float64ObservableGauge(
+		"fx.rates.age",
+		metric.WithDescription("Time since exchange rates were last refreshed"),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		c.mu.RLock()
+		fetchedAt := c.fetchedAt
+		c.mu.RUnlock()
+		if !fetchedAt.IsZero() {
+			o.ObserveFloat64(age, time.Since(fetchedAt).Seconds())
+		}
+		return nil
+	}, age)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Start loads the rates and then refreshes them on the configured interval
+// until Stop is called
+func (c *Converter) Start() {
+	go func() {
+		defer close(c.done)
+		_ = c.Refresh(context.Background())
+		ticker := time.NewTicker(c.cfg.RefreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.stop:
+				return
+			case <-ticker.C:
+				_ = c.Refresh(context.Background())
+			}
+		}
+	}()
+}
+
+func (c *Converter) Stop(ctx context.Context) error {
+	c.once.Do(func() { close(c.stop) })
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Refresh fetches new rates, keeping the current ones on failure
+func (c *Converter) Refresh(ctx context.Context) error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	ctx, span := c.tracer.Start(ctx, "RefreshFXRates")
+	defer span.End()
+
+	rates, err := c.source.Rates(ctx)
+	if err == nil && len(rates) == 0 {
+		err = errors.New("no rates returned")
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "rate refresh failed")
+		c.refreshes.Add(ctx, 1, metric.WithAttributes(attribute.String("outcome", "failed")))
+		c.logger.Warn("exchange rate refresh failed, keeping previous rates",
+			slog.String("error", err.Error()),
+			slog.Duration("rates_age", c.age()),
+		)
+		return err
+	}
+
+	normalized := make(map[string]float64, len(rates))
+	for code, rate := range rates {
+		normalized[strings.ToUpper(code)] = rate
+	}
+	c.mu.Lock()
+	c.rates = normalized
+	c.fetchedAt = time.Now()
+	c.mu.Unlock()
+
+	span.SetAttributes(attribute.Int("fx.currencies", len(normalized)))
+	c.refreshes.Add(ctx, 1, metric.WithAttributes(attribute.String("outcome", "refreshed")))
+	return nil
+}
+
+func (c *Converter) age() time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.fetchedAt.IsZero() {
+		return 0
+	}
+	return time.Since(c.fetchedAt)
+}
+
+// Convert converts amount between currencies, rounded to cents. Errors wrap
+// ErrUnsupportedCurrency or ErrStaleRates.
+func (c *Converter) Convert(ctx context.Context, amount float64, from, to string) (float64, error) {
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+	if from == to {
+		return amount, nil
+	}
+	if c == nil {
+		return 0, fmt.Errorf("%w: %s", ErrUnsupportedCurrency, other(from, to))
+	}
+
+	ctx, span := c.tracer.Start(ctx, "ConvertCurrency", trace.WithAttributes(
+		attribute.String("currency.from", from),
+		attribute.String("currency.to", to),
+	))
+	defer span.End()
+
+	c.mu.RLock()
+	rates, fetchedAt := c.rates, c.fetchedAt
+	c.mu.RUnlock()
+	if fetchedAt.IsZero() {
+		// First order before Start has loaded anything
+		if err := c.Refresh(ctx); err == nil {
+			c.mu.RLock()
+			rates, fetchedAt = c.rates, c.fetchedAt
+			c.mu.RUnlock()
+		}
+	}
+
+	age := time.Since(fetchedAt)
+	span.SetAttributes(attribute.Float64("fx.rates_age_seconds", age.Seconds()))
+
+	outcome := "converted"
+	var err error
+	fromRate, fromOK := rateOf(rates, from)
+	toRate, toOK := rateOf(rates, to)
+	switch {
+	case !fromOK || !toOK:
+		outcome = "unsupported"
+		err = fmt.Errorf("%w: %s", ErrUnsupportedCurrency, other(from, to))
+	case fetchedAt.IsZero() || age > c.cfg.MaxStaleness:
+		outcome = "stale"
+		err = ErrStaleRates
+	}
+
+	// Unsupported codes come from clients; keep them out of the label
+	label := other(from, to)
+	if outcome == "unsupported" {
+		label = "other"
+	}
+	c.conversions.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("currency", label),
+		attribute.String("outcome", outcome),
+	))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return 0, err
+	}
+
+	rate := toRate / fromRate
+	span.SetAttributes(attribute.Float64("fx.rate", rate))
+	return math.Round(amount*rate*100) / 100, nil
+}
+
+func rateOf(rates map[string]float64, code string) (float64, bool) {
+	if code == ReportingCurrency {
+		return 1, true
+	}
+	rate, ok := rates[code]
+	return rate, ok && rate > 0
+}
+
+// other returns the side of a conversion that isn't the reporting currency
+func other(from, to string) string {
+	if from == ReportingCurrency {
+		return to
+	}
+	return from
+}
@@ -0,0 +1,120 @@
+package fx
+
+import (
+	"context"
+	"errors"
+	"go-observability-demo/internal/observability/observabilitytest"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+)
+
+type flakySource struct {
+	rates map[string]float64
+	err   error
+}
+
+func (s *flakySource) Rates(context.Context) (map[string]float64, error) {
+	return s.rates, s.err
+}
+
+func newTestConverter(t *testing.T, source Source, cfg Config) (*Converter, *observabilitytest.Recorder) {
+	t.Helper()
+	recorder := observabilitytest.New(t)
+	c, err := New(cfg, source, otel.Meter("test"), recorder.Logger)
+	if err != nil {
+		t.Fatalf("Failed to create converter: %v", err)
+	}
+	return c, recorder
+}
+
+func TestConverter_ConvertsBothWays(t *testing.T) {
+	c, recorder := newTestConverter(t, StaticSource{"EUR": 0.5, "GBP": 0.25}, Config{})
+	ctx := context.Background()
+
+	tests := []struct {
+		amount   float64
+		from, to string
+		want     float64
+	}{
+		{10, "USD", "EUR", 5},
+		{5, "eur", "USD", 10},
+		{4, "EUR", "GBP", 2},
+		{7, "USD", "USD", 7},
+	}
+	for _, tt := range tests {
+		got, err := c.Convert(ctx, tt.amount, tt.from, tt.to)
+		if err != nil || got != tt.want {
+			t.Errorf("Convert(%v %s -> %s): expected %v, got %v (%v)", tt.amount, tt.from, tt.to, tt.want, got, err)
+		}
+	}
+
+	if _, err := c.Convert(ctx, 1, "XYZ", "USD"); !errors.Is(err, ErrUnsupportedCurrency) {
+		t.Errorf("Expected ErrUnsupportedCurrency, got %v", err)
+	}
+	if got := len(recorder.SpansNamed("ConvertCurrency")); got != 4 {
+		t.Errorf("Expected 4 ConvertCurrency spans, got %d", got)
+	}
+	if got := recorder.GaugeValue(t, "fx.rates.age"); got < 0 || got > 1 {
+		t.Errorf("Expected fresh rates, got age %vs", got)
+	}
+}
+
+func TestConverter_KeepsStaleRatesUntilMaxStaleness(t *testing.T) {
+	source := &flakySource{rates: map[string]float64{"EUR": 0.5}}
+	c, recorder := newTestConverter(t, source, Config{MaxStaleness: time.Hour})
+	ctx := context.Background()
+	if err := c.Refresh(ctx); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+
+	source.err = errors.New("connection refused")
+	if err := c.Refresh(ctx); err == nil {
+		t.Fatal("Expected the refresh to fail")
+	}
+	if got, err := c.Convert(ctx, 10, "USD", "EUR"); err != nil || got != 5 {
+		t.Errorf("Expected the previous rates to be used, got %v (%v)", got, err)
+	}
+
+	c.mu.Lock()
+	c.fetchedAt = time.Now().Add(-2 * time.Hour)
+	c.mu.Unlock()
+	if _, err := c.Convert(ctx, 10, "USD", "EUR"); !errors.Is(err, ErrStaleRates) {
+		t.Errorf("Expected ErrStaleRates, got %v", err)
+	}
+	if got := recorder.GaugeValue(t, "fx.rates.age"); got < 7200 {
+		t.Errorf("Expected fx.rates.age of 2h, got %vs", got)
+	}
+}
+
+func TestNilConverter_OnlyReportingCurrency(t *testing.T) {
+	var c *Converter
+	if got, err := c.Convert(context.Background(), 3, "USD", "usd"); err != nil || got != 3 {
+		t.Errorf("Expected 3 USD, got %v (%v)", got, err)
+	}
+	if _, err := c.Convert(context.Background(), 3, "EUR", "USD"); !errors.Is(err, ErrUnsupportedCurrency) {
+		t.Errorf("Expected ErrUnsupportedCurrency, got %v", err)
+	}
+}
+
+func TestHTTPSource(t *testing.T) {
+	body := `{"amount":1.0,"base":"USD","date":"2024-01-02","rates":{"EUR":0.91}}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+	source := HTTPSource{URL: server.URL, Client: server.Client()}
+
+	rates, err := source.Rates(context.Background())
+	if err != nil || rates["EUR"] != 0.91 {
+		t.Errorf("Expected EUR 0.91, got %v (%v)", rates, err)
+	}
+
+	body = `{"base":"EUR","rates":{"USD":1.1}}`
+	if _, err := source.Rates(context.Background()); err == nil {
+		t.Error("Expected rates in another base to be rejected")
+	}
+}
@@ -0,0 +1,98 @@
+package gctuning
+
+import (
+	"log/slog"
+	"math"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"strings"
+
+	"go-observability-demo/internal/config"
+)
+
+// Cgroup files holding the container memory limit (v2 first, then v1)
+var cgroupLimitFiles = []string{
+	"/sys/fs/cgroup/memory.max",
+	"/sys/fs/cgroup/memory/memory.limit_in_bytes",
+}
+
+// Settings is the effective GC configuration after Configure
+type Settings struct {
+	GCPercent        int
+	MemoryLimit      int64
+	ContainerLimit   int64
+	MemoryLimitFrom  string
+	GCPercentChanged bool
+}
+
+// Configure applies optional GC tuning from the environment:
+//
+//	GC_PERCENT             overrides GOGC (e.g. 200 trades memory for fewer cycles)
+//	GC_MEMORY_LIMIT_RATIO  sets a soft memory limit as a fraction of the
+//	                       container limit (e.g. 0.9), unless GOMEMLIMIT is set
+//
+// With neither set the runtime defaults are left untouched.
+func Configure(logger *slog.Logger) Settings {
+	var s Settings
+
+	if percent := config.Int("GC_PERCENT", 0); percent != 0 {
+		debug.SetGCPercent(percent)
+		s.GCPercentChanged = true
+	}
+	// Reading the value back leaves it unchanged
+	s.GCPercent = debug.SetGCPercent(-1)
+	debug.SetGCPercent(s.GCPercent)
+
+	s.ContainerLimit = containerMemoryLimit()
+	ratio := config.Float("GC_MEMORY_LIMIT_RATIO", 0)
+
+	switch {
+	case os.Getenv("GOMEMLIMIT") != "":
+		s.MemoryLimitFrom = "GOMEMLIMIT"
+	case ratio > 0 && ratio <= 1 && s.ContainerLimit > 0:
+		debug.SetMemoryLimit(int64(float64(s.ContainerLimit) * ratio))
+		s.MemoryLimitFrom = "container"
+	case ratio > 0:
+		logger.Warn("GC_MEMORY_LIMIT_RATIO ignored",
+			slog.Float64("ratio", ratio),
+			slog.Int64("container_limit_bytes", s.ContainerLimit),
+		)
+	}
+	s.MemoryLimit = debug.SetMemoryLimit(-1)
+
+	logger.Info("GC settings",
+		slog.Int("gc_percent", s.GCPercent),
+		slog.Bool("gc_percent_overridden", s.GCPercentChanged),
+		slog.Int64("memory_limit_bytes", s.MemoryLimit),
+		slog.Int64("container_limit_bytes", s.ContainerLimit),
+		slog.String("memory_limit_source", s.MemoryLimitFrom),
+	)
+	return s
+}
+
+// containerMemoryLimit returns the cgroup memory limit, or 0 when unlimited
+// or not running in a container
+func containerMemoryLimit() int64 {
+	for _, path := range cgroupLimitFiles {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		return parseCgroupLimit(string(raw))
+	}
+	return 0
+}
+
+func parseCgroupLimit(raw string) int64 {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || raw == "max" {
+		return 0
+	}
+	limit, err := strconv.ParseInt(raw, 10, 64)
+	// cgroup v1 reports "unlimited" as a huge page-aligned number
+	if err != nil || limit <= 0 || limit >= math.MaxInt64/2 {
+		return 0
+	}
+	return limit
+}
@@ -0,0 +1,71 @@
+package gctuning
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestParseCgroupLimit(t *testing.T) {
+	tests := map[string]int64{
+		"max\n":               0,
+		"536870912\n":         536870912,
+		"9223372036854771712": 0, // cgroup v1 "unlimited"
+		"":                    0,
+		"garbage":             0,
+	}
+
+	for raw, want := range tests {
+		if got := parseCgroupLimit(raw); got != want {
+			t.Errorf("parseCgroupLimit(%q) = %d, want %d", raw, got, want)
+		}
+	}
+}
+
+func TestHistogramQuantile(t *testing.T) {
+	buckets := []float64{math.Inf(-1), 0.001, 0.01, 0.1, math.Inf(1)}
+	counts := []uint64{0, 98, 1, 1}
+
+	if got := histogramQuantile(buckets, counts, 0.5); got != 0.01 {
+		t.Errorf("Expected p50 bucket bound 0.01, got %v", got)
+	}
+	if got := histogramQuantile(buckets, counts, 0.99); got != 0.1 {
+		t.Errorf("Expected p99 bucket bound 0.1, got %v", got)
+	}
+	if got := histogramQuantile(buckets, counts, 1); got != 0.1 {
+		t.Errorf("Expected open-ended bucket to fall back to its lower bound, got %v", got)
+	}
+	if got := histogramQuantile(buckets, []uint64{0, 0, 0, 0}, 0.99); got != 0 {
+		t.Errorf("Expected 0 for an empty histogram, got %v", got)
+	}
+}
+
+func TestDeltaCounts(t *testing.T) {
+	delta := deltaCounts([]uint64{1, 2, 3}, []uint64{1, 5, 4})
+	if delta[0] != 0 || delta[1] != 3 || delta[2] != 1 {
+		t.Errorf("Unexpected delta %v", delta)
+	}
+}
+
+func TestPauseHistory_Baseline(t *testing.T) {
+	var p pauseHistory
+	start := time.Now()
+	at := func(d time.Duration, pauses uint64) []uint64 {
+		return p.baseline(start.Add(d), []uint64{pauses})
+	}
+
+	if got := at(0, 1); got[0] != 1 {
+		t.Errorf("Expected the first reading as the baseline, got %v", got)
+	}
+	// A second reader right after the first sees the same window
+	at(30*time.Second, 5)
+	if got := at(30*time.Second+time.Millisecond, 5); got[0] != 1 {
+		t.Errorf("Expected both readers to share the baseline, got %v", got)
+	}
+	if got := at(90*time.Second, 9); got[0] != 5 {
+		t.Errorf("Expected the reading a minute back as the baseline, got %v", got)
+	}
+	if got := len(p.snapshots); got > 13 {
+		t.Errorf("Expected the history to stay bounded, got %d snapshots", got)
+	}
+}
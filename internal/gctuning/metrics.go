@@ -0,0 +1,160 @@
+package gctuning
+
+import (
+	"context"
+	"math"
+	"runtime/metrics"
+	"slices"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	gcPausesMetric   = "/sched/pauses/total/gc:seconds"
+	gcCyclesMetric   = "/gc/cycles/total:gc-cycle"
+	pauseQuantileP99 = 0.99
+	// pauseWindow is how far back go.gc.pause.p99 looks
+	pauseWindow = time.Minute
+)
+
+// RegisterMetrics exposes GC pause impact as observable instruments. The
+// pause quantile is computed over roughly the last pauseWindow, whatever
+// reads it and how often, so it tracks recent behaviour rather than the
+// whole process lifetime. Heap goal, memory limit, and GOGC come with the
+// runtime metrics (RUNTIME_METRICS_ENABLED).
+func RegisterMetrics(meter metric.Meter) error {
+	pauseP99, err := meter.Float64ObservableGauge(
+		"go.gc.pause.p99",
+		metric.WithDescription("99th percentile stop-the-world GC pause over the last minute"),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return err
+	}
+
+	pauseTotal, err := meter.Float64ObservableCounter(
+		"go.gc.pause.total",
+		metric.WithDescription("Approximate cumulative stop-the-world GC pause time"),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return err
+	}
+
+	cycles, err := meter.Int64ObservableCounter(
+		"go.gc.cycles",
+		metric.WithDescription("Completed GC cycles"),
+		metric.WithUnit("{cycle}"),
+	)
+	if err != nil {
+		return err
+	}
+
+	samples := []metrics.Sample{
+		{Name: gcPausesMetric},
+		{Name: gcCyclesMetric},
+	}
+	var mu sync.Mutex
+	var window pauseHistory
+
+	_, err = meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		metrics.Read(samples)
+
+		if hist := samples[0].Value; hist.Kind() == metrics.KindFloat64Histogram {
+			h := hist.Float64Histogram()
+			recent := deltaCounts(window.baseline(time.Now(), h.Counts), h.Counts)
+			o.ObserveFloat64(pauseP99, histogramQuantile(h.Buckets, recent, pauseQuantileP99))
+			o.ObserveFloat64(pauseTotal, histogramSum(h.Buckets, h.Counts))
+		}
+		if v := samples[1].Value; v.Kind() == metrics.KindUint64 {
+			o.ObserveInt64(cycles, int64(v.Uint64()))
+		}
+		return nil
+	}, pauseP99, pauseTotal, cycles)
+	return err
+}
+
+// pauseHistory keeps snapshots of the cumulative pause histogram, so every
+// reader gets the pauses of the same window instead of those since some
+// other reader's last collection
+type pauseHistory struct {
+	snapshots []pauseSnapshot
+}
+
+type pauseSnapshot struct {
+	at     time.Time
+	counts []uint64
+}
+
+// baseline records counts and returns the newest snapshot at least
+// pauseWindow old, or the oldest one while the process is younger
+func (p *pauseHistory) baseline(now time.Time, counts []uint64) []uint64 {
+	// Snapshots a twelfth of the window apart bound the history however
+	// often the metrics are read
+	if n := len(p.snapshots); n == 0 || now.Sub(p.snapshots[n-1].at) >= pauseWindow/12 {
+		p.snapshots = append(p.snapshots, pauseSnapshot{at: now, counts: slices.Clone(counts)})
+	}
+	for len(p.snapshots) > 1 && now.Sub(p.snapshots[1].at) >= pauseWindow {
+		p.snapshots = p.snapshots[1:]
+	}
+	return p.snapshots[0].counts
+}
+
+func deltaCounts(previous, current []uint64) []uint64 {
+	if len(previous) != len(current) {
+		return current
+	}
+	delta := make([]uint64, len(current))
+	for i := range current {
+		delta[i] = current[i] - previous[i]
+	}
+	return delta
+}
+
+// histogramQuantile returns the upper bound of the bucket containing quantile
+// q; buckets has one more element than counts
+func histogramQuantile(buckets []float64, counts []uint64, q float64) float64 {
+	var total uint64
+	for _, c := range counts {
+		total += c
+	}
+	if total == 0 {
+		return 0
+	}
+
+	threshold := uint64(math.Ceil(q * float64(total)))
+	var seen uint64
+	for i, c := range counts {
+		seen += c
+		if seen >= threshold {
+			return finiteBound(buckets[i+1], buckets[i])
+		}
+	}
+	return 0
+}
+
+// histogramSum approximates the total from bucket midpoints
+func histogramSum(buckets []float64, counts []uint64) float64 {
+	var sum float64
+	for i, c := range counts {
+		if c == 0 {
+			continue
+		}
+		lower := finiteBound(buckets[i], 0)
+		upper := finiteBound(buckets[i+1], lower)
+		sum += float64(c) * (lower + upper) / 2
+	}
+	return sum
+}
+
+func finiteBound(v, fallback float64) float64 {
+	if math.IsInf(v, 0) {
+		return fallback
+	}
+	return v
+}
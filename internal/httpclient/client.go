@@ -0,0 +1,166 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"fmt"
+	"go-observability-demo/internal/config"
+	"go-observability-demo/internal/observability"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// Config tunes the transport of a downstream client. http.DefaultTransport
+// keeps only 2 idle connections per host, which forces new TCP/TLS
+// handshakes as soon as more than two calls to the same peer overlap.
+type Config struct {
+	Timeout               time.Duration
+	MaxIdleConns          int
+	MaxIdleConnsPerHost   int
+	MaxConnsPerHost       int
+	IdleConnTimeout       time.Duration
+	DialTimeout           time.Duration
+	KeepAlive             time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ExpectContinueTimeout time.Duration
+	ResponseHeaderTimeout time.Duration
+	// TLS is set for mTLS between the demo services; nil keeps the default
+	TLS *tls.Config
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Timeout:               5 * time.Second,
+		MaxIdleConns:          200,
+		MaxIdleConnsPerHost:   64,
+		IdleConnTimeout:       90 * time.Second,
+		DialTimeout:           2 * time.Second,
+		KeepAlive:             30 * time.Second,
+		TLSHandshakeTimeout:   3 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+}
+
+// mtlsPeers are the demo services that expect our client certificate;
+// third-party APIs such as fx, shipping, or webhooks don't
+var mtlsPeers = map[string]bool{
+	"PAYMENT_CLIENT_":   true,
+	"INVENTORY_CLIENT_": true,
+}
+
+// ConfigFromEnv reads <PREFIX>_TIMEOUT, <PREFIX>_MAX_IDLE_CONNS_PER_HOST, and
+// so on, e.g. ConfigFromEnv("PAYMENT_CLIENT"). When MTLS_CERT_FILE is set the
+// payment and inventory clients present that certificate, as does any client
+// with <PREFIX>_MTLS=true, and unreadable certificates are an error.
+func ConfigFromEnv(prefix string) (Config, error) {
+	d := DefaultConfig()
+	p := strings.ToUpper(prefix) + "_"
+
+	var tlsCfg *tls.Config
+	if mtls, ok := observability.MTLSConfigFromEnv(); ok && config.Bool(p+"MTLS", mtlsPeers[p]) {
+		var err error
+		if tlsCfg, err = mtls.ClientTLS(); err != nil {
+			return Config{}, fmt.Errorf("client mTLS: %w", err)
+		}
+	}
+
+	return Config{
+		Timeout:               config.Duration(p+"TIMEOUT", d.Timeout),
+		MaxIdleConns:          config.Int(p+"MAX_IDLE_CONNS", d.MaxIdleConns),
+		MaxIdleConnsPerHost:   config.Int(p+"MAX_IDLE_CONNS_PER_HOST", d.MaxIdleConnsPerHost),
+		MaxConnsPerHost:       config.Int(p+"MAX_CONNS_PER_HOST", d.MaxConnsPerHost),
+		IdleConnTimeout:       config.Duration(p+"IDLE_CONN_TIMEOUT", d.IdleConnTimeout),
+		DialTimeout:           config.Duration(p+"DIAL_TIMEOUT", d.DialTimeout),
+		KeepAlive:             config.Duration(p+"KEEP_ALIVE", d.KeepAlive),
+		TLSHandshakeTimeout:   config.Duration(p+"TLS_HANDSHAKE_TIMEOUT", d.TLSHandshakeTimeout),
+		ExpectContinueTimeout: config.Duration(p+"EXPECT_CONTINUE_TIMEOUT", d.ExpectContinueTimeout),
+		ResponseHeaderTimeout: config.Duration(p+"RESPONSE_HEADER_TIMEOUT", d.ResponseHeaderTimeout),
+		TLS:                   tlsCfg,
+	}, nil
+}
+
+// NewTransport builds a tuned *http.Transport from cfg
+func NewTransport(cfg Config) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   cfg.DialTimeout,
+		KeepAlive: cfg.KeepAlive,
+	}
+
+	return &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          cfg.MaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       cfg.MaxConnsPerHost,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		TLSHandshakeTimeout:   cfg.TLSHandshakeTimeout,
+		ExpectContinueTimeout: cfg.ExpectContinueTimeout,
+		ResponseHeaderTimeout: cfg.ResponseHeaderTimeout,
+		TLSClientConfig:       cfg.TLS,
+	}
+}
+
+// New returns a traced client for the named downstream. Every connection the
+// transport hands out is counted with reused=true|false, so a healthy
+// keep-alive setup shows almost only reused connections under steady load.
+// Over mTLS the server's identity is recorded on the client span.
+func New(name string, cfg Config, metrics *observability.Metrics) *http.Client {
+	return &http.Client{
+		Transport: otelhttp.NewTransport(&connTrackingTransport{
+			base:    NewTransport(cfg),
+			counter: metrics.ConnectionsAcquired,
+			reused:  connAttrs(name, true),
+			fresh:   connAttrs(name, false),
+		}),
+		Timeout: cfg.Timeout,
+	}
+}
+
+func connAttrs(name string, reused bool) metric.MeasurementOption {
+	return metric.WithAttributeSet(attribute.NewSet(
+		attribute.String("client", name),
+		attribute.Bool("reused", reused),
+	))
+}
+
+type connTrackingTransport struct {
+	base    http.RoundTripper
+	counter metric.Int64Counter
+	reused  metric.MeasurementOption
+	fresh   metric.MeasurementOption
+}
+
+func (t *connTrackingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				t.counter.Add(ctx, 1, t.reused)
+			} else {
+				t.counter.Add(ctx, 1, t.fresh)
+			}
+		},
+	}
+	resp, err := t.base.RoundTrip(req.WithContext(httptrace.WithClientTrace(ctx, trace)))
+	if err == nil {
+		if id := observability.PeerIdentity(resp.TLS); id != "" {
+			oteltrace.SpanFromContext(ctx).SetAttributes(attribute.String("tls.server.identity", id))
+		}
+	}
+	return resp, err
+}
+
+// CloseIdleConnections lets http.Client.CloseIdleConnections reach the base transport
+func (t *connTrackingTransport) CloseIdleConnections() {
+	if c, ok := t.base.(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,98 @@
+package httpclient
+
+import (
+	"go-observability-demo/internal/observability"
+	"go-observability-demo/internal/observability/observabilitytest"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestClient_CountsConnectionReuse(t *testing.T) {
+	recorder := observabilitytest.New(t)
+	metrics, err := observability.NewMetrics()
+	if err != nil {
+		t.Fatalf("Failed to create metrics: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	client := New("payment", DefaultConfig(), metrics)
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	m, ok := recorder.Metric(t, "http.client.connections.acquired")
+	if !ok {
+		t.Fatal("Connection metric not recorded")
+	}
+
+	counts := map[bool]int64{}
+	for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+		reused, _ := dp.Attributes.Value(attribute.Key("reused"))
+		counts[reused.AsBool()] += dp.Value
+	}
+	if counts[false] != 1 || counts[true] != 2 {
+		t.Errorf("Expected 1 new and 2 reused connections, got %v", counts)
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("PAYMENT_CLIENT_MAX_IDLE_CONNS_PER_HOST", "128")
+	t.Setenv("PAYMENT_CLIENT_DIAL_TIMEOUT", "750ms")
+	t.Setenv("PAYMENT_CLIENT_TIMEOUT", "not-a-duration")
+
+	cfg, err := ConfigFromEnv("payment_client")
+	if err != nil {
+		t.Fatalf("ConfigFromEnv failed: %v", err)
+	}
+	if cfg.MaxIdleConnsPerHost != 128 {
+		t.Errorf("Expected 128 idle conns per host, got %d", cfg.MaxIdleConnsPerHost)
+	}
+	if cfg.DialTimeout.String() != "750ms" {
+		t.Errorf("Expected 750ms dial timeout, got %s", cfg.DialTimeout)
+	}
+	if cfg.Timeout != DefaultConfig().Timeout {
+		t.Errorf("Expected invalid timeout to fall back to default, got %s", cfg.Timeout)
+	}
+}
+
+func TestConfigFromEnv_FailsOnBadCertificates(t *testing.T) {
+	t.Setenv("MTLS_CERT_FILE", filepath.Join(t.TempDir(), "missing.crt"))
+	t.Setenv("MTLS_KEY_FILE", filepath.Join(t.TempDir(), "missing.key"))
+	t.Setenv("MTLS_CA_FILE", filepath.Join(t.TempDir(), "missing-ca.crt"))
+	if _, err := ConfigFromEnv("payment_client"); err == nil {
+		t.Error("Expected an error for unreadable mTLS files")
+	}
+}
+
+func TestConfigFromEnv_MTLSOnlyForDemoServices(t *testing.T) {
+	t.Setenv("MTLS_CERT_FILE", filepath.Join(t.TempDir(), "missing.crt"))
+	t.Setenv("MTLS_KEY_FILE", filepath.Join(t.TempDir(), "missing.key"))
+	t.Setenv("MTLS_CA_FILE", filepath.Join(t.TempDir(), "missing-ca.crt"))
+	cfg, err := ConfigFromEnv("fx_client")
+	if err != nil {
+		t.Fatalf("Expected third-party clients to skip mTLS, got %v", err)
+	}
+	if cfg.TLS != nil {
+		t.Error("Expected the default TLS config for a third-party client")
+	}
+
+	t.Setenv("FX_CLIENT_MTLS", "true")
+	if _, err := ConfigFromEnv("fx_client"); err == nil {
+		t.Error("Expected FX_CLIENT_MTLS=true to load the client certificate")
+	}
+}
@@ -0,0 +1,71 @@
+package inventory
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// LevelsHandler serves GET /admin/inventory
+func (s *Stock) LevelsHandler(w http.ResponseWriter, r *http.Request) {
+	levels := s.Levels()
+	if levels == nil {
+		levels = []Level{}
+	}
+	writeJSON(w, http.StatusOK, levels)
+}
+
+// SetHandler serves PUT /admin/inventory/{product} with
+// {"quantity": 500, "low_stock_threshold": 20}; the threshold is optional
+func (s *Stock) SetHandler(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Quantity          *int `json:"quantity"`
+		LowStockThreshold *int `json:"low_stock_threshold"`
+	}
+	if err := decode(w, r, &body); err != nil || body.Quantity == nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "quantity is required"})
+		return
+	}
+	if *body.Quantity < 0 || (body.LowStockThreshold != nil && *body.LowStockThreshold < 0) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "quantity and threshold must not be negative"})
+		return
+	}
+	l, err := s.Set(r.Context(), r.PathValue("product"), *body.Quantity, body.LowStockThreshold)
+	respond(w, l, err)
+}
+
+// AdjustHandler serves POST /admin/inventory/{product}/adjust with
+// {"delta": -3, "reason": "damaged"}
+func (s *Stock) AdjustHandler(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Delta  int    `json:"delta"`
+		Reason string `json:"reason"`
+	}
+	if err := decode(w, r, &body); err != nil || body.Delta == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "a non-zero delta is required"})
+		return
+	}
+	l, err := s.Adjust(r.Context(), r.PathValue("product"), body.Delta, body.Reason)
+	respond(w, l, err)
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v any) error {
+	return json.NewDecoder(http.MaxBytesReader(w, r.Body, 16<<10)).Decode(v)
+}
+
+func respond(w http.ResponseWriter, l Level, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidQuantity):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	case err != nil:
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	default:
+		writeJSON(w, http.StatusOK, l)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,294 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-observability-demo/internal/config"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	ErrInsufficientStock = errors.New("insufficient stock")
+	ErrInvalidQuantity   = errors.New("stock cannot go below zero")
+)
+
+// Config seeds stock levels and sets when a product counts as low on stock
+type Config struct {
+	Stock map[string]int
+	// LowStockThreshold applies to products without their own threshold;
+	// a product is low on stock at or below it
+	LowStockThreshold int
+	Thresholds        map[string]int
+}
+
+// ConfigFromEnv reads INVENTORY_STOCK ("prod-123=500,..."),
+// INVENTORY_LOW_STOCK_THRESHOLD, and INVENTORY_LOW_STOCK_THRESHOLDS
+// ("prod-vip=2,...")
+func ConfigFromEnv() Config {
+	return Config{
+		Stock:             parseCounts(config.List("INVENTORY_STOCK", nil)),
+		LowStockThreshold: config.Int("INVENTORY_LOW_STOCK_THRESHOLD", 10),
+		Thresholds:        parseCounts(config.List("INVENTORY_LOW_STOCK_THRESHOLDS", nil)),
+	}
+}
+
+func parseCounts(entries []string) map[string]int {
+	counts := make(map[string]int, len(entries))
+	for _, entry := range entries {
+		id, raw, ok := strings.Cut(entry, "=")
+		if n, err := strconv.Atoi(strings.TrimSpace(raw)); ok && err == nil && n >= 0 {
+			counts[strings.TrimSpace(id)] = n
+		}
+	}
+	return counts
+}
+
+// Level is a product's stock as reported by the admin API
+type Level struct {
+	ProductID         string `json:"product_id"`
+	Quantity          int    `json:"quantity"`
+	LowStockThreshold int    `json:"low_stock_threshold"`
+	Low               bool   `json:"low"`
+}
+
+type item struct {
+	quantity  int
+	threshold int
+}
+
+func (i *item) low() bool {
+	return i.quantity <= i.threshold
+}
+
+// Stock tracks quantity on hand per product. Products that were never
+// stocked are not tracked and never run out, so the demo works without
+// seeding. Levels are exported as inventory.stock_level and
+// inventory.low_stock_threshold, and crossing the threshold downwards is a
+// low_stock span event, an inventory.low_stock log, and a count in
+// inventory.low_stock.events. A nil *Stock tracks nothing.
+type Stock struct {
+	defaultThreshold int
+	logger           *slog.Logger
+	tracer           trace.Tracer
+
+	lowStockEvents metric.Int64Counter
+
+	mu    sync.Mutex
+	items map[string]*item
+}
+
+func New(cfg Config, meter metric.Meter, logger *slog.Logger) (*Stock, error) {
+	s := &Stock{
+		defaultThreshold: max(0, cfg.LowStockThreshold),
+		logger:           logger,
+		tracer:           otel.Tracer("order-service/inventory"),
+		items:            make(map[string]*item, len(cfg.Stock)),
+	}
+	for id, qty := range cfg.Stock {
+		threshold, ok := cfg.Thresholds[id]
+		if !ok {
+			threshold = s.defaultThreshold
+		}
+		s.items[id] = &item{quantity: qty, threshold: threshold}
+	}
+
+	var err error
+	s.lowStockEvents, err = meter.Int64Counter(
+		"inventory.low_stock.events",
+		metric.WithDescription("Times a product's stock fell to or below its low-stock threshold"),
+		metric.WithUnit("{event}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	level, err := meter.Int64ObservableGauge(
+		"inventory.stock_level",
+		metric.WithDescription("Units on hand per tracked product"),
+		metric.WithUnit("{unit}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	threshold, err := meter.Int64ObservableGauge(
+		"inventory.low_stock_threshold",
+		metric.WithDescription("Stock level at or below which a product is low on stock"),
+		metric.WithUnit("{unit}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		for _, l := range s.Levels() {
+			attrs := metric.WithAttributes(attribute.String("product.id", l.ProductID))
+			o.ObserveInt64(level, int64(l.Quantity), attrs)
+			o.ObserveInt64(threshold, int64(l.LowStockThreshold), attrs)
+		}
+		return nil
+	}, level, threshold)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Available reports whether quantity units can be taken; untracked
+// products always can
+func (s *Stock) Available(productID string, quantity int) bool {
+	if s == nil {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	it, ok := s.items[productID]
+	return !ok || it.quantity >= quantity
+}
+
+// Reserve takes quantity units of a tracked product, or returns
+// ErrInsufficientStock without taking any
+func (s *Stock) Reserve(ctx context.Context, productID string, quantity int) error {
+	if s == nil {
+		return nil
+	}
+	_, err := s.change(ctx, productID, func(it *item) error {
+		if it.quantity < quantity {
+			return fmt.Errorf("%w: %d of %s requested, %d left", ErrInsufficientStock, quantity, productID, it.quantity)
+		}
+		it.quantity -= quantity
+		return nil
+	}, false)
+	return err
+}
+
+// Release returns quantity units of a tracked product taken by Reserve,
+// e.g. when an order is cancelled
+func (s *Stock) Release(ctx context.Context, productID string, quantity int) error {
+	if s == nil {
+		return nil
+	}
+	_, err := s.change(ctx, productID, func(it *item) error {
+		it.quantity += quantity
+		return nil
+	}, false)
+	return err
+}
+
+// Set replaces a product's stock level and starts tracking it. A nil
+// threshold keeps the current one.
+func (s *Stock) Set(ctx context.Context, productID string, quantity int, threshold *int) (Level, error) {
+	ctx, span := s.tracer.Start(ctx, "SetStock", trace.WithAttributes(
+		attribute.String("product.id", productID),
+		attribute.Int("inventory.quantity", quantity),
+	))
+	defer span.End()
+
+	if quantity < 0 || (threshold != nil && *threshold < 0) {
+		span.SetStatus(codes.Error, ErrInvalidQuantity.Error())
+		return Level{}, ErrInvalidQuantity
+	}
+	return s.change(ctx, productID, func(it *item) error {
+		it.quantity = quantity
+		if threshold != nil {
+			it.threshold = *threshold
+		}
+		return nil
+	}, true)
+}
+
+// Adjust adds delta (negative for shrinkage) to a product's stock and
+// starts tracking it
+func (s *Stock) Adjust(ctx context.Context, productID string, delta int, reason string) (Level, error) {
+	ctx, span := s.tracer.Start(ctx, "AdjustStock", trace.WithAttributes(
+		attribute.String("product.id", productID),
+		attribute.Int("inventory.delta", delta),
+		attribute.String("inventory.reason", reason),
+	))
+	defer span.End()
+
+	l, err := s.change(ctx, productID, func(it *item) error {
+		if it.quantity+delta < 0 {
+			return ErrInvalidQuantity
+		}
+		it.quantity += delta
+		return nil
+	}, true)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return l, err
+}
+
+// change applies fn to a product under the lock and reports a low-stock
+// event if it crossed the threshold. Untracked products are only created
+// when track is set; otherwise fn is skipped.
+func (s *Stock) change(ctx context.Context, productID string, fn func(*item) error, track bool) (Level, error) {
+	s.mu.Lock()
+	it, ok := s.items[productID]
+	if !ok {
+		if !track {
+			s.mu.Unlock()
+			return Level{ProductID: productID}, nil
+		}
+		it = &item{threshold: s.defaultThreshold}
+	}
+	wasLow := ok && it.low()
+	if err := fn(it); err != nil {
+		s.mu.Unlock()
+		return Level{}, err
+	}
+	s.items[productID] = it
+	l := Level{ProductID: productID, Quantity: it.quantity, LowStockThreshold: it.threshold, Low: it.low()}
+	s.mu.Unlock()
+
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.Int("inventory.stock_level", l.Quantity))
+	switch {
+	case l.Low && !wasLow:
+		span.AddEvent("low_stock", trace.WithAttributes(
+			attribute.String("product.id", productID),
+			attribute.Int("inventory.stock_level", l.Quantity),
+			attribute.Int("inventory.low_stock_threshold", l.LowStockThreshold),
+		))
+		s.lowStockEvents.Add(ctx, 1, metric.WithAttributes(attribute.String("product.id", productID)))
+		s.logger.WarnContext(ctx, "product is low on stock",
+			slog.String("event.name", "inventory.low_stock"),
+			slog.String("product_id", productID),
+			slog.Int("quantity", l.Quantity),
+			slog.Int("threshold", l.LowStockThreshold),
+		)
+	case wasLow && !l.Low:
+		s.logger.InfoContext(ctx, "product restocked",
+			slog.String("event.name", "inventory.restocked"),
+			slog.String("product_id", productID),
+			slog.Int("quantity", l.Quantity),
+		)
+	}
+	return l, nil
+}
+
+// Levels returns every tracked product, sorted by ID
+func (s *Stock) Levels() []Level {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	levels := make([]Level, 0, len(s.items))
+	for id, it := range s.items {
+		levels = append(levels, Level{ProductID: id, Quantity: it.quantity, LowStockThreshold: it.threshold, Low: it.low()})
+	}
+	s.mu.Unlock()
+
+	slices.SortFunc(levels, func(a, b Level) int { return strings.Compare(a.ProductID, b.ProductID) })
+	return levels
+}
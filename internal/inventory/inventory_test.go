@@ -0,0 +1,100 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"go-observability-demo/internal/observability/observabilitytest"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+)
+
+func newTestStock(t *testing.T, cfg Config) (*Stock, *observabilitytest.Recorder) {
+	t.Helper()
+	recorder := observabilitytest.New(t)
+	s, err := New(cfg, otel.Meter("test"), recorder.Logger)
+	if err != nil {
+		t.Fatalf("Failed to create stock: %v", err)
+	}
+	return s, recorder
+}
+
+func TestStock_ReserveAndLowStockEvent(t *testing.T) {
+	s, recorder := newTestStock(t, Config{Stock: map[string]int{"prod-123": 12}, LowStockThreshold: 10})
+	ctx, span := otel.Tracer("test").Start(context.Background(), "ReserveInventory")
+
+	if err := s.Reserve(ctx, "prod-123", 1); err != nil {
+		t.Fatalf("Reserve failed: %v", err)
+	}
+	if err := s.Reserve(ctx, "prod-123", 2); err != nil {
+		t.Fatalf("Reserve failed: %v", err)
+	}
+	s.Reserve(ctx, "prod-123", 1) // already low, no second event
+	if err := s.Reserve(ctx, "prod-123", 50); !errors.Is(err, ErrInsufficientStock) {
+		t.Errorf("Expected ErrInsufficientStock, got %v", err)
+	}
+	if err := s.Reserve(ctx, "untracked", 1000); err != nil {
+		t.Errorf("Expected untracked products to be unlimited, got %v", err)
+	}
+	span.End()
+
+	if got := recorder.Int64Sum(t, "inventory.low_stock.events"); got != 1 {
+		t.Errorf("Expected 1 low-stock event, got %d", got)
+	}
+	if got := recorder.GaugeValue(t, "inventory.stock_level"); got != 8 {
+		t.Errorf("Expected a stock level of 8, got %v", got)
+	}
+	events := recorder.SpansNamed("ReserveInventory")[0].Events
+	if len(events) != 1 || events[0].Name != "low_stock" {
+		t.Errorf("Expected one low_stock span event, got %+v", events)
+	}
+}
+
+func TestStock_AdjustCannotGoNegative(t *testing.T) {
+	s, _ := newTestStock(t, Config{LowStockThreshold: 5})
+	ctx := context.Background()
+
+	if l, err := s.Adjust(ctx, "prod-456", 20, "delivery"); err != nil || l.Quantity != 20 || l.Low {
+		t.Fatalf("Expected 20 in stock, got %+v (%v)", l, err)
+	}
+	if _, err := s.Adjust(ctx, "prod-456", -21, "damaged"); !errors.Is(err, ErrInvalidQuantity) {
+		t.Errorf("Expected ErrInvalidQuantity, got %v", err)
+	}
+	threshold := 25
+	if l, _ := s.Set(ctx, "prod-456", 20, &threshold); !l.Low {
+		t.Errorf("Expected a raised threshold to make the product low, got %+v", l)
+	}
+}
+
+func TestHandlers(t *testing.T) {
+	s, _ := newTestStock(t, Config{LowStockThreshold: 5})
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/inventory", s.LevelsHandler)
+	mux.HandleFunc("PUT /admin/inventory/{product}", s.SetHandler)
+	mux.HandleFunc("POST /admin/inventory/{product}/adjust", s.AdjustHandler)
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+	if rec := send(http.MethodPut, "/admin/inventory/prod-123", `{"quantity":-1}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for negative stock, got %d", rec.Code)
+	}
+	if rec := send(http.MethodPut, "/admin/inventory/prod-123", `{"quantity":7}`); rec.Code != http.StatusOK {
+		t.Errorf("Expected 200, got %d", rec.Code)
+	}
+	if rec := send(http.MethodPost, "/admin/inventory/prod-123/adjust", `{"delta":-10}`); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for an adjustment below zero, got %d", rec.Code)
+	}
+	send(http.MethodPost, "/admin/inventory/prod-123/adjust", `{"delta":-3,"reason":"damaged"}`)
+
+	rec := send(http.MethodGet, "/admin/inventory", "")
+	want := `[{"product_id":"prod-123","quantity":4,"low_stock_threshold":5,"low":true}]`
+	if got := strings.TrimSpace(rec.Body.String()); got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+}
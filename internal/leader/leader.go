@@ -0,0 +1,277 @@
+package leader
+
+import (
+	"context"
+	"fmt"
+	"go-observability-demo/internal/config"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Lease is an expiring lock that can be renewed by its holder.
+// redisstore.Client provides one shared between replicas; locks.MemoryLocker
+// makes a single instance its own leader.
+type Lease interface {
+	TryLock(ctx context.Context, key string, ttl time.Duration) (token string, ok bool, err error)
+	Refresh(ctx context.Context, key, token string, ttl time.Duration) (bool, error)
+	Unlock(ctx context.Context, key, token string) error
+}
+
+// Config names the election and sets the lease timing
+type Config struct {
+	Name string
+	// TTL is how long a crashed leader blocks the others
+	TTL time.Duration
+	// RenewInterval must be well under TTL so one slow renewal doesn't lose
+	// leadership
+	RenewInterval time.Duration
+}
+
+// ConfigFromEnv reads LEADER_ELECTION_NAME, LEADER_LEASE_TTL, and
+// LEADER_RENEW_INTERVAL
+func ConfigFromEnv() Config {
+	return Config{
+		Name:          config.String("LEADER_ELECTION_NAME", "order-service-jobs"),
+		TTL:           config.Duration("LEADER_LEASE_TTL", 15*time.Second),
+		RenewInterval: config.Duration("LEADER_RENEW_INTERVAL", 5*time.Second),
+	}
+}
+
+type job struct {
+	name string
+	run  func(ctx context.Context)
+}
+
+// Elector campaigns for a lease and runs background jobs only while it
+// holds it, so jobs like sweepers and relays run on exactly one replica.
+// Leadership is exported as the leader.is_leader gauge and every
+// transition is logged and traced.
+type Elector struct {
+	cfg      Config
+	lease    Lease
+	identity string
+	logger   *slog.Logger
+	tracer   trace.Tracer
+	attrs    metric.MeasurementOption
+
+	transitions metric.Int64Counter
+
+	mu     sync.Mutex
+	jobs   []job
+	token  string
+	cancel context.CancelFunc // stops the jobs of the current term
+	wg     sync.WaitGroup
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+func New(cfg Config, lease Lease, meter metric.Meter, logger *slog.Logger) (*Elector, error) {
+	if cfg.TTL <= 0 {
+		cfg.TTL = 15 * time.Second
+	}
+	if cfg.RenewInterval <= 0 || cfg.RenewInterval >= cfg.TTL {
+		cfg.RenewInterval = cfg.TTL / 3
+	}
+	host, _ := os.Hostname()
+
+	e := &Elector{
+		cfg:      cfg,
+		lease:    lease,
+		identity: fmt.Sprintf("%s-%d", host, os.Getpid()),
+		logger:   logger,
+		tracer:   otel.Tracer("order-service/leader"),
+		attrs:    metric.WithAttributes(attribute.String("leader.election", cfg.Name)),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	var err error
+	e.transitions, err = meter.Int64Counter(
+		"leader.transitions",
+		metric.WithDescription("Leadership changes, by new state (elected, lost, resigned)"),
+		metric.WithUnit("{transition}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	gauge, err := meter.Int64ObservableGauge(
+		"leader.is_leader",
+		metric.WithDescription("1 while this instance holds the leader lease"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		var v int64
+		if e.IsLeader() {
+			v = 1
+		}
+		o.ObserveInt64(gauge, v, e.attrs)
+		return nil
+	}, gauge)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Go registers a job to run while this instance is leader. Its context is
+// cancelled when leadership is lost, and it is started again on re-election.
+// Register jobs before Start.
+func (e *Elector) Go(name string, run func(ctx context.Context)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.jobs = append(e.jobs, job{name: name, run: run})
+}
+
+func (e *Elector) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.token != ""
+}
+
+// Start campaigns immediately and then on every renew interval
+func (e *Elector) Start() {
+	go func() {
+		defer close(e.done)
+		ticker := time.NewTicker(e.cfg.RenewInterval)
+		defer ticker.Stop()
+		for {
+			e.tick()
+			select {
+			case <-e.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop ends the campaign, stops the jobs, and releases the lease so another
+// replica can take over without waiting for it to expire
+func (e *Elector) Stop(ctx context.Context) error {
+	e.once.Do(func() { close(e.stop) })
+	select {
+	case <-e.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	e.mu.Lock()
+	token := e.token
+	e.mu.Unlock()
+	if token == "" {
+		return nil
+	}
+	e.stepDown(ctx, "resigned", nil)
+	return e.lease.Unlock(ctx, e.cfg.Name, token)
+}
+
+func (e *Elector) tick() {
+	ctx, cancel := context.WithTimeout(context.Background(), e.cfg.RenewInterval)
+	defer cancel()
+
+	e.mu.Lock()
+	token := e.token
+	e.mu.Unlock()
+
+	if token == "" {
+		token, ok, err := e.lease.TryLock(ctx, e.cfg.Name, e.cfg.TTL)
+		if err != nil {
+			e.logger.Warn("leader election attempt failed",
+				slog.String("leader.election", e.cfg.Name),
+				slog.String("error", err.Error()),
+			)
+		}
+		if ok {
+			e.becomeLeader(ctx, token)
+		}
+		return
+	}
+
+	// Step down on any doubt; a second leader is worse than none for a
+	// renew interval
+	ok, err := e.lease.Refresh(ctx, e.cfg.Name, token, e.cfg.TTL)
+	if err != nil || !ok {
+		e.stepDown(ctx, "lost", err)
+	}
+}
+
+func (e *Elector) becomeLeader(ctx context.Context, token string) {
+	_, span := e.tracer.Start(ctx, "LeaderTransition", trace.WithAttributes(
+		attribute.String("leader.election", e.cfg.Name),
+		attribute.String("leader.state", "elected"),
+		attribute.String("leader.identity", e.identity),
+	))
+	defer span.End()
+
+	jobCtx, cancel := context.WithCancel(context.Background())
+	e.mu.Lock()
+	e.token = token
+	e.cancel = cancel
+	for _, j := range e.jobs {
+		e.wg.Add(1)
+		go func() {
+			defer e.wg.Done()
+			j.run(jobCtx)
+		}()
+	}
+	jobs := len(e.jobs)
+	e.mu.Unlock()
+
+	span.SetAttributes(attribute.Int("leader.jobs", jobs))
+	e.transitions.Add(ctx, 1, e.attrs, metric.WithAttributes(attribute.String("state", "elected")))
+	e.logger.Info("leadership acquired",
+		slog.String("event.name", "leader.transition"),
+		slog.String("leader.election", e.cfg.Name),
+		slog.String("leader.identity", e.identity),
+		slog.Int("jobs", jobs),
+	)
+}
+
+// stepDown cancels the jobs of the current term and waits for them to return
+func (e *Elector) stepDown(ctx context.Context, state string, cause error) {
+	_, span := e.tracer.Start(ctx, "LeaderTransition", trace.WithAttributes(
+		attribute.String("leader.election", e.cfg.Name),
+		attribute.String("leader.state", state),
+		attribute.String("leader.identity", e.identity),
+	))
+	defer span.End()
+	if cause != nil {
+		span.RecordError(cause)
+	}
+
+	e.mu.Lock()
+	e.token = ""
+	if e.cancel != nil {
+		e.cancel()
+		e.cancel = nil
+	}
+	e.mu.Unlock()
+	e.wg.Wait()
+
+	e.transitions.Add(ctx, 1, e.attrs, metric.WithAttributes(attribute.String("state", state)))
+	attrs := []any{
+		slog.String("event.name", "leader.transition"),
+		slog.String("leader.election", e.cfg.Name),
+		slog.String("leader.identity", e.identity),
+	}
+	if cause != nil {
+		attrs = append(attrs, slog.String("error", cause.Error()))
+	}
+	if state == "lost" {
+		e.logger.Warn("leadership lost", attrs...)
+	} else {
+		e.logger.Info("leadership released", attrs...)
+	}
+}
@@ -0,0 +1,120 @@
+package leader
+
+import (
+	"context"
+	"go-observability-demo/internal/locks"
+	"go-observability-demo/internal/observability/observabilitytest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func newTestElector(t *testing.T, recorder *observabilitytest.Recorder, lease Lease, runs *atomic.Int32) *Elector {
+	t.Helper()
+	e, err := New(Config{Name: "jobs", TTL: 200 * time.Millisecond, RenewInterval: 20 * time.Millisecond},
+		lease, otel.Meter("test"), recorder.Logger)
+	if err != nil {
+		t.Fatalf("Failed to create elector: %v", err)
+	}
+	e.Go("sweeper", func(ctx context.Context) {
+		runs.Add(1)
+		<-ctx.Done()
+		runs.Add(-1)
+	})
+	return e
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestElector_OneLeaderRunsJobsAndHandsOver(t *testing.T) {
+	recorder := observabilitytest.New(t)
+	lease := locks.NewMemoryLocker()
+	var runs atomic.Int32
+	a := newTestElector(t, recorder, lease, &runs)
+	b := newTestElector(t, recorder, lease, &runs)
+
+	a.Start()
+	waitFor(t, "a to lead", a.IsLeader)
+	b.Start()
+	time.Sleep(60 * time.Millisecond)
+
+	if b.IsLeader() {
+		t.Error("Expected only one leader")
+	}
+	if got := runs.Load(); got != 1 {
+		t.Errorf("Expected the job to run once, got %d", got)
+	}
+
+	if err := a.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	waitFor(t, "b to take over", b.IsLeader)
+	if got := runs.Load(); got != 1 {
+		t.Errorf("Expected the job to move to the new leader, got %d running", got)
+	}
+	b.Stop(context.Background())
+	if got := runs.Load(); got != 0 {
+		t.Errorf("Expected no jobs after both stopped, got %d", got)
+	}
+}
+
+func TestElector_ExportsLeadershipGauge(t *testing.T) {
+	recorder := observabilitytest.New(t)
+	var runs atomic.Int32
+	e := newTestElector(t, recorder, locks.NewMemoryLocker(), &runs)
+
+	if got := recorder.GaugeValue(t, "leader.is_leader"); got != 0 {
+		t.Errorf("Expected leader.is_leader 0 before the campaign, got %v", got)
+	}
+	e.Start()
+	waitFor(t, "leadership", e.IsLeader)
+	if got := recorder.GaugeValue(t, "leader.is_leader"); got != 1 {
+		t.Errorf("Expected leader.is_leader 1 while leading, got %v", got)
+	}
+	e.Stop(context.Background())
+	if got := recorder.GaugeValue(t, "leader.is_leader"); got != 0 {
+		t.Errorf("Expected leader.is_leader 0 after resigning, got %v", got)
+	}
+}
+
+// lostLease grants the lock but refuses every renewal
+type lostLease struct{ *locks.MemoryLocker }
+
+func (lostLease) Refresh(context.Context, string, string, time.Duration) (bool, error) {
+	return false, nil
+}
+
+func TestElector_StepsDownWhenRenewalFails(t *testing.T) {
+	recorder := observabilitytest.New(t)
+	var runs atomic.Int32
+	e := newTestElector(t, recorder, lostLease{locks.NewMemoryLocker()}, &runs)
+	e.Start()
+	defer e.Stop(context.Background())
+
+	waitFor(t, "a lost transition", func() bool {
+		for _, s := range recorder.SpansNamed("LeaderTransition") {
+			for _, attr := range s.Attributes {
+				if attr == attribute.String("leader.state", "lost") {
+					return true
+				}
+			}
+		}
+		return false
+	})
+	// The transition span ends once the jobs have returned
+	if got := runs.Load(); got != 0 {
+		t.Errorf("Expected the job to stop with leadership, got %d running", got)
+	}
+}
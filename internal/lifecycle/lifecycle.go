@@ -0,0 +1,160 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Phase groups shutdown hooks. Hooks run phase by phase in ascending order,
+// so everything registered in PhaseDrain finishes before PhaseFlush starts.
+type Phase int
+
+const (
+	// PhaseDrain stops accepting new work and waits for in-flight requests
+	// and background workers to finish.
+	PhaseDrain Phase = iota
+	// PhaseFlush pushes buffered telemetry to the exporters.
+	PhaseFlush
+	// PhaseClose tears down whatever is left, such as admin listeners.
+	PhaseClose
+)
+
+func (p Phase) String() string {
+	switch p {
+	case PhaseDrain:
+		return "drain"
+	case PhaseFlush:
+		return "flush"
+	case PhaseClose:
+		return "close"
+	default:
+		return fmt.Sprintf("phase(%d)", int(p))
+	}
+}
+
+// Hook is a single shutdown step
+type Hook struct {
+	Name    string
+	Phase   Phase
+	Timeout time.Duration
+	Fn      func(context.Context) error
+}
+
+// Manager runs registered shutdown hooks in phase order, each with its own timeout
+type Manager struct {
+	logger *slog.Logger
+
+	mu    sync.Mutex
+	hooks []Hook
+}
+
+func New(logger *slog.Logger) *Manager {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Manager{logger: logger}
+}
+
+// Register adds a hook. A zero timeout means the hook is only bounded by the
+// context passed to Shutdown.
+func (m *Manager) Register(phase Phase, name string, timeout time.Duration, fn func(context.Context) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.hooks = append(m.hooks, Hook{
+		Name:    name,
+		Phase:   phase,
+		Timeout: timeout,
+		Fn:      fn,
+	})
+}
+
+// Wait blocks until one of the given signals arrives (SIGINT and SIGTERM by
+// default) or ctx is cancelled.
+func (m *Manager) Wait(ctx context.Context, signals ...os.Signal) os.Signal {
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+	}
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, signals...)
+	defer signal.Stop(quit)
+
+	select {
+	case sig := <-quit:
+		return sig
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+// Shutdown runs every hook, phase by phase. A failing or timed-out hook does
+// not stop later hooks from running; all errors are returned joined.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.mu.Lock()
+	hooks := make([]Hook, len(m.hooks))
+	copy(hooks, m.hooks)
+	m.mu.Unlock()
+
+	// Stable sort keeps registration order within a phase
+	sort.SliceStable(hooks, func(i, j int) bool {
+		return hooks[i].Phase < hooks[j].Phase
+	})
+
+	var errs []error
+	for _, hook := range hooks {
+		if err := m.runHook(ctx, hook); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (m *Manager) runHook(ctx context.Context, hook Hook) error {
+	hookCtx := ctx
+	if hook.Timeout > 0 {
+		var cancel context.CancelFunc
+		hookCtx, cancel = context.WithTimeout(ctx, hook.Timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- hook.Fn(hookCtx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-hookCtx.Done():
+		err = hookCtx.Err()
+	}
+
+	duration := time.Since(start)
+	if err != nil {
+		m.logger.Error("shutdown hook failed",
+			slog.String("hook", hook.Name),
+			slog.String("phase", hook.Phase.String()),
+			slog.Duration("duration", duration),
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("%s: %w", hook.Name, err)
+	}
+
+	m.logger.Info("shutdown hook completed",
+		slog.String("hook", hook.Name),
+		slog.String("phase", hook.Phase.String()),
+		slog.Duration("duration", duration),
+	)
+	return nil
+}
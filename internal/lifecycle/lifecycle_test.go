@@ -0,0 +1,219 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// handoffChildEnv makes the test binary act as the process started by
+// Handoff instead of running the tests
+const handoffChildEnv = "LIFECYCLE_TEST_HANDOFF_CHILD"
+
+func TestMain(m *testing.M) {
+	if want := os.Getenv(handoffChildEnv); want != "" {
+		os.Exit(runHandoffChild(want))
+	}
+	os.Exit(m.Run())
+}
+
+// runHandoffChild reports ready only if every listener in want
+// ("name=addr,...") was inherited on its address
+func runHandoffChild(want string) int {
+	for _, pair := range strings.Split(want, ",") {
+		name, addr, _ := strings.Cut(pair, "=")
+		ln, inherited, err := Listen(context.Background(), name, "127.0.0.1:0")
+		if err != nil || !inherited || ln.Addr().String() != addr {
+			return 1
+		}
+	}
+	if err := Ready(); err != nil {
+		return 1
+	}
+	return 0
+}
+
+func newTestManager() *Manager {
+	return New(slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestShutdown_RunsPhasesInOrder(t *testing.T) {
+	m := newTestManager()
+
+	var mu sync.Mutex
+	var order []string
+	record := func(name string) func(context.Context) error {
+		return func(context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, name)
+			return nil
+		}
+	}
+
+	// Register out of order to make sure phases, not registration, decide
+	m.Register(PhaseClose, "listeners", time.Second, record("listeners"))
+	m.Register(PhaseFlush, "exporters", time.Second, record("exporters"))
+	m.Register(PhaseDrain, "workers", time.Second, record("workers"))
+	m.Register(PhaseDrain, "http-server", time.Second, record("http-server"))
+
+	if err := m.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []string{"workers", "http-server", "exporters", "listeners"}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("Expected order %v, got %v", expected, order)
+	}
+}
+
+func TestShutdown_HookTimeout(t *testing.T) {
+	m := newTestManager()
+
+	ranAfter := false
+	m.Register(PhaseDrain, "stuck", 20*time.Millisecond, func(ctx context.Context) error {
+		<-time.After(time.Second)
+		return nil
+	})
+	m.Register(PhaseFlush, "after", time.Second, func(context.Context) error {
+		ranAfter = true
+		return nil
+	})
+
+	start := time.Now()
+	err := m.Shutdown(context.Background())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Errorf("Shutdown did not respect hook timeout, took %v", time.Since(start))
+	}
+	if !ranAfter {
+		t.Error("Hook after a timed-out hook did not run")
+	}
+}
+
+func TestShutdown_JoinsErrors(t *testing.T) {
+	m := newTestManager()
+
+	errA := errors.New("a failed")
+	errB := errors.New("b failed")
+	m.Register(PhaseDrain, "a", 0, func(context.Context) error { return errA })
+	m.Register(PhaseFlush, "b", 0, func(context.Context) error { return errB })
+
+	err := m.Shutdown(context.Background())
+	if !errors.Is(err, errA) || !errors.Is(err, errB) {
+		t.Errorf("Expected both errors to be reported, got %v", err)
+	}
+}
+
+func TestWait_ContextCancelled(t *testing.T) {
+	m := newTestManager()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if sig := m.Wait(ctx); sig != nil {
+		t.Errorf("Expected nil signal on cancelled context, got %v", sig)
+	}
+}
+
+func TestListen_ReusePortAllowsSecondBind(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("SO_REUSEPORT not supported on " + runtime.GOOS)
+	}
+	t.Setenv("LISTEN_FDS", "")
+
+	first, inherited, err := Listen(context.Background(), MainListener, "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer first.Close()
+	if inherited {
+		t.Error("Expected a fresh listener without LISTEN_FDS")
+	}
+
+	// A new release binds the same port while the old one is still serving
+	second, _, err := Listen(context.Background(), MainListener, first.Addr().String())
+	if err != nil {
+		t.Fatalf("Expected a second bind on %s to succeed, got %v", first.Addr(), err)
+	}
+	second.Close()
+}
+
+func TestChild_WaitReady(t *testing.T) {
+	pipe := func(t *testing.T) (*Child, *os.File) {
+		t.Helper()
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatalf("Pipe failed: %v", err)
+		}
+		return &Child{ready: r}, w
+	}
+
+	t.Run("ready", func(t *testing.T) {
+		child, w := pipe(t)
+		if err := reportReady(w); err != nil {
+			t.Fatalf("Ready failed: %v", err)
+		}
+		if err := child.WaitReady(context.Background()); err != nil {
+			t.Errorf("Expected the child to be ready, got %v", err)
+		}
+	})
+
+	t.Run("exited", func(t *testing.T) {
+		child, w := pipe(t)
+		w.Close()
+		if err := child.WaitReady(context.Background()); err == nil {
+			t.Error("Expected an error when the child exits before it is ready")
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		child, w := pipe(t)
+		defer w.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if err := child.WaitReady(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected a timeout, got %v", err)
+		}
+	})
+}
+
+func TestHandoff_PassesNamedListeners(t *testing.T) {
+	listeners := map[string]net.Listener{}
+	var want []string
+	for _, name := range []string{MainListener, "metrics"} {
+		ln, _, err := Listen(context.Background(), name, "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Listen failed: %v", err)
+		}
+		defer ln.Close()
+		listeners[name] = ln
+		want = append(want, name+"="+ln.Addr().String())
+	}
+	t.Setenv(handoffChildEnv, strings.Join(want, ","))
+
+	child, err := Handoff(listeners)
+	if err != nil {
+		t.Fatalf("Handoff failed: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := child.WaitReady(ctx); err != nil {
+		child.Process.Kill()
+		t.Fatalf("Expected the new process to take over both listeners, got %v", err)
+	}
+	if state, err := child.Process.Wait(); err != nil || !state.Success() {
+		t.Errorf("Expected the new process to exit cleanly, got %v %v", state, err)
+	}
+}
@@ -0,0 +1,197 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Inherited listeners follow systemd socket activation: LISTEN_FDS says how
+// many sockets were passed, starting at fd 3, and LISTEN_FDNAMES names them
+const (
+	listenFDsEnv     = "LISTEN_FDS"
+	listenFDNamesEnv = "LISTEN_FDNAMES"
+	listenPIDEnv     = "LISTEN_PID"
+	firstListenFD    = 3
+	// readyFDEnv names the pipe a handed-off process reports readiness on
+	readyFDEnv = "LISTEN_READY_FD"
+)
+
+// MainListener names the service's own port. A socket passed without
+// LISTEN_FDNAMES, as by systemd for a single socket, is taken to be it.
+const MainListener = "http"
+
+// inheritedFDs maps the names of inherited listeners to their fds. The
+// environment is cleared so the fds aren't passed on to our own children.
+var inheritedFDs = sync.OnceValue(func() map[string]uintptr {
+	n, err := strconv.Atoi(os.Getenv(listenFDsEnv))
+	pid := os.Getenv(listenPIDEnv)
+	names := strings.Split(os.Getenv(listenFDNamesEnv), ":")
+	os.Unsetenv(listenFDsEnv)
+	os.Unsetenv(listenFDNamesEnv)
+	os.Unsetenv(listenPIDEnv)
+	if err != nil || n <= 0 || (pid != "" && pid != strconv.Itoa(os.Getpid())) {
+		return nil
+	}
+
+	fds := make(map[string]uintptr, n)
+	for i := range n {
+		name := MainListener
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+		if _, ok := fds[name]; !ok {
+			fds[name] = uintptr(firstListenFD + i)
+		}
+	}
+	return fds
+})
+
+// Listen returns the listener called name handed over by a previous process
+// (see Handoff) or by systemd, and otherwise binds addr. Where the platform
+// supports it the socket is opened with SO_REUSEPORT, so a new release can
+// bind the same port before this one stops accepting. inherited reports
+// whether the listener came from a previous process.
+func Listen(ctx context.Context, name, addr string) (ln net.Listener, inherited bool, err error) {
+	if fd, ok := inheritedFDs()[name]; ok {
+		ln, err := listenerFromFD(fd)
+		return ln, err == nil, err
+	}
+
+	lc := net.ListenConfig{Control: reusePort}
+	ln, err = lc.Listen(ctx, "tcp", addr)
+	return ln, false, err
+}
+
+func listenerFromFD(fd uintptr) (net.Listener, error) {
+	f := os.NewFile(fd, "inherited-listener")
+	if f == nil {
+		return nil, errors.New("invalid inherited listener fd")
+	}
+	// FileListener dups the descriptor, so the original can be closed
+	defer f.Close()
+	return net.FileListener(f)
+}
+
+// Ready tells the process that handed its listener over (see Handoff) that
+// this one is serving. It does nothing when there is no such process.
+func Ready() error {
+	raw := os.Getenv(readyFDEnv)
+	if raw == "" {
+		return nil
+	}
+	os.Unsetenv(readyFDEnv)
+	fd, err := strconv.Atoi(raw)
+	if err != nil {
+		return err
+	}
+	f := os.NewFile(uintptr(fd), "handoff-ready")
+	if f == nil {
+		return errors.New("invalid readiness fd")
+	}
+	return reportReady(f)
+}
+
+func reportReady(f *os.File) error {
+	defer f.Close()
+	_, err := f.Write([]byte{1})
+	return err
+}
+
+// Child is a process started by Handoff
+type Child struct {
+	Process *os.Process
+	ready   *os.File
+}
+
+// WaitReady blocks until the child calls Ready. It fails if the child
+// exits first or ctx ends; the caller should then keep serving and stop
+// the child.
+func (c *Child) WaitReady(ctx context.Context) error {
+	defer c.ready.Close()
+	done := make(chan error, 1)
+	go func() {
+		var b [1]byte
+		_, err := c.ready.Read(b[:])
+		if errors.Is(err, io.EOF) {
+			err = errors.New("new process exited before it was ready")
+		}
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Handoff starts a new copy of the running binary with listeners as fds 3
+// onwards, named by their keys, and a readiness pipe after them. Both
+// processes accept on the same sockets until this one shuts down, so no
+// connection is refused during the restart.
+func Handoff(listeners map[string]net.Listener) (*Child, error) {
+	names := slices.Sorted(maps.Keys(listeners))
+	files := make([]*os.File, 0, len(names)+1)
+	// Only the child keeps the write end of the pipe, so the read sees EOF
+	// if it exits
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	for _, name := range names {
+		fl, ok := listeners[name].(interface{ File() (*os.File, error) })
+		if !ok {
+			return nil, fmt.Errorf("listener %s cannot be passed to another process", name)
+		}
+		f, err := fl.File()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	ready, readyW, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, readyW)
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	for _, kv := range os.Environ() {
+		key, _, _ := strings.Cut(kv, "=")
+		switch key {
+		case listenFDsEnv, listenFDNamesEnv, listenPIDEnv, readyFDEnv:
+		default:
+			cmd.Env = append(cmd.Env, kv)
+		}
+	}
+	cmd.Env = append(cmd.Env,
+		listenFDsEnv+"="+strconv.Itoa(len(names)),
+		listenFDNamesEnv+"="+strings.Join(names, ":"),
+		readyFDEnv+"="+strconv.Itoa(firstListenFD+len(names)),
+	)
+	cmd.ExtraFiles = files
+
+	if err := cmd.Start(); err != nil {
+		ready.Close()
+		return nil, err
+	}
+	return &Child{Process: cmd.Process, ready: ready}, nil
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package lifecycle
+
+import "syscall"
+
+// reusePort is a no-op where SO_REUSEPORT isn't available; Handoff still works
+func reusePort(_, _ string, _ syscall.RawConn) error {
+	return nil
+}
@@ -0,0 +1,88 @@
+package locks
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// Locker grants exclusive, expiring ownership of a key. TryLock returns a
+// token identifying this holder and false if the key is already held;
+// Unlock only releases the key while the token still owns it, so a holder
+// whose lock expired cannot release someone else's.
+type Locker interface {
+	TryLock(ctx context.Context, key string, ttl time.Duration) (token string, ok bool, err error)
+	Unlock(ctx context.Context, key, token string) error
+}
+
+// NewToken returns a random lock token
+func NewToken() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+type entry struct {
+	token     string
+	expiresAt time.Time
+}
+
+// MemoryLocker holds locks in process, so it only excludes requests served
+// by the same instance. Use a shared locker when running more than one replica.
+type MemoryLocker struct {
+	mu    sync.Mutex
+	held  map[string]entry
+	swept time.Time
+}
+
+func NewMemoryLocker() *MemoryLocker {
+	return &MemoryLocker{held: map[string]entry{}}
+}
+
+func (l *MemoryLocker) TryLock(_ context.Context, key string, ttl time.Duration) (string, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	// Drop expired locks now and then so abandoned keys don't accumulate
+	if now.Sub(l.swept) >= time.Minute {
+		for k, e := range l.held {
+			if !now.Before(e.expiresAt) {
+				delete(l.held, k)
+			}
+		}
+		l.swept = now
+	}
+
+	if e, ok := l.held[key]; ok && now.Before(e.expiresAt) {
+		return "", false, nil
+	}
+	token := NewToken()
+	l.held[key] = entry{token: token, expiresAt: now.Add(ttl)}
+	return token, true, nil
+}
+
+// Refresh extends the lock for ttl if the token still owns it
+func (l *MemoryLocker) Refresh(_ context.Context, key, token string, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.held[key]
+	if !ok || e.token != token || !time.Now().Before(e.expiresAt) {
+		return false, nil
+	}
+	l.held[key] = entry{token: token, expiresAt: time.Now().Add(ttl)}
+	return true, nil
+}
+
+func (l *MemoryLocker) Unlock(_ context.Context, key, token string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if e, ok := l.held[key]; ok && e.token == token {
+		delete(l.held, key)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package locks
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryLocker_ExclusiveUntilUnlockOrExpiry(t *testing.T) {
+	l := NewMemoryLocker()
+	ctx := context.Background()
+
+	token, ok, _ := l.TryLock(ctx, "order", time.Minute)
+	if !ok {
+		t.Fatal("Expected the first lock to succeed")
+	}
+	if _, ok, _ := l.TryLock(ctx, "order", time.Minute); ok {
+		t.Error("Expected a second lock on a held key to fail")
+	}
+
+	l.Unlock(ctx, "order", "stale-token")
+	if _, ok, _ := l.TryLock(ctx, "order", time.Minute); ok {
+		t.Error("Expected an unlock with the wrong token to be ignored")
+	}
+	l.Unlock(ctx, "order", token)
+	if _, ok, _ := l.TryLock(ctx, "order", time.Millisecond); !ok {
+		t.Error("Expected the lock to be free after unlock")
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	if _, ok, _ := l.TryLock(ctx, "order", time.Minute); !ok {
+		t.Error("Expected an expired lock to be taken over")
+	}
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// UserIDHeader identifies the caller, as set by the authenticating gateway
+// in front of the service
+const UserIDHeader = "X-User-ID"
+
+// RequireAdminToken only lets requests with "Authorization: Bearer <token>"
+// through to admin endpoints. An empty token leaves them open, which is
+// only meant for the local demo.
+func RequireAdminToken(token string, next http.Handler) http.Handler {
+	if token == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !HasAdminToken(r, token) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// HasAdminToken reports whether r carries the admin bearer token; it is
+// always false when no token is configured
+func HasAdminToken(r *http.Request, token string) bool {
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && token != "" && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var (
+	connActiveAttrs = metric.WithAttributes(attribute.String("http.connection.state", "active"))
+	connIdleAttrs   = metric.WithAttributes(attribute.String("http.connection.state", "idle"))
+)
+
+// ConnTracker reports the server's open connections as the
+// http.server.open_connections gauge, split into active (serving a
+// request) and idle (keep-alive, or accepted but not yet read). Install
+// ConnState as the http.Server's ConnState hook.
+type ConnTracker struct {
+	mu     sync.Mutex
+	states map[net.Conn]http.ConnState
+}
+
+func NewConnTracker(meter metric.Meter) (*ConnTracker, error) {
+	t := &ConnTracker{states: map[net.Conn]http.ConnState{}}
+	_, err := meter.Int64ObservableGauge(
+		"http.server.open_connections",
+		metric.WithDescription("Open HTTP server connections, by state (active, idle)"),
+		metric.WithUnit("{connection}"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			active, idle := t.counts()
+			o.Observe(active, connActiveAttrs)
+			o.Observe(idle, connIdleAttrs)
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// ConnState follows each connection through its states; hijacked
+// connections, such as upgraded ones, are no longer the server's
+func (t *ConnTracker) ConnState(c net.Conn, state http.ConnState) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch state {
+	case http.StateClosed, http.StateHijacked:
+		delete(t.states, c)
+	default:
+		t.states[c] = state
+	}
+}
+
+func (t *ConnTracker) counts() (active, idle int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, state := range t.states {
+		if state == http.StateActive {
+			active++
+		} else {
+			idle++
+		}
+	}
+	return active, idle
+}
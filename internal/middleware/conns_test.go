@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"go-observability-demo/internal/observability/observabilitytest"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestConnTracker(t *testing.T) {
+	recorder := observabilitytest.New(t)
+	tracker, err := NewConnTracker(otel.Meter("test"))
+	if err != nil {
+		t.Fatalf("Failed to create tracker: %v", err)
+	}
+
+	inHandler := make(chan struct{})
+	release := make(chan struct{})
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inHandler <- struct{}{}
+		<-release
+	}))
+	server.Config.ConnState = tracker.ConnState
+	server.Start()
+	defer server.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		resp, err := http.Get(server.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+	<-inHandler
+
+	states := func() map[string]int64 {
+		m, ok := recorder.Metric(t, "http.server.open_connections")
+		if !ok {
+			t.Fatal("Expected http.server.open_connections to be reported")
+		}
+		out := map[string]int64{}
+		for _, p := range m.Data.(metricdata.Gauge[int64]).DataPoints {
+			state, _ := p.Attributes.Value("http.connection.state")
+			out[state.AsString()] = p.Value
+		}
+		return out
+	}
+	if got := states(); got["active"] != 1 || got["idle"] != 0 {
+		t.Errorf("Expected 1 active connection, got %v", got)
+	}
+
+	close(release)
+	<-done
+	server.CloseClientConnections()
+	server.Close()
+	if got := states(); got["active"] != 0 || got["idle"] != 0 {
+		t.Errorf("Expected no open connections after close, got %v", got)
+	}
+}
@@ -0,0 +1,270 @@
+package middleware
+
+import (
+	"context"
+	"go-observability-demo/internal/config"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// LimiterConfig tunes the gradient limiter
+type LimiterConfig struct {
+	InitialLimit int
+	MinLimit     int
+	MaxLimit     int
+	// Smoothing weights each new limit estimate against the current one
+	Smoothing float64
+	// Tolerance is how much the short-term latency may exceed the long-term
+	// baseline before the limit starts shrinking
+	Tolerance float64
+	// NormalShare and LowShare are the fractions of the limit that normal and
+	// low priority requests may fill; the rest is kept for high priority
+	NormalShare float64
+	LowShare    float64
+}
+
+func DefaultLimiterConfig() LimiterConfig {
+	return LimiterConfig{
+		InitialLimit: 20,
+		MinLimit:     4,
+		MaxLimit:     200,
+		Smoothing:    0.2,
+		Tolerance:    1.5,
+		NormalShare:  0.8,
+		LowShare:     0.5,
+	}
+}
+
+func LimiterConfigFromEnv() LimiterConfig {
+	d := DefaultLimiterConfig()
+	return LimiterConfig{
+		InitialLimit: config.Int("CONCURRENCY_LIMIT_INITIAL", d.InitialLimit),
+		MinLimit:     config.Int("CONCURRENCY_LIMIT_MIN", d.MinLimit),
+		MaxLimit:     config.Int("CONCURRENCY_LIMIT_MAX", d.MaxLimit),
+		Smoothing:    config.Float("CONCURRENCY_LIMIT_SMOOTHING", d.Smoothing),
+		Tolerance:    config.Float("CONCURRENCY_LIMIT_TOLERANCE", d.Tolerance),
+		NormalShare:  config.Float("CONCURRENCY_LIMIT_NORMAL_SHARE", d.NormalShare),
+		LowShare:     config.Float("CONCURRENCY_LIMIT_LOW_SHARE", d.LowShare),
+	}
+}
+
+// GradientLimiter adapts a concurrency limit from the ratio between the
+// long-term and short-term request latency, in the style of Netflix's
+// Gradient2 limiter. When latency climbs above the baseline the service is
+// queueing, so the limit shrinks; when latency is flat the limit grows by
+// roughly sqrt(limit) per sample to probe for more capacity.
+type GradientLimiter struct {
+	mu       sync.Mutex
+	cfg      LimiterConfig
+	limit    float64
+	inflight int
+	longRTT  float64 // slow EWMA of latency, in seconds
+	shortRTT float64 // fast EWMA of latency, in seconds
+}
+
+func NewGradientLimiter(cfg LimiterConfig) *GradientLimiter {
+	if cfg.MinLimit < 1 {
+		cfg.MinLimit = 1
+	}
+	if cfg.MaxLimit < cfg.MinLimit {
+		cfg.MaxLimit = cfg.MinLimit
+	}
+	return &GradientLimiter{
+		cfg:   cfg,
+		limit: math.Max(float64(cfg.MinLimit), math.Min(float64(cfg.InitialLimit), float64(cfg.MaxLimit))),
+	}
+}
+
+// Acquire reserves a slot. The returned release func must be called with the
+// observed latency once the request completes.
+func (l *GradientLimiter) Acquire() (release func(time.Duration), ok bool) {
+	return l.AcquirePriority(PriorityHigh)
+}
+
+// AcquirePriority is Acquire for a request that may only fill its
+// priority's share of the limit
+func (l *GradientLimiter) AcquirePriority(p Priority) (release func(time.Duration), ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if float64(l.inflight) >= l.limit*l.share(p) {
+		return nil, false
+	}
+	l.inflight++
+
+	var once sync.Once
+	return func(rtt time.Duration) {
+		once.Do(func() { l.release(rtt) })
+	}, true
+}
+
+// share returns the fraction of the limit open to p; unset shares admit
+// up to the full limit
+func (l *GradientLimiter) share(p Priority) float64 {
+	var share float64
+	switch p {
+	case PriorityLow:
+		share = l.cfg.LowShare
+	case PriorityNormal:
+		share = l.cfg.NormalShare
+	}
+	if share <= 0 || share > 1 {
+		return 1
+	}
+	return share
+}
+
+func (l *GradientLimiter) release(rtt time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	inflight := l.inflight
+	l.inflight--
+
+	sample := rtt.Seconds()
+	if sample <= 0 {
+		return
+	}
+	if l.longRTT == 0 {
+		l.longRTT, l.shortRTT = sample, sample
+		return
+	}
+	l.shortRTT = l.shortRTT*0.9 + sample*0.1
+	l.longRTT = l.longRTT*0.995 + sample*0.005
+
+	// Only grow the limit when it is actually being used, otherwise an idle
+	// service would drift up to MaxLimit and lose its protection
+	if float64(inflight) < l.limit/2 && l.shortRTT <= l.longRTT {
+		return
+	}
+
+	gradient := math.Max(0.5, math.Min(1, l.cfg.Tolerance*l.longRTT/l.shortRTT))
+	estimate := l.limit*gradient + math.Sqrt(l.limit)
+	next := l.limit*(1-l.cfg.Smoothing) + estimate*l.cfg.Smoothing
+	l.limit = math.Max(float64(l.cfg.MinLimit), math.Min(next, float64(l.cfg.MaxLimit)))
+
+	// Let the baseline drift down after overload so it doesn't anchor on
+	// latencies measured while the service was saturated
+	if l.longRTT > l.shortRTT*2 {
+		l.longRTT *= 0.95
+	}
+}
+
+// Limit returns the current concurrency limit
+func (l *GradientLimiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.limit)
+}
+
+// Inflight returns the number of requests holding a slot
+func (l *GradientLimiter) Inflight() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inflight
+}
+
+// RetryAfter suggests how long a shed client should wait: about one
+// baseline latency, rounded up to whole seconds as the header requires
+func (l *GradientLimiter) RetryAfter() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return time.Duration(math.Max(1, math.Ceil(l.longRTT))) * time.Second
+}
+
+// ConcurrencyLimiter is HTTP middleware that sheds load with 503 and
+// Retry-After once the gradient limit is reached. Low priority requests are
+// shed first because they may only use part of the limit.
+type ConcurrencyLimiter struct {
+	limiter *GradientLimiter
+	shed    metric.Int64Counter
+}
+
+func NewConcurrencyLimiter(limiter *GradientLimiter, meter metric.Meter) (*ConcurrencyLimiter, error) {
+	limitGauge, err := meter.Int64ObservableGauge(
+		"http.server.concurrency_limit",
+		metric.WithDescription("Current adaptive concurrency limit"),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	inflightGauge, err := meter.Int64ObservableGauge(
+		"http.server.concurrency_limit.inflight",
+		metric.WithDescription("Requests currently holding a concurrency slot"),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	shed, err := meter.Int64Counter(
+		"http.server.requests.shed",
+		metric.WithDescription("Requests rejected because the concurrency limit was reached, by priority"),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveInt64(limitGauge, int64(limiter.Limit()))
+		o.ObserveInt64(inflightGauge, int64(limiter.Inflight()))
+		return nil
+	}, limitGauge, inflightGauge)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ConcurrencyLimiter{limiter: limiter, shed: shed}, nil
+}
+
+// Wrap limits route at normal priority
+func (c *ConcurrencyLimiter) Wrap(route string, next http.Handler) http.Handler {
+	return c.WrapPriority(route, PriorityNormal, next)
+}
+
+// WrapPriority limits route at priority, which requests can lower with
+// PriorityHeader
+func (c *ConcurrencyLimiter) WrapPriority(route string, priority Priority, next http.Handler) http.Handler {
+	var shedAttrs [PriorityHigh + 1]metric.MeasurementOption
+	for p := range shedAttrs {
+		shedAttrs[p] = metric.WithAttributeSet(attribute.NewSet(
+			attribute.String("http.route", route),
+			attribute.String("reason", "concurrency_limit"),
+			attribute.String("priority", Priority(p).String()),
+		))
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := ClassifyRequest(r, priority)
+		span := trace.SpanFromContext(r.Context())
+		span.SetAttributes(attribute.String("request.priority", p.String()))
+
+		release, ok := c.limiter.AcquirePriority(p)
+		if !ok {
+			c.shed.Add(r.Context(), 1, shedAttrs[p])
+			span.AddEvent("request_shed", trace.WithAttributes(
+				attribute.String("reason", "concurrency_limit"),
+				attribute.String("priority", p.String()),
+				attribute.Int("limit", c.limiter.Limit()),
+				attribute.Int("inflight", c.limiter.Inflight()),
+			))
+			w.Header().Set("Retry-After", strconv.Itoa(int(c.limiter.RetryAfter().Seconds())))
+			http.Error(w, "service overloaded", http.StatusServiceUnavailable)
+			return
+		}
+
+		start := time.Now()
+		defer func() { release(time.Since(start)) }()
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,154 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+// saturate acquires every free slot and releases them all with rtt,
+// simulating a fully utilized service
+func saturate(l *GradientLimiter, rtt time.Duration, rounds int) {
+	for i := 0; i < rounds; i++ {
+		var releases []func(time.Duration)
+		for {
+			release, ok := l.Acquire()
+			if !ok {
+				break
+			}
+			releases = append(releases, release)
+		}
+		for _, release := range releases {
+			release(rtt)
+		}
+	}
+}
+
+func TestGradientLimiter_RejectsAtLimit(t *testing.T) {
+	l := NewGradientLimiter(LimiterConfig{InitialLimit: 2, MinLimit: 1, MaxLimit: 10, Smoothing: 0.2, Tolerance: 1.5})
+
+	r1, ok1 := l.Acquire()
+	r2, ok2 := l.Acquire()
+	_, ok3 := l.Acquire()
+	if !ok1 || !ok2 || ok3 {
+		t.Fatalf("Expected two slots then rejection, got %v %v %v", ok1, ok2, ok3)
+	}
+
+	r1(time.Millisecond)
+	r1(time.Millisecond) // double release must not free two slots
+	if l.Inflight() != 1 {
+		t.Errorf("Expected 1 inflight request, got %d", l.Inflight())
+	}
+	r2(time.Millisecond)
+}
+
+func TestGradientLimiter_AdaptsToLatency(t *testing.T) {
+	l := NewGradientLimiter(LimiterConfig{InitialLimit: 10, MinLimit: 2, MaxLimit: 100, Smoothing: 0.2, Tolerance: 1.5})
+
+	saturate(l, 10*time.Millisecond, 50)
+	grown := l.Limit()
+	if grown <= 10 {
+		t.Fatalf("Expected limit to grow under steady latency, got %d", grown)
+	}
+
+	saturate(l, 200*time.Millisecond, 1)
+	if shrunk := l.Limit(); shrunk >= grown {
+		t.Errorf("Expected limit to shrink when latency rises, got %d (was %d)", shrunk, grown)
+	}
+}
+
+func TestConcurrencyLimiter_ShedsWith503(t *testing.T) {
+	l := NewGradientLimiter(LimiterConfig{InitialLimit: 1, MinLimit: 1, MaxLimit: 1, Smoothing: 0.2, Tolerance: 1.5})
+	mw, err := NewConcurrencyLimiter(l, noop.NewMeterProvider().Meter("test"))
+	if err != nil {
+		t.Fatalf("Failed to create middleware: %v", err)
+	}
+
+	release, _ := l.Acquire()
+	defer release(time.Millisecond)
+
+	handler := mw.Wrap("/orders", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Handler should not run when the limit is reached")
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/orders", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("Expected Retry-After header on shed response")
+	}
+}
+
+func TestGradientLimiter_ShedsLowPriorityFirst(t *testing.T) {
+	l := NewGradientLimiter(LimiterConfig{InitialLimit: 10, MinLimit: 10, MaxLimit: 10, NormalShare: 0.8, LowShare: 0.5})
+
+	var admitted [PriorityHigh + 1]int
+	for _, p := range []Priority{PriorityLow, PriorityNormal, PriorityHigh} {
+		for {
+			if _, ok := l.AcquirePriority(p); !ok {
+				break
+			}
+			admitted[p]++
+		}
+	}
+
+	// Low fills half the limit, normal tops it up to 80%, high takes the rest
+	if admitted != [PriorityHigh + 1]int{5, 3, 2} {
+		t.Errorf("Expected 5 low, 3 normal, 2 high admitted, got %v", admitted)
+	}
+}
+
+func TestClassifyRequest_HeaderOnlyLowersPriority(t *testing.T) {
+	tests := []struct {
+		header string
+		route  Priority
+		want   Priority
+	}{
+		{"", PriorityHigh, PriorityHigh},
+		{"bulk", PriorityHigh, PriorityLow},
+		{"high", PriorityLow, PriorityLow},
+		{"nonsense", PriorityNormal, PriorityNormal},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/orders", nil)
+		r.Header.Set(PriorityHeader, tt.header)
+		if got := ClassifyRequest(r, tt.route); got != tt.want {
+			t.Errorf("ClassifyRequest(%q, %s): expected %s, got %s", tt.header, tt.route, tt.want, got)
+		}
+	}
+}
+
+func TestConcurrencyLimiter_ShedsBulkBeforeInteractive(t *testing.T) {
+	l := NewGradientLimiter(LimiterConfig{InitialLimit: 2, MinLimit: 2, MaxLimit: 2, LowShare: 0.5})
+	mw, err := NewConcurrencyLimiter(l, noop.NewMeterProvider().Meter("test"))
+	if err != nil {
+		t.Fatalf("Failed to create middleware: %v", err)
+	}
+
+	release, _ := l.AcquirePriority(PriorityLow)
+	defer release(time.Millisecond)
+
+	handler := mw.WrapPriority("/orders", PriorityHigh, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	bulk := httptest.NewRequest(http.MethodPost, "/orders", nil)
+	bulk.Header.Set(PriorityHeader, "low")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, bulk)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected bulk request to be shed, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/orders", nil))
+	if rec.Code != http.StatusCreated {
+		t.Errorf("Expected interactive request to be admitted, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,243 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"go-observability-demo/internal/config"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ShadowHeader marks mirrored requests. It carries the mirror's shared
+// secret, and the order service honours it by checking the order without
+// charging, reserving, storing, or announcing it.
+const ShadowHeader = "X-Shadow-Request"
+
+// IsShadow reports whether r was sent by a mirror sharing secret; it is
+// always false when no secret is configured
+func IsShadow(r *http.Request, secret string) bool {
+	got := r.Header.Get(ShadowHeader)
+	return got != "" && secret != "" && subtle.ConstantTimeCompare([]byte(got), []byte(secret)) == 1
+}
+
+// StripShadowHeader drops ShadowHeader from requests that don't carry the
+// mirror's secret, so clients can't pass their orders off as shadows
+func StripShadowHeader(secret string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(ShadowHeader) != "" && !IsShadow(r, secret) {
+			r.Header.Del(ShadowHeader)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// MirrorConfig selects how much traffic is copied to the shadow endpoint
+type MirrorConfig struct {
+	// URL is the base of the shadow instance; the request path is appended
+	URL string
+	// Secret is sent in ShadowHeader; the shadow instance only treats
+	// requests carrying it as shadows
+	Secret  string
+	Percent float64
+	Timeout time.Duration
+	// MaxInflight caps concurrent shadow requests; extra ones are skipped
+	MaxInflight int
+	// MaxBodyBytes skips requests whose body is larger than this
+	MaxBodyBytes int64
+}
+
+func MirrorConfigFromEnv() MirrorConfig {
+	return MirrorConfig{
+		URL:          config.String("MIRROR_URL", ""),
+		Secret:       config.String("MIRROR_SECRET", ""),
+		Percent:      config.Float("MIRROR_PERCENT", 10),
+		Timeout:      config.Duration("MIRROR_TIMEOUT", 2*time.Second),
+		MaxInflight:  config.Int("MIRROR_MAX_INFLIGHT", 16),
+		MaxBodyBytes: int64(config.Int("MIRROR_MAX_BODY_BYTES", 1<<20)),
+	}
+}
+
+func (c MirrorConfig) Enabled() bool {
+	return c.URL != "" && c.Percent > 0
+}
+
+// Mirror copies a percentage of requests to a shadow endpoint once the
+// primary response is written, and discards the shadow response. Each
+// shadow request is its own trace linked to the primary one, so canary
+// traces never inflate the primary's latency, and its status is compared
+// with the primary's.
+type Mirror struct {
+	cfg    MirrorConfig
+	client *http.Client
+	tracer trace.Tracer
+	slots  chan struct{}
+	wg     sync.WaitGroup
+
+	requests   metric.Int64Counter
+	mismatches metric.Int64Counter
+}
+
+func NewMirror(cfg MirrorConfig, meter metric.Meter) (*Mirror, error) {
+	if cfg.Secret == "" {
+		// Without it the shadow would take mirrored orders for real ones
+		return nil, errors.New("mirroring requires MIRROR_SECRET")
+	}
+	if cfg.MaxInflight <= 0 {
+		cfg.MaxInflight = 1
+	}
+
+	requests, err := meter.Int64Counter(
+		"http.server.mirror.requests",
+		metric.WithDescription("Requests copied to the shadow endpoint, by outcome"),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	mismatches, err := meter.Int64Counter(
+		"http.server.mirror.status_mismatches",
+		metric.WithDescription("Shadow responses whose status class differs from the primary"),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Mirror{
+		cfg:        cfg,
+		client:     &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport), Timeout: cfg.Timeout},
+		tracer:     otel.Tracer("order-service/mirror"),
+		slots:      make(chan struct{}, cfg.MaxInflight),
+		requests:   requests,
+		mismatches: mismatches,
+	}, nil
+}
+
+func (m *Mirror) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rand.Float64()*100 >= m.cfg.Percent || r.ContentLength > m.cfg.MaxBodyBytes {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var body []byte
+		if r.Body != nil {
+			var err error
+			body, err = io.ReadAll(io.LimitReader(r.Body, m.cfg.MaxBodyBytes+1))
+			r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+			if err != nil || int64(len(body)) > m.cfg.MaxBodyBytes {
+				m.count(r.Context(), "skipped")
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		select {
+		case m.slots <- struct{}{}:
+		default:
+			m.count(r.Context(), "skipped")
+			return
+		}
+		primary := trace.SpanContextFromContext(r.Context())
+		shadow := r.Clone(context.WithoutCancel(r.Context()))
+		m.wg.Add(1)
+		go func() {
+			defer func() {
+				<-m.slots
+				m.wg.Done()
+			}()
+			m.send(shadow, body, primary, rec.status)
+		}()
+	})
+}
+
+func (m *Mirror) send(r *http.Request, body []byte, primary trace.SpanContext, primaryStatus int) {
+	// A new root keeps the shadow out of the primary trace; the link lets
+	// the two be compared side by side
+	opts := []trace.SpanStartOption{
+		trace.WithNewRoot(),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", r.Method),
+			attribute.String("url.path", r.URL.Path),
+			attribute.Int("mirror.primary_status_code", primaryStatus),
+		),
+	}
+	if primary.IsValid() {
+		opts = append(opts, trace.WithLinks(trace.Link{
+			SpanContext: primary,
+			Attributes:  []attribute.KeyValue{attribute.String("link.type", "mirror_of")},
+		}))
+	}
+	ctx, span := m.tracer.Start(context.Background(), "MirrorRequest", opts...)
+	defer span.End()
+
+	req, err := http.NewRequestWithContext(ctx, r.Method, strings.TrimSuffix(m.cfg.URL, "/")+r.URL.RequestURI(), bytes.NewReader(body))
+	if err != nil {
+		m.fail(ctx, span, err)
+		return
+	}
+	req.Header = r.Header.Clone()
+	req.Header.Set(ShadowHeader, m.cfg.Secret)
+	// The shadow has its own trace; drop the primary's context headers
+	for _, k := range otel.GetTextMapPropagator().Fields() {
+		req.Header.Del(k)
+	}
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		m.fail(ctx, span, err)
+		return
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode/100 != primaryStatus/100 {
+		span.AddEvent("mirror_status_mismatch")
+		m.mismatches.Add(ctx, 1)
+	}
+	m.count(ctx, "sent")
+}
+
+func (m *Mirror) fail(ctx context.Context, span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, "shadow request failed")
+	m.count(ctx, "failed")
+}
+
+func (m *Mirror) count(ctx context.Context, outcome string) {
+	m.requests.Add(ctx, 1, metric.WithAttributes(attribute.String("outcome", outcome)))
+}
+
+// Close waits for shadow requests still in flight
+func (m *Mirror) Close(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"context"
+	"go-observability-demo/internal/observability/observabilitytest"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+func TestMirror_CopiesRequestToShadowWithLinkedTrace(t *testing.T) {
+	recorder := observabilitytest.New(t)
+	prev := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTextMapPropagator(prev) })
+
+	type shadowRequest struct {
+		body, shadowHeader, traceparent string
+	}
+	received := make(chan shadowRequest, 1)
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- shadowRequest{string(body), r.Header.Get(ShadowHeader), r.Header.Get("traceparent")}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer shadow.Close()
+
+	mirror, err := NewMirror(MirrorConfig{URL: shadow.URL, Secret: "s3cret", Percent: 100, MaxInflight: 1, MaxBodyBytes: 1024}, otel.Meter("test"))
+	if err != nil {
+		t.Fatalf("Failed to create mirror: %v", err)
+	}
+	handler := mirror.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != `{"user_id":"u1"}` {
+			t.Errorf("Primary handler got body %q", body)
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	ctx, primary := otel.Tracer("test").Start(context.Background(), "POST /orders")
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(`{"user_id":"u1"}`)).WithContext(ctx)
+	req.Header.Set("traceparent", "00-"+primary.SpanContext().TraceID().String()+"-"+primary.SpanContext().SpanID().String()+"-01")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	primary.End()
+
+	if rec.Code != http.StatusCreated {
+		t.Errorf("Expected the primary response to be untouched, got %d", rec.Code)
+	}
+	if err := mirror.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	got := <-received
+	if got.body != `{"user_id":"u1"}` || got.shadowHeader != "s3cret" {
+		t.Errorf("Unexpected shadow request %+v", got)
+	}
+
+	span := recorder.SpansNamed("MirrorRequest")[0]
+	if span.SpanContext.TraceID() == primary.SpanContext().TraceID() {
+		t.Error("Shadow request should be its own trace")
+	}
+	if !strings.Contains(got.traceparent, span.SpanContext.TraceID().String()) {
+		t.Errorf("Expected the shadow trace in traceparent, got %q", got.traceparent)
+	}
+	if len(span.Links) != 1 || span.Links[0].SpanContext.SpanID() != primary.SpanContext().SpanID() {
+		t.Errorf("Expected a link to the primary span, got %v", span.Links)
+	}
+	if got := recorder.Int64Sum(t, "http.server.mirror.status_mismatches"); got != 1 {
+		t.Errorf("Expected the 500 vs 201 mismatch to be counted, got %d", got)
+	}
+}
+
+func TestStripShadowHeader(t *testing.T) {
+	for _, tc := range []struct {
+		name, header, want string
+	}{
+		{"mirror", "s3cret", "s3cret"},
+		{"client", "true", ""},
+		{"none", "", ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var got string
+			handler := StripShadowHeader("s3cret", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.Header.Get(ShadowHeader)
+			}))
+			req := httptest.NewRequest(http.MethodPost, "/orders", nil)
+			if tc.header != "" {
+				req.Header.Set(ShadowHeader, tc.header)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+			if got != tc.want {
+				t.Errorf("Expected %q to reach the handler, got %q", tc.want, got)
+			}
+		})
+	}
+}
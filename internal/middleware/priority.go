@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Priority orders traffic for load shedding: under overload the lowest
+// priorities are rejected first
+type Priority int
+
+const (
+	// PriorityLow is bulk and listing traffic that can be retried later
+	PriorityLow Priority = iota
+	// PriorityNormal is the default for routes without a classification
+	PriorityNormal
+	// PriorityHigh is interactive traffic such as order creation
+	PriorityHigh
+)
+
+// PriorityHeader lets callers mark a request as less important than its
+// route, e.g. a batch job sending X-Request-Priority: low
+const PriorityHeader = "X-Request-Priority"
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityHigh:
+		return "high"
+	default:
+		return "normal"
+	}
+}
+
+// ParsePriority accepts low/bulk, normal, and high/interactive
+func ParsePriority(s string) (Priority, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "low", "bulk":
+		return PriorityLow, true
+	case "normal":
+		return PriorityNormal, true
+	case "high", "interactive":
+		return PriorityHigh, true
+	}
+	return PriorityNormal, false
+}
+
+// ClassifyRequest returns the route's priority, lowered by PriorityHeader.
+// The header can never raise it, so clients can't promote their own traffic.
+func ClassifyRequest(r *http.Request, route Priority) Priority {
+	if p, ok := ParsePriority(r.Header.Get(PriorityHeader)); ok && p < route {
+		return p
+	}
+	return route
+}
@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// durationBuckets are the semantic conventions' advisory boundaries for
+// http.server.request.duration, in seconds
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.075, 0.1, 0.25, 0.5, 0.75, 1, 2.5, 5, 7.5, 10}
+
+// knownMethods are recorded as is; anything else is _OTHER, as the
+// semantic conventions require, so made-up methods can't add series
+var knownMethods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true,
+	http.MethodPatch: true, http.MethodDelete: true, http.MethodConnect: true,
+	http.MethodOptions: true, http.MethodTrace: true,
+}
+
+// REDMetrics records rate, errors, and duration for every route of a
+// ServeMux: http.server.request.duration, http.server.requests, and
+// http.server.active_requests, by http.request.method and http.route, plus
+// http.response.status_code once the response is written. Requests that
+// match no route have no http.route.
+type REDMetrics struct {
+	duration metric.Float64Histogram
+	requests metric.Int64Counter
+	active   metric.Int64UpDownCounter
+}
+
+func NewREDMetrics(meter metric.Meter) (*REDMetrics, error) {
+	duration, err := meter.Float64Histogram(
+		"http.server.request.duration",
+		metric.WithDescription("Duration of HTTP server requests"),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(durationBuckets...),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	requests, err := meter.Int64Counter(
+		"http.server.requests",
+		metric.WithDescription("HTTP server requests, by route and status code"),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	active, err := meter.Int64UpDownCounter(
+		"http.server.active_requests",
+		metric.WithDescription("HTTP server requests in flight"),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &REDMetrics{duration: duration, requests: requests, active: active}, nil
+}
+
+// Wrap measures every request mux serves. It looks the route up before
+// serving, so in-flight requests are labeled with it too.
+func (m *REDMetrics) Wrap(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		start := time.Now()
+
+		attrs := make([]attribute.KeyValue, 0, 3)
+		attrs = append(attrs, attribute.String("http.request.method", method(r.Method)))
+		if _, pattern := mux.Handler(r); pattern != "" {
+			attrs = append(attrs, attribute.String("http.route", route(pattern)))
+		}
+		inFlight := metric.WithAttributeSet(attribute.NewSet(attrs...))
+		m.active.Add(ctx, 1, inFlight)
+		defer m.active.Add(ctx, -1, inFlight)
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		mux.ServeHTTP(rec, r)
+
+		done := metric.WithAttributes(append(attrs, attribute.Int("http.response.status_code", rec.status))...)
+		m.duration.Record(ctx, time.Since(start).Seconds(), done)
+		m.requests.Add(ctx, 1, done)
+	})
+}
+
+func method(m string) string {
+	if knownMethods[m] {
+		return m
+	}
+	return "_OTHER"
+}
+
+// route strips the method and host from a ServeMux pattern, e.g.
+// "GET /users/{id}/orders" becomes "/users/{id}/orders"
+func route(pattern string) string {
+	if i := strings.Index(pattern, "/"); i >= 0 {
+		return pattern[i:]
+	}
+	return pattern
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"go-observability-demo/internal/observability/observabilitytest"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestREDMetrics(t *testing.T) {
+	recorder := observabilitytest.New(t)
+	red, err := NewREDMetrics(otel.Meter("test"))
+	if err != nil {
+		t.Fatalf("Failed to create RED metrics: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users/{id}/orders", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/orders", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+	})
+	handler := red.Wrap(mux)
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/users/alice/orders", nil),
+		httptest.NewRequest(http.MethodGet, "/users/bob/orders", nil),
+		httptest.NewRequest(http.MethodPost, "/orders", nil),
+		httptest.NewRequest("BREW", "/orders", nil),
+		httptest.NewRequest(http.MethodGet, "/nowhere", nil),
+	} {
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	m, ok := recorder.Metric(t, "http.server.requests")
+	if !ok {
+		t.Fatal("Expected http.server.requests to be recorded")
+	}
+	counts := map[string]int64{}
+	for _, p := range m.Data.(metricdata.Sum[int64]).DataPoints {
+		method, _ := p.Attributes.Value("http.request.method")
+		route, _ := p.Attributes.Value("http.route")
+		status, _ := p.Attributes.Value("http.response.status_code")
+		counts[method.AsString()+" "+route.AsString()+" "+status.Emit()] += p.Value
+	}
+	want := map[string]int64{
+		"GET /users/{id}/orders 200": 2,
+		"POST /orders 400":           1,
+		"_OTHER /orders 400":         1,
+		"GET  404":                   1,
+	}
+	for k, v := range want {
+		if counts[k] != v {
+			t.Errorf("Expected %d requests for %q, got %v", v, k, counts)
+		}
+	}
+
+	if _, ok := recorder.Metric(t, "http.server.request.duration"); !ok {
+		t.Error("Expected http.server.request.duration to be recorded")
+	}
+	if got := recorder.Int64Sum(t, "http.server.active_requests"); got != 0 {
+		t.Errorf("Expected no requests in flight afterwards, got %d", got)
+	}
+}
+
+func TestRoute(t *testing.T) {
+	for pattern, want := range map[string]string{
+		"GET /users/{id}/orders": "/users/{id}/orders",
+		"/health":                "/health",
+		"example.com/admin/":     "/admin/",
+	} {
+		if got := route(pattern); got != want {
+			t.Errorf("route(%q) = %q, want %q", pattern, got, want)
+		}
+	}
+}
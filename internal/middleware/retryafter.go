@@ -0,0 +1,216 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// ClientIDHeader identifies a client across connections; without it the
+// remote IP is used
+const ClientIDHeader = "X-Client-ID"
+
+// maxTrackedClients bounds the backoff table; expired entries are swept
+// once it is reached
+const maxTrackedClients = 10000
+
+// RetryAfterTracker watches responses for a Retry-After header, whichever
+// handler set it (concurrency limiter, drain gate, quotas), and counts
+// clients that come back before the time they were given. A high ignored
+// count means retries are amplifying an overload instead of backing off.
+type RetryAfterTracker struct {
+	issued  metric.Int64Counter
+	ignored metric.Int64Counter
+
+	mu    sync.Mutex
+	until map[string]time.Time
+}
+
+func NewRetryAfterTracker(meter metric.Meter) (*RetryAfterTracker, error) {
+	issued, err := meter.Int64Counter(
+		"http.server.retry_after.issued",
+		metric.WithDescription("Responses that told the client to back off with Retry-After, by status code"),
+		metric.WithUnit("{response}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	ignored, err := meter.Int64Counter(
+		"http.server.retry_after.ignored",
+		metric.WithDescription("Requests from clients that retried before their Retry-After elapsed"),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RetryAfterTracker{issued: issued, ignored: ignored, until: map[string]time.Time{}}, nil
+}
+
+func (t *RetryAfterTracker) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client := clientKey(r)
+		if t.returnedEarly(client) {
+			t.ignored.Add(r.Context(), 1)
+		}
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		if rec.status != http.StatusServiceUnavailable && rec.status != http.StatusTooManyRequests {
+			return
+		}
+		seconds, err := strconv.Atoi(w.Header().Get("Retry-After"))
+		if err != nil || seconds <= 0 {
+			return
+		}
+		t.remember(client, time.Now().Add(time.Duration(seconds)*time.Second))
+		t.issued.Add(r.Context(), 1, metric.WithAttributes(attribute.Int("http.response.status_code", rec.status)))
+	})
+}
+
+// returnedEarly reports whether client is still inside its Retry-After
+func (t *RetryAfterTracker) returnedEarly(client string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	until, ok := t.until[client]
+	if !ok {
+		return false
+	}
+	if time.Now().Before(until) {
+		return true
+	}
+	delete(t.until, client)
+	return false
+}
+
+func (t *RetryAfterTracker) remember(client string, until time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.until) >= maxTrackedClients {
+		now := time.Now()
+		for k, u := range t.until {
+			if u.Before(now) {
+				delete(t.until, k)
+			}
+		}
+		if len(t.until) >= maxTrackedClients {
+			return
+		}
+	}
+	t.until[client] = until
+}
+
+func clientKey(r *http.Request) string {
+	if id := r.Header.Get(ClientIDHeader); id != "" {
+		return id
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// statusRecorder captures the status code while passing writes through
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if !r.wroteHeader {
+		r.status = code
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach Flush and deadlines on the
+// underlying writer
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// DrainGate turns new requests away with 503 once shutdown begins. Drain
+// holds the listener open for the grace period so load balancers notice
+// the failing health check and stop routing here; requests arriving
+// meanwhile are told to retry once the grace period is over, by which time
+// they will land on another instance.
+type DrainGate struct {
+	grace time.Duration
+
+	mu       sync.Mutex
+	draining bool
+	deadline time.Time
+}
+
+func NewDrainGate(grace time.Duration) *DrainGate {
+	return &DrainGate{grace: grace}
+}
+
+// Drain starts rejecting requests and waits out the grace period
+func (g *DrainGate) Drain(ctx context.Context) error {
+	g.mu.Lock()
+	g.draining = true
+	g.deadline = time.Now().Add(g.grace)
+	g.mu.Unlock()
+
+	if g.grace <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(g.grace)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// RetryAfter returns how long a rejected client should wait, or false when
+// the gate is open
+func (g *DrainGate) RetryAfter() (time.Duration, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.draining {
+		return 0, false
+	}
+	return max(time.Second, time.Until(g.deadline)), true
+}
+
+func (g *DrainGate) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		retryAfter, draining := g.RetryAfter()
+		if !draining {
+			next.ServeHTTP(w, r)
+			return
+		}
+		// Close keep-alive connections so the client reconnects elsewhere
+		w.Header().Set("Connection", "close")
+		w.Header().Set("Retry-After", strconv.Itoa(int((retryAfter+time.Second-1)/time.Second)))
+		http.Error(w, "service draining", http.StatusServiceUnavailable)
+	})
+}
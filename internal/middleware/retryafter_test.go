@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"context"
+	"go-observability-demo/internal/observability/observabilitytest"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+)
+
+func TestRetryAfterTracker_CountsEarlyRetries(t *testing.T) {
+	recorder := observabilitytest.New(t)
+	tracker, err := NewRetryAfterTracker(otel.Meter("test"))
+	if err != nil {
+		t.Fatalf("Failed to create tracker: %v", err)
+	}
+
+	shed := true
+	handler := tracker.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if shed {
+			w.Header().Set("Retry-After", "30")
+			http.Error(w, "service overloaded", http.StatusServiceUnavailable)
+		}
+	}))
+
+	send := func(client string) {
+		req := httptest.NewRequest(http.MethodPost, "/orders", nil)
+		req.Header.Set(ClientIDHeader, client)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	send("loadgen-1")
+	shed = false
+	send("loadgen-1") // retried immediately despite Retry-After: 30
+	send("loadgen-2") // never told to back off
+
+	if got := recorder.Int64Sum(t, "http.server.retry_after.issued"); got != 1 {
+		t.Errorf("Expected 1 Retry-After issued, got %d", got)
+	}
+	if got := recorder.Int64Sum(t, "http.server.retry_after.ignored"); got != 1 {
+		t.Errorf("Expected 1 ignored Retry-After, got %d", got)
+	}
+}
+
+func TestDrainGate_RejectsWithRetryAfterWhileDraining(t *testing.T) {
+	gate := NewDrainGate(2 * time.Second)
+	handler := gate.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200 before draining, got %d", rec.Code)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- gate.Drain(ctx) }()
+	time.Sleep(10 * time.Millisecond)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 while draining, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Expected Retry-After of the remaining grace period (2), got %q", got)
+	}
+	if rec.Header().Get("Connection") != "close" {
+		t.Error("Expected the connection to be closed while draining")
+	}
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("Expected Drain to stop with its context, got %v", err)
+	}
+}
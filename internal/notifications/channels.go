@@ -0,0 +1,92 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// EmailChannel delivers over SMTP with the sender's own retries; only
+// errors the sender gave up on as transient are retried again later
+type EmailChannel struct {
+	Sender *EmailSender
+}
+
+func (EmailChannel) Name() string { return "email" }
+
+func (c EmailChannel) Deliver(ctx context.Context, m Message) (string, error) {
+	messageID, err := c.Sender.Send(ctx, Email{To: []string{m.To}, Subject: m.Subject, Body: m.Body, OrderID: m.OrderID})
+	if err != nil && !retryable(err) {
+		return messageID, Permanent(err)
+	}
+	return messageID, err
+}
+
+// WebhookChannel POSTs the event as JSON to the user's URL. The delivery
+// ID is sent in X-Notification-ID for deduplication and the trace context
+// travels in the usual headers when Client is instrumented.
+type WebhookChannel struct {
+	Client *http.Client
+}
+
+func (WebhookChannel) Name() string { return "webhook" }
+
+func (c WebhookChannel) Deliver(ctx context.Context, m Message) (string, error) {
+	_, err := postJSON(ctx, c.Client, m.To, m.ID, m.Event)
+	return m.ID, err
+}
+
+// SMSChannel sends the subject line through an HTTP SMS gateway that
+// accepts {"to", "body"} and replies with the message's {"id"}
+type SMSChannel struct {
+	URL    string
+	Client *http.Client
+}
+
+func (SMSChannel) Name() string { return "sms" }
+
+func (c SMSChannel) Deliver(ctx context.Context, m Message) (string, error) {
+	resp, err := postJSON(ctx, c.Client, c.URL, m.ID, map[string]string{"to": m.To, "body": m.Subject})
+	if err != nil {
+		return "", err
+	}
+	var reply struct {
+		ID string `json:"id"`
+	}
+	json.Unmarshal(resp, &reply)
+	return reply.ID, nil
+}
+
+// postJSON returns the response body of a 2xx reply. Other 4xx replies
+// than 429 are permanent errors.
+func postJSON(ctx context.Context, client *http.Client, url, deliveryID string, body any) ([]byte, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, Permanent(err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Notification-ID", deliveryID)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	reply, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode/100 != 2 {
+		err := fmt.Errorf("%s returned %d", req.URL.Host, resp.StatusCode)
+		if resp.StatusCode/100 == 4 && resp.StatusCode != http.StatusTooManyRequests {
+			return nil, Permanent(err)
+		}
+		return nil, err
+	}
+	return reply, nil
+}
@@ -0,0 +1,224 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"go-observability-demo/internal/config"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// EmailConfig configures the SMTP sender
+type EmailConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	// MaxPerSecond rate limits sends across the process
+	MaxPerSecond float64
+	MaxRetries   int
+}
+
+func EmailConfigFromEnv() EmailConfig {
+	return EmailConfig{
+		Host:         config.String("SMTP_HOST", ""),
+		Port:         config.Int("SMTP_PORT", 587),
+		Username:     config.String("SMTP_USERNAME", ""),
+		Password:     config.String("SMTP_PASSWORD", ""),
+		From:         config.String("SMTP_FROM", "orders@example.com"),
+		MaxPerSecond: config.Float("SMTP_MAX_PER_SECOND", 5),
+		MaxRetries:   config.Int("SMTP_MAX_RETRIES", 3),
+	}
+}
+
+func (c EmailConfig) Enabled() bool {
+	return c.Host != ""
+}
+
+// Email is a plain-text message about an order
+type Email struct {
+	To      []string
+	Subject string
+	Body    string
+	OrderID string
+}
+
+// sendFunc matches smtp.SendMail so tests can replace the transport
+type sendFunc func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+
+// EmailSender sends traced, retried, and rate limited emails. Each message
+// gets a Message-ID recorded on the send span and carries the trace context
+// in its headers, so a delivered email can be traced back to its order.
+type EmailSender struct {
+	cfg     EmailConfig
+	send    sendFunc
+	tracer  trace.Tracer
+	sent    metric.Int64Counter
+	limiter *rateLimiter
+	backoff time.Duration
+}
+
+func NewEmailSender(cfg EmailConfig, meter metric.Meter) (*EmailSender, error) {
+	sent, err := meter.Int64Counter(
+		"notifications.email.sent",
+		metric.WithDescription("Emails sent, by outcome"),
+		metric.WithUnit("{email}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EmailSender{
+		cfg:     cfg,
+		send:    smtp.SendMail,
+		tracer:  otel.Tracer("order-service/notifications"),
+		sent:    sent,
+		limiter: newRateLimiter(cfg.MaxPerSecond),
+		backoff: 500 * time.Millisecond,
+	}, nil
+}
+
+// Send delivers e and returns its Message-ID
+func (s *EmailSender) Send(ctx context.Context, e Email) (string, error) {
+	ctx, span := s.tracer.Start(ctx, "SendEmail", trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+
+	domain := s.cfg.From[strings.LastIndex(s.cfg.From, "@")+1:]
+	messageID := fmt.Sprintf("<%s@%s>", uuid.NewString(), domain)
+	span.SetAttributes(
+		attribute.String("email.message_id", messageID),
+		attribute.String("order.id", e.OrderID),
+		attribute.Int("email.recipients", len(e.To)),
+		attribute.String("server.address", s.cfg.Host),
+	)
+
+	msg := s.buildMessage(ctx, e, messageID)
+	addr := net.JoinHostPort(s.cfg.Host, fmt.Sprint(s.cfg.Port))
+	var auth smtp.Auth
+	if s.cfg.Username != "" {
+		auth = smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)
+	}
+
+	var err error
+	backoff := s.backoff
+retry:
+	for attempt := 0; attempt <= s.cfg.MaxRetries; attempt++ {
+		if attempt > 0 {
+			span.AddEvent("email_retry", trace.WithAttributes(attribute.Int("attempt", attempt)))
+			select {
+			case <-time.After(backoff):
+				backoff *= 2
+			case <-ctx.Done():
+				err = ctx.Err()
+				break retry
+			}
+		}
+		if waitErr := s.limiter.wait(ctx); waitErr != nil {
+			err = waitErr
+			break
+		}
+
+		err = s.send(addr, auth, s.cfg.From, e.To, msg)
+		if err == nil || !retryable(err) {
+			break
+		}
+	}
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "email delivery failed")
+		s.sent.Add(ctx, 1, metric.WithAttributes(attribute.String("outcome", "failed")))
+		return messageID, fmt.Errorf("failed to send email %s: %w", messageID, err)
+	}
+	s.sent.Add(ctx, 1, metric.WithAttributes(attribute.String("outcome", "sent")))
+	return messageID, nil
+}
+
+func (s *EmailSender) buildMessage(ctx context.Context, e Email, messageID string) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", s.cfg.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(e.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", e.Subject)
+	fmt.Fprintf(&b, "Message-ID: %s\r\n", messageID)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	if e.OrderID != "" {
+		fmt.Fprintf(&b, "X-Order-ID: %s\r\n", e.OrderID)
+	}
+
+	// traceparent/tracestate as X- headers, for mail pipelines that are
+	// themselves instrumented
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	for _, k := range carrier.Keys() {
+		fmt.Fprintf(&b, "X-%s: %s\r\n", textproto.CanonicalMIMEHeaderKey(k), carrier.Get(k))
+	}
+
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(e.Body)
+	return b.Bytes()
+}
+
+// retryable treats network errors and 4xx SMTP replies as transient; 5xx
+// replies are permanent (bad recipient, rejected content)
+func retryable(err error) bool {
+	var tpErr *textproto.Error
+	if errors.As(err, &tpErr) {
+		return tpErr.Code >= 400 && tpErr.Code < 500
+	}
+	return true
+}
+
+// rateLimiter spaces calls at least 1/perSecond apart
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newRateLimiter(perSecond float64) *rateLimiter {
+	if perSecond <= 0 {
+		return &rateLimiter{}
+	}
+	return &rateLimiter{interval: time.Duration(float64(time.Second) / perSecond)}
+}
+
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l.interval == 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	slot := l.next
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next = slot.Add(l.interval)
+	l.mu.Unlock()
+
+	delay := slot.Sub(now)
+	if delay <= 0 {
+		return nil
+	}
+	select {
+	case <-time.After(delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
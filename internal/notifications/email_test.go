@@ -0,0 +1,96 @@
+package notifications
+
+import (
+	"context"
+	"go-observability-demo/internal/observability/observabilitytest"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+func newTestSender(t *testing.T, send sendFunc) (*EmailSender, *observabilitytest.Recorder) {
+	recorder := observabilitytest.New(t)
+	prev := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTextMapPropagator(prev) })
+
+	sender, err := NewEmailSender(EmailConfig{Host: "smtp.test", Port: 25, From: "orders@shop.test", MaxRetries: 2}, otel.Meter("test"))
+	if err != nil {
+		t.Fatalf("Failed to create sender: %v", err)
+	}
+	sender.send = send
+	sender.backoff = time.Millisecond
+	return sender, recorder
+}
+
+func TestEmailSender_TracesAndRetries(t *testing.T) {
+	var attempts int
+	var sent []byte
+	sender, recorder := newTestSender(t, func(addr string, _ smtp.Auth, _ string, _ []string, msg []byte) error {
+		attempts++
+		if attempts == 1 {
+			return &textproto.Error{Code: 421, Msg: "try again later"}
+		}
+		sent = msg
+		return nil
+	})
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "CreateOrder")
+	messageID, err := sender.Send(ctx, Email{To: []string{"user@shop.test"}, Subject: "Order confirmed", Body: "Thanks", OrderID: "order-1"})
+	parent.End()
+	if err != nil {
+		t.Fatalf("Send returned %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("Expected a retry after 421, got %d attempts", attempts)
+	}
+	if !strings.HasSuffix(messageID, "@shop.test>") || !strings.Contains(string(sent), "Message-ID: "+messageID) {
+		t.Errorf("Expected Message-ID header %s in %s", messageID, sent)
+	}
+
+	span := recorder.SpansNamed("SendEmail")[0]
+	if span.Parent.TraceID() != parent.SpanContext().TraceID() {
+		t.Error("SendEmail should be part of the order trace")
+	}
+	if !strings.Contains(string(sent), "X-Traceparent: 00-"+span.SpanContext.TraceID().String()) {
+		t.Errorf("Expected trace context in headers, got %s", sent)
+	}
+	if got := recorder.Int64Sum(t, "notifications.email.sent"); got != 1 {
+		t.Errorf("Expected 1 email counted, got %d", got)
+	}
+}
+
+func TestEmailSender_PermanentFailureIsNotRetried(t *testing.T) {
+	var attempts int
+	sender, recorder := newTestSender(t, func(string, smtp.Auth, string, []string, []byte) error {
+		attempts++
+		return &textproto.Error{Code: 550, Msg: "mailbox unavailable"}
+	})
+
+	if _, err := sender.Send(context.Background(), Email{To: []string{"nobody@shop.test"}}); err == nil {
+		t.Fatal("Expected an error for a 550 reply")
+	}
+	if attempts != 1 {
+		t.Errorf("Expected a single attempt, got %d", attempts)
+	}
+	if span := recorder.SpansNamed("SendEmail")[0]; span.Status.Code != codes.Error {
+		t.Errorf("Expected error status, got %v", span.Status.Code)
+	}
+}
+
+func TestRateLimiter_SpacesCalls(t *testing.T) {
+	l := newRateLimiter(100)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		l.wait(context.Background())
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("Expected at least 20ms for 3 calls at 100/s, took %s", elapsed)
+	}
+}
@@ -0,0 +1,143 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-observability-demo/internal/middleware"
+	"net/http"
+	"net/url"
+	"slices"
+)
+
+// PreferencesHandler serves GET and PUT /users/{id}/notification-preferences.
+// Users may only see and change their own, unless the request is an
+// admin's; other users are reported as not found.
+func (n *Notifier) PreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("id")
+	if !n.authorize(w, r, func(caller string) bool { return caller == userID }) {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		prefs, err := n.prefs.Get(r.Context(), userID)
+		if err != nil {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, prefs)
+	case http.MethodPut:
+		var prefs Preferences
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 16<<10)).Decode(&prefs); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request"})
+			return
+		}
+		if err := n.validate(r.Context(), prefs); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		if err := n.prefs.Set(r.Context(), userID, prefs); err != nil {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, prefs)
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	}
+}
+
+// DeliveriesHandler serves GET /orders/{id}/notifications to the user the
+// order belongs to, or to an admin
+func (n *Notifier) DeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	deliveries := n.Deliveries(r.PathValue("id"))
+	owns := func(caller string) bool {
+		for _, d := range deliveries {
+			if d.UserID != caller {
+				return false
+			}
+		}
+		return true
+	}
+	if !n.authorize(w, r, owns) {
+		return
+	}
+	if deliveries == nil {
+		deliveries = []Delivery{}
+	}
+	writeJSON(w, http.StatusOK, deliveries)
+}
+
+// authorize answers 401 without a caller or admin token and 404 when owns
+// rejects the caller, and reports whether the request may go on
+func (n *Notifier) authorize(w http.ResponseWriter, r *http.Request, owns func(caller string) bool) bool {
+	if middleware.HasAdminToken(r, n.cfg.AdminToken) {
+		return true
+	}
+	caller := r.Header.Get(middleware.UserIDHeader)
+	switch {
+	case caller == "":
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "missing " + middleware.UserIDHeader})
+		return false
+	case !owns(caller):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+		return false
+	}
+	return true
+}
+
+// validate rejects channels this instance cannot deliver on, channels
+// without an address, malformed email addresses, and webhooks that would
+// reach into the internal network
+func (n *Notifier) validate(ctx context.Context, p Preferences) error {
+	for _, name := range p.Channels {
+		if _, ok := n.channels[name]; !ok {
+			known := make([]string, 0, len(n.channels))
+			for k := range n.channels {
+				known = append(known, k)
+			}
+			slices.Sort(known)
+			return fmt.Errorf("unsupported channel %q, expected one of %v", name, known)
+		}
+		if p.Address(name) == "" {
+			return fmt.Errorf("channel %q needs an address", name)
+		}
+	}
+	if p.Email != "" && !validEmail(p.Email) {
+		return fmt.Errorf("email must be a plain address such as name@example.com")
+	}
+	if p.WebhookURL != "" {
+		u, err := url.Parse(p.WebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+			return fmt.Errorf("webhook_url must be an absolute http(s) URL")
+		}
+		if err := n.checkWebhookHost(ctx, u.Hostname()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkWebhookHost rejects hosts that resolve to loopback, private, or
+// link-local addresses, such as the cloud metadata endpoint, so users
+// can't make the service call internal endpoints for them
+func (n *Notifier) checkWebhookHost(ctx context.Context, host string) error {
+	addrs, err := n.lookupIP(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		return fmt.Errorf("webhook_url host %q cannot be resolved", host)
+	}
+	for _, a := range addrs {
+		ip := a.IP
+		if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+			ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+			return fmt.Errorf("webhook_url must not point at a loopback, private, or link-local address")
+		}
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,463 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-observability-demo/internal/config"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Event is an order update a customer can be notified about
+type Event struct {
+	Type    string `json:"type"`
+	OrderID string `json:"order_id"`
+	UserID  string `json:"user_id"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// OrderPlaced is the confirmation sent once an order is created
+func OrderPlaced(orderID, userID string, amount float64, currency string) Event {
+	return Event{
+		Type:    "order.placed",
+		OrderID: orderID,
+		UserID:  userID,
+		Subject: "Order " + orderID + " confirmed",
+		Body:    fmt.Sprintf("Thanks for your order. %.2f %s has been charged.", amount, currency),
+	}
+}
+
+// Message is one delivery of an event over a channel. ID stays the same
+// across retries so receivers can deduplicate.
+type Message struct {
+	ID string
+	To string
+	Event
+}
+
+// Channel delivers messages to an address: an email address, a webhook
+// URL, or a phone number. Deliver returns the provider's reference for the
+// message, if any; errors wrapped with Permanent are not retried.
+type Channel interface {
+	Name() string
+	Deliver(ctx context.Context, m Message) (ref string, err error)
+}
+
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks a delivery error that retrying will not fix
+func Permanent(err error) error {
+	return permanentError{err: err}
+}
+
+func isPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
+
+// DeliveryStatus is where a delivery is in its lifecycle
+type DeliveryStatus string
+
+const (
+	StatusPending   DeliveryStatus = "pending"
+	StatusRetrying  DeliveryStatus = "retrying"
+	StatusDelivered DeliveryStatus = "delivered"
+	StatusFailed    DeliveryStatus = "failed"
+)
+
+// Delivery tracks one message until it is delivered or given up on
+type Delivery struct {
+	ID        string         `json:"id"`
+	OrderID   string         `json:"order_id"`
+	UserID    string         `json:"user_id"`
+	EventType string         `json:"event_type"`
+	Channel   string         `json:"channel"`
+	Status    DeliveryStatus `json:"status"`
+	Attempts  int            `json:"attempts"`
+	Ref       string         `json:"ref,omitempty"`
+	LastError string         `json:"last_error,omitempty"`
+	TraceID   string         `json:"trace_id,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+// Config controls the delivery queue and retry policy
+type Config struct {
+	Workers     int
+	QueueSize   int
+	MaxAttempts int
+	Backoff     time.Duration // doubled after each retry
+	Timeout     time.Duration // per attempt
+	// MaxTracked bounds the delivery history kept in memory; the oldest
+	// deliveries are forgotten beyond it
+	MaxTracked int
+	// AdminToken lets requests with the admin bearer token read and change
+	// any user's preferences and deliveries
+	AdminToken string
+}
+
+// ConfigFromEnv reads NOTIFY_WORKERS, NOTIFY_QUEUE_SIZE, NOTIFY_MAX_ATTEMPTS,
+// NOTIFY_RETRY_BACKOFF, NOTIFY_TIMEOUT, and NOTIFY_MAX_TRACKED
+func ConfigFromEnv() Config {
+	return Config{
+		Workers:     config.Int("NOTIFY_WORKERS", 2),
+		QueueSize:   config.Int("NOTIFY_QUEUE_SIZE", 1000),
+		MaxAttempts: config.Int("NOTIFY_MAX_ATTEMPTS", 3),
+		Backoff:     config.Duration("NOTIFY_RETRY_BACKOFF", time.Second),
+		Timeout:     config.Duration("NOTIFY_TIMEOUT", 10*time.Second),
+		MaxTracked:  config.Int("NOTIFY_MAX_TRACKED", 10000),
+	}
+}
+
+type job struct {
+	delivery string
+	msg      Message
+	channel  Channel
+	parent   trace.SpanContext
+	queuedAt time.Time
+}
+
+// Notifier fans order events out to each channel the user opted into and
+// delivers them in the background with retries. Every delivery is a
+// DeliverNotification span in the order's trace, so a notification can be
+// followed from the request that caused it to the provider that accepted
+// it. A nil *Notifier ignores events.
+type Notifier struct {
+	cfg      Config
+	prefs    PreferenceStore
+	channels map[string]Channel
+	logger   *slog.Logger
+	tracer   trace.Tracer
+	// lookupIP resolves webhook hosts when preferences are saved
+	lookupIP func(ctx context.Context, host string) ([]net.IPAddr, error)
+
+	deliveries metric.Int64Counter
+	latency    metric.Float64Histogram
+
+	mu      sync.Mutex
+	tracked map[string]*Delivery
+	order   []string // delivery IDs, oldest first
+
+	queue chan job
+	stop  chan struct{}
+	wg    sync.WaitGroup
+	once  sync.Once
+}
+
+func New(cfg Config, prefs PreferenceStore, meter metric.Meter, logger *slog.Logger, channels ...Channel) (*Notifier, error) {
+	cfg.Workers = max(1, cfg.Workers)
+	cfg.QueueSize = max(1, cfg.QueueSize)
+	cfg.MaxAttempts = max(1, cfg.MaxAttempts)
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+
+	n := &Notifier{
+		cfg:      cfg,
+		prefs:    prefs,
+		channels: make(map[string]Channel, len(channels)),
+		logger:   logger,
+		tracer:   otel.Tracer("order-service/notifications"),
+		lookupIP: net.DefaultResolver.LookupIPAddr,
+		tracked:  make(map[string]*Delivery),
+		queue:    make(chan job, cfg.QueueSize),
+		stop:     make(chan struct{}),
+	}
+	for _, c := range channels {
+		n.channels[c.Name()] = c
+	}
+
+	var err error
+	n.deliveries, err = meter.Int64Counter(
+		"notifications.deliveries",
+		metric.WithDescription("Notifications by channel and final status (delivered, failed, dropped)"),
+		metric.WithUnit("{notification}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	n.latency, err = meter.Float64Histogram(
+		"notifications.delivery.duration",
+		metric.WithDescription("Time from order event to final delivery status, including queueing and retries"),
+		metric.WithUnit("ms"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	depth, err := meter.Int64ObservableGauge(
+		"notifications.queue.depth",
+		metric.WithDescription("Notifications waiting for a worker"),
+		metric.WithUnit("{notification}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveInt64(depth, int64(len(n.queue)))
+		return nil
+	}, depth)
+	if err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// Start runs the delivery workers
+func (n *Notifier) Start() {
+	if n == nil {
+		return
+	}
+	for range n.cfg.Workers {
+		n.wg.Add(1)
+		go n.run()
+	}
+}
+
+// Stop delivers what is already queued, without further retries, and
+// waits for the workers until ctx is done
+func (n *Notifier) Stop(ctx context.Context) error {
+	if n == nil {
+		return nil
+	}
+	n.once.Do(func() { close(n.stop) })
+
+	done := make(chan struct{})
+	go func() {
+		n.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (n *Notifier) run() {
+	defer n.wg.Done()
+	for {
+		select {
+		case j := <-n.queue:
+			n.deliver(j)
+		case <-n.stop:
+			for {
+				select {
+				case j := <-n.queue:
+					n.deliver(j)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// Notify queues e for every channel in the user's preferences. It never
+// blocks on delivery; a full queue drops the notification.
+func (n *Notifier) Notify(ctx context.Context, e Event) {
+	if n == nil {
+		return
+	}
+	ctx, span := n.tracer.Start(ctx, "NotifyCustomer", trace.WithAttributes(
+		attribute.String("notification.event", e.Type),
+		attribute.String("order.id", e.OrderID),
+		attribute.String("user.id", e.UserID),
+	))
+	defer span.End()
+
+	prefs, err := n.prefs.Get(ctx, e.UserID)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "preferences unavailable")
+		n.logger.WarnContext(ctx, "notification preferences unavailable",
+			slog.String("user_id", e.UserID),
+			slog.String("error", err.Error()),
+		)
+		return
+	}
+
+	queued := 0
+	for _, name := range prefs.Channels {
+		channel, ok := n.channels[name]
+		to := prefs.Address(name)
+		if !ok || to == "" {
+			span.AddEvent("channel_skipped", trace.WithAttributes(attribute.String("notification.channel", name)))
+			continue
+		}
+
+		d := n.track(e, name, span.SpanContext().TraceID().String())
+		j := job{
+			delivery: d.ID,
+			msg:      Message{ID: d.ID, To: to, Event: e},
+			channel:  channel,
+			parent:   span.SpanContext(),
+			queuedAt: d.CreatedAt,
+		}
+		select {
+		case n.queue <- j:
+			queued++
+		default:
+			n.finish(ctx, j, StatusFailed, "dropped", "", errors.New("notification queue full"))
+		}
+	}
+	span.SetAttributes(attribute.Int("notification.queued", queued))
+}
+
+func (n *Notifier) deliver(j job) {
+	// Detached from the request, but still part of its trace
+	ctx := trace.ContextWithSpanContext(context.Background(), j.parent)
+	ctx, span := n.tracer.Start(ctx, "DeliverNotification",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("notification.id", j.delivery),
+			attribute.String("notification.channel", j.channel.Name()),
+			attribute.String("notification.event", j.msg.Type),
+			attribute.String("order.id", j.msg.OrderID),
+			attribute.Float64("notification.queue_ms", float64(time.Since(j.queuedAt).Milliseconds())),
+		),
+	)
+	defer span.End()
+
+	var (
+		ref string
+		err error
+	)
+	backoff := n.cfg.Backoff
+	for attempt := 1; ; attempt++ {
+		n.update(j.delivery, func(d *Delivery) { d.Attempts = attempt })
+		attemptCtx, cancel := context.WithTimeout(ctx, n.cfg.Timeout)
+		ref, err = j.channel.Deliver(attemptCtx, j.msg)
+		cancel()
+		if err == nil || isPermanent(err) || attempt >= n.cfg.MaxAttempts {
+			break
+		}
+
+		n.update(j.delivery, func(d *Delivery) {
+			d.Status = StatusRetrying
+			d.LastError = err.Error()
+		})
+		span.AddEvent("notification_retry", trace.WithAttributes(
+			attribute.Int("attempt", attempt+1),
+			attribute.String("error", err.Error()),
+		))
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+			continue
+		case <-n.stop:
+			err = fmt.Errorf("shutting down after %d attempts: %w", attempt, err)
+		}
+		break
+	}
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "notification not delivered")
+		n.finish(ctx, j, StatusFailed, string(StatusFailed), ref, err)
+		return
+	}
+	span.SetAttributes(attribute.String("notification.ref", ref))
+	n.finish(ctx, j, StatusDelivered, string(StatusDelivered), ref, nil)
+}
+
+// finish records the final status of a delivery. outcome is the status
+// label on the metrics, which tells dropped deliveries apart from failed ones.
+func (n *Notifier) finish(ctx context.Context, j job, status DeliveryStatus, outcome, ref string, err error) {
+	n.update(j.delivery, func(d *Delivery) {
+		d.Status = status
+		d.Ref = ref
+		if err != nil {
+			d.LastError = err.Error()
+		}
+	})
+
+	attrs := metric.WithAttributes(
+		attribute.String("notification.channel", j.channel.Name()),
+		attribute.String("status", outcome),
+	)
+	n.deliveries.Add(ctx, 1, attrs)
+	n.latency.Record(ctx, float64(time.Since(j.queuedAt).Milliseconds()), attrs)
+
+	logAttrs := []any{
+		slog.String("event.name", "notification."+outcome),
+		slog.String("notification.id", j.delivery),
+		slog.String("notification.channel", j.channel.Name()),
+		slog.String("order_id", j.msg.OrderID),
+	}
+	if err != nil {
+		n.logger.WarnContext(ctx, "notification failed", append(logAttrs, slog.String("error", err.Error()))...)
+		return
+	}
+	n.logger.InfoContext(ctx, "notification delivered", logAttrs...)
+}
+
+// track records a new pending delivery, forgetting the oldest beyond
+// MaxTracked
+func (n *Notifier) track(e Event, channel, traceID string) Delivery {
+	now := time.Now()
+	d := &Delivery{
+		ID:        uuid.NewString(),
+		OrderID:   e.OrderID,
+		UserID:    e.UserID,
+		EventType: e.Type,
+		Channel:   channel,
+		Status:    StatusPending,
+		TraceID:   traceID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.tracked[d.ID] = d
+	n.order = append(n.order, d.ID)
+	if limit := n.cfg.MaxTracked; limit > 0 && len(n.order) > limit {
+		for _, id := range n.order[:len(n.order)-limit] {
+			delete(n.tracked, id)
+		}
+		n.order = append([]string(nil), n.order[len(n.order)-limit:]...)
+	}
+	return *d
+}
+
+func (n *Notifier) update(id string, fn func(*Delivery)) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if d, ok := n.tracked[id]; ok {
+		fn(d)
+		d.UpdatedAt = time.Now()
+	}
+}
+
+// Deliveries returns the tracked deliveries for an order, oldest first
+func (n *Notifier) Deliveries(orderID string) []Delivery {
+	if n == nil {
+		return nil
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	var out []Delivery
+	for _, id := range n.order {
+		if d := n.tracked[id]; d != nil && d.OrderID == orderID {
+			out = append(out, *d)
+		}
+	}
+	return out
+}
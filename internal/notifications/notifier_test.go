@@ -0,0 +1,252 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"go-observability-demo/internal/observability/observabilitytest"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+)
+
+// fakeChannel fails its first `failures` deliveries with err
+type fakeChannel struct {
+	name     string
+	failures int32
+	err      error
+	calls    atomic.Int32
+}
+
+func (f *fakeChannel) Name() string { return f.name }
+
+func (f *fakeChannel) Deliver(context.Context, Message) (string, error) {
+	if f.calls.Add(1) <= f.failures {
+		return "", f.err
+	}
+	return "ref-1", nil
+}
+
+func newTestNotifier(t *testing.T, channels ...Channel) (*Notifier, *observabilitytest.Recorder) {
+	t.Helper()
+	recorder := observabilitytest.New(t)
+	n, err := New(Config{MaxAttempts: 3, Backoff: time.Millisecond}, NewMemoryPreferences(), otel.Meter("test"), recorder.Logger, channels...)
+	if err != nil {
+		t.Fatalf("Failed to create notifier: %v", err)
+	}
+	n.Start()
+	t.Cleanup(func() { n.Stop(context.Background()) })
+	return n, recorder
+}
+
+// waitForStatus polls until every delivery for the order is final
+func waitForStatus(t *testing.T, n *Notifier, orderID string) []Delivery {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		deliveries := n.Deliveries(orderID)
+		final := len(deliveries) > 0
+		for _, d := range deliveries {
+			final = final && (d.Status == StatusDelivered || d.Status == StatusFailed)
+		}
+		if final || time.Now().After(deadline) {
+			return deliveries
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestNotifier_RetriesAndTracesFromOrder(t *testing.T) {
+	webhook := &fakeChannel{name: "webhook", failures: 1, err: errors.New("connection reset")}
+	n, recorder := newTestNotifier(t, webhook, &fakeChannel{name: "sms"})
+	ctx := context.Background()
+	n.prefs.Set(ctx, "user-1", Preferences{Channels: []string{"webhook"}, WebhookURL: "http://hooks.test/1"})
+
+	ctx, parent := otel.Tracer("test").Start(ctx, "CreateOrder")
+	n.Notify(ctx, OrderPlaced("order-1", "user-1", 10, "USD"))
+	parent.End()
+
+	deliveries := waitForStatus(t, n, "order-1")
+	if len(deliveries) != 1 {
+		t.Fatalf("Expected only the preferred channel to be used, got %+v", deliveries)
+	}
+	d := deliveries[0]
+	if d.Status != StatusDelivered || d.Attempts != 2 || d.Ref != "ref-1" {
+		t.Errorf("Expected delivery on the second attempt, got %+v", d)
+	}
+	if d.TraceID != parent.SpanContext().TraceID().String() {
+		t.Errorf("Expected the order's trace ID on the delivery, got %s", d.TraceID)
+	}
+
+	n.Stop(context.Background())
+	span := recorder.SpansNamed("DeliverNotification")[0]
+	if span.SpanContext.TraceID() != parent.SpanContext().TraceID() {
+		t.Error("DeliverNotification should be part of the order trace")
+	}
+	if len(span.Events) != 1 || span.Events[0].Name != "notification_retry" {
+		t.Errorf("Expected one notification_retry event, got %+v", span.Events)
+	}
+	if got := recorder.Int64Sum(t, "notifications.deliveries"); got != 1 {
+		t.Errorf("Expected 1 delivery counted, got %d", got)
+	}
+}
+
+func TestNotifier_PermanentErrorsAreNotRetried(t *testing.T) {
+	webhook := &fakeChannel{name: "webhook", failures: 10, err: Permanent(errors.New("410 gone"))}
+	n, _ := newTestNotifier(t, webhook)
+	ctx := context.Background()
+	n.prefs.Set(ctx, "user-1", Preferences{Channels: []string{"webhook"}, WebhookURL: "http://hooks.test/1"})
+
+	n.Notify(ctx, OrderPlaced("order-1", "user-1", 10, "USD"))
+	deliveries := waitForStatus(t, n, "order-1")
+	if len(deliveries) != 1 || deliveries[0].Status != StatusFailed || deliveries[0].Attempts != 1 {
+		t.Errorf("Expected one failed attempt, got %+v", deliveries)
+	}
+	if !strings.Contains(deliveries[0].LastError, "410") {
+		t.Errorf("Expected the error on the delivery, got %q", deliveries[0].LastError)
+	}
+}
+
+func TestNotifier_UsersWithoutPreferencesAreSkipped(t *testing.T) {
+	webhook := &fakeChannel{name: "webhook"}
+	n, _ := newTestNotifier(t, webhook)
+
+	n.Notify(context.Background(), OrderPlaced("order-1", "user-1", 10, "USD"))
+	n.Stop(context.Background())
+	if got := n.Deliveries("order-1"); len(got) != 0 || webhook.calls.Load() != 0 {
+		t.Errorf("Expected no deliveries, got %+v", got)
+	}
+}
+
+func TestWebhookChannel(t *testing.T) {
+	var gotID string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotID = r.Header.Get("X-Notification-ID")
+		if strings.HasSuffix(r.URL.Path, "/gone") {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, "/busy") {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+	}))
+	defer server.Close()
+	c := WebhookChannel{Client: server.Client()}
+	ctx := context.Background()
+
+	if _, err := c.Deliver(ctx, Message{ID: "d-1", To: server.URL + "/ok"}); err != nil || gotID != "d-1" {
+		t.Errorf("Expected delivery with X-Notification-ID d-1, got %q (%v)", gotID, err)
+	}
+	if _, err := c.Deliver(ctx, Message{ID: "d-2", To: server.URL + "/gone"}); !isPermanent(err) {
+		t.Errorf("Expected a permanent error for 410, got %v", err)
+	}
+	if _, err := c.Deliver(ctx, Message{ID: "d-3", To: server.URL + "/busy"}); err == nil || isPermanent(err) {
+		t.Errorf("Expected a retryable error for 429, got %v", err)
+	}
+}
+
+func TestPreferencesHandler(t *testing.T) {
+	n, _ := newTestNotifier(t, &fakeChannel{name: "webhook"}, &fakeChannel{name: "email"})
+	hosts := map[string]string{"hooks.test": "203.0.113.10", "internal.test": "10.0.0.5"}
+	n.lookupIP = func(_ context.Context, host string) ([]net.IPAddr, error) {
+		if ip := net.ParseIP(host); ip != nil {
+			return []net.IPAddr{{IP: ip}}, nil
+		}
+		if addr, ok := hosts[host]; ok {
+			return []net.IPAddr{{IP: net.ParseIP(addr)}}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/{id}/notification-preferences", n.PreferencesHandler)
+
+	put := func(body string) int {
+		req := httptest.NewRequest(http.MethodPut, "/users/user-1/notification-preferences", strings.NewReader(body))
+		req.Header.Set("X-User-ID", "user-1")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if code := put(`{"channels":["sms"],"phone":"+15550100"}`); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unsupported channel, got %d", code)
+	}
+	if code := put(`{"channels":["webhook"],"webhook_url":"file:///etc/passwd"}`); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a non-http webhook, got %d", code)
+	}
+	for _, hook := range []string{"http://127.0.0.1:8080/admin", "http://169.254.169.254/latest/meta-data", "http://internal.test/", "http://[::1]/", "http://unknown.test/"} {
+		if code := put(`{"channels":["webhook"],"webhook_url":"` + hook + `"}`); code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for a webhook to %s, got %d", hook, code)
+		}
+	}
+	for _, email := range []string{"not-an-address", "Eve <eve@example.com>", "a@example.com\r\nBcc: b@example.com"} {
+		if code := put(`{"channels":["email"],"email":"` + email + `"}`); code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for email %q, got %d", email, code)
+		}
+	}
+	if code := put(`{"channels":["email"],"email":"alice@example.com"}`); code != http.StatusOK {
+		t.Errorf("Expected 200 for a plain email address, got %d", code)
+	}
+	if code := put(`{"channels":["webhook"],"webhook_url":"https://hooks.test/1"}`); code != http.StatusOK {
+		t.Errorf("Expected 200, got %d", code)
+	}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/users/user-1/notification-preferences", nil)
+	req.Header.Set("X-User-ID", "user-1")
+	mux.ServeHTTP(rec, req)
+	if !strings.Contains(rec.Body.String(), `"webhook_url":"https://hooks.test/1"`) {
+		t.Errorf("Expected the saved preferences, got %s", rec.Body.String())
+	}
+}
+
+func TestHandlers_RejectOtherUsers(t *testing.T) {
+	n, _ := newTestNotifier(t, &fakeChannel{name: "webhook"})
+	n.cfg.AdminToken = "admin-token"
+	ctx := context.Background()
+	n.prefs.Set(ctx, "user-1", Preferences{Channels: []string{"webhook"}, WebhookURL: "http://hooks.test/1"})
+	n.Notify(ctx, OrderPlaced("order-1", "user-1", 10, "USD"))
+	waitForStatus(t, n, "order-1")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/{id}/notification-preferences", n.PreferencesHandler)
+	mux.HandleFunc("GET /orders/{id}/notifications", n.DeliveriesHandler)
+	serve := func(method, path, caller, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if caller != "" {
+			req.Header.Set("X-User-ID", caller)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	prefs := "/users/user-1/notification-preferences"
+	deliveries := "/orders/order-1/notifications"
+	for path, want := range map[string]string{prefs: "hooks.test", deliveries: "order-1"} {
+		if rec := serve(http.MethodGet, path, "", "", ""); rec.Code != http.StatusUnauthorized {
+			t.Errorf("Expected 401 for %s without a caller, got %d", path, rec.Code)
+		}
+		if rec := serve(http.MethodGet, path, "mallory", "", ""); rec.Code != http.StatusNotFound || strings.Contains(rec.Body.String(), want) {
+			t.Errorf("Expected 404 for %s as another user, got %d %s", path, rec.Code, rec.Body.String())
+		}
+		if rec := serve(http.MethodGet, path, "", "admin-token", ""); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("Expected the admin to read %s, got %d", path, rec.Code)
+		}
+	}
+
+	if rec := serve(http.MethodPut, prefs, "mallory", "", `{"channels":["webhook"],"webhook_url":"https://attacker.test/"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 when changing another user's preferences, got %d", rec.Code)
+	}
+	if p, _ := n.prefs.Get(ctx, "user-1"); p.WebhookURL != "http://hooks.test/1" {
+		t.Errorf("Expected the preferences unchanged, got %q", p.WebhookURL)
+	}
+}
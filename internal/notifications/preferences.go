@@ -0,0 +1,67 @@
+package notifications
+
+import (
+	"context"
+	"net/mail"
+	"sync"
+)
+
+// Preferences are a user's notification channels and where to reach them
+// on each. Users without preferences are not notified.
+type Preferences struct {
+	Channels   []string `json:"channels"`
+	Email      string   `json:"email,omitempty"`
+	Phone      string   `json:"phone,omitempty"`
+	WebhookURL string   `json:"webhook_url,omitempty"`
+}
+
+// Address returns where to send on the named channel, or "" if the user
+// has no address for it
+func (p Preferences) Address(channel string) string {
+	switch channel {
+	case "email":
+		return p.Email
+	case "sms":
+		return p.Phone
+	case "webhook":
+		return p.WebhookURL
+	}
+	return ""
+}
+
+// validEmail accepts a bare address such as a@example.com, without a
+// display name or anything else that would end up in the To header
+func validEmail(s string) bool {
+	addr, err := mail.ParseAddress(s)
+	return err == nil && addr.Address == s
+}
+
+// PreferenceStore keeps per-user preferences; Get returns the zero value
+// for unknown users
+type PreferenceStore interface {
+	Get(ctx context.Context, userID string) (Preferences, error)
+	Set(ctx context.Context, userID string, p Preferences) error
+}
+
+// MemoryPreferences keeps preferences in process
+type MemoryPreferences struct {
+	mu    sync.RWMutex
+	prefs map[string]Preferences
+}
+
+func NewMemoryPreferences() *MemoryPreferences {
+	return &MemoryPreferences{prefs: make(map[string]Preferences)}
+}
+
+func (m *MemoryPreferences) Get(_ context.Context, userID string) (Preferences, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.prefs[userID], nil
+}
+
+func (m *MemoryPreferences) Set(_ context.Context, userID string, p Preferences) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prefs[userID] = p
+	return nil
+}
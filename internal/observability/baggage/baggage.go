@@ -0,0 +1,149 @@
+// Package baggage carries request-wide identifiers (tenant, user, request
+// source) in OTel baggage, so they cross service boundaries with the trace
+// context and show up on every span and log record without being passed
+// around by hand.
+package baggage
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+
+	"go.opentelemetry.io/otel/attribute"
+	otelbaggage "go.opentelemetry.io/otel/baggage"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Baggage keys, also used as the span attribute names
+const (
+	TenantIDKey      = "tenant.id"
+	UserIDKey        = "user.id"
+	RequestSourceKey = "request.source"
+)
+
+// keys are copied onto spans and logs; other baggage members are only
+// propagated
+var keys = []struct {
+	baggage string
+	log     string
+}{
+	{TenantIDKey, "tenant_id"},
+	{UserIDKey, "user_id"},
+	{RequestSourceKey, "request_source"},
+}
+
+// WithTenantID returns a context whose baggage carries the tenant
+func WithTenantID(ctx context.Context, id string) context.Context {
+	return with(ctx, TenantIDKey, id)
+}
+
+// TenantID returns the tenant from the context's baggage, or ""
+func TenantID(ctx context.Context) string {
+	return otelbaggage.FromContext(ctx).Member(TenantIDKey).Value()
+}
+
+// WithUserID returns a context whose baggage carries the user
+func WithUserID(ctx context.Context, id string) context.Context {
+	return with(ctx, UserIDKey, id)
+}
+
+// UserID returns the user from the context's baggage, or ""
+func UserID(ctx context.Context) string {
+	return otelbaggage.FromContext(ctx).Member(UserIDKey).Value()
+}
+
+// WithRequestSource returns a context whose baggage carries where the
+// request came from, e.g. "web", "mobile", or "batch"
+func WithRequestSource(ctx context.Context, source string) context.Context {
+	return with(ctx, RequestSourceKey, source)
+}
+
+// RequestSource returns the request source from the context's baggage, or ""
+func RequestSource(ctx context.Context) string {
+	return otelbaggage.FromContext(ctx).Member(RequestSourceKey).Value()
+}
+
+// with sets key, leaving ctx unchanged if value is empty or not valid
+// baggage
+func with(ctx context.Context, key, value string) context.Context {
+	if value == "" {
+		return ctx
+	}
+	member, err := otelbaggage.NewMemberRaw(key, value)
+	if err != nil {
+		return ctx
+	}
+	b, err := otelbaggage.FromContext(ctx).SetMember(member)
+	if err != nil {
+		return ctx
+	}
+	return otelbaggage.ContextWithBaggage(ctx, b)
+}
+
+// SpanProcessor copies the baggage keys onto spans as they start
+type SpanProcessor struct{}
+
+func NewSpanProcessor() SpanProcessor {
+	return SpanProcessor{}
+}
+
+func (SpanProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	b := otelbaggage.FromContext(parent)
+	for _, k := range keys {
+		if v := b.Member(k.baggage).Value(); v != "" {
+			s.SetAttributes(attribute.String(k.baggage, v))
+		}
+	}
+}
+
+func (SpanProcessor) OnEnd(sdktrace.ReadOnlySpan)      {}
+func (SpanProcessor) ForceFlush(context.Context) error { return nil }
+func (SpanProcessor) Shutdown(context.Context) error   { return nil }
+
+// NewLogHandler adds the baggage keys to records logged with a context
+// that carries them
+func NewLogHandler(next slog.Handler) slog.Handler {
+	return logHandler{Handler: next}
+}
+
+type logHandler struct {
+	slog.Handler
+	// attached holds the baggage keys a logger.With call already added
+	attached []string
+}
+
+func (h logHandler) Handle(ctx context.Context, r slog.Record) error {
+	b := otelbaggage.FromContext(ctx)
+	for _, k := range keys {
+		if v := b.Member(k.baggage).Value(); v != "" && !slices.Contains(h.attached, k.log) && !hasAttr(r, k.log) {
+			r.AddAttrs(slog.String(k.log, v))
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h logHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	attached := slices.Clone(h.attached)
+	for _, a := range attrs {
+		for _, k := range keys {
+			if a.Key == k.log {
+				attached = append(attached, k.log)
+			}
+		}
+	}
+	return logHandler{h.Handler.WithAttrs(attrs), attached}
+}
+
+func (h logHandler) WithGroup(name string) slog.Handler {
+	return logHandler{h.Handler.WithGroup(name), h.attached}
+}
+
+// hasAttr reports whether the call site already logged key
+func hasAttr(r slog.Record, key string) bool {
+	found := false
+	r.Attrs(func(a slog.Attr) bool {
+		found = a.Key == key
+		return !found
+	})
+	return found
+}
@@ -0,0 +1,82 @@
+package baggage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSettersAndGetters(t *testing.T) {
+	ctx := WithTenantID(context.Background(), "acme")
+	ctx = WithUserID(ctx, "user-1")
+	ctx = WithRequestSource(ctx, "mobile")
+	ctx = WithUserID(ctx, "")
+
+	if TenantID(ctx) != "acme" || UserID(ctx) != "user-1" || RequestSource(ctx) != "mobile" {
+		t.Errorf("Expected acme/user-1/mobile, got %s/%s/%s", TenantID(ctx), UserID(ctx), RequestSource(ctx))
+	}
+	if UserID(context.Background()) != "" {
+		t.Error("Expected no user without baggage")
+	}
+}
+
+func TestSpanProcessor_CopiesBaggage(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(NewSpanProcessor()),
+		sdktrace.WithSyncer(exporter),
+	)
+	ctx := WithTenantID(context.Background(), "acme")
+	_, span := tp.Tracer("test").Start(ctx, "ProcessOrder")
+	span.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("Expected 1 span, got %d", len(spans))
+	}
+	found := false
+	for _, kv := range spans[0].Attributes {
+		if string(kv.Key) == TenantIDKey && kv.Value.AsString() == "acme" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected tenant.id=acme on the span, got %v", spans[0].Attributes)
+	}
+}
+
+func TestLogHandler_AddsBaggage(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewLogHandler(slog.NewJSONHandler(&buf, nil)))
+	ctx := WithUserID(WithRequestSource(context.Background(), "web"), "user-1")
+
+	logger.InfoContext(ctx, "order created", "user_id", "user-1")
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Invalid log line %q: %v", buf.String(), err)
+	}
+	if entry["request_source"] != "web" {
+		t.Errorf("Expected request_source=web, got %v", entry)
+	}
+	if bytes.Count(buf.Bytes(), []byte(`"user_id"`)) != 1 {
+		t.Errorf("Expected user_id once, got %s", buf.String())
+	}
+}
+
+func TestLogHandler_SkipsKeysFromWith(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewLogHandler(slog.NewJSONHandler(&buf, nil))).With("user_id", "user-1")
+	ctx := WithUserID(context.Background(), "user-1")
+
+	logger.InfoContext(ctx, "order created")
+
+	if bytes.Count(buf.Bytes(), []byte(`"user_id"`)) != 1 {
+		t.Errorf("Expected user_id once, got %s", buf.String())
+	}
+}
@@ -0,0 +1,49 @@
+package observability
+
+import (
+	"go-observability-demo/internal/config"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// BatchConfig sizes the batch span processors, one per span exporter
+type BatchConfig struct {
+	// MaxQueueSize is how many ended spans wait for export; more are dropped
+	MaxQueueSize int
+	// ScheduleDelay is how often a partial batch is sent
+	ScheduleDelay time.Duration
+	// MaxExportBatchSize caps the spans per request; it is cut to
+	// MaxQueueSize if larger
+	MaxExportBatchSize int
+	ExportTimeout      time.Duration
+}
+
+// BatchConfigFromEnv reads OTEL_BSP_MAX_QUEUE_SIZE (2048),
+// OTEL_BSP_SCHEDULE_DELAY (5s), OTEL_BSP_MAX_EXPORT_BATCH_SIZE (512), and
+// OTEL_BSP_EXPORT_TIMEOUT (30s). Delays are milliseconds or durations;
+// values that aren't positive keep the default.
+func BatchConfigFromEnv() BatchConfig {
+	return BatchConfig{
+		MaxQueueSize:       positive(config.Int("OTEL_BSP_MAX_QUEUE_SIZE", 2048), 2048),
+		ScheduleDelay:      positive(config.Duration("OTEL_BSP_SCHEDULE_DELAY", 5*time.Second), 5*time.Second),
+		MaxExportBatchSize: positive(config.Int("OTEL_BSP_MAX_EXPORT_BATCH_SIZE", 512), 512),
+		ExportTimeout:      positive(config.Duration("OTEL_BSP_EXPORT_TIMEOUT", 30*time.Second), 30*time.Second),
+	}
+}
+
+func positive[T int | time.Duration](v, defaultValue T) T {
+	if v <= 0 {
+		return defaultValue
+	}
+	return v
+}
+
+func (c BatchConfig) options() []sdktrace.BatchSpanProcessorOption {
+	return []sdktrace.BatchSpanProcessorOption{
+		sdktrace.WithMaxQueueSize(c.MaxQueueSize),
+		sdktrace.WithBatchTimeout(c.ScheduleDelay),
+		sdktrace.WithMaxExportBatchSize(c.MaxExportBatchSize),
+		sdktrace.WithExportTimeout(c.ExportTimeout),
+	}
+}
@@ -0,0 +1,27 @@
+package observability
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBatchConfigFromEnv(t *testing.T) {
+	cfg := BatchConfigFromEnv()
+	if cfg.MaxQueueSize != 2048 || cfg.ScheduleDelay != 5*time.Second || cfg.MaxExportBatchSize != 512 {
+		t.Errorf("Expected the defaults, got %+v", cfg)
+	}
+
+	t.Setenv("OTEL_BSP_MAX_QUEUE_SIZE", "8192")
+	t.Setenv("OTEL_BSP_SCHEDULE_DELAY", "200")
+	t.Setenv("OTEL_BSP_MAX_EXPORT_BATCH_SIZE", "0")
+	cfg = BatchConfigFromEnv()
+	if cfg.MaxQueueSize != 8192 || cfg.ScheduleDelay != 200*time.Millisecond {
+		t.Errorf("Expected a queue of 8192 flushed every 200ms, got %+v", cfg)
+	}
+	if cfg.MaxExportBatchSize != 512 {
+		t.Errorf("Expected a zero batch size to keep the default, got %d", cfg.MaxExportBatchSize)
+	}
+	if len(cfg.options()) != 4 {
+		t.Error("Expected an option per setting")
+	}
+}
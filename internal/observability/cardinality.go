@@ -0,0 +1,33 @@
+package observability
+
+import "sync"
+
+// OverflowValue replaces attribute values past a BoundedValues limit
+const OverflowValue = overflowSpanName
+
+// BoundedValues keeps a high-cardinality attribute such as user.id usable
+// on metrics: the first Limit distinct values pass through and every later
+// one is recorded as OverflowValue, so the series count stays bounded
+type BoundedValues struct {
+	limit int
+
+	mu   sync.Mutex
+	seen map[string]struct{}
+}
+
+func NewBoundedValues(limit int) *BoundedValues {
+	return &BoundedValues{limit: limit, seen: make(map[string]struct{})}
+}
+
+func (b *BoundedValues) Value(v string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.seen[v]; ok {
+		return v
+	}
+	if len(b.seen) >= b.limit {
+		return OverflowValue
+	}
+	b.seen[v] = struct{}{}
+	return v
+}
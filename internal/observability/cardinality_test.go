@@ -0,0 +1,57 @@
+package observability
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+func TestBoundedValues(t *testing.T) {
+	b := NewBoundedValues(2)
+	for _, v := range []string{"alice", "bob", "alice"} {
+		if got := b.Value(v); got != v {
+			t.Errorf("Expected %s to pass through, got %s", v, got)
+		}
+	}
+	if got := b.Value("carol"); got != OverflowValue {
+		t.Errorf("Expected %s past the limit, got %s", OverflowValue, got)
+	}
+}
+
+func TestNewMeterProvider_CardinalityLimit(t *testing.T) {
+	t.Setenv("OTEL_METRICS_CARDINALITY_LIMIT", "3")
+	reader := sdkmetric.NewManualReader()
+	mp, err := newMeterProvider(resource.Empty(), []sdkmetric.Reader{reader})
+	if err != nil {
+		t.Fatalf("newMeterProvider failed: %v", err)
+	}
+	defer mp.Shutdown(context.Background())
+
+	counter, _ := mp.Meter("test").Int64Counter("orders.created")
+	for i := range 10 {
+		counter.Add(context.Background(), 1, metric.WithAttributes(attribute.Int("user.id", i)))
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+	points := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Sum[int64]).DataPoints
+	if len(points) != 3 {
+		t.Errorf("Expected 3 series, got %d", len(points))
+	}
+	var overflow int64
+	for _, p := range points {
+		if v, ok := p.Attributes.Value("otel.metric.overflow"); ok && v.AsBool() {
+			overflow = p.Value
+		}
+	}
+	if overflow != 8 {
+		t.Errorf("Expected 8 measurements in the overflow series, got %d", overflow)
+	}
+}
@@ -0,0 +1,192 @@
+package observability
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// Runtime detectors for Kubernetes and the cloud metadata services. They
+// talk to the metadata endpoints directly rather than pulling in the AWS
+// and GCP SDKs, and give up after metadataTimeout so a wrong choice in
+// OTEL_RESOURCE_DETECTORS only costs a warning at startup.
+
+const metadataTimeout = time.Second
+
+var errNotDetected = errors.New("not detected")
+
+// k8sDetector reads pod details that the deployment exposes through the
+// downward API as K8S_POD_NAME, K8S_POD_NAMESPACE, K8S_POD_UID, and
+// K8S_NODE_NAME
+type k8sDetector struct{}
+
+func (k8sDetector) Detect(context.Context) (*resource.Resource, error) {
+	var attrs []attribute.KeyValue
+	for env, key := range map[string]string{
+		"K8S_POD_NAME":      "k8s.pod.name",
+		"K8S_POD_NAMESPACE": "k8s.namespace.name",
+		"K8S_POD_UID":       "k8s.pod.uid",
+		"K8S_NODE_NAME":     "k8s.node.name",
+	} {
+		if v := os.Getenv(env); v != "" {
+			attrs = append(attrs, attribute.String(key, v))
+		}
+	}
+	if len(attrs) == 0 {
+		return nil, fmt.Errorf("k8s: %w, set K8S_POD_NAME and friends from the downward API", errNotDetected)
+	}
+	return resource.NewSchemaless(attrs...), nil
+}
+
+// ec2Detector reads the instance identity document through IMDSv2
+type ec2Detector struct {
+	endpoint string
+	client   *http.Client
+}
+
+func (d ec2Detector) Detect(ctx context.Context) (*resource.Resource, error) {
+	ctx, cancel := context.WithTimeout(ctx, metadataTimeout)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPut, d.endpoint+"/latest/api/token", nil)
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "60")
+	token, err := fetchMetadata(d.client, req)
+	if err != nil {
+		return nil, fmt.Errorf("ec2: %w", err)
+	}
+
+	req, _ = http.NewRequestWithContext(ctx, http.MethodGet, d.endpoint+"/latest/dynamic/instance-identity/document", nil)
+	req.Header.Set("X-aws-ec2-metadata-token", string(token))
+	body, err := fetchMetadata(d.client, req)
+	if err != nil {
+		return nil, fmt.Errorf("ec2: %w", err)
+	}
+	var doc struct {
+		InstanceID       string `json:"instanceId"`
+		InstanceType     string `json:"instanceType"`
+		ImageID          string `json:"imageId"`
+		AccountID        string `json:"accountId"`
+		Region           string `json:"region"`
+		AvailabilityZone string `json:"availabilityZone"`
+	}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("ec2: invalid identity document: %w", err)
+	}
+	return resource.NewSchemaless(
+		attribute.String("cloud.provider", "aws"),
+		attribute.String("cloud.platform", "aws_ec2"),
+		attribute.String("cloud.account.id", doc.AccountID),
+		attribute.String("cloud.region", doc.Region),
+		attribute.String("cloud.availability_zone", doc.AvailabilityZone),
+		attribute.String("host.id", doc.InstanceID),
+		attribute.String("host.type", doc.InstanceType),
+		attribute.String("host.image.id", doc.ImageID),
+	), nil
+}
+
+// ecsDetector reads the task metadata endpoint (v4) that ECS injects as
+// ECS_CONTAINER_METADATA_URI_V4
+type ecsDetector struct {
+	client *http.Client
+}
+
+func (d ecsDetector) Detect(ctx context.Context) (*resource.Resource, error) {
+	base := os.Getenv("ECS_CONTAINER_METADATA_URI_V4")
+	if base == "" {
+		return nil, fmt.Errorf("ecs: %w, ECS_CONTAINER_METADATA_URI_V4 is not set", errNotDetected)
+	}
+	ctx, cancel := context.WithTimeout(ctx, metadataTimeout)
+	defer cancel()
+
+	var task struct {
+		Cluster          string
+		TaskARN          string
+		Family           string
+		Revision         string
+		AvailabilityZone string
+		LaunchType       string
+	}
+	var container struct {
+		DockerID string `json:"DockerId"`
+	}
+	for url, dst := range map[string]any{base + "/task": &task, base: &container} {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		body, err := fetchMetadata(d.client, req)
+		if err != nil {
+			return nil, fmt.Errorf("ecs: %w", err)
+		}
+		if err := json.Unmarshal(body, dst); err != nil {
+			return nil, fmt.Errorf("ecs: invalid metadata: %w", err)
+		}
+	}
+	return resource.NewSchemaless(
+		attribute.String("cloud.provider", "aws"),
+		attribute.String("cloud.platform", "aws_ecs"),
+		attribute.String("cloud.availability_zone", task.AvailabilityZone),
+		attribute.String("aws.ecs.cluster.arn", task.Cluster),
+		attribute.String("aws.ecs.task.arn", task.TaskARN),
+		attribute.String("aws.ecs.task.family", task.Family),
+		attribute.String("aws.ecs.task.revision", task.Revision),
+		attribute.String("aws.ecs.launchtype", strings.ToLower(task.LaunchType)),
+		attribute.String("container.id", container.DockerID),
+	), nil
+}
+
+// gceDetector reads the GCE metadata server, which also answers on GKE
+// and Cloud Run
+type gceDetector struct {
+	endpoint string
+	client   *http.Client
+}
+
+func (d gceDetector) Detect(ctx context.Context) (*resource.Resource, error) {
+	ctx, cancel := context.WithTimeout(ctx, metadataTimeout)
+	defer cancel()
+
+	values := map[string]string{}
+	for _, path := range []string{"project/project-id", "instance/zone", "instance/id", "instance/name"} {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, d.endpoint+"/computeMetadata/v1/"+path, nil)
+		req.Header.Set("Metadata-Flavor", "Google")
+		body, err := fetchMetadata(d.client, req)
+		if err != nil {
+			return nil, fmt.Errorf("gcp: %w", err)
+		}
+		values[path] = string(body)
+	}
+	// The zone comes as projects/<number>/zones/us-central1-a
+	zone := values["instance/zone"][strings.LastIndex(values["instance/zone"], "/")+1:]
+	region := zone
+	if i := strings.LastIndex(zone, "-"); i > 0 {
+		region = zone[:i]
+	}
+	return resource.NewSchemaless(
+		attribute.String("cloud.provider", "gcp"),
+		attribute.String("cloud.platform", "gcp_compute_engine"),
+		attribute.String("cloud.account.id", values["project/project-id"]),
+		attribute.String("cloud.availability_zone", zone),
+		attribute.String("cloud.region", region),
+		attribute.String("host.id", values["instance/id"]),
+		attribute.String("host.name", values["instance/name"]),
+	), nil
+}
+
+func fetchMetadata(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errNotDetected, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %s returned %d", errNotDetected, req.URL.Path, resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+}
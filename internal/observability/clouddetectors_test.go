@@ -0,0 +1,112 @@
+package observability
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+func resourceValues(res *resource.Resource) map[string]string {
+	values := map[string]string{}
+	for _, kv := range res.Attributes() {
+		values[string(kv.Key)] = kv.Value.Emit()
+	}
+	return values
+}
+
+func TestK8sDetector(t *testing.T) {
+	t.Setenv("K8S_POD_NAME", "order-service-7d9f")
+	t.Setenv("K8S_POD_NAMESPACE", "shop")
+	res, err := k8sDetector{}.Detect(context.Background())
+	if err != nil {
+		t.Fatalf("Detect failed: %v", err)
+	}
+	values := resourceValues(res)
+	if values["k8s.pod.name"] != "order-service-7d9f" || values["k8s.namespace.name"] != "shop" {
+		t.Errorf("Expected the pod name and namespace, got %v", values)
+	}
+
+	t.Setenv("K8S_POD_NAME", "")
+	t.Setenv("K8S_POD_NAMESPACE", "")
+	if _, err := (k8sDetector{}).Detect(context.Background()); !errors.Is(err, errNotDetected) {
+		t.Errorf("Expected errNotDetected outside Kubernetes, got %v", err)
+	}
+}
+
+func TestEC2Detector(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPut && r.URL.Path == "/latest/api/token":
+			w.Write([]byte("token-1"))
+		case r.URL.Path == "/latest/dynamic/instance-identity/document" && r.Header.Get("X-aws-ec2-metadata-token") == "token-1":
+			w.Write([]byte(`{"instanceId":"i-0abc","instanceType":"t3.micro","accountId":"123456789012","region":"eu-west-1","availabilityZone":"eu-west-1a"}`))
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+
+	res, err := ec2Detector{endpoint: srv.URL, client: srv.Client()}.Detect(context.Background())
+	if err != nil {
+		t.Fatalf("Detect failed: %v", err)
+	}
+	values := resourceValues(res)
+	if values["cloud.platform"] != "aws_ec2" || values["host.id"] != "i-0abc" || values["cloud.region"] != "eu-west-1" {
+		t.Errorf("Expected the identity document on the resource, got %v", values)
+	}
+}
+
+func TestECSDetector(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v4/task" {
+			w.Write([]byte(`{"Cluster":"arn:aws:ecs:eu-west-1:123:cluster/shop","TaskARN":"arn:aws:ecs:eu-west-1:123:task/shop/abc","Family":"orders","Revision":"7","LaunchType":"FARGATE"}`))
+			return
+		}
+		w.Write([]byte(`{"DockerId":"c0ffee"}`))
+	}))
+	defer srv.Close()
+
+	t.Setenv("ECS_CONTAINER_METADATA_URI_V4", srv.URL+"/v4")
+	res, err := ecsDetector{client: srv.Client()}.Detect(context.Background())
+	if err != nil {
+		t.Fatalf("Detect failed: %v", err)
+	}
+	values := resourceValues(res)
+	if values["aws.ecs.task.family"] != "orders" || values["aws.ecs.launchtype"] != "fargate" || values["container.id"] != "c0ffee" {
+		t.Errorf("Expected the task metadata on the resource, got %v", values)
+	}
+}
+
+func TestGCEDetector(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(map[string]string{
+			"/computeMetadata/v1/project/project-id": "shop-prod",
+			"/computeMetadata/v1/instance/zone":      "projects/42/zones/us-central1-a",
+			"/computeMetadata/v1/instance/id":        "9876",
+			"/computeMetadata/v1/instance/name":      "orders-1",
+		}[r.URL.Path]))
+	}))
+	defer srv.Close()
+
+	res, err := gceDetector{endpoint: srv.URL, client: srv.Client()}.Detect(context.Background())
+	if err != nil {
+		t.Fatalf("Detect failed: %v", err)
+	}
+	values := resourceValues(res)
+	if values["cloud.availability_zone"] != "us-central1-a" || values["cloud.region"] != "us-central1" {
+		t.Errorf("Expected zone us-central1-a in region us-central1, got %v", values)
+	}
+
+	srv.Close()
+	if _, err := (gceDetector{endpoint: srv.URL, client: srv.Client()}).Detect(context.Background()); !errors.Is(err, errNotDetected) {
+		t.Errorf("Expected errNotDetected without a metadata server, got %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package observability
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ANSI escape codes for the console handler
+const (
+	ansiReset  = "\x1b[0m"
+	ansiFaint  = "\x1b[2m"
+	ansiRed    = "\x1b[31m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+	ansiBlue   = "\x1b[34m"
+)
+
+// consoleHandler writes one human-readable line per record for local
+// development, e.g.
+//
+//	14:03:07.512 INFO  order created order_id=o-1 trace_id=4bf9...
+//
+// It is not meant to be parsed; use the json or logfmt format for that.
+type consoleHandler struct {
+	w     io.Writer
+	mu    *sync.Mutex
+	opts  slog.HandlerOptions
+	color bool
+	// attrs holds the attributes from WithAttrs, already formatted
+	attrs  []byte
+	prefix string
+}
+
+func newConsoleHandler(w io.Writer, opts *slog.HandlerOptions, color bool) *consoleHandler {
+	h := &consoleHandler{w: w, mu: &sync.Mutex{}, color: color}
+	if opts != nil {
+		h.opts = *opts
+	}
+	return h
+}
+
+func (h *consoleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	threshold := slog.LevelInfo
+	if h.opts.Level != nil {
+		threshold = h.opts.Level.Level()
+	}
+	return level >= threshold
+}
+
+func (h *consoleHandler) Handle(_ context.Context, r slog.Record) error {
+	buf := make([]byte, 0, 256)
+	if !r.Time.IsZero() {
+		buf = h.paint(buf, ansiFaint, r.Time.Format("15:04:05.000"))
+		buf = append(buf, ' ')
+	}
+	buf = h.paint(buf, levelColor(r.Level), padLevel(r.Level.String()))
+	buf = append(buf, ' ')
+	buf = append(buf, r.Message...)
+	buf = append(buf, h.attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		buf = h.appendAttr(buf, h.prefix, a)
+		return true
+	})
+	if h.opts.AddSource && r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		buf = append(buf, ' ')
+		buf = h.paint(buf, ansiFaint, filepath.Base(frame.File)+":"+strconv.Itoa(frame.Line))
+	}
+	buf = append(buf, '\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := h.w.Write(buf)
+	return err
+}
+
+func (h *consoleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := *h
+	out.attrs = append([]byte(nil), h.attrs...)
+	for _, a := range attrs {
+		out.attrs = h.appendAttr(out.attrs, h.prefix, a)
+	}
+	return &out
+}
+
+func (h *consoleHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	out := *h
+	out.prefix = h.prefix + name + "."
+	return &out
+}
+
+func (h *consoleHandler) appendAttr(buf []byte, prefix string, a slog.Attr) []byte {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return buf
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		// Groups with an empty key are inlined, as in the slog handlers
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			buf = h.appendAttr(buf, prefix, ga)
+		}
+		return buf
+	}
+	buf = append(buf, ' ')
+	buf = h.paint(buf, ansiFaint, prefix+a.Key+"=")
+	return append(buf, quoteIfNeeded(a.Value.String())...)
+}
+
+// paint appends s, wrapped in the color code when colors are on
+func (h *consoleHandler) paint(buf []byte, code, s string) []byte {
+	if !h.color {
+		return append(buf, s...)
+	}
+	buf = append(buf, code...)
+	buf = append(buf, s...)
+	return append(buf, ansiReset...)
+}
+
+func levelColor(level slog.Level) string {
+	switch {
+	case level >= slog.LevelError:
+		return ansiRed
+	case level >= slog.LevelWarn:
+		return ansiYellow
+	case level >= slog.LevelInfo:
+		return ansiGreen
+	default:
+		return ansiBlue
+	}
+}
+
+// padLevel keeps messages aligned for the common five-letter levels
+func padLevel(s string) string {
+	if len(s) < 5 {
+		return s + strings.Repeat(" ", 5-len(s))
+	}
+	return s
+}
+
+func quoteIfNeeded(s string) string {
+	if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+		return strconv.Quote(s)
+	}
+	return s
+}
@@ -0,0 +1,42 @@
+package observability
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestConsoleHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(newConsoleHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo}, false))
+
+	logger.Debug("hidden")
+	logger.With("service", "orders").WithGroup("req").Warn("payment slow",
+		"order_id", "o-1",
+		"note", "two words",
+		slog.Group("user", "id", 7),
+	)
+
+	line := buf.String()
+	if strings.Count(line, "\n") != 1 {
+		t.Fatalf("Expected one line above the level, got %q", line)
+	}
+	for _, want := range []string{"WARN  payment slow", "service=orders", "req.order_id=o-1", `req.note="two words"`, "req.user.id=7"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("Expected %q in %q", want, line)
+		}
+	}
+	if strings.Contains(line, "\x1b[") {
+		t.Errorf("Expected no color codes, got %q", line)
+	}
+}
+
+func TestConsoleHandler_Color(t *testing.T) {
+	var buf bytes.Buffer
+	slog.New(newConsoleHandler(&buf, nil, true)).Error("payment failed")
+
+	if !strings.Contains(buf.String(), ansiRed+"ERROR"+ansiReset) {
+		t.Errorf("Expected a red level, got %q", buf.String())
+	}
+}
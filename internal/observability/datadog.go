@@ -0,0 +1,101 @@
+package observability
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	ddTraceIDHeader  = "x-datadog-trace-id"
+	ddParentIDHeader = "x-datadog-parent-id"
+	ddPriorityHeader = "x-datadog-sampling-priority"
+	ddTagsHeader     = "x-datadog-tags"
+	ddTraceIDTag     = "_dd.p.tid"
+)
+
+// DatadogPropagator reads and writes the x-datadog-* headers used by
+// dd-trace instrumented services. Datadog carries the lower 64 bits of the
+// trace ID as a decimal header and the upper 64 bits in the _dd.p.tid tag.
+type DatadogPropagator struct{}
+
+var _ propagation.TextMapPropagator = DatadogPropagator{}
+
+func (DatadogPropagator) Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return
+	}
+
+	tid := sc.TraceID()
+	sid := sc.SpanID()
+	carrier.Set(ddTraceIDHeader, strconv.FormatUint(binary.BigEndian.Uint64(tid[8:]), 10))
+	carrier.Set(ddParentIDHeader, strconv.FormatUint(binary.BigEndian.Uint64(sid[:]), 10))
+	if upper := binary.BigEndian.Uint64(tid[:8]); upper != 0 {
+		carrier.Set(ddTagsHeader, ddTraceIDTag+"="+hex.EncodeToString(tid[:8]))
+	}
+
+	priority := "0"
+	if sc.IsSampled() {
+		priority = "1"
+	}
+	carrier.Set(ddPriorityHeader, priority)
+}
+
+func (DatadogPropagator) Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	lower, err := strconv.ParseUint(carrier.Get(ddTraceIDHeader), 10, 64)
+	if err != nil || lower == 0 {
+		return ctx
+	}
+	parent, err := strconv.ParseUint(carrier.Get(ddParentIDHeader), 10, 64)
+	if err != nil || parent == 0 {
+		return ctx
+	}
+
+	var tid trace.TraceID
+	binary.BigEndian.PutUint64(tid[8:], lower)
+	if upper, ok := ddUpperTraceID(carrier.Get(ddTagsHeader)); ok {
+		copy(tid[:8], upper)
+	}
+
+	var sid trace.SpanID
+	binary.BigEndian.PutUint64(sid[:], parent)
+
+	var flags trace.TraceFlags
+	// Priorities 1 (auto keep) and 2 (user keep) mean sampled
+	if p, err := strconv.Atoi(carrier.Get(ddPriorityHeader)); err == nil && p > 0 {
+		flags = trace.FlagsSampled
+	}
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    tid,
+		SpanID:     sid,
+		TraceFlags: flags,
+		Remote:     true,
+	})
+	return trace.ContextWithRemoteSpanContext(ctx, sc)
+}
+
+func (DatadogPropagator) Fields() []string {
+	return []string{ddTraceIDHeader, ddParentIDHeader, ddPriorityHeader, ddTagsHeader}
+}
+
+func ddUpperTraceID(tags string) ([]byte, bool) {
+	for _, tag := range strings.Split(tags, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(tag), "=")
+		if !ok || key != ddTraceIDTag || len(value) != 16 {
+			continue
+		}
+		upper, err := hex.DecodeString(value)
+		if err != nil {
+			return nil, false
+		}
+		return upper, true
+	}
+	return nil, false
+}
@@ -0,0 +1,74 @@
+package observability
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestDatadogPropagator_RoundTrip(t *testing.T) {
+	tid, _ := trace.TraceIDFromHex("0af7651916cd43dd8448eb211c80319c")
+	sid, _ := trace.SpanIDFromHex("b7ad6b7169203331")
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    tid,
+		SpanID:     sid,
+		TraceFlags: trace.FlagsSampled,
+	})
+
+	carrier := propagation.MapCarrier{}
+	DatadogPropagator{}.Inject(trace.ContextWithSpanContext(context.Background(), sc), carrier)
+
+	if carrier.Get(ddTraceIDHeader) != "9532127138774266268" {
+		t.Errorf("Unexpected trace ID header %q", carrier.Get(ddTraceIDHeader))
+	}
+	if carrier.Get(ddTagsHeader) != "_dd.p.tid=0af7651916cd43dd" {
+		t.Errorf("Unexpected tags header %q", carrier.Get(ddTagsHeader))
+	}
+	if carrier.Get(ddPriorityHeader) != "1" {
+		t.Errorf("Expected sampling priority 1, got %q", carrier.Get(ddPriorityHeader))
+	}
+
+	extracted := trace.SpanContextFromContext(DatadogPropagator{}.Extract(context.Background(), carrier))
+	if extracted.TraceID() != tid || extracted.SpanID() != sid || !extracted.IsSampled() || !extracted.IsRemote() {
+		t.Errorf("Round trip mismatch: %+v", extracted)
+	}
+}
+
+func TestDatadogPropagator_Extract64BitTraceID(t *testing.T) {
+	carrier := propagation.MapCarrier{
+		ddTraceIDHeader:  "1234",
+		ddParentIDHeader: "5678",
+		ddPriorityHeader: "-1",
+	}
+
+	sc := trace.SpanContextFromContext(DatadogPropagator{}.Extract(context.Background(), carrier))
+	if !sc.IsValid() {
+		t.Fatal("Expected a valid span context")
+	}
+	if sc.TraceID().String() != "000000000000000000000000000004d2" {
+		t.Errorf("Unexpected trace ID %s", sc.TraceID())
+	}
+	if sc.IsSampled() {
+		t.Error("Priority -1 (user drop) must not be sampled")
+	}
+
+	if sc := trace.SpanContextFromContext(DatadogPropagator{}.Extract(context.Background(), propagation.MapCarrier{})); sc.IsValid() {
+		t.Error("Expected no span context without headers")
+	}
+}
+
+func TestDatadogProfile_DeltaTemporality(t *testing.T) {
+	if deltaTemporality(metric.InstrumentKindCounter) != metricdata.DeltaTemporality {
+		t.Error("Counters should use delta temporality")
+	}
+	if deltaTemporality(metric.InstrumentKindUpDownCounter) != metricdata.CumulativeTemporality {
+		t.Error("Up-down counters should stay cumulative")
+	}
+	if len(ProfileDatadog.resourceAttributes("order-service", "1.0.0", "prod")) != 3 {
+		t.Error("Expected env, service, and version resource attributes")
+	}
+}
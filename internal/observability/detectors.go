@@ -0,0 +1,44 @@
+package observability
+
+import (
+	"fmt"
+	"go-observability-demo/internal/config"
+	"net/http"
+
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// resourceDetectors maps the names accepted in OTEL_RESOURCE_DETECTORS to
+// the detectors they enable; k8s and the cloud ones are in
+// clouddetectors.go. process leaves out the command line and owner,
+// which can carry secrets and usernames.
+var resourceDetectors = map[string][]resource.Option{
+	"host":      {resource.WithHost(), resource.WithHostID()},
+	"os":        {resource.WithOS()},
+	"container": {resource.WithContainer()},
+	"process": {
+		resource.WithProcessPID(),
+		resource.WithProcessExecutableName(),
+		resource.WithProcessRuntimeName(),
+		resource.WithProcessRuntimeVersion(),
+		resource.WithProcessRuntimeDescription(),
+	},
+	"k8s": {resource.WithDetectors(k8sDetector{})},
+	"ec2": {resource.WithDetectors(ec2Detector{endpoint: "http://169.254.169.254", client: http.DefaultClient})},
+	"ecs": {resource.WithDetectors(ecsDetector{client: http.DefaultClient})},
+	"gcp": {resource.WithDetectors(gceDetector{endpoint: "http://metadata.google.internal", client: http.DefaultClient})},
+}
+
+// resourceDetectorOptions returns the detectors named in
+// OTEL_RESOURCE_DETECTORS (comma separated, none by default)
+func resourceDetectorOptions() ([]resource.Option, error) {
+	var opts []resource.Option
+	for _, name := range config.List("OTEL_RESOURCE_DETECTORS", nil) {
+		detectors, ok := resourceDetectors[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown resource detector %q", name)
+		}
+		opts = append(opts, detectors...)
+	}
+	return opts, nil
+}
@@ -0,0 +1,33 @@
+package observability
+
+import (
+	"context"
+	"testing"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+func TestNewResource_Detectors(t *testing.T) {
+	t.Setenv("OTEL_RESOURCE_DETECTORS", "host,process,os")
+	res, err := newResource(context.Background(), "order-service", ProfileDefault)
+	if err != nil {
+		t.Fatalf("newResource failed: %v", err)
+	}
+	got := map[string]bool{}
+	for _, kv := range res.Attributes() {
+		got[string(kv.Key)] = true
+	}
+	for _, key := range []string{"host.name", "process.pid", "os.type", string(semconv.ServiceNameKey)} {
+		if !got[key] {
+			t.Errorf("Expected %s on the resource, got %v", key, res.Attributes())
+		}
+	}
+	if got["process.command_args"] {
+		t.Error("Expected the command line to be left out")
+	}
+
+	t.Setenv("OTEL_RESOURCE_DETECTORS", "gpu")
+	if _, err := newResource(context.Background(), "order-service", ProfileDefault); err == nil {
+		t.Error("Expected an error for an unknown detector")
+	}
+}
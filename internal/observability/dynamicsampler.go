@@ -0,0 +1,114 @@
+package observability
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// DynamicSampler lets the trace ratio be changed at runtime, e.g. raised
+// during an incident, and put back to the configured sampler afterwards
+type DynamicSampler struct {
+	base SamplerConfig
+	// configured is base's sampler, restored by Reset
+	configured sdktrace.Sampler
+	logger     *slog.Logger
+	active     atomic.Pointer[dynamicState]
+}
+
+type dynamicState struct {
+	sampler sdktrace.Sampler
+	// ratio is the override, or nil for the configured sampler
+	ratio *float64
+}
+
+func NewDynamicSampler(base SamplerConfig, logger *slog.Logger) (*DynamicSampler, error) {
+	sampler, err := base.Sampler()
+	if err != nil {
+		return nil, err
+	}
+	d := &DynamicSampler{base: base, configured: sampler, logger: logger}
+	d.active.Store(&dynamicState{sampler: sampler})
+	return d, nil
+}
+
+func (d *DynamicSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	return d.active.Load().sampler.ShouldSample(p)
+}
+
+func (d *DynamicSampler) Description() string {
+	return d.active.Load().sampler.Description()
+}
+
+// SetRatio samples ratio of new traces. Rules and the parent-based and
+// remote-parent settings stay as configured; only the ratio changes.
+func (d *DynamicSampler) SetRatio(ratio float64) error {
+	cfg := d.base
+	cfg.Name = "traceidratio"
+	if d.base.parentBased() {
+		cfg.Name = "parentbased_traceidratio"
+	}
+	cfg.Arg = strconv.FormatFloat(ratio, 'g', -1, 64)
+	sampler, err := cfg.Sampler()
+	if err != nil {
+		return err
+	}
+	d.active.Store(&dynamicState{sampler: sampler, ratio: &ratio})
+	return nil
+}
+
+// Reset goes back to the configured sampler
+func (d *DynamicSampler) Reset() {
+	d.active.Store(&dynamicState{sampler: d.configured})
+}
+
+// Handler serves the sampling admin API: GET shows the active sampler,
+// PUT {"ratio": 0.5} overrides the ratio, and DELETE removes the override
+func (d *DynamicSampler) Handler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var body struct {
+			Ratio *float64 `json:"ratio"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<10)).Decode(&body); err != nil || body.Ratio == nil {
+			writeAdminError(w, "ratio is required")
+			return
+		}
+		if err := d.SetRatio(*body.Ratio); err != nil {
+			writeAdminError(w, err.Error())
+			return
+		}
+		d.logger.InfoContext(r.Context(), "trace sampling ratio overridden",
+			slog.Float64("ratio", *body.Ratio),
+			slog.String("sampler", d.Description()),
+		)
+	case http.MethodDelete:
+		d.Reset()
+		d.logger.InfoContext(r.Context(), "trace sampling override removed",
+			slog.String("sampler", d.Description()),
+		)
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	state := d.active.Load()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"sampler":    state.sampler.Description(),
+		"ratio":      state.ratio,
+		"overridden": state.ratio != nil,
+	})
+}
+
+func writeAdminError(w http.ResponseWriter, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
@@ -0,0 +1,53 @@
+package observability
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDynamicSampler_Handler(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	d, err := NewDynamicSampler(SamplerConfig{Name: "parentbased_traceidratio", Arg: "0.1"}, logger)
+	if err != nil {
+		t.Fatalf("NewDynamicSampler failed: %v", err)
+	}
+
+	serve := func(method, body string) (int, map[string]any) {
+		rec := httptest.NewRecorder()
+		d.Handler(rec, httptest.NewRequest(method, "/admin/sampling", strings.NewReader(body)))
+		var got map[string]any
+		json.NewDecoder(rec.Body).Decode(&got)
+		return rec.Code, got
+	}
+
+	code, got := serve(http.MethodPut, `{"ratio":0.5}`)
+	if code != http.StatusOK || got["ratio"] != 0.5 || got["overridden"] != true {
+		t.Errorf("Expected the ratio to be overridden, got %d %v", code, got)
+	}
+	if !strings.HasPrefix(d.Description(), "ParentBased{root:TraceIDRatioBased{0.5}") {
+		t.Errorf("Expected the override to stay parent-based, got %s", d.Description())
+	}
+
+	for _, body := range []string{`{"ratio":1.5}`, `{}`, `nope`} {
+		if code, _ := serve(http.MethodPut, body); code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %d", body, code)
+		}
+	}
+
+	code, got = serve(http.MethodDelete, "")
+	if code != http.StatusOK || got["overridden"] != false {
+		t.Errorf("Expected the override to be removed, got %d %v", code, got)
+	}
+	if !strings.HasPrefix(d.Description(), "ParentBased{root:TraceIDRatioBased{0.1}") {
+		t.Errorf("Expected the configured ratio back, got %s", d.Description())
+	}
+
+	if code, _ := serve(http.MethodPost, ""); code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for POST, got %d", code)
+	}
+}
@@ -0,0 +1,473 @@
+package observability
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"go-observability-demo/internal/config"
+	"maps"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"google.golang.org/grpc/credentials"
+)
+
+// OTLP transports, as spelled in OTEL_EXPORTER_OTLP_PROTOCOL
+const (
+	ProtocolHTTP = "http/protobuf"
+	ProtocolGRPC = "grpc"
+)
+
+// Where telemetry goes, as spelled in OTEL_EXPORTER
+const (
+	ExporterOTLP = "otlp"
+	// ExporterStdout prints spans and metrics as JSON to stdout, for local
+	// development without a collector
+	ExporterStdout = "stdout"
+)
+
+// ExportConfig controls how OTLP payloads are sent to the collector
+type ExportConfig struct {
+	// Exporter is ExporterOTLP or ExporterStdout
+	Exporter string
+	// Protocol is ProtocolHTTP or ProtocolGRPC
+	Protocol string
+	// Compression is "gzip" or "none"
+	Compression string
+	Timeout     time.Duration
+	Retry       RetryConfig
+	TLS         ExportTLSConfig
+	// FanoutTraceEndpoints receive a copy of every span alongside the main
+	// endpoint. An http:// or https:// prefix picks plaintext or TLS for
+	// that endpoint; without one it follows TLS.
+	FanoutTraceEndpoints []string
+	// HistogramAggregation is HistogramExplicit or HistogramExponential
+	HistogramAggregation string
+	// Headers go with every export, e.g. an API key for a hosted backend
+	Headers map[string]string
+	// Traces, Metrics, and Logs override the settings above for one signal,
+	// so each can go to a different backend
+	Traces  SignalConfig
+	Metrics SignalConfig
+	Logs    SignalConfig
+}
+
+// SignalConfig holds the per-signal overrides; empty fields keep the
+// shared setting
+type SignalConfig struct {
+	// Endpoint replaces the collector endpoint, as host:port or a URL. A URL
+	// path replaces the default /v1/traces (or metrics, logs), and an
+	// http:// or https:// scheme picks plaintext or TLS.
+	Endpoint string
+	// Headers are added to the shared ones, replacing any with the same name
+	Headers     map[string]string
+	Compression string
+}
+
+// Histogram aggregations for pushed metrics, as spelled in
+// OTEL_EXPORTER_OTLP_METRICS_DEFAULT_HISTOGRAM_AGGREGATION
+const (
+	HistogramExplicit = "explicit_bucket_histogram"
+	// HistogramExponential sizes buckets to the data, so latency
+	// histograms need no hand-tuned boundaries
+	HistogramExponential = "base2_exponential_bucket_histogram"
+)
+
+// ExportTLSConfig secures the connection to a TLS-terminated collector.
+// The zero value uses TLS with the system roots.
+type ExportTLSConfig struct {
+	Insecure bool
+	// CAFile replaces the system roots, e.g. for a private collector
+	CAFile string
+	// CertFile and KeyFile are the client certificate for mTLS; they are
+	// re-read when the file changes
+	CertFile   string
+	KeyFile    string
+	ServerName string
+}
+
+// RetryConfig mirrors the exporters' retry settings
+type RetryConfig struct {
+	Enabled         bool
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	MaxElapsedTime  time.Duration
+}
+
+// DefaultExportConfig compresses by default: a 512-span batch shrinks about
+// 8x with gzip for under 1ms of CPU (see BenchmarkExportCompression), which
+// is the right trade over a constrained WAN link
+func DefaultExportConfig() ExportConfig {
+	return ExportConfig{
+		Exporter:             ExporterOTLP,
+		Protocol:             ProtocolHTTP,
+		TLS:                  ExportTLSConfig{Insecure: true},
+		Compression:          "gzip",
+		Timeout:              10 * time.Second,
+		HistogramAggregation: HistogramExplicit,
+		Retry: RetryConfig{
+			Enabled:         true,
+			InitialInterval: 5 * time.Second,
+			MaxInterval:     30 * time.Second,
+			MaxElapsedTime:  time.Minute,
+		},
+	}
+}
+
+// ExportConfigFromEnv reads the OTEL_EXPORTER_OTLP_* settings. Exports are
+// plaintext unless OTEL_EXPORTER_OTLP_INSECURE=false or a certificate or
+// server name is configured.
+func ExportConfigFromEnv() ExportConfig {
+	d := DefaultExportConfig()
+	tlsCfg := ExportTLSConfig{
+		CAFile:     config.String("OTEL_EXPORTER_OTLP_CERTIFICATE", ""),
+		CertFile:   config.String("OTEL_EXPORTER_OTLP_CLIENT_CERTIFICATE", ""),
+		KeyFile:    config.String("OTEL_EXPORTER_OTLP_CLIENT_KEY", ""),
+		ServerName: config.String("OTEL_EXPORTER_OTLP_SERVER_NAME", ""),
+	}
+	secure := tlsCfg.CAFile != "" || tlsCfg.CertFile != "" || tlsCfg.ServerName != ""
+	tlsCfg.Insecure = config.Bool("OTEL_EXPORTER_OTLP_INSECURE", !secure)
+
+	return ExportConfig{
+		Exporter:    config.String("OTEL_EXPORTER", d.Exporter),
+		Protocol:    config.String("OTEL_EXPORTER_OTLP_PROTOCOL", d.Protocol),
+		Compression: config.String("OTEL_EXPORTER_OTLP_COMPRESSION", d.Compression),
+		Timeout:     config.Duration("OTEL_EXPORTER_OTLP_TIMEOUT", d.Timeout),
+		Retry: RetryConfig{
+			Enabled:         config.Bool("OTEL_EXPORTER_OTLP_RETRY_ENABLED", d.Retry.Enabled),
+			InitialInterval: config.Duration("OTEL_EXPORTER_OTLP_RETRY_INITIAL_INTERVAL", d.Retry.InitialInterval),
+			MaxInterval:     config.Duration("OTEL_EXPORTER_OTLP_RETRY_MAX_INTERVAL", d.Retry.MaxInterval),
+			MaxElapsedTime:  config.Duration("OTEL_EXPORTER_OTLP_RETRY_MAX_ELAPSED_TIME", d.Retry.MaxElapsedTime),
+		},
+		TLS:                  tlsCfg,
+		FanoutTraceEndpoints: config.List("OTEL_TRACES_FANOUT_ENDPOINTS", nil),
+		HistogramAggregation: config.String("OTEL_EXPORTER_OTLP_METRICS_DEFAULT_HISTOGRAM_AGGREGATION", d.HistogramAggregation),
+		Headers:              parseHeaders(config.String("OTEL_EXPORTER_OTLP_HEADERS", "")),
+		Traces:               signalConfigFromEnv("TRACES"),
+		Metrics:              signalConfigFromEnv("METRICS"),
+		Logs:                 signalConfigFromEnv("LOGS"),
+	}
+}
+
+// signalConfigFromEnv reads OTEL_EXPORTER_OTLP_<SIGNAL>_ENDPOINT, _HEADERS,
+// and _COMPRESSION
+func signalConfigFromEnv(signal string) SignalConfig {
+	prefix := "OTEL_EXPORTER_OTLP_" + signal
+	return SignalConfig{
+		Endpoint:    config.String(prefix+"_ENDPOINT", ""),
+		Headers:     parseHeaders(config.String(prefix+"_HEADERS", "")),
+		Compression: config.String(prefix+"_COMPRESSION", ""),
+	}
+}
+
+// parseHeaders reads "key1=value1,key2=value2" with URL-encoded values;
+// malformed entries are skipped
+func parseHeaders(raw string) map[string]string {
+	headers := map[string]string{}
+	for _, pair := range strings.Split(raw, ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			continue
+		}
+		if decoded, err := url.PathUnescape(strings.TrimSpace(value)); err == nil {
+			headers[key] = decoded
+		}
+	}
+	if len(headers) == 0 {
+		return nil
+	}
+	return headers
+}
+
+// forSignal applies the overrides in s and returns the config, the
+// endpoint to use, and the URL path ("" for the exporter default)
+func (c ExportConfig) forSignal(s SignalConfig, endpoint string) (ExportConfig, string, string) {
+	if s.Compression != "" {
+		c.Compression = s.Compression
+	}
+	if len(s.Headers) > 0 {
+		headers := maps.Clone(c.Headers)
+		if headers == nil {
+			headers = make(map[string]string, len(s.Headers))
+		}
+		maps.Copy(headers, s.Headers)
+		c.Headers = headers
+	}
+	if s.Endpoint == "" {
+		return c, endpoint, ""
+	}
+	c, endpoint = c.forEndpoint(s.Endpoint)
+	if i := strings.Index(endpoint, "/"); i >= 0 {
+		if path := endpoint[i:]; path != "/" {
+			return c, endpoint[:i], path
+		}
+		return c, endpoint[:i], ""
+	}
+	return c, endpoint, ""
+}
+
+// forEndpoint strips an http:// or https:// scheme from endpoint and
+// returns the config to reach it
+func (c ExportConfig) forEndpoint(endpoint string) (ExportConfig, string) {
+	if rest, ok := strings.CutPrefix(endpoint, "https://"); ok {
+		c.TLS.Insecure = false
+		return c, rest
+	}
+	if rest, ok := strings.CutPrefix(endpoint, "http://"); ok {
+		c.TLS.Insecure = true
+		return c, rest
+	}
+	return c, endpoint
+}
+
+// clientConfig returns nil for plaintext exports
+func (t ExportTLSConfig) clientConfig() (*tls.Config, error) {
+	if t.Insecure {
+		return nil, nil
+	}
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: t.ServerName,
+	}
+	if t.CAFile != "" {
+		pool, err := loadCAPool(t.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = pool
+	}
+	if t.CertFile != "" {
+		certs, err := newCertReloader(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load OTLP client certificate: %w", err)
+		}
+		cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) { return certs.get() }
+	}
+	return cfg, nil
+}
+
+// DefaultEndpoint is the collector's standard port for the protocol
+func (c ExportConfig) DefaultEndpoint() string {
+	if c.Protocol == ProtocolGRPC {
+		return "localhost:4317"
+	}
+	return "localhost:4318"
+}
+
+// traceClient returns an OTLP trace client for the protocol
+func (c ExportConfig) traceClient(endpoint string) (otlptrace.Client, error) {
+	c, endpoint, path := c.forSignal(c.Traces, endpoint)
+	tlsCfg, err := c.TLS.clientConfig()
+	if err != nil {
+		return nil, err
+	}
+	switch c.Protocol {
+	case ProtocolHTTP:
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(endpoint), otlptracehttp.WithInsecure()}
+		if tlsCfg != nil {
+			opts = []otlptracehttp.Option{otlptracehttp.WithEndpoint(endpoint), otlptracehttp.WithTLSClientConfig(tlsCfg)}
+		}
+		if path != "" {
+			opts = append(opts, otlptracehttp.WithURLPath(path))
+		}
+		return otlptracehttp.NewClient(append(opts, c.traceOptions()...)...), nil
+	case ProtocolGRPC:
+		opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(endpoint), otlptracegrpc.WithInsecure()}
+		if tlsCfg != nil {
+			opts = []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(endpoint), otlptracegrpc.WithTLSCredentials(credentials.NewTLS(tlsCfg))}
+		}
+		return otlptracegrpc.NewClient(append(opts, c.traceGRPCOptions()...)...), nil
+	default:
+		return nil, fmt.Errorf("unsupported OTLP protocol %q, want %s or %s", c.Protocol, ProtocolHTTP, ProtocolGRPC)
+	}
+}
+
+// metricExporter returns an OTLP metric exporter for the protocol; a nil
+// temporality keeps the exporter default
+func (c ExportConfig) metricExporter(ctx context.Context, endpoint string, temporality metric.TemporalitySelector) (metric.Exporter, error) {
+	c, endpoint, path := c.forSignal(c.Metrics, endpoint)
+	tlsCfg, err := c.TLS.clientConfig()
+	if err != nil {
+		return nil, err
+	}
+	aggregation, err := c.histogramSelector()
+	if err != nil {
+		return nil, err
+	}
+	switch c.Protocol {
+	case ProtocolHTTP:
+		opts := []otlpmetrichttp.Option{otlpmetrichttp.WithEndpoint(endpoint), otlpmetrichttp.WithInsecure()}
+		if tlsCfg != nil {
+			opts = []otlpmetrichttp.Option{otlpmetrichttp.WithEndpoint(endpoint), otlpmetrichttp.WithTLSClientConfig(tlsCfg)}
+		}
+		if path != "" {
+			opts = append(opts, otlpmetrichttp.WithURLPath(path))
+		}
+		opts = append(opts, c.metricOptions()...)
+		if temporality != nil {
+			opts = append(opts, otlpmetrichttp.WithTemporalitySelector(temporality))
+		}
+		if aggregation != nil {
+			opts = append(opts, otlpmetrichttp.WithAggregationSelector(aggregation))
+		}
+		return otlpmetrichttp.New(ctx, opts...)
+	case ProtocolGRPC:
+		opts := []otlpmetricgrpc.Option{otlpmetricgrpc.WithEndpoint(endpoint), otlpmetricgrpc.WithInsecure()}
+		if tlsCfg != nil {
+			opts = []otlpmetricgrpc.Option{otlpmetricgrpc.WithEndpoint(endpoint), otlpmetricgrpc.WithTLSCredentials(credentials.NewTLS(tlsCfg))}
+		}
+		opts = append(opts, c.metricGRPCOptions()...)
+		if temporality != nil {
+			opts = append(opts, otlpmetricgrpc.WithTemporalitySelector(temporality))
+		}
+		if aggregation != nil {
+			opts = append(opts, otlpmetricgrpc.WithAggregationSelector(aggregation))
+		}
+		return otlpmetricgrpc.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("unsupported OTLP protocol %q, want %s or %s", c.Protocol, ProtocolHTTP, ProtocolGRPC)
+	}
+}
+
+// histogramSelector returns the aggregation selector for
+// HistogramAggregation, or nil to keep the exporter default. A view with
+// its own aggregation still wins.
+func (c ExportConfig) histogramSelector() (metric.AggregationSelector, error) {
+	switch c.HistogramAggregation {
+	case "", HistogramExplicit:
+		return nil, nil
+	case HistogramExponential:
+		return func(kind metric.InstrumentKind) metric.Aggregation {
+			if kind == metric.InstrumentKindHistogram {
+				return exponentialHistogram
+			}
+			return metric.DefaultAggregationSelector(kind)
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported histogram aggregation %q, want %s or %s", c.HistogramAggregation, HistogramExplicit, HistogramExponential)
+	}
+}
+
+// logExporter returns an OTLP log exporter for the protocol
+func (c ExportConfig) logExporter(ctx context.Context, endpoint string) (sdklog.Exporter, error) {
+	c, endpoint, path := c.forSignal(c.Logs, endpoint)
+	tlsCfg, err := c.TLS.clientConfig()
+	if err != nil {
+		return nil, err
+	}
+	switch c.Protocol {
+	case ProtocolHTTP:
+		opts := []otlploghttp.Option{otlploghttp.WithEndpoint(endpoint), otlploghttp.WithInsecure()}
+		if tlsCfg != nil {
+			opts = []otlploghttp.Option{otlploghttp.WithEndpoint(endpoint), otlploghttp.WithTLSClientConfig(tlsCfg)}
+		}
+		if path != "" {
+			opts = append(opts, otlploghttp.WithURLPath(path))
+		}
+		if len(c.Headers) > 0 {
+			opts = append(opts, otlploghttp.WithHeaders(c.Headers))
+		}
+		compression := otlploghttp.NoCompression
+		if c.Compression == "gzip" {
+			compression = otlploghttp.GzipCompression
+		}
+		opts = append(opts,
+			otlploghttp.WithCompression(compression),
+			otlploghttp.WithTimeout(c.Timeout),
+			otlploghttp.WithRetry(otlploghttp.RetryConfig(c.Retry)),
+		)
+		return otlploghttp.New(ctx, opts...)
+	case ProtocolGRPC:
+		opts := []otlploggrpc.Option{otlploggrpc.WithEndpoint(endpoint), otlploggrpc.WithInsecure()}
+		if tlsCfg != nil {
+			opts = []otlploggrpc.Option{otlploggrpc.WithEndpoint(endpoint), otlploggrpc.WithTLSCredentials(credentials.NewTLS(tlsCfg))}
+		}
+		if len(c.Headers) > 0 {
+			opts = append(opts, otlploggrpc.WithHeaders(c.Headers))
+		}
+		opts = append(opts,
+			otlploggrpc.WithTimeout(c.Timeout),
+			otlploggrpc.WithRetry(otlploggrpc.RetryConfig(c.Retry)),
+		)
+		if c.Compression == "gzip" {
+			opts = append(opts, otlploggrpc.WithCompressor("gzip"))
+		}
+		return otlploggrpc.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("unsupported OTLP protocol %q, want %s or %s", c.Protocol, ProtocolHTTP, ProtocolGRPC)
+	}
+}
+
+func (c ExportConfig) traceOptions() []otlptracehttp.Option {
+	compression := otlptracehttp.NoCompression
+	if c.Compression == "gzip" {
+		compression = otlptracehttp.GzipCompression
+	}
+	opts := []otlptracehttp.Option{
+		otlptracehttp.WithCompression(compression),
+		otlptracehttp.WithTimeout(c.Timeout),
+		otlptracehttp.WithRetry(otlptracehttp.RetryConfig(c.Retry)),
+	}
+	if len(c.Headers) > 0 {
+		opts = append(opts, otlptracehttp.WithHeaders(c.Headers))
+	}
+	return opts
+}
+
+func (c ExportConfig) metricOptions() []otlpmetrichttp.Option {
+	compression := otlpmetrichttp.NoCompression
+	if c.Compression == "gzip" {
+		compression = otlpmetrichttp.GzipCompression
+	}
+	opts := []otlpmetrichttp.Option{
+		otlpmetrichttp.WithCompression(compression),
+		otlpmetrichttp.WithTimeout(c.Timeout),
+		otlpmetrichttp.WithRetry(otlpmetrichttp.RetryConfig(c.Retry)),
+	}
+	if len(c.Headers) > 0 {
+		opts = append(opts, otlpmetrichttp.WithHeaders(c.Headers))
+	}
+	return opts
+}
+
+// The gRPC exporters only take "gzip" as a compressor and complain about
+// anything else, so no compression is the absence of the option
+
+func (c ExportConfig) traceGRPCOptions() []otlptracegrpc.Option {
+	opts := []otlptracegrpc.Option{
+		otlptracegrpc.WithTimeout(c.Timeout),
+		otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig(c.Retry)),
+	}
+	if c.Compression == "gzip" {
+		opts = append(opts, otlptracegrpc.WithCompressor("gzip"))
+	}
+	if len(c.Headers) > 0 {
+		opts = append(opts, otlptracegrpc.WithHeaders(c.Headers))
+	}
+	return opts
+}
+
+func (c ExportConfig) metricGRPCOptions() []otlpmetricgrpc.Option {
+	opts := []otlpmetricgrpc.Option{
+		otlpmetricgrpc.WithTimeout(c.Timeout),
+		otlpmetricgrpc.WithRetry(otlpmetricgrpc.RetryConfig(c.Retry)),
+	}
+	if c.Compression == "gzip" {
+		opts = append(opts, otlpmetricgrpc.WithCompressor("gzip"))
+	}
+	if len(c.Headers) > 0 {
+		opts = append(opts, otlpmetricgrpc.WithHeaders(c.Headers))
+	}
+	return opts
+}
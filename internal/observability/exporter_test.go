@@ -0,0 +1,225 @@
+package observability
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestExportConfigFromEnv(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_COMPRESSION", "none")
+	t.Setenv("OTEL_EXPORTER_OTLP_TIMEOUT", "2500")
+	t.Setenv("OTEL_EXPORTER_OTLP_RETRY_ENABLED", "false")
+
+	cfg := ExportConfigFromEnv()
+	if cfg.Compression != "none" || cfg.Timeout != 2500*time.Millisecond || cfg.Retry.Enabled {
+		t.Errorf("Unexpected config %+v", cfg)
+	}
+	if cfg.Retry.MaxElapsedTime != DefaultExportConfig().Retry.MaxElapsedTime {
+		t.Error("Unset retry settings should keep their defaults")
+	}
+	if len(cfg.traceOptions()) != 3 || len(cfg.metricOptions()) != 3 {
+		t.Error("Expected compression, timeout, and retry options")
+	}
+	if len(cfg.traceGRPCOptions()) != 2 || len(cfg.metricGRPCOptions()) != 2 {
+		t.Error("Expected no gRPC compressor when compression is off")
+	}
+}
+
+func TestExportConfig_Protocols(t *testing.T) {
+	ctx := context.Background()
+	for _, protocol := range []string{ProtocolHTTP, ProtocolGRPC} {
+		cfg := DefaultExportConfig()
+		cfg.Protocol = protocol
+		if _, err := cfg.traceClient(cfg.DefaultEndpoint()); err != nil {
+			t.Errorf("%s: failed to create trace client: %v", protocol, err)
+		}
+		exp, err := cfg.metricExporter(ctx, cfg.DefaultEndpoint(), deltaTemporality)
+		if err != nil {
+			t.Fatalf("%s: failed to create metric exporter: %v", protocol, err)
+		}
+		if exp.Temporality(sdkmetric.InstrumentKindCounter) != metricdata.DeltaTemporality {
+			t.Errorf("%s: expected the profile temporality to be applied", protocol)
+		}
+		exp.Shutdown(ctx)
+	}
+
+	cfg := DefaultExportConfig()
+	cfg.Protocol = "http/json"
+	if _, err := cfg.traceClient("localhost:4318"); err == nil {
+		t.Error("Expected an error for an unsupported protocol")
+	}
+	cfg.Protocol = ProtocolGRPC
+	if cfg.DefaultEndpoint() != "localhost:4317" {
+		t.Errorf("Expected the gRPC port by default, got %s", cfg.DefaultEndpoint())
+	}
+}
+
+func TestExportConfig_ExponentialHistograms(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("OTEL_EXPORTER_OTLP_METRICS_DEFAULT_HISTOGRAM_AGGREGATION", HistogramExponential)
+	cfg := ExportConfigFromEnv()
+	for _, protocol := range []string{ProtocolHTTP, ProtocolGRPC} {
+		cfg.Protocol = protocol
+		exp, err := cfg.metricExporter(ctx, cfg.DefaultEndpoint(), nil)
+		if err != nil {
+			t.Fatalf("%s: failed to create metric exporter: %v", protocol, err)
+		}
+		if _, ok := exp.Aggregation(sdkmetric.InstrumentKindHistogram).(sdkmetric.AggregationBase2ExponentialHistogram); !ok {
+			t.Errorf("%s: expected exponential histograms, got %T", protocol, exp.Aggregation(sdkmetric.InstrumentKindHistogram))
+		}
+		if _, ok := exp.Aggregation(sdkmetric.InstrumentKindCounter).(sdkmetric.AggregationSum); !ok {
+			t.Errorf("%s: expected counters to keep their sum aggregation", protocol)
+		}
+		exp.Shutdown(ctx)
+	}
+
+	cfg.HistogramAggregation = "summary"
+	if _, err := cfg.metricExporter(ctx, cfg.DefaultEndpoint(), nil); err == nil {
+		t.Error("Expected an error for an unsupported histogram aggregation")
+	}
+}
+
+func TestExportConfig_PerSignalSettings(t *testing.T) {
+	var gotPath, gotKey, gotTenant string
+	traces := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotKey, gotTenant = r.URL.Path, r.Header.Get("X-Api-Key"), r.Header.Get("X-Tenant")
+	}))
+	defer traces.Close()
+
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "x-api-key=shared,x-tenant=acme%20corp")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", traces.URL+"/custom/traces")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_HEADERS", "x-api-key=traces-only")
+	t.Setenv("OTEL_EXPORTER_OTLP_METRICS_ENDPOINT", "metrics.example.com:4318")
+	t.Setenv("OTEL_EXPORTER_OTLP_METRICS_COMPRESSION", "none")
+	cfg := ExportConfigFromEnv()
+	cfg.Retry.Enabled = false
+
+	client, err := cfg.traceClient("localhost:4318")
+	if err != nil {
+		t.Fatalf("Failed to create trace client: %v", err)
+	}
+	ctx := context.Background()
+	client.Start(ctx)
+	if err := client.UploadTraces(ctx, nil); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	client.Stop(ctx)
+	if gotPath != "/custom/traces" || gotKey != "traces-only" || gotTenant != "acme corp" {
+		t.Errorf("Expected /custom/traces with the traces key and shared tenant, got %s %q %q", gotPath, gotKey, gotTenant)
+	}
+
+	metricsCfg, endpoint, path := cfg.forSignal(cfg.Metrics, "localhost:4318")
+	if endpoint != "metrics.example.com:4318" || path != "" || metricsCfg.Compression != "none" {
+		t.Errorf("Expected metrics at metrics.example.com:4318 uncompressed, got %s %q %s", endpoint, path, metricsCfg.Compression)
+	}
+	if metricsCfg.Headers["x-api-key"] != "shared" {
+		t.Errorf("Expected the shared headers on metrics, got %v", metricsCfg.Headers)
+	}
+	if _, endpoint, _ := cfg.forSignal(cfg.Logs, "localhost:4318"); endpoint != "localhost:4318" {
+		t.Errorf("Expected logs to keep the shared endpoint, got %s", endpoint)
+	}
+}
+
+func TestExportTLS_PresentsClientCertificate(t *testing.T) {
+	pki := writeTestPKI(t, "spiffe://demo.local/collector", "spiffe://demo.local/order")
+	collector := pki["spiffe://demo.local/collector"]
+	serverTLS, err := collector.ServerTLS()
+	if err != nil {
+		t.Fatalf("Failed to build server TLS: %v", err)
+	}
+	peers := make(chan string, 1)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		peers <- r.URL.Path + " " + PeerIdentity(r.TLS)
+	}))
+	server.Listener = tls.NewListener(server.Listener, serverTLS)
+	server.Start()
+	t.Cleanup(server.Close)
+
+	order := pki["spiffe://demo.local/order"]
+	cfg := DefaultExportConfig()
+	cfg.Retry.Enabled = false
+	cfg.TLS = ExportTLSConfig{CAFile: order.CAFile, CertFile: order.CertFile, KeyFile: order.KeyFile, ServerName: "127.0.0.1"}
+	client, err := cfg.traceClient(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to create trace client: %v", err)
+	}
+	ctx := context.Background()
+	client.Start(ctx)
+	defer client.Stop(ctx)
+
+	if err := client.UploadTraces(ctx, []*tracepb.ResourceSpans{{}}); err != nil {
+		t.Fatalf("Upload over mTLS failed: %v", err)
+	}
+	if got := <-peers; got != "/v1/traces spiffe://demo.local/order" {
+		t.Errorf("Expected the export to present the order certificate, got %q", got)
+	}
+
+	cfg.TLS.CAFile = ""
+	if client, _ := cfg.traceClient(server.Listener.Addr().String()); client.UploadTraces(ctx, []*tracepb.ResourceSpans{{}}) == nil {
+		t.Error("Expected the collector certificate to be rejected without its CA")
+	}
+}
+
+func TestExportConfigFromEnv_TLS(t *testing.T) {
+	if !ExportConfigFromEnv().TLS.Insecure {
+		t.Error("Expected plaintext exports by default")
+	}
+	t.Setenv("OTEL_EXPORTER_OTLP_CERTIFICATE", "/etc/otel/ca.pem")
+	if ExportConfigFromEnv().TLS.Insecure {
+		t.Error("Expected a CA bundle to turn on TLS")
+	}
+	t.Setenv("OTEL_EXPORTER_OTLP_INSECURE", "true")
+	if !ExportConfigFromEnv().TLS.Insecure {
+		t.Error("Expected OTEL_EXPORTER_OTLP_INSECURE to win")
+	}
+}
+
+// BenchmarkExportCompression measures gzip on a full span batch shaped like
+// the order service's spans, to back the default in DefaultExportConfig
+func BenchmarkExportCompression(b *testing.B) {
+	spans := make([]*tracepb.Span, 512)
+	for i := range spans {
+		spans[i] = &tracepb.Span{
+			TraceId:           bytes.Repeat([]byte{byte(i)}, 16),
+			SpanId:            bytes.Repeat([]byte{byte(i)}, 8),
+			Name:              "ProcessPayment",
+			StartTimeUnixNano: uint64(time.Now().UnixNano()),
+			EndTimeUnixNano:   uint64(time.Now().UnixNano()),
+			Attributes: []*commonpb.KeyValue{
+				{Key: "user.id", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: fmt.Sprintf("user-%d", i%50)}}},
+				{Key: "product.id", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: "prod-123"}}},
+				{Key: "http.route", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: "/orders"}}},
+			},
+		}
+	}
+	data, err := proto.Marshal(&tracepb.TracesData{ResourceSpans: []*tracepb.ResourceSpans{{
+		ScopeSpans: []*tracepb.ScopeSpans{{Spans: spans}},
+	}}})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		zw := gzip.NewWriter(&buf)
+		zw.Write(data)
+		zw.Close()
+	}
+	b.ReportMetric(float64(len(data))/float64(buf.Len()), "ratio")
+}
@@ -0,0 +1,84 @@
+package observability
+
+import (
+	"go-observability-demo/internal/config"
+	"slices"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// SpanLimitsFromEnv reads the OTEL_SPAN_* and OTEL_*_ATTRIBUTE_COUNT_LIMIT
+// variables. Unlike the SDK, attribute values are capped at 4096 bytes by
+// default (OTEL_ATTRIBUTE_VALUE_LENGTH_LIMIT, -1 for no limit), so a long
+// error message or request body can't bloat a span.
+func SpanLimitsFromEnv() sdktrace.SpanLimits {
+	valueLength := config.Int("OTEL_ATTRIBUTE_VALUE_LENGTH_LIMIT", 4096)
+	return sdktrace.SpanLimits{
+		AttributeValueLengthLimit:   config.Int("OTEL_SPAN_ATTRIBUTE_VALUE_LENGTH_LIMIT", valueLength),
+		AttributeCountLimit:         config.Int("OTEL_SPAN_ATTRIBUTE_COUNT_LIMIT", sdktrace.DefaultAttributeCountLimit),
+		EventCountLimit:             config.Int("OTEL_SPAN_EVENT_COUNT_LIMIT", sdktrace.DefaultEventCountLimit),
+		LinkCountLimit:              config.Int("OTEL_SPAN_LINK_COUNT_LIMIT", sdktrace.DefaultLinkCountLimit),
+		AttributePerEventCountLimit: config.Int("OTEL_EVENT_ATTRIBUTE_COUNT_LIMIT", sdktrace.DefaultAttributePerEventCountLimit),
+		AttributePerLinkCountLimit:  config.Int("OTEL_LINK_ATTRIBUTE_COUNT_LIMIT", sdktrace.DefaultAttributePerLinkCountLimit),
+	}
+}
+
+// eventLimitProcessor applies the value length limit to event attributes,
+// which the SDK leaves alone; RecordError puts the whole error message in
+// one
+type eventLimitProcessor struct {
+	sdktrace.SpanProcessor
+	limit int
+}
+
+func (p eventLimitProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	p.SpanProcessor.OnEnd(limitedEventsSpan{s, p.limit})
+}
+
+type limitedEventsSpan struct {
+	sdktrace.ReadOnlySpan
+	limit int
+}
+
+func (s limitedEventsSpan) Events() []sdktrace.Event {
+	events := s.ReadOnlySpan.Events()
+	cloned := false
+	for i, e := range events {
+		attrs := truncateStrings(e.Attributes, s.limit)
+		if attrs == nil {
+			continue
+		}
+		// The span's own events are shared with other processors
+		if !cloned {
+			events, cloned = slices.Clone(events), true
+		}
+		events[i].Attributes = attrs
+	}
+	return events
+}
+
+// truncateStrings returns a copy of attrs with string values cut to limit
+// bytes, or nil when none were longer
+func truncateStrings(attrs []attribute.KeyValue, limit int) []attribute.KeyValue {
+	var out []attribute.KeyValue
+	for i, kv := range attrs {
+		if kv.Value.Type() != attribute.STRING || len(kv.Value.AsString()) <= limit {
+			continue
+		}
+		if out == nil {
+			out = slices.Clone(attrs)
+		}
+		out[i] = attribute.String(string(kv.Key), truncateUTF8(kv.Value.AsString(), limit))
+	}
+	return out
+}
+
+// truncateUTF8 cuts v to at most limit bytes without splitting a rune
+func truncateUTF8(v string, limit int) string {
+	for limit > 0 && !utf8.RuneStart(v[limit]) {
+		limit--
+	}
+	return v[:limit]
+}
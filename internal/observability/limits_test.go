@@ -0,0 +1,57 @@
+package observability
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSpanLimits_TruncateLongValues(t *testing.T) {
+	t.Setenv("OTEL_ATTRIBUTE_VALUE_LENGTH_LIMIT", "16")
+	t.Setenv("OTEL_SPAN_EVENT_COUNT_LIMIT", "2")
+	limits := SpanLimitsFromEnv()
+	if limits.AttributeCountLimit != sdktrace.DefaultAttributeCountLimit {
+		t.Errorf("Expected the default attribute count, got %d", limits.AttributeCountLimit)
+	}
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithRawSpanLimits(limits),
+		sdktrace.WithSpanProcessor(eventLimitProcessor{sdktrace.NewSimpleSpanProcessor(exporter), limits.AttributeValueLengthLimit}),
+	)
+	defer tp.Shutdown(context.Background())
+
+	long := strings.Repeat("x", 100)
+	_, span := tp.Tracer("test").Start(context.Background(), "CreateOrder")
+	span.SetAttributes(attribute.String("http.request.body", long))
+	span.RecordError(errors.New("payment declined: " + long))
+	span.AddEvent("retry")
+	span.AddEvent("retry")
+	span.End()
+
+	got := exporter.GetSpans()[0]
+	if v := got.Attributes[0].Value.AsString(); len(v) != 16 {
+		t.Errorf("Expected the attribute cut to 16 bytes, got %d", len(v))
+	}
+	if len(got.Events) != 2 || got.DroppedEvents != 1 {
+		t.Errorf("Expected 2 events kept and 1 dropped, got %d and %d", len(got.Events), got.DroppedEvents)
+	}
+	for _, e := range got.Events {
+		for _, kv := range e.Attributes {
+			if len(kv.Value.AsString()) > 16 {
+				t.Errorf("Expected event attribute %s cut to 16 bytes, got %d", kv.Key, len(kv.Value.AsString()))
+			}
+		}
+	}
+}
+
+func TestTruncateUTF8(t *testing.T) {
+	if got := truncateUTF8("héllo", 2); got != "h" {
+		t.Errorf("Expected the cut to back off to a rune boundary, got %q", got)
+	}
+}
@@ -0,0 +1,99 @@
+package observability
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// NewSpanEventHandler also records warn and error logs on the span in the
+// record's context, so a trace shows the log lines that fired during the
+// request: warnings as "log" events, errors through span.RecordError.
+// Attributes keep their keys, with groups flattened to "group.key".
+func NewSpanEventHandler(next slog.Handler) slog.Handler {
+	return spanEventHandler{Handler: next}
+}
+
+type spanEventHandler struct {
+	slog.Handler
+	attrs  []attribute.KeyValue
+	prefix string
+}
+
+func (h spanEventHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level >= slog.LevelWarn {
+		if span := trace.SpanFromContext(ctx); span.IsRecording() {
+			h.record(span, r)
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h spanEventHandler) record(span trace.Span, r slog.Record) {
+	kvs := slices.Clone(h.attrs)
+	kvs = append(kvs,
+		attribute.String("log.severity", r.Level.String()),
+		attribute.String("log.message", r.Message),
+	)
+	// The "error" attribute, when there is one, is the exception message
+	cause := r.Message
+	r.Attrs(func(a slog.Attr) bool {
+		if h.prefix == "" && a.Key == "error" {
+			cause = a.Value.Resolve().String()
+		}
+		kvs = appendKeyValues(kvs, h.prefix, a)
+		return true
+	})
+
+	if r.Level >= slog.LevelError {
+		span.RecordError(errors.New(cause), trace.WithAttributes(kvs...), trace.WithTimestamp(r.Time))
+		return
+	}
+	span.AddEvent("log", trace.WithAttributes(kvs...), trace.WithTimestamp(r.Time))
+}
+
+func (h spanEventHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := spanEventHandler{Handler: h.Handler.WithAttrs(attrs), attrs: slices.Clone(h.attrs), prefix: h.prefix}
+	for _, a := range attrs {
+		out.attrs = appendKeyValues(out.attrs, h.prefix, a)
+	}
+	return out
+}
+
+func (h spanEventHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return spanEventHandler{Handler: h.Handler.WithGroup(name), attrs: h.attrs, prefix: h.prefix + name + "."}
+}
+
+// appendKeyValues converts a to span attributes, flattening groups
+func appendKeyValues(kvs []attribute.KeyValue, prefix string, a slog.Attr) []attribute.KeyValue {
+	v := a.Value.Resolve()
+	key := prefix + a.Key
+	switch v.Kind() {
+	case slog.KindGroup:
+		if a.Key != "" {
+			prefix = key + "."
+		}
+		for _, ga := range v.Group() {
+			kvs = appendKeyValues(kvs, prefix, ga)
+		}
+		return kvs
+	case slog.KindBool:
+		return append(kvs, attribute.Bool(key, v.Bool()))
+	case slog.KindInt64:
+		return append(kvs, attribute.Int64(key, v.Int64()))
+	case slog.KindFloat64:
+		return append(kvs, attribute.Float64(key, v.Float64()))
+	default:
+		if a.Key == "" {
+			return kvs
+		}
+		return append(kvs, attribute.String(key, v.String()))
+	}
+}
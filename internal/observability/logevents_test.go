@@ -0,0 +1,49 @@
+package observability
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSpanEventHandler(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	ctx, span := tp.Tracer("test").Start(context.Background(), "CreateOrder")
+
+	logger := slog.New(NewSpanEventHandler(slog.NewJSONHandler(io.Discard, nil))).With("order_id", "o-1")
+	logger.InfoContext(ctx, "order creation started")
+	logger.WarnContext(ctx, "payment processing slow", slog.Group("payment", "attempt", 2))
+	logger.ErrorContext(ctx, "order processing failed", "error", "card declined")
+	logger.ErrorContext(context.Background(), "no span here")
+	span.End()
+
+	events := recorder.Ended()[0].Events()
+	if len(events) != 2 {
+		t.Fatalf("Expected events for the warn and error logs only, got %d", len(events))
+	}
+	warn := attributeMap(events[0].Attributes)
+	if events[0].Name != "log" || warn["log.message"] != "payment processing slow" || warn["order_id"] != "o-1" {
+		t.Errorf("Unexpected warn event %s %v", events[0].Name, warn)
+	}
+	if warn["payment.attempt"] != int64(2) {
+		t.Errorf("Expected the group flattened to payment.attempt, got %v", warn)
+	}
+	failed := attributeMap(events[1].Attributes)
+	if events[1].Name != "exception" || failed["exception.message"] != "card declined" || failed["log.severity"] != "ERROR" {
+		t.Errorf("Unexpected error event %s %v", events[1].Name, failed)
+	}
+}
+
+func attributeMap(kvs []attribute.KeyValue) map[string]any {
+	m := make(map[string]any, len(kvs))
+	for _, kv := range kvs {
+		m[string(kv.Key)] = kv.Value.AsInterface()
+	}
+	return m
+}
@@ -2,49 +2,227 @@ package observability
 
 import (
 	"context"
+	"encoding/hex"
+	"errors"
+	"go-observability-demo/internal/config"
+	"go-observability-demo/internal/observability/baggage"
+	"io"
 	"log/slog"
 	"os"
+	"strings"
+	"sync"
 
 	"go.opentelemetry.io/otel/trace"
 )
 
 func NewLogger() *slog.Logger {
-	level := slog.LevelInfo
-	if os.Getenv("LOG_LEVEL") == "debug" {
-		level = slog.LevelDebug
+	configured, levelOK := logLevelFromEnv()
+	configuredLevel.Store(int64(configured))
+	logLevel.Set(configured)
+	level := &logLevel
+
+	// Stdout writes happen in the background so a slow log consumer
+	// doesn't block requests
+	var out io.Writer = os.Stdout
+	if config.Bool("LOG_ASYNC", true) {
+		async := NewAsyncWriter(os.Stdout, config.Int("LOG_BUFFER_SIZE", defaultLogBufferSize))
+		registerLogSink(async.Close)
+		out = async
 	}
 
-	return slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+	format := getEnv("LOG_FORMAT", LogFormatJSON)
+	handler, ok := newFormatHandler(format, out, &slog.HandlerOptions{
 		Level:     level,
 		AddSource: true,
-	}))
+	})
+
+	if cfg, ok := SplunkHECConfigFromEnv(); ok {
+		hec := NewSplunkHECHandler(cfg, level)
+		registerLogSink(hec.Close)
+		handler = fanoutHandler{handler, hec}
+	}
+	// The OTel bridge takes the trace context from ctx itself
+	handler = NewTraceHandler(handler)
+
+	if LogsExportEnabled() {
+		handler = fanoutHandler{handler, otelLogHandler(level)}
+	}
+
+	if config.Bool("LOG_SPAN_EVENTS", true) {
+		handler = NewSpanEventHandler(handler)
+	}
+	handler = NewRateLimitHandler(handler, config.Int("LOG_RATE_LIMIT", defaultLogRateLimit))
+	// Inside the baggage handler so the user_id it adds is redacted too
+	redaction, redactErr := RedactionConfigFromEnv()
+	if redactErr != nil {
+		redaction = defaultRedaction
+	}
+	handler = NewRedactHandler(handler, redaction)
+	logger := slog.New(baggage.NewLogHandler(handler))
+	if !ok {
+		logger.Warn("unknown LOG_FORMAT, using json", "log_format", format)
+	}
+	if redactErr != nil {
+		logger.Error("invalid log redaction config, using the defaults", "error", redactErr.Error())
+	}
+	if !levelOK {
+		logger.Warn("unknown LOG_LEVEL, using info", "log_level", os.Getenv("LOG_LEVEL"))
+	}
+	return logger
+}
+
+// Values for LOG_FORMAT
+const (
+	LogFormatJSON = "json"
+	// LogFormatLogfmt writes key=value lines; "text" is accepted as an alias
+	LogFormatLogfmt = "logfmt"
+	LogFormatText   = "text"
+	// LogFormatConsole writes colored lines for a terminal; NO_COLOR turns
+	// the colors off
+	LogFormatConsole = "console"
+)
+
+// newFormatHandler returns the stdout handler for a LOG_FORMAT value, or a
+// JSON handler and false when the format is unknown
+func newFormatHandler(format string, w io.Writer, opts *slog.HandlerOptions) (slog.Handler, bool) {
+	switch strings.ToLower(format) {
+	case LogFormatJSON:
+		return slog.NewJSONHandler(w, opts), true
+	case LogFormatLogfmt, LogFormatText:
+		return slog.NewTextHandler(w, opts), true
+	case LogFormatConsole:
+		_, noColor := os.LookupEnv("NO_COLOR")
+		return newConsoleHandler(w, opts, !noColor), true
+	default:
+		return slog.NewJSONHandler(w, opts), false
+	}
+}
+
+var (
+	logSinksMu sync.Mutex
+	logSinks   []func(context.Context) error
+)
+
+func registerLogSink(closeFn func(context.Context) error) {
+	logSinksMu.Lock()
+	defer logSinksMu.Unlock()
+	logSinks = append(logSinks, closeFn)
+}
+
+// CloseLogSinks flushes and stops background log sinks such as Splunk HEC
+// and the async stdout writer.
+// Register it as a late shutdown hook so shutdown logs are still delivered.
+func CloseLogSinks(ctx context.Context) error {
+	logSinksMu.Lock()
+	sinks := logSinks
+	logSinks = nil
+	logSinksMu.Unlock()
+
+	var errs []error
+	for _, closeFn := range sinks {
+		errs = append(errs, closeFn(ctx))
+	}
+	return errors.Join(errs...)
 }
 
-// LogWithTrace adds trace context to logs for correlation
-func LogWithTrace(ctx context.Context, logger *slog.Logger, level slog.Level, msg string, args ...any) {
-	span := trace.SpanFromContext(ctx)
-	if span.SpanContext().IsValid() {
-		args = append(args,
-			slog.String("trace_id", span.SpanContext().TraceID().String()),
-			slog.String("span_id", span.SpanContext().SpanID().String()),
+// fanoutHandler sends each record to every handler that accepts its level
+type fanoutHandler []slog.Handler
+
+func (f fanoutHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range f {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f fanoutHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, h := range f {
+		if h.Enabled(ctx, r.Level) {
+			errs = append(errs, h.Handle(ctx, r.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (f fanoutHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := make(fanoutHandler, len(f))
+	for i, h := range f {
+		out[i] = h.WithAttrs(attrs)
+	}
+	return out
+}
+
+func (f fanoutHandler) WithGroup(name string) slog.Handler {
+	out := make(fanoutHandler, len(f))
+	for i, h := range f {
+		out[i] = h.WithGroup(name)
+	}
+	return out
+}
+
+// NewTraceHandler adds trace_id and span_id to records logged with a
+// context that carries a span, so plain logger.InfoContext(ctx, ...) calls
+// are correlated. Error records also get a trace_url when
+// TRACE_URL_TEMPLATE is set.
+func NewTraceHandler(next slog.Handler) slog.Handler {
+	return traceHandler{next}
+}
+
+type traceHandler struct {
+	slog.Handler
+}
+
+func (h traceHandler) Handle(ctx context.Context, r slog.Record) error {
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		traceID, spanID := traceIDs(sc)
+		r.AddAttrs(
+			slog.String("trace_id", traceID),
+			slog.String("span_id", spanID),
 		)
+		// Error logs get a ready-to-click link into the tracing UI
+		if r.Level >= slog.LevelError && traceURLTemplate != "" {
+			r.AddAttrs(slog.String("trace_url", renderTraceURL(traceURLTemplate, sc)))
+		}
 	}
-	logger.Log(ctx, level, msg, args...)
+	return h.Handler.Handle(ctx, r)
 }
 
-// Helper methods for common log levels
-func InfoWithTrace(ctx context.Context, logger *slog.Logger, msg string, args ...any) {
-	LogWithTrace(ctx, logger, slog.LevelInfo, msg, args...)
+func (h traceHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return traceHandler{h.Handler.WithAttrs(attrs)}
 }
 
-func ErrorWithTrace(ctx context.Context, logger *slog.Logger, msg string, args ...any) {
-	LogWithTrace(ctx, logger, slog.LevelError, msg, args...)
+func (h traceHandler) WithGroup(name string) slog.Handler {
+	return traceHandler{h.Handler.WithGroup(name)}
 }
 
-func WarnWithTrace(ctx context.Context, logger *slog.Logger, msg string, args ...any) {
-	LogWithTrace(ctx, logger, slog.LevelWarn, msg, args...)
+// traceIDs hex-encodes both IDs with a single string allocation
+func traceIDs(sc trace.SpanContext) (string, string) {
+	tid, sid := sc.TraceID(), sc.SpanID()
+
+	var buf [2*len(tid) + 2*len(sid)]byte
+	hex.Encode(buf[:2*len(tid)], tid[:])
+	hex.Encode(buf[2*len(tid):], sid[:])
+
+	ids := string(buf[:])
+	return ids[:2*len(tid)], ids[2*len(tid):]
 }
 
-func DebugWithTrace(ctx context.Context, logger *slog.Logger, msg string, args ...any) {
-	LogWithTrace(ctx, logger, slog.LevelDebug, msg, args...)
+type loggerKey struct{}
+
+// ContextWithLogger returns a copy of ctx carrying logger, typically one
+// with request-scoped fields such as request_id already attached
+func ContextWithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// LoggerFromContext returns the logger ContextWithLogger stored in ctx, or
+// slog.Default() when there is none
+func LoggerFromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
 }
@@ -0,0 +1,160 @@
+package observability
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func newTestLogger(w io.Writer, level slog.Level) *slog.Logger {
+	return slog.New(NewTraceHandler(slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:     level,
+		AddSource: true,
+	})))
+}
+
+func spanContext(b testing.TB) (context.Context, func()) {
+	tp := sdktrace.NewTracerProvider()
+	ctx, span := tp.Tracer("test").Start(context.Background(), "op")
+	return ctx, func() {
+		span.End()
+		_ = tp.Shutdown(context.Background())
+	}
+}
+
+func TestTraceHandler_AddsTraceContext(t *testing.T) {
+	ctx, end := spanContext(t)
+	defer end()
+
+	var buf bytes.Buffer
+	newTestLogger(&buf, slog.LevelInfo).InfoContext(ctx, "hello", slog.String("k", "v"))
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Failed to decode log line: %v", err)
+	}
+	if entry["trace_id"] == nil || entry["span_id"] == nil || entry["k"] != "v" {
+		t.Errorf("Expected trace_id, span_id and k in %v", entry)
+	}
+
+	source, _ := entry["source"].(map[string]any)
+	if file, _ := source["file"].(string); !strings.HasSuffix(file, "logger_test.go") {
+		t.Errorf("Expected source to point at the caller, got %v", source["file"])
+	}
+}
+
+func TestTraceHandler_WithoutSpan(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newTestLogger(&buf, slog.LevelInfo).With("component", "test")
+	logger.InfoContext(context.Background(), "hello")
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Failed to decode log line: %v", err)
+	}
+	if _, ok := entry["trace_id"]; ok {
+		t.Errorf("Expected no trace_id outside a span, got %v", entry)
+	}
+	if entry["component"] != "test" {
+		t.Errorf("Expected attributes from With to be kept, got %v", entry)
+	}
+}
+
+func TestTraceHandler_AddsTraceURLToErrors(t *testing.T) {
+	ctx, end := spanContext(t)
+	defer end()
+
+	prev := traceURLTemplate
+	traceURLTemplate = "http://jaeger:16686/trace/{trace_id}"
+	defer func() { traceURLTemplate = prev }()
+
+	var buf bytes.Buffer
+	logger := newTestLogger(&buf, slog.LevelInfo)
+	logger.ErrorContext(ctx, "payment failed")
+	logger.InfoContext(ctx, "order created")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	var errorEntry, infoEntry map[string]any
+	json.Unmarshal([]byte(lines[0]), &errorEntry)
+	json.Unmarshal([]byte(lines[1]), &infoEntry)
+
+	want := "http://jaeger:16686/trace/" + errorEntry["trace_id"].(string)
+	if errorEntry["trace_url"] != want {
+		t.Errorf("Expected trace_url %s, got %v", want, errorEntry["trace_url"])
+	}
+	if _, ok := infoEntry["trace_url"]; ok {
+		t.Error("trace_url should only be added to error logs")
+	}
+	if url := TraceURL(ctx); url != want {
+		t.Errorf("TraceURL returned %q, want %q", url, want)
+	}
+}
+
+func BenchmarkTraceHandler_Disabled(b *testing.B) {
+	ctx, end := spanContext(b)
+	defer end()
+	logger := newTestLogger(io.Discard, slog.LevelInfo)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.DebugContext(ctx, "checking inventory",
+			slog.String("product_id", "prod-123"),
+			slog.Int("quantity", 2),
+		)
+	}
+}
+
+func BenchmarkTraceHandler_Enabled(b *testing.B) {
+	ctx, end := spanContext(b)
+	defer end()
+	logger := newTestLogger(io.Discard, slog.LevelInfo)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.InfoContext(ctx, "order created successfully",
+			slog.String("order_id", "order-1"),
+			slog.Int64("duration_ms", 42),
+		)
+	}
+}
+
+func TestNewFormatHandler(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+		ok     bool
+	}{
+		{"json", `"msg":"hello"`, true},
+		{"logfmt", "msg=hello", true},
+		{"TEXT", "msg=hello", true},
+		{"console", "INFO  hello", true},
+		{"yaml", `"msg":"hello"`, false},
+	}
+	for _, tt := range tests {
+		t.Setenv("NO_COLOR", "1")
+		var buf bytes.Buffer
+		handler, ok := newFormatHandler(tt.format, &buf, nil)
+		slog.New(handler).Info("hello")
+		if ok != tt.ok || !strings.Contains(buf.String(), tt.want) {
+			t.Errorf("LOG_FORMAT=%s: expected %q (ok=%v), got %q (ok=%v)", tt.format, tt.want, tt.ok, buf.String(), ok)
+		}
+	}
+}
+
+func TestLoggerFromContext(t *testing.T) {
+	if LoggerFromContext(context.Background()) != slog.Default() {
+		t.Error("Expected the default logger without one in the context")
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil)).With("request_id", "r-1")
+	if got := LoggerFromContext(ContextWithLogger(context.Background(), logger)); got != logger {
+		t.Error("Expected the logger stored in the context")
+	}
+}
@@ -0,0 +1,90 @@
+package observability
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+var (
+	// logLevel is shared by every handler NewLogger builds, so changing it
+	// takes effect everywhere at once
+	logLevel slog.LevelVar
+	// configuredLevel is the LOG_LEVEL the process started with
+	configuredLevel atomic.Int64
+)
+
+// LogLevel is the level of the loggers NewLogger returns; set it to
+// change what is logged without a restart
+func LogLevel() *slog.LevelVar {
+	return &logLevel
+}
+
+// logLevelFromEnv parses LOG_LEVEL (debug, info, warn, error), falling back
+// to info
+func logLevelFromEnv() (slog.Level, bool) {
+	raw := getEnv("LOG_LEVEL", "info")
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(raw)); err != nil {
+		return slog.LevelInfo, false
+	}
+	return level, true
+}
+
+// ToggleDebugLogging switches between debug and the configured level and
+// returns the new level, e.g. on SIGUSR1
+func ToggleDebugLogging() slog.Level {
+	level := slog.LevelDebug
+	if logLevel.Level() == slog.LevelDebug {
+		level = slog.Level(configuredLevel.Load())
+	}
+	logLevel.Set(level)
+	return level
+}
+
+// LogLevelHandler serves the log level admin API: GET shows the level,
+// PUT {"level": "debug"} changes it, and DELETE goes back to LOG_LEVEL
+func LogLevelHandler(logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		previous := logLevel.Level()
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			var body struct {
+				Level string `json:"level"`
+			}
+			var level slog.Level
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<10)).Decode(&body); err != nil || body.Level == "" {
+				writeAdminError(w, "level is required")
+				return
+			}
+			if err := level.UnmarshalText([]byte(body.Level)); err != nil {
+				writeAdminError(w, "level must be debug, info, warn, or error")
+				return
+			}
+			logLevel.Set(level)
+		case http.MethodDelete:
+			logLevel.Set(slog.Level(configuredLevel.Load()))
+		default:
+			w.Header().Set("Allow", "GET, PUT, DELETE")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		level := logLevel.Level()
+		if level != previous {
+			// Logged at warn so the change shows up whatever the new level is
+			logger.WarnContext(r.Context(), "log level changed",
+				slog.String("from", previous.String()),
+				slog.String("to", level.String()),
+			)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"level":      strings.ToLower(level.String()),
+			"configured": strings.ToLower(slog.Level(configuredLevel.Load()).String()),
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package observability
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// withConfiguredLevel sets the level as NewLogger would and restores it
+// after the test
+func withConfiguredLevel(t *testing.T, level slog.Level) {
+	t.Helper()
+	prevLevel, prevConfigured := logLevel.Level(), configuredLevel.Load()
+	t.Cleanup(func() {
+		logLevel.Set(prevLevel)
+		configuredLevel.Store(prevConfigured)
+	})
+	logLevel.Set(level)
+	configuredLevel.Store(int64(level))
+}
+
+func TestLogLevelHandler(t *testing.T) {
+	withConfiguredLevel(t, slog.LevelInfo)
+	handler := LogLevelHandler(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	call := func(method, body string) (int, map[string]string) {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(method, "/admin/loglevel", strings.NewReader(body)))
+		var got map[string]string
+		json.Unmarshal(rec.Body.Bytes(), &got)
+		return rec.Code, got
+	}
+
+	if code, got := call(http.MethodPut, `{"level":"debug"}`); code != http.StatusOK || got["level"] != "debug" {
+		t.Errorf("Expected debug after PUT, got %d %v", code, got)
+	}
+	if LogLevel().Level() != slog.LevelDebug {
+		t.Error("Expected the shared level to be debug")
+	}
+	if code, _ := call(http.MethodPut, `{"level":"verbose"}`); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown level, got %d", code)
+	}
+	if _, got := call(http.MethodGet, ""); got["level"] != "debug" || got["configured"] != "info" {
+		t.Errorf("Expected debug over a configured info, got %v", got)
+	}
+	if _, got := call(http.MethodDelete, ""); got["level"] != "info" {
+		t.Errorf("Expected DELETE to restore info, got %v", got)
+	}
+	if code, _ := call(http.MethodPost, ""); code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405, got %d", code)
+	}
+}
+
+func TestToggleDebugLogging(t *testing.T) {
+	withConfiguredLevel(t, slog.LevelWarn)
+
+	if got := ToggleDebugLogging(); got != slog.LevelDebug {
+		t.Errorf("Expected debug, got %s", got)
+	}
+	if got := ToggleDebugLogging(); got != slog.LevelWarn {
+		t.Errorf("Expected the configured warn level back, got %s", got)
+	}
+}
+
+func TestLogLevelFromEnv(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "WARN")
+	if level, ok := logLevelFromEnv(); !ok || level != slog.LevelWarn {
+		t.Errorf("Expected warn, got %s", level)
+	}
+	t.Setenv("LOG_LEVEL", "loud")
+	if level, ok := logLevelFromEnv(); ok || level != slog.LevelInfo {
+		t.Errorf("Expected info for an unknown level, got %s", level)
+	}
+}
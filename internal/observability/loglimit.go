@@ -0,0 +1,127 @@
+package observability
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// defaultLogRateLimit is how many records with the same level and message
+// are written per second before the rest are suppressed
+const defaultLogRateLimit = 100
+
+// NewRateLimitHandler passes at most limit records per second for each
+// level and message, so a failing dependency logging on every request
+// can't flood the log pipeline. The first record written after others were
+// suppressed carries their count as "suppressed"; the total is also
+// counted in the log.records.suppressed metric. A limit <= 0 disables it.
+func NewRateLimitHandler(next slog.Handler, limit int) slog.Handler {
+	if limit <= 0 {
+		return next
+	}
+	suppressed, _ := otel.Meter("order-service/logs").Int64Counter(
+		"log.records.suppressed",
+		metric.WithDescription("Log records dropped by the per-message rate limit"),
+		metric.WithUnit("{record}"),
+	)
+	return rateLimitHandler{next, &logLimiter{
+		limit:      limit,
+		keys:       map[logKey]*logWindow{},
+		suppressed: suppressed,
+	}}
+}
+
+type rateLimitHandler struct {
+	slog.Handler
+	limiter *logLimiter
+}
+
+func (h rateLimitHandler) Handle(ctx context.Context, r slog.Record) error {
+	allow, suppressed := h.limiter.allow(r)
+	if !allow {
+		h.limiter.suppressed.Add(ctx, 1, metric.WithAttributes(attribute.String("level", r.Level.String())))
+		return nil
+	}
+	if suppressed > 0 {
+		r.AddAttrs(slog.Int("suppressed", suppressed))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h rateLimitHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return rateLimitHandler{h.Handler.WithAttrs(attrs), h.limiter}
+}
+
+func (h rateLimitHandler) WithGroup(name string) slog.Handler {
+	return rateLimitHandler{h.Handler.WithGroup(name), h.limiter}
+}
+
+type logKey struct {
+	level slog.Level
+	msg   string
+}
+
+// logWindow counts one key's records in the current one-second window
+type logWindow struct {
+	second     int64
+	written    int
+	suppressed int
+}
+
+// logLimiter is shared by a handler and everything derived from it with
+// WithAttrs or WithGroup
+type logLimiter struct {
+	limit      int
+	suppressed metric.Int64Counter
+
+	mu   sync.Mutex
+	keys map[logKey]*logWindow
+	// swept is the window in which idle keys were last removed
+	swept int64
+}
+
+// allow reports whether r may be written and how many records with its key
+// were suppressed since the last one written
+func (l *logLimiter) allow(r slog.Record) (bool, int) {
+	t := r.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	second := t.Unix()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if second != l.swept {
+		// Keys with nothing left to report are cheap to forget, which keeps
+		// one-off messages from growing the map
+		for k, w := range l.keys {
+			if w.second != second && w.suppressed == 0 {
+				delete(l.keys, k)
+			}
+		}
+		l.swept = second
+	}
+
+	key := logKey{r.Level, r.Message}
+	w, ok := l.keys[key]
+	if !ok {
+		w = &logWindow{second: second}
+		l.keys[key] = w
+	}
+	if w.second != second {
+		w.second, w.written = second, 0
+	}
+	if w.written >= l.limit {
+		w.suppressed++
+		return false, 0
+	}
+	w.written++
+	suppressed := w.suppressed
+	w.suppressed = 0
+	return true, suppressed
+}
@@ -0,0 +1,55 @@
+package observability
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimitHandler(t *testing.T) {
+	var buf bytes.Buffer
+	handler := NewRateLimitHandler(slog.NewJSONHandler(&buf, nil), 2)
+	logger := slog.New(handler.WithAttrs([]slog.Attr{slog.String("component", "payments")}))
+
+	start := time.Unix(1700000000, 0)
+	log := func(at time.Time, level slog.Level, msg string) {
+		r := slog.NewRecord(at, level, msg, 0)
+		if err := logger.Handler().Handle(context.Background(), r); err != nil {
+			t.Fatalf("Handle failed: %v", err)
+		}
+	}
+	for i := range 5 {
+		log(start.Add(time.Duration(i)*time.Millisecond), slog.LevelError, "payment gateway unreachable")
+	}
+	// Another message or level has its own budget
+	log(start, slog.LevelError, "inventory unreachable")
+	log(start, slog.LevelWarn, "payment gateway unreachable")
+	// The next second reports what was suppressed
+	log(start.Add(time.Second), slog.LevelError, "payment gateway unreachable")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("Expected 5 lines, got %d:\n%s", len(lines), buf.String())
+	}
+	var last map[string]any
+	if err := json.Unmarshal([]byte(lines[4]), &last); err != nil {
+		t.Fatalf("Failed to decode log line: %v", err)
+	}
+	if last["suppressed"] != float64(3) {
+		t.Errorf("Expected 3 suppressed records, got %v", last["suppressed"])
+	}
+	if strings.Contains(lines[0], "suppressed") {
+		t.Errorf("Expected no suppressed count before any were dropped, got %s", lines[0])
+	}
+}
+
+func TestRateLimitHandler_Disabled(t *testing.T) {
+	next := slog.NewJSONHandler(&bytes.Buffer{}, nil)
+	if NewRateLimitHandler(next, 0) != slog.Handler(next) {
+		t.Error("Expected a limit of 0 to return the handler unchanged")
+	}
+}
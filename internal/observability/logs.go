@@ -0,0 +1,63 @@
+package observability
+
+import (
+	"context"
+	"go-observability-demo/internal/config"
+	"log/slog"
+
+	"go.opentelemetry.io/contrib/bridges/otelslog"
+	"go.opentelemetry.io/otel/log/global"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// LogsExportEnabled reports whether OTEL_LOGS_EXPORTER=otlp, which sends
+// logs through the OTel Logs pipeline to the collector alongside traces and
+// metrics. Logs are printed to stdout either way; with OTEL_EXPORTER=stdout
+// that is all they get.
+func LogsExportEnabled() bool {
+	return config.String("OTEL_LOGS_EXPORTER", "none") == "otlp" && ExportConfigFromEnv().Exporter == ExporterOTLP && !Disabled()
+}
+
+// newLoggerProvider installs the global LoggerProvider that the slog
+// bridge in NewLogger writes to. It is flushed by CloseLogSinks, after the
+// other shutdown hooks have logged.
+func newLoggerProvider(ctx context.Context, res *resource.Resource, endpoint string) (*sdklog.LoggerProvider, error) {
+	exporter, err := ExportConfigFromEnv().logExporter(ctx, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	lp := sdklog.NewLoggerProvider(
+		sdklog.WithResource(res),
+		sdklog.WithProcessor(sdklog.NewBatchProcessor(exporter)),
+	)
+	global.SetLoggerProvider(lp)
+	registerLogSink(lp.Shutdown)
+	return lp, nil
+}
+
+// otelLogHandler forwards records to the global LoggerProvider, which may
+// be installed after the logger is created. Records keep the trace and
+// span of the context they are logged with.
+func otelLogHandler(level slog.Leveler) slog.Handler {
+	return leveledHandler{otelslog.NewHandler("order-service", otelslog.WithSource(true)), level}
+}
+
+// leveledHandler drops records below level before they reach a handler
+// that has no level of its own
+type leveledHandler struct {
+	slog.Handler
+	level slog.Leveler
+}
+
+func (h leveledHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level.Level() && h.Handler.Enabled(ctx, level)
+}
+
+func (h leveledHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return leveledHandler{h.Handler.WithAttrs(attrs), h.level}
+}
+
+func (h leveledHandler) WithGroup(name string) slog.Handler {
+	return leveledHandler{h.Handler.WithGroup(name), h.level}
+}
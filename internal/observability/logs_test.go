@@ -0,0 +1,65 @@
+package observability
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/log/global"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestLoggerProvider_ExportsSlogRecordsWithTrace(t *testing.T) {
+	prev := global.GetLoggerProvider()
+	t.Cleanup(func() { global.SetLoggerProvider(prev) })
+	t.Setenv("OTEL_EXPORTER_OTLP_COMPRESSION", "none")
+	t.Setenv("OTEL_EXPORTER_OTLP_RETRY_ENABLED", "false")
+
+	var records []*logspb.LogRecord
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req collogspb.ExportLogsServiceRequest
+		if r.URL.Path != "/v1/logs" || proto.Unmarshal(body, &req) != nil {
+			t.Errorf("Unexpected export to %s", r.URL.Path)
+			return
+		}
+		for _, rl := range req.ResourceLogs {
+			for _, sl := range rl.ScopeLogs {
+				records = append(records, sl.LogRecords...)
+			}
+		}
+	}))
+	defer collector.Close()
+
+	ctx := context.Background()
+	if _, err := newLoggerProvider(ctx, resource.Empty(), collector.Listener.Addr().String()); err != nil {
+		t.Fatalf("newLoggerProvider failed: %v", err)
+	}
+	logger := slog.New(otelLogHandler(slog.LevelInfo))
+
+	spanCtx, span := sdktrace.NewTracerProvider().Tracer("test").Start(ctx, "ProcessPayment")
+	logger.DebugContext(spanCtx, "not exported")
+	logger.WarnContext(spanCtx, "payment slow", "order_id", "o1")
+	span.End()
+
+	if err := CloseLogSinks(ctx); err != nil {
+		t.Fatalf("CloseLogSinks failed: %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("Expected 1 exported record, got %d", len(records))
+	}
+	got := records[0]
+	if got.Body.GetStringValue() != "payment slow" || got.SeverityText != "WARN" {
+		t.Errorf("Unexpected record %v", got)
+	}
+	if [16]byte(got.TraceId) != span.SpanContext().TraceID() {
+		t.Errorf("Expected the record in the span's trace, got %x", got.TraceId)
+	}
+}
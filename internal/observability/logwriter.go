@@ -0,0 +1,136 @@
+package observability
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// defaultLogBufferSize is how many log lines AsyncWriter holds before it
+// starts dropping the oldest
+const defaultLogBufferSize = 10000
+
+// AsyncWriter moves log output off the request path: Write queues a copy
+// of the line and a background goroutine writes it to the underlying
+// writer. When the queue is full the oldest line is dropped and counted in
+// the logs.dropped metric, so a slow stdout consumer costs log lines rather
+// than request latency.
+type AsyncWriter struct {
+	w       io.Writer
+	max     int
+	dropped metric.Int64Counter
+
+	mu    sync.Mutex
+	cond  *sync.Cond
+	queue [][]byte
+	// writing is set while the background goroutine writes a batch
+	writing bool
+	closed  bool
+	done    chan struct{}
+}
+
+// NewAsyncWriter starts the background goroutine writing to w; stop it
+// with Close
+func NewAsyncWriter(w io.Writer, size int) *AsyncWriter {
+	if size <= 0 {
+		size = defaultLogBufferSize
+	}
+	dropped, _ := otel.Meter("order-service/logs").Int64Counter(
+		"logs.dropped",
+		metric.WithDescription("Log lines dropped because the async log buffer was full"),
+		metric.WithUnit("{record}"),
+	)
+	a := &AsyncWriter{w: w, max: size, dropped: dropped, done: make(chan struct{})}
+	a.cond = sync.NewCond(&a.mu)
+	go a.run()
+	return a
+}
+
+// Write queues p. It doesn't fail: errors from the underlying writer
+// surface nowhere, as with a log line lost on a closed stdout.
+func (a *AsyncWriter) Write(p []byte) (int, error) {
+	line := append([]byte(nil), p...)
+
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		// Late lines, e.g. from other shutdown hooks, go out directly once
+		// the queue has been written
+		<-a.done
+		return a.w.Write(line)
+	}
+	if len(a.queue) >= a.max {
+		a.queue[0] = nil
+		a.queue = a.queue[1:]
+		a.dropped.Add(context.Background(), 1)
+	}
+	a.queue = append(a.queue, line)
+	a.cond.Broadcast()
+	a.mu.Unlock()
+	return len(p), nil
+}
+
+func (a *AsyncWriter) run() {
+	defer close(a.done)
+	for {
+		a.mu.Lock()
+		for len(a.queue) == 0 && !a.closed {
+			a.cond.Wait()
+		}
+		if len(a.queue) == 0 {
+			a.mu.Unlock()
+			return
+		}
+		batch := a.queue
+		a.queue = nil
+		a.writing = true
+		a.mu.Unlock()
+
+		for _, line := range batch {
+			_, _ = a.w.Write(line)
+		}
+
+		a.mu.Lock()
+		a.writing = false
+		a.cond.Broadcast()
+		a.mu.Unlock()
+	}
+}
+
+// Flush waits until every queued line has been written, or ctx is done
+func (a *AsyncWriter) Flush(ctx context.Context) error {
+	drained := make(chan struct{})
+	go func() {
+		a.mu.Lock()
+		for len(a.queue) > 0 || a.writing {
+			a.cond.Wait()
+		}
+		a.mu.Unlock()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close writes what is queued and stops the background goroutine. Lines
+// written after Close go straight to the underlying writer.
+func (a *AsyncWriter) Close(ctx context.Context) error {
+	a.mu.Lock()
+	a.closed = true
+	a.cond.Broadcast()
+	a.mu.Unlock()
+
+	select {
+	case <-a.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,89 @@
+package observability
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// gatedWriter blocks writes until open is closed
+type gatedWriter struct {
+	open chan struct{}
+	mu   sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (w *gatedWriter) Write(p []byte) (int, error) {
+	<-w.open
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *gatedWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
+
+func TestAsyncWriter_FlushWritesInOrder(t *testing.T) {
+	w := &gatedWriter{open: make(chan struct{})}
+	close(w.open)
+	a := NewAsyncWriter(w, 100)
+	for i := range 10 {
+		fmt.Fprintf(a, "line %d\n", i)
+	}
+	if err := a.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(w.String()), "\n")
+	if len(lines) != 10 || lines[0] != "line 0" || lines[9] != "line 9" {
+		t.Errorf("Expected lines 0-9 in order, got %q", lines)
+	}
+	if err := a.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	fmt.Fprintln(a, "after close")
+	if !strings.HasSuffix(w.String(), "after close\n") {
+		t.Error("Expected writes after Close to go straight through")
+	}
+}
+
+func TestAsyncWriter_DropsOldest(t *testing.T) {
+	w := &gatedWriter{open: make(chan struct{})}
+	a := NewAsyncWriter(w, 3)
+
+	// The first line may already be held by the blocked background write
+	fmt.Fprintln(a, "first")
+	for i := range 10 {
+		fmt.Fprintf(a, "line %d\n", i)
+	}
+	close(w.open)
+	if err := a.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	out := w.String()
+	if !strings.HasSuffix(out, "line 7\nline 8\nline 9\n") {
+		t.Errorf("Expected the newest lines to survive, got %q", out)
+	}
+	if strings.Contains(out, "line 0\n") {
+		t.Errorf("Expected the oldest queued lines to be dropped, got %q", out)
+	}
+}
+
+func TestAsyncWriter_FlushHonorsContext(t *testing.T) {
+	w := &gatedWriter{open: make(chan struct{})}
+	defer close(w.open)
+	a := NewAsyncWriter(w, 10)
+	fmt.Fprintln(a, "stuck")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := a.Flush(ctx); err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
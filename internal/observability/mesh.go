@@ -0,0 +1,65 @@
+package observability
+
+import (
+	"context"
+
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+const (
+	envoyRequestIDHeader   = "x-request-id"
+	envoySpanContextHeader = "x-ot-span-context"
+)
+
+type meshHeadersKey struct{}
+
+// meshPropagators returns the propagators needed when running behind
+// Envoy/Istio. The sidecars emit B3 headers and expect the application to
+// forward them together with x-request-id; x-ot-span-context is opaque to
+// us and is forwarded unchanged.
+func meshPropagators() []propagation.TextMapPropagator {
+	return []propagation.TextMapPropagator{
+		b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader | b3.B3SingleHeader)),
+		MeshHeaderPropagator{},
+	}
+}
+
+// MeshHeaderPropagator forwards the mesh headers that carry no trace
+// context of their own, so Envoy can stitch the hops into one request
+type MeshHeaderPropagator struct{}
+
+var _ propagation.TextMapPropagator = MeshHeaderPropagator{}
+
+func (MeshHeaderPropagator) Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	headers, _ := ctx.Value(meshHeadersKey{}).(map[string]string)
+	for k, v := range headers {
+		carrier.Set(k, v)
+	}
+}
+
+func (p MeshHeaderPropagator) Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	var headers map[string]string
+	for _, k := range p.Fields() {
+		if v := carrier.Get(k); v != "" {
+			if headers == nil {
+				headers = make(map[string]string, 2)
+			}
+			headers[k] = v
+		}
+	}
+	if headers == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, meshHeadersKey{}, headers)
+}
+
+func (MeshHeaderPropagator) Fields() []string {
+	return []string{envoyRequestIDHeader, envoySpanContextHeader}
+}
+
+// MeshRequestID returns the x-request-id assigned by the mesh, if any
+func MeshRequestID(ctx context.Context) string {
+	headers, _ := ctx.Value(meshHeadersKey{}).(map[string]string)
+	return headers[envoyRequestIDHeader]
+}
@@ -0,0 +1,55 @@
+package observability
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func meshComposite() propagation.TextMapPropagator {
+	return propagation.NewCompositeTextMapPropagator(append(meshPropagators(), propagation.TraceContext{})...)
+}
+
+func TestMeshPropagators_ExtractB3AndForwardRequestID(t *testing.T) {
+	carrier := propagation.MapCarrier{
+		"x-b3-traceid":      "0af7651916cd43dd8448eb211c80319c",
+		"x-b3-spanid":       "b7ad6b7169203331",
+		"x-b3-sampled":      "1",
+		"x-request-id":      "3b6e6a2c-9a4f-4d7e-8d65-1f5a0f2b7c11",
+		"x-ot-span-context": "opaque",
+	}
+
+	ctx := meshComposite().Extract(context.Background(), carrier)
+	sc := trace.SpanContextFromContext(ctx)
+	if sc.TraceID().String() != "0af7651916cd43dd8448eb211c80319c" || !sc.IsSampled() {
+		t.Errorf("Expected B3 context to be extracted, got %+v", sc)
+	}
+	if MeshRequestID(ctx) != "3b6e6a2c-9a4f-4d7e-8d65-1f5a0f2b7c11" {
+		t.Errorf("Unexpected request ID %q", MeshRequestID(ctx))
+	}
+
+	out := propagation.MapCarrier{}
+	meshComposite().Inject(ctx, out)
+	if out.Get("x-request-id") != carrier.Get("x-request-id") || out.Get("x-ot-span-context") != "opaque" {
+		t.Errorf("Mesh headers were not forwarded: %v", out)
+	}
+	if out.Get("x-b3-traceid") == "" || out.Get("traceparent") == "" {
+		t.Errorf("Expected both B3 and W3C headers downstream, got %v", out)
+	}
+}
+
+func TestMeshPropagators_TraceparentWins(t *testing.T) {
+	carrier := propagation.MapCarrier{
+		"x-b3-traceid": "11111111111111111111111111111111",
+		"x-b3-spanid":  "2222222222222222",
+		"x-b3-sampled": "1",
+		"traceparent":  "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+	}
+
+	sc := trace.SpanContextFromContext(meshComposite().Extract(context.Background(), carrier))
+	if sc.TraceID().String() != "0af7651916cd43dd8448eb211c80319c" {
+		t.Errorf("Expected W3C context to take precedence, got %s", sc.TraceID())
+	}
+}
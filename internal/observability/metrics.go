@@ -1,71 +1,182 @@
 package observability
 
 import (
+	"context"
+	"fmt"
+	"go-observability-demo/internal/config"
+	"slices"
+	"strconv"
+
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
 )
 
+// defaultOrderDurationBuckets (ms) reach a minute, so slow payments land in
+// a bucket instead of the overflow and p95/p99 stay meaningful
+var defaultOrderDurationBuckets = []float64{10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000, 20000, 30000, 60000}
+
+// Metrics holds the instruments the order service records on every request.
+// Less common ones come from the embedded Registry by name, e.g.
+// metrics.Counter("orders.refunded").
 type Metrics struct {
-	OrderCounter      metric.Int64Counter
-	OrderDuration     metric.Float64Histogram
-	PaymentAmount     metric.Float64Counter
-	InventoryRequests metric.Int64Counter
-	ErrorCounter      metric.Int64Counter
+	*Registry
+
+	OrderCounter        metric.Int64Counter
+	OrderDuration       metric.Float64Histogram
+	PaymentAmount       metric.Float64Counter
+	PaymentDuration     metric.Float64Histogram
+	PaymentsDeclined    metric.Int64Counter
+	InventoryRequests   metric.Int64Counter
+	ErrorCounter        metric.Int64Counter
+	ConnectionsAcquired metric.Int64Counter
+	StreamedBytes       metric.Int64Counter
+	BudgetExceeded      metric.Int64Counter
+	HistoryRequests     metric.Int64Counter
+	OrderLookups        metric.Int64Counter
+	OrdersCancelled     metric.Int64Counter
+	DuplicatesDetected  metric.Int64Counter
+	ExportedRows        metric.Int64Counter
+	OrdersInFlight      metric.Int64UpDownCounter
 }
 
 func NewMetrics() (*Metrics, error) {
-	meter := otel.Meter("order-service")
+	registry := NewRegistry(otel.Meter("order-service"))
 
-	orderCounter, err := meter.Int64Counter(
-		"orders.created",
-		metric.WithDescription("Total number of orders created"),
-		metric.WithUnit("{order}"),
-	)
+	buckets, err := orderDurationBuckets()
 	if err != nil {
 		return nil, err
 	}
 
-	orderDuration, err := meter.Float64Histogram(
-		"orders.duration",
-		metric.WithDescription("Order processing duration"),
-		metric.WithUnit("ms"),
-	)
-	if err != nil {
-		return nil, err
+	m := &Metrics{
+		Registry: registry,
+		OrderCounter: registry.Counter(
+			"orders.created",
+			metric.WithDescription("Total number of orders created"),
+			metric.WithUnit("{order}"),
+		),
+		OrderDuration: registry.Histogram(
+			"orders.duration",
+			metric.WithDescription("Order processing duration"),
+			metric.WithUnit("ms"),
+			metric.WithExplicitBucketBoundaries(buckets...),
+		),
+		PaymentAmount: registry.FloatCounter(
+			"payments.total_amount",
+			metric.WithDescription("Total payment amount processed"),
+			metric.WithUnit("USD"),
+		),
+		PaymentDuration: registry.Histogram(
+			"payments.duration",
+			metric.WithDescription("Payment processing duration, by gateway and outcome"),
+			metric.WithUnit("ms"),
+			metric.WithExplicitBucketBoundaries(buckets...),
+		),
+		PaymentsDeclined: registry.Counter(
+			"payments.declined",
+			metric.WithDescription("Payments the gateway declined, by gateway and decline reason"),
+			metric.WithUnit("{payment}"),
+		),
+		InventoryRequests: registry.Counter(
+			"inventory.requests",
+			metric.WithDescription("Number of inventory check requests"),
+			metric.WithUnit("{request}"),
+		),
+		ErrorCounter: registry.Counter(
+			"errors.total",
+			metric.WithDescription("Total number of errors"),
+			metric.WithUnit("{error}"),
+		),
+		ConnectionsAcquired: registry.Counter(
+			"http.client.connections.acquired",
+			metric.WithDescription("Connections obtained by downstream HTTP clients, split by keep-alive reuse"),
+			metric.WithUnit("{connection}"),
+		),
+		StreamedBytes: registry.Counter(
+			"http.server.response.streamed_bytes",
+			metric.WithDescription("Bytes written by streaming JSON responses"),
+			metric.WithUnit("By"),
+		),
+		BudgetExceeded: registry.Counter(
+			"orders.step.budget_exceeded",
+			metric.WithDescription("Order steps that ran past their latency budget, by step"),
+			metric.WithUnit("{step}"),
+		),
+		HistoryRequests: registry.Counter(
+			"orders.history.requests",
+			metric.WithDescription("Order history requests by user (bounded) and outcome"),
+			metric.WithUnit("{request}"),
+		),
+		OrderLookups: registry.Counter(
+			"orders.lookups",
+			metric.WithDescription("Single order reads, by outcome (found, not_found, unauthorized, error)"),
+			metric.WithUnit("{request}"),
+		),
+		OrdersCancelled: registry.Counter(
+			"orders.cancelled",
+			metric.WithDescription("Orders cancelled, with their payment refunded and stock released"),
+			metric.WithUnit("{order}"),
+		),
+		DuplicatesDetected: registry.Counter(
+			"orders.duplicates.detected",
+			metric.WithDescription("Orders that looked like a repeat of a recent one, by action (flagged, blocked)"),
+			metric.WithUnit("{order}"),
+		),
+		ExportedRows: registry.Counter(
+			"orders.export.rows",
+			metric.WithDescription("Orders written by exports, by format"),
+			metric.WithUnit("{order}"),
+		),
+		OrdersInFlight: registry.UpDownCounter(
+			"orders.in_flight",
+			metric.WithDescription("Orders being processed right now"),
+			metric.WithUnit("{order}"),
+		),
 	}
-
-	paymentAmount, err := meter.Float64Counter(
-		"payments.total_amount",
-		metric.WithDescription("Total payment amount processed"),
-		metric.WithUnit("USD"),
-	)
-	if err != nil {
+	if err := registry.Err(); err != nil {
 		return nil, err
 	}
+	return m, nil
+}
 
-	inventoryRequests, err := meter.Int64Counter(
-		"inventory.requests",
-		metric.WithDescription("Number of inventory check requests"),
-		metric.WithUnit("{request}"),
+// RegisterGauge reports observe as an Int64ObservableGauge read on every
+// collection, so queues and worker pools can expose their state without a
+// loop recording it. Components sharing a name tell themselves apart with
+// attrs. Unregister the returned registration when the component stops.
+func (m *Metrics) RegisterGauge(name, description, unit string, observe func(context.Context) int64, attrs ...attribute.KeyValue) (metric.Registration, error) {
+	gauge, err := m.Registry.meter.Int64ObservableGauge(
+		name,
+		metric.WithDescription(description),
+		metric.WithUnit(unit),
 	)
 	if err != nil {
 		return nil, err
 	}
+	opt := metric.WithAttributeSet(attribute.NewSet(attrs...))
+	return m.Registry.meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		o.ObserveInt64(gauge, observe(ctx), opt)
+		return nil
+	}, gauge)
+}
 
-	errorCounter, err := meter.Int64Counter(
-		"errors.total",
-		metric.WithDescription("Total number of errors"),
-		metric.WithUnit("{error}"),
-	)
-	if err != nil {
-		return nil, err
+// orderDurationBuckets reads ORDERS_DURATION_BUCKETS, comma separated
+// boundaries in milliseconds. A view in OTEL_METRIC_VIEWS still overrides
+// them.
+func orderDurationBuckets() ([]float64, error) {
+	values := config.List("ORDERS_DURATION_BUCKETS", nil)
+	if len(values) == 0 {
+		return defaultOrderDurationBuckets, nil
 	}
-
-	return &Metrics{
-		OrderCounter:      orderCounter,
-		OrderDuration:     orderDuration,
-		PaymentAmount:     paymentAmount,
-		InventoryRequests: inventoryRequests,
-		ErrorCounter:      errorCounter,
-	}, nil
+	buckets := make([]float64, len(values))
+	for i, v := range values {
+		b, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ORDERS_DURATION_BUCKETS value %q", v)
+		}
+		buckets[i] = b
+	}
+	if !slices.IsSorted(buckets) {
+		return nil, fmt.Errorf("ORDERS_DURATION_BUCKETS must be in increasing order")
+	}
+	return buckets, nil
 }
@@ -0,0 +1,82 @@
+package observability
+
+import (
+	"context"
+	"go-observability-demo/internal/observability/observabilitytest"
+	"slices"
+	"sync/atomic"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestOrderDurationBuckets(t *testing.T) {
+	buckets, err := orderDurationBuckets()
+	if err != nil || !slices.Equal(buckets, defaultOrderDurationBuckets) {
+		t.Errorf("Expected the default buckets, got %v (%v)", buckets, err)
+	}
+
+	t.Setenv("ORDERS_DURATION_BUCKETS", "100, 1000,10000")
+	buckets, err = orderDurationBuckets()
+	if err != nil || !slices.Equal(buckets, []float64{100, 1000, 10000}) {
+		t.Errorf("Expected [100 1000 10000], got %v (%v)", buckets, err)
+	}
+
+	for _, bad := range []string{"100,1s", "1000,100"} {
+		t.Setenv("ORDERS_DURATION_BUCKETS", bad)
+		if _, err := orderDurationBuckets(); err == nil {
+			t.Errorf("Expected an error for %q", bad)
+		}
+	}
+}
+
+func TestRegisterGauge(t *testing.T) {
+	recorder := observabilitytest.New(t)
+	metrics, err := NewMetrics()
+	if err != nil {
+		t.Fatalf("Failed to create metrics: %v", err)
+	}
+
+	var depth atomic.Int64
+	depth.Store(3)
+	reg, err := metrics.RegisterGauge("queue.depth", "Items waiting", "{item}", func(context.Context) int64 {
+		return depth.Load()
+	}, attribute.String("queue", "emails"))
+	if err != nil {
+		t.Fatalf("Failed to register gauge: %v", err)
+	}
+	if _, err := metrics.RegisterGauge("queue.depth", "Items waiting", "{item}", func(context.Context) int64 {
+		return 7
+	}, attribute.String("queue", "webhooks")); err != nil {
+		t.Fatalf("Failed to register second gauge: %v", err)
+	}
+
+	depth.Store(5)
+	got := gaugePoints(t, recorder)
+	if got["emails"] != 5 || got["webhooks"] != 7 {
+		t.Errorf("Expected emails=5 webhooks=7, got %v", got)
+	}
+
+	if err := reg.Unregister(); err != nil {
+		t.Fatalf("Failed to unregister: %v", err)
+	}
+	got = gaugePoints(t, recorder)
+	if _, ok := got["emails"]; ok || got["webhooks"] != 7 {
+		t.Errorf("Expected only webhooks after unregistering, got %v", got)
+	}
+}
+
+func gaugePoints(t *testing.T, recorder *observabilitytest.Recorder) map[string]int64 {
+	t.Helper()
+	m, ok := recorder.Metric(t, "queue.depth")
+	if !ok {
+		t.Fatal("Expected queue.depth to be recorded")
+	}
+	points := map[string]int64{}
+	for _, dp := range m.Data.(metricdata.Gauge[int64]).DataPoints {
+		queue, _ := dp.Attributes.Value("queue")
+		points[queue.AsString()] = dp.Value
+	}
+	return points
+}
@@ -0,0 +1,190 @@
+package observability
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// MTLSConfig points at PEM files for mutual TLS between the demo services.
+// With SPIFFE these are the SVID files kept fresh by spiffe-helper; the
+// certificate is re-read when the file changes, so rotation needs no restart.
+type MTLSConfig struct {
+	CertFile string
+	KeyFile  string
+	CAFile   string
+	// AllowedPeers restricts which client identities may connect, e.g.
+	// spiffe://demo.local/payment; empty allows any peer signed by the CA
+	AllowedPeers []string
+}
+
+// MTLSConfigFromEnv returns the config and whether MTLS_CERT_FILE is set
+func MTLSConfigFromEnv() (MTLSConfig, bool) {
+	cfg := MTLSConfig{
+		CertFile: getEnv("MTLS_CERT_FILE", ""),
+		KeyFile:  getEnv("MTLS_KEY_FILE", ""),
+		CAFile:   getEnv("MTLS_CA_FILE", ""),
+	}
+	if peers := getEnv("MTLS_ALLOWED_PEERS", ""); peers != "" {
+		for _, p := range strings.Split(peers, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				cfg.AllowedPeers = append(cfg.AllowedPeers, p)
+			}
+		}
+	}
+	return cfg, cfg.CertFile != ""
+}
+
+// ServerTLS requires and verifies client certificates
+func (c MTLSConfig) ServerTLS() (*tls.Config, error) {
+	pool, err := c.caPool()
+	if err != nil {
+		return nil, err
+	}
+	certs, err := newCertReloader(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		MinVersion:       tls.VersionTLS12,
+		ClientAuth:       tls.RequireAndVerifyClientCert,
+		ClientCAs:        pool,
+		GetCertificate:   func(*tls.ClientHelloInfo) (*tls.Certificate, error) { return certs.get() },
+		VerifyConnection: c.verifyPeer,
+	}, nil
+}
+
+// ClientTLS presents our certificate and verifies the server against the CA
+func (c MTLSConfig) ClientTLS() (*tls.Config, error) {
+	pool, err := c.caPool()
+	if err != nil {
+		return nil, err
+	}
+	certs, err := newCertReloader(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		MinVersion:           tls.VersionTLS12,
+		RootCAs:              pool,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) { return certs.get() },
+	}, nil
+}
+
+func (c MTLSConfig) caPool() (*x509.CertPool, error) {
+	return loadCAPool(c.CAFile)
+}
+
+func loadCAPool(file string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New("no certificates found in CA file")
+	}
+	return pool, nil
+}
+
+func (c MTLSConfig) verifyPeer(cs tls.ConnectionState) error {
+	if len(c.AllowedPeers) == 0 {
+		return nil
+	}
+	id := PeerIdentity(&cs)
+	for _, allowed := range c.AllowedPeers {
+		if id == allowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("peer %q is not allowed", id)
+}
+
+// PeerIdentity returns the SPIFFE ID (URI SAN) of the peer certificate, or
+// its common name for static certificates
+func PeerIdentity(cs *tls.ConnectionState) string {
+	if cs == nil || len(cs.PeerCertificates) == 0 {
+		return ""
+	}
+	leaf := cs.PeerCertificates[0]
+	for _, uri := range leaf.URIs {
+		if uri.Scheme == "spiffe" {
+			return uri.String()
+		}
+	}
+	return leaf.Subject.CommonName
+}
+
+// RecordPeerIdentity adds the client's identity to the request span. Wrap
+// it inside otelhttp.NewHandler so the attribute lands on the server span.
+func RecordPeerIdentity(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id := PeerIdentity(r.TLS); id != "" {
+			trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("tls.client.identity", id))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// certReloader re-reads the key pair when the certificate file changes,
+// checking at most once per second
+type certReloader struct {
+	certFile, keyFile string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+	checked time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if _, err := r.get(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *certReloader) get() (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.cert != nil && time.Since(r.checked) < time.Second {
+		return r.cert, nil
+	}
+	r.checked = time.Now()
+
+	info, err := os.Stat(r.certFile)
+	if err != nil {
+		if r.cert != nil {
+			return r.cert, nil
+		}
+		return nil, fmt.Errorf("failed to stat certificate: %w", err)
+	}
+	if r.cert != nil && !info.ModTime().After(r.modTime) {
+		return r.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		if r.cert != nil {
+			// Keep serving the old pair while a rotation is half written
+			return r.cert, nil
+		}
+		return nil, fmt.Errorf("failed to load key pair: %w", err)
+	}
+	r.cert = &cert
+	r.modTime = info.ModTime()
+	return r.cert, nil
+}
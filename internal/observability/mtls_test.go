@@ -0,0 +1,163 @@
+package observability
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"go-observability-demo/internal/observability/observabilitytest"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// writeTestPKI creates a CA plus a certificate per SPIFFE ID and returns an
+// MTLSConfig for each, keyed by ID
+func writeTestPKI(t *testing.T, ids ...string) map[string]MTLSConfig {
+	t.Helper()
+	dir := t.TempDir()
+
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "demo-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	caCert, _ := x509.ParseCertificate(caDER)
+	caFile := filepath.Join(dir, "ca.pem")
+	writePEM(t, caFile, "CERTIFICATE", caDER)
+
+	configs := map[string]MTLSConfig{}
+	for i, id := range ids {
+		key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		uri, _ := url.Parse(id)
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(int64(i + 2)),
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			URIs:         []*url.URL{uri},
+			IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, caCert, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatalf("Failed to create certificate: %v", err)
+		}
+		keyDER, _ := x509.MarshalECPrivateKey(key)
+
+		cfg := MTLSConfig{
+			CertFile: filepath.Join(dir, uri.Path[1:]+".pem"),
+			KeyFile:  filepath.Join(dir, uri.Path[1:]+"-key.pem"),
+			CAFile:   caFile,
+		}
+		writePEM(t, cfg.CertFile, "CERTIFICATE", der)
+		writePEM(t, cfg.KeyFile, "EC PRIVATE KEY", keyDER)
+		configs[id] = cfg
+	}
+	return configs
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+func startMTLSServer(t *testing.T, cfg MTLSConfig) *httptest.Server {
+	t.Helper()
+	serverTLS, err := cfg.ServerTLS()
+	if err != nil {
+		t.Fatalf("Failed to build server TLS: %v", err)
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) })
+	// StartTLS would install its own certificate ahead of GetCertificate
+	server := httptest.NewUnstartedServer(otelhttp.NewHandler(RecordPeerIdentity(handler), "POST /orders"))
+	server.Listener = tls.NewListener(server.Listener, serverTLS)
+	server.Start()
+	server.URL = "https://" + server.Listener.Addr().String()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func mtlsClient(t *testing.T, cfg MTLSConfig) *http.Client {
+	t.Helper()
+	clientTLS, err := cfg.ClientTLS()
+	if err != nil {
+		t.Fatalf("Failed to build client TLS: %v", err)
+	}
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: clientTLS}}
+}
+
+func TestMTLS_RecordsPeerIdentityOnServerSpan(t *testing.T) {
+	recorder := observabilitytest.New(t)
+	pki := writeTestPKI(t, "spiffe://demo.local/order", "spiffe://demo.local/payment")
+	server := startMTLSServer(t, pki["spiffe://demo.local/order"])
+
+	resp, err := mtlsClient(t, pki["spiffe://demo.local/payment"]).Get(server.URL)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if got := PeerIdentity(resp.TLS); got != "spiffe://demo.local/order" {
+		t.Errorf("Expected server identity spiffe://demo.local/order, got %q", got)
+	}
+	span := recorder.SpansNamed("POST /orders")[0]
+	want := attribute.String("tls.client.identity", "spiffe://demo.local/payment")
+	found := false
+	for _, attr := range span.Attributes {
+		if attr == want {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected %v on server span, got %v", want, span.Attributes)
+	}
+}
+
+func TestMTLS_RejectsClientsWithoutCertificateOrNotAllowed(t *testing.T) {
+	pki := writeTestPKI(t, "spiffe://demo.local/order", "spiffe://demo.local/payment", "spiffe://demo.local/loadgen")
+	serverCfg := pki["spiffe://demo.local/order"]
+	serverCfg.AllowedPeers = []string{"spiffe://demo.local/payment"}
+	server := startMTLSServer(t, serverCfg)
+
+	// Trusts the CA but presents no certificate
+	noCert := mtlsClient(t, serverCfg)
+	noCert.Transport.(*http.Transport).TLSClientConfig.GetClientCertificate = nil
+	if _, err := noCert.Get(server.URL); err == nil {
+		t.Error("Expected a client without a certificate to be rejected")
+	}
+
+	if _, err := mtlsClient(t, pki["spiffe://demo.local/loadgen"]).Get(server.URL); err == nil {
+		t.Error("Expected a peer outside MTLS_ALLOWED_PEERS to be rejected")
+	}
+
+	resp, err := mtlsClient(t, pki["spiffe://demo.local/payment"]).Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected an allowed peer to connect, got %v", err)
+	}
+	resp.Body.Close()
+}
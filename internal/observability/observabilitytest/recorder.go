@@ -0,0 +1,255 @@
+package observabilitytest
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Recorder captures spans, metrics, and logs in memory so tests can assert
+// on all three signals together
+type Recorder struct {
+	Logger         *slog.Logger
+	TracerProvider *sdktrace.TracerProvider
+	MeterProvider  *sdkmetric.MeterProvider
+
+	spans  *tracetest.InMemoryExporter
+	reader *sdkmetric.ManualReader
+	logs   *logStore
+}
+
+// LogRecord is a captured log line with its trace correlation resolved
+type LogRecord struct {
+	Level   slog.Level
+	Message string
+	Attrs   map[string]any
+	TraceID string
+	SpanID  string
+}
+
+// New installs in-memory tracer and meter providers as the otel globals and
+// restores the previous globals when the test finishes
+func New(t testing.TB) *Recorder {
+	t.Helper()
+
+	spans := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(spans))
+
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	prevTP := otel.GetTracerProvider()
+	prevMP := otel.GetMeterProvider()
+	otel.SetTracerProvider(tp)
+	otel.SetMeterProvider(mp)
+
+	t.Cleanup(func() {
+		ctx := context.Background()
+		_ = tp.Shutdown(ctx)
+		_ = mp.Shutdown(ctx)
+		otel.SetTracerProvider(prevTP)
+		otel.SetMeterProvider(prevMP)
+	})
+
+	logs := &logStore{}
+	return &Recorder{
+		Logger:         slog.New(&captureHandler{store: logs, level: slog.LevelDebug}),
+		TracerProvider: tp,
+		MeterProvider:  mp,
+		spans:          spans,
+		reader:         reader,
+		logs:           logs,
+	}
+}
+
+// Spans returns all ended spans
+func (r *Recorder) Spans() tracetest.SpanStubs {
+	return r.spans.GetSpans()
+}
+
+// SpansNamed returns ended spans with the given name
+func (r *Recorder) SpansNamed(name string) []tracetest.SpanStub {
+	var out []tracetest.SpanStub
+	for _, s := range r.spans.GetSpans() {
+		if s.Name == name {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// Logs returns all captured log records
+func (r *Recorder) Logs() []LogRecord {
+	return r.logs.all()
+}
+
+// LogsForTrace returns the log records correlated with the given trace ID
+func (r *Recorder) LogsForTrace(traceID trace.TraceID) []LogRecord {
+	id := traceID.String()
+	var out []LogRecord
+	for _, rec := range r.logs.all() {
+		if rec.TraceID == id {
+			out = append(out, rec)
+		}
+	}
+	return out
+}
+
+// Metrics collects the current state of every instrument
+func (r *Recorder) Metrics(t testing.TB) metricdata.ResourceMetrics {
+	t.Helper()
+
+	var rm metricdata.ResourceMetrics
+	if err := r.reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Failed to collect metrics: %v", err)
+	}
+	return rm
+}
+
+// Metric returns the named instrument's data, or false if nothing was recorded
+func (r *Recorder) Metric(t testing.TB, name string) (metricdata.Metrics, bool) {
+	t.Helper()
+
+	rm := r.Metrics(t)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == name {
+				return m, true
+			}
+		}
+	}
+	return metricdata.Metrics{}, false
+}
+
+// Int64Sum adds up every data point of an int64 counter
+func (r *Recorder) Int64Sum(t testing.TB, name string) int64 {
+	t.Helper()
+
+	m, ok := r.Metric(t, name)
+	if !ok {
+		return 0
+	}
+	sum, ok := m.Data.(metricdata.Sum[int64])
+	if !ok {
+		t.Fatalf("Metric %s is %T, not an int64 sum", name, m.Data)
+	}
+
+	var total int64
+	for _, dp := range sum.DataPoints {
+		total += dp.Value
+	}
+	return total
+}
+
+// GaugeValue returns the first data point of an int64 or float64 gauge
+func (r *Recorder) GaugeValue(t testing.TB, name string) float64 {
+	t.Helper()
+
+	m, ok := r.Metric(t, name)
+	if !ok {
+		t.Fatalf("Gauge %s not recorded", name)
+	}
+	switch g := m.Data.(type) {
+	case metricdata.Gauge[int64]:
+		return float64(g.DataPoints[0].Value)
+	case metricdata.Gauge[float64]:
+		return g.DataPoints[0].Value
+	}
+	t.Fatalf("Metric %s is %T, not a gauge", name, m.Data)
+	return 0
+}
+
+type logStore struct {
+	mu      sync.Mutex
+	records []LogRecord
+}
+
+func (s *logStore) add(rec LogRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, rec)
+}
+
+func (s *logStore) all() []LogRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]LogRecord, len(s.records))
+	copy(out, s.records)
+	return out
+}
+
+// captureHandler is a slog.Handler that stores records instead of writing them
+type captureHandler struct {
+	store *logStore
+	level slog.Level
+	attrs []slog.Attr
+	group string
+}
+
+func (h *captureHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *captureHandler) Handle(ctx context.Context, record slog.Record) error {
+	rec := LogRecord{
+		Level:   record.Level,
+		Message: record.Message,
+		Attrs:   make(map[string]any),
+	}
+
+	for _, a := range h.attrs {
+		rec.Attrs[a.Key] = a.Value.Resolve().Any()
+	}
+	record.Attrs(func(a slog.Attr) bool {
+		rec.Attrs[h.key(a.Key)] = a.Value.Resolve().Any()
+		return true
+	})
+
+	// Prefer explicit fields, fall back to the span carried by the context
+	if id, ok := rec.Attrs["trace_id"].(string); ok {
+		rec.TraceID = id
+	}
+	if id, ok := rec.Attrs["span_id"].(string); ok {
+		rec.SpanID = id
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() && rec.TraceID == "" {
+		rec.TraceID = sc.TraceID().String()
+		rec.SpanID = sc.SpanID().String()
+	}
+
+	h.store.add(rec)
+	return nil
+}
+
+func (h *captureHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.attrs = append([]slog.Attr{}, h.attrs...)
+	for _, a := range attrs {
+		clone.attrs = append(clone.attrs, slog.Attr{Key: h.key(a.Key), Value: a.Value})
+	}
+	return &clone
+}
+
+func (h *captureHandler) WithGroup(name string) slog.Handler {
+	clone := *h
+	if clone.group != "" {
+		name = clone.group + "." + name
+	}
+	clone.group = name
+	return &clone
+}
+
+func (h *captureHandler) key(k string) string {
+	if h.group == "" {
+		return k
+	}
+	return h.group + "." + k
+}
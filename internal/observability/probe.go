@@ -0,0 +1,65 @@
+package observability
+
+import (
+	"context"
+	"fmt"
+	"go-observability-demo/internal/config"
+	"log/slog"
+	"net"
+	"time"
+)
+
+// ProbeConfig controls the startup check that the OTLP collector accepts
+// connections
+type ProbeConfig struct {
+	// Require fails startup when the collector can't be reached within
+	// Timeout; otherwise the check runs in the background and only warns
+	Require    bool
+	Timeout    time.Duration
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// ProbeConfigFromEnv reads OTEL_REQUIRE_COLLECTOR (false) and
+// OTEL_COLLECTOR_PROBE_TIMEOUT (30s)
+func ProbeConfigFromEnv() ProbeConfig {
+	return ProbeConfig{
+		Require:    config.Bool("OTEL_REQUIRE_COLLECTOR", false),
+		Timeout:    config.Duration("OTEL_COLLECTOR_PROBE_TIMEOUT", 30*time.Second),
+		MinBackoff: 250 * time.Millisecond,
+		MaxBackoff: 5 * time.Second,
+	}
+}
+
+// probeCollector dials endpoint until it answers or cfg.Timeout passes,
+// backing off between attempts. A TCP connect is enough to catch a wrong
+// endpoint or a collector that isn't running, for either protocol.
+func probeCollector(ctx context.Context, endpoint string, cfg ProbeConfig, logger *slog.Logger) error {
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	backoff := cfg.MinBackoff
+	for attempt := 1; ; attempt++ {
+		conn, err := dialer.DialContext(ctx, "tcp", endpoint)
+		if err == nil {
+			conn.Close()
+			if attempt > 1 {
+				logger.Info("OTLP collector reachable", "endpoint", endpoint, "attempts", attempt)
+			}
+			return nil
+		}
+		logger.Warn("OTLP collector not reachable, telemetry is dropped until it is",
+			"endpoint", endpoint,
+			"attempt", attempt,
+			"retry_in", backoff.String(),
+			"error", err.Error(),
+		)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return fmt.Errorf("OTLP collector at %s not reachable after %s: %w", endpoint, cfg.Timeout, err)
+		}
+		backoff = min(backoff*2, cfg.MaxBackoff)
+	}
+}
@@ -0,0 +1,43 @@
+package observability
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestProbeCollector(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg := ProbeConfig{Timeout: 300 * time.Millisecond, MinBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	if err := probeCollector(context.Background(), addr, cfg, logger); err != nil {
+		t.Errorf("Expected the listening collector to be reachable, got %v", err)
+	}
+
+	ln.Close()
+	start := time.Now()
+	if err := probeCollector(context.Background(), addr, cfg, logger); err == nil {
+		t.Error("Expected an error once the collector is gone")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the probe to give up after its timeout, took %s", elapsed)
+	}
+}
+
+func TestInitObservability_RequireCollector(t *testing.T) {
+	t.Setenv("OTEL_REQUIRE_COLLECTOR", "true")
+	t.Setenv("OTEL_COLLECTOR_PROBE_TIMEOUT", "200ms")
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	if _, err := InitObservability(context.Background(), "test-service", "127.0.0.1:1", WithLogger(logger)); err == nil {
+		t.Error("Expected startup to fail without a collector")
+	}
+}
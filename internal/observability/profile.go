@@ -0,0 +1,137 @@
+package observability
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/contrib/propagators/aws/xray"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Profile adapts the exported telemetry to what a specific backend expects.
+// It is selected with OBSERVABILITY_PROFILE.
+type Profile string
+
+const (
+	ProfileDefault Profile = ""
+	ProfileDatadog Profile = "datadog"
+	ProfileElastic Profile = "elastic"
+	// ProfileXRay joins traces with ALB and other X-Ray instrumented hops
+	ProfileXRay Profile = "xray"
+)
+
+func profileFromEnv() Profile {
+	return Profile(getEnv("OBSERVABILITY_PROFILE", string(ProfileDefault)))
+}
+
+// resourceAttributes returns extra resource attributes for the profile
+func (p Profile) resourceAttributes(serviceName, version, environment string) []attribute.KeyValue {
+	switch p {
+	case ProfileDatadog:
+		// Unified service tagging: env, service, and version on every signal
+		return []attribute.KeyValue{
+			attribute.String("env", environment),
+			attribute.String("service", serviceName),
+			attribute.String("version", version),
+		}
+	case ProfileElastic:
+		// Elastic APM groups services by service.environment and ignores the
+		// older deployment.environment key in newer intake versions
+		return []attribute.KeyValue{
+			attribute.String("service.environment", environment),
+			attribute.String("deployment.environment.name", environment),
+		}
+	default:
+		return nil
+	}
+}
+
+// temporality returns the OTLP metric temporality for the profile, or nil
+// for the exporter default
+func (p Profile) temporality() metric.TemporalitySelector {
+	switch p {
+	case ProfileDatadog, ProfileElastic:
+		return deltaTemporality
+	default:
+		return nil
+	}
+}
+
+// propagators returns extra propagators for the profile. They are placed
+// before the W3C propagators so a traceparent header wins when both exist.
+func (p Profile) propagators() []propagation.TextMapPropagator {
+	switch p {
+	case ProfileDatadog:
+		if getEnv("DD_TRACE_PROPAGATION", "true") == "true" {
+			return []propagation.TextMapPropagator{DatadogPropagator{}}
+		}
+		return nil
+	case ProfileXRay:
+		return []propagation.TextMapPropagator{xray.Propagator{}}
+	default:
+		return nil
+	}
+}
+
+// idGenerator returns the trace ID generator for the profile, or nil for
+// the SDK's random IDs. X-Ray rejects trace IDs that don't start with the
+// epoch seconds.
+func (p Profile) idGenerator() sdktrace.IDGenerator {
+	if p == ProfileXRay {
+		return xray.NewIDGenerator()
+	}
+	return nil
+}
+
+// spanProcessors returns extra span processors for the profile
+func (p Profile) spanProcessors() []sdktrace.SpanProcessor {
+	switch p {
+	case ProfileElastic:
+		return []sdktrace.SpanProcessor{elasticTransactionNamer{}}
+	default:
+		return nil
+	}
+}
+
+// elasticTransactionNamer renames server spans to "METHOD name". Elastic
+// APM turns each server span into a transaction and groups transactions by
+// name, and its UI expects the HTTP method in front.
+type elasticTransactionNamer struct{}
+
+func (elasticTransactionNamer) OnStart(_ context.Context, s sdktrace.ReadWriteSpan) {
+	if s.SpanKind() != trace.SpanKindServer {
+		return
+	}
+	for _, attr := range s.Attributes() {
+		if attr.Key != "http.request.method" && attr.Key != "http.method" {
+			continue
+		}
+		method := attr.Value.AsString()
+		if !strings.HasPrefix(s.Name(), method+" ") {
+			s.SetName(method + " " + s.Name())
+		}
+		return
+	}
+}
+
+func (elasticTransactionNamer) OnEnd(sdktrace.ReadOnlySpan)      {}
+func (elasticTransactionNamer) Shutdown(context.Context) error   { return nil }
+func (elasticTransactionNamer) ForceFlush(context.Context) error { return nil }
+
+// deltaTemporality is what Datadog and Elastic expect for monotonic sums
+// and histograms; up-down counters stay cumulative so they keep their meaning
+func deltaTemporality(kind metric.InstrumentKind) metricdata.Temporality {
+	switch kind {
+	case metric.InstrumentKindCounter,
+		metric.InstrumentKindHistogram,
+		metric.InstrumentKindObservableCounter:
+		return metricdata.DeltaTemporality
+	default:
+		return metricdata.CumulativeTemporality
+	}
+}
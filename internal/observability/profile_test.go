@@ -0,0 +1,76 @@
+package observability
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestElasticProfile_NamesTransactions(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	opts := []sdktrace.TracerProviderOption{}
+	for _, sp := range ProfileElastic.spanProcessors() {
+		opts = append(opts, sdktrace.WithSpanProcessor(sp))
+	}
+	tp := sdktrace.NewTracerProvider(append(opts, sdktrace.WithSyncer(exporter))...)
+	defer tp.Shutdown(context.Background())
+	tracer := tp.Tracer("test")
+
+	method := trace.WithAttributes(attribute.String("http.request.method", "POST"))
+	_, server := tracer.Start(context.Background(), "/orders", trace.WithSpanKind(trace.SpanKindServer), method)
+	server.End()
+	_, named := tracer.Start(context.Background(), "POST /orders", trace.WithSpanKind(trace.SpanKindServer), method)
+	named.End()
+	_, internal := tracer.Start(context.Background(), "CheckInventory", method)
+	internal.End()
+
+	spans := exporter.GetSpans()
+	for i, want := range []string{"POST /orders", "POST /orders", "CheckInventory"} {
+		if spans[i].Name != want {
+			t.Errorf("Expected span %d to be named %q, got %q", i, want, spans[i].Name)
+		}
+	}
+}
+
+func TestElasticProfile_ResourceAttributes(t *testing.T) {
+	attrs := ProfileElastic.resourceAttributes("order-service", "1.0.0", "prod")
+	if len(attrs) == 0 || attrs[0].Key != "service.environment" || attrs[0].Value.AsString() != "prod" {
+		t.Errorf("Expected service.environment=prod, got %v", attrs)
+	}
+	if ProfileElastic.temporality() == nil {
+		t.Error("Expected the Elastic profile to select delta temporality")
+	}
+}
+
+func TestXRayProfile_JoinsAmznTraceID(t *testing.T) {
+	propagators := ProfileXRay.propagators()
+	if len(propagators) != 1 {
+		t.Fatalf("Expected the X-Ray propagator, got %v", propagators)
+	}
+	carrier := propagation.MapCarrier{
+		"X-Amzn-Trace-Id": "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1",
+	}
+	ctx := propagators[0].Extract(context.Background(), carrier)
+	if sc := trace.SpanContextFromContext(ctx); sc.TraceID().String() != "5759e988bd862e3fe1be46a994272793" {
+		t.Errorf("Expected the ALB trace to be extracted, got %s", sc.TraceID())
+	}
+
+	tp := sdktrace.NewTracerProvider(sdktrace.WithIDGenerator(ProfileXRay.idGenerator()))
+	defer tp.Shutdown(context.Background())
+	_, span := tp.Tracer("test").Start(context.Background(), "CreateOrder")
+	span.End()
+	id := span.SpanContext().TraceID()
+	epoch := int64(id[0])<<24 | int64(id[1])<<16 | int64(id[2])<<8 | int64(id[3])
+	if now := time.Now().Unix(); epoch < now-60 || epoch > now+60 {
+		t.Errorf("Expected the trace ID to start with the epoch seconds, got %s", id)
+	}
+	if ProfileDefault.idGenerator() != nil {
+		t.Error("Expected random IDs by default")
+	}
+}
@@ -0,0 +1,53 @@
+package observability
+
+import (
+	"fmt"
+	"go-observability-demo/internal/config"
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	otelprom "go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/sdk/metric"
+)
+
+// MetricsExport is where metrics go, from OTEL_METRICS_EXPORTER: a comma
+// separated list of otlp (pushed through OTEL_EXPORTER, the default) and
+// prometheus, or none
+type MetricsExport struct {
+	Push       bool
+	Prometheus bool
+}
+
+func MetricsExportFromEnv() (MetricsExport, error) {
+	var m MetricsExport
+	if Disabled() {
+		return m, nil
+	}
+	for _, name := range config.List("OTEL_METRICS_EXPORTER", []string{"otlp"}) {
+		switch strings.ToLower(name) {
+		case "otlp":
+			m.Push = true
+		case "prometheus":
+			m.Prometheus = true
+		case "none":
+		default:
+			return m, fmt.Errorf("unsupported metrics exporter %q, want otlp, prometheus, or none", name)
+		}
+	}
+	return m, nil
+}
+
+// NewPrometheus returns a reader to pass to WithMetricReader and the
+// handler that serves what it collects for scraping. It uses its own
+// registry, so only the meter provider's metrics are exposed. Scrapers that
+// ask for OpenMetrics get exemplars with the trace ID of the measurement.
+func NewPrometheus() (metric.Reader, http.Handler, error) {
+	registry := prometheus.NewRegistry()
+	exporter, err := otelprom.New(otelprom.WithRegisterer(registry))
+	if err != nil {
+		return nil, nil, err
+	}
+	return exporter, promhttp.HandlerFor(registry, promhttp.HandlerOpts{EnableOpenMetrics: true}), nil
+}
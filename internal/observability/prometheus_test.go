@@ -0,0 +1,78 @@
+package observability
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestMetricsExportFromEnv(t *testing.T) {
+	if m, err := MetricsExportFromEnv(); err != nil || !m.Push || m.Prometheus {
+		t.Errorf("Expected OTLP push only by default, got %+v (%v)", m, err)
+	}
+	t.Setenv("OTEL_METRICS_EXPORTER", "prometheus")
+	if m, _ := MetricsExportFromEnv(); m.Push || !m.Prometheus {
+		t.Errorf("Expected Prometheus instead of push, got %+v", m)
+	}
+	t.Setenv("OTEL_METRICS_EXPORTER", "otlp,statsd")
+	if _, err := MetricsExportFromEnv(); err == nil {
+		t.Error("Expected an error for an unknown exporter")
+	}
+}
+
+func TestNewPrometheus_ServesMeterProviderMetrics(t *testing.T) {
+	reader, handler, err := NewPrometheus()
+	if err != nil {
+		t.Fatalf("NewPrometheus failed: %v", err)
+	}
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer mp.Shutdown(context.Background())
+	counter, _ := mp.Meter("test").Int64Counter("orders.created")
+	counter.Add(context.Background(), 3)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(rec.Body.String(), "orders_created_total") {
+		t.Errorf("Expected orders_created_total in the scrape, got:\n%s", rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), "go_goroutines") {
+		t.Error("Expected only meter provider metrics, not the default registry")
+	}
+}
+
+func TestNewPrometheus_ServesExemplarsOverOpenMetrics(t *testing.T) {
+	reader, handler, err := NewPrometheus()
+	if err != nil {
+		t.Fatalf("NewPrometheus failed: %v", err)
+	}
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer mp.Shutdown(context.Background())
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSampler(sdktrace.AlwaysSample()))
+	defer tp.Shutdown(context.Background())
+
+	duration, _ := mp.Meter("test").Float64Histogram("orders.duration", metric.WithUnit("ms"))
+	ctx, span := tp.Tracer("test").Start(context.Background(), "CreateOrder")
+	duration.Record(ctx, 42)
+	span.End()
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Accept", "application/openmetrics-text")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/openmetrics-text") {
+		t.Fatalf("Expected an OpenMetrics response, got %q", ct)
+	}
+	want := `trace_id="` + span.SpanContext().TraceID().String() + `"`
+	for line := range strings.Lines(rec.Body.String()) {
+		if strings.HasPrefix(line, "orders_duration") && strings.Contains(line, want) {
+			return
+		}
+	}
+	t.Errorf("Expected an orders_duration exemplar with %s, got:\n%s", want, rec.Body.String())
+}
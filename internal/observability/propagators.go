@@ -0,0 +1,44 @@
+package observability
+
+import (
+	"fmt"
+	"go-observability-demo/internal/config"
+
+	"go.opentelemetry.io/contrib/propagators/aws/xray"
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// textMapPropagators maps the names accepted in OTEL_PROPAGATORS to
+// propagators. Both B3 variants read either header style; the name picks
+// what is sent downstream.
+var textMapPropagators = map[string]func() propagation.TextMapPropagator{
+	"tracecontext": func() propagation.TextMapPropagator { return propagation.TraceContext{} },
+	"baggage":      func() propagation.TextMapPropagator { return propagation.Baggage{} },
+	"b3": func() propagation.TextMapPropagator {
+		return b3.New(b3.WithInjectEncoding(b3.B3SingleHeader))
+	},
+	"b3multi": func() propagation.TextMapPropagator {
+		return b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader))
+	},
+	"xray": func() propagation.TextMapPropagator { return xray.Propagator{} },
+}
+
+// propagatorsFromEnv returns the propagators named in OTEL_PROPAGATORS
+// (comma separated, tracecontext,baggage by default; none for no
+// propagation). When a request carries several formats, the last one
+// listed wins.
+func propagatorsFromEnv() ([]propagation.TextMapPropagator, error) {
+	var propagators []propagation.TextMapPropagator
+	for _, name := range config.List("OTEL_PROPAGATORS", []string{"tracecontext", "baggage"}) {
+		if name == "none" {
+			continue
+		}
+		newPropagator, ok := textMapPropagators[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown propagator %q", name)
+		}
+		propagators = append(propagators, newPropagator())
+	}
+	return propagators, nil
+}
@@ -0,0 +1,50 @@
+package observability
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestPropagatorsFromEnv_B3(t *testing.T) {
+	t.Setenv("OTEL_PROPAGATORS", "tracecontext,baggage,b3multi")
+	propagators, err := propagatorsFromEnv()
+	if err != nil {
+		t.Fatalf("propagatorsFromEnv failed: %v", err)
+	}
+	composite := propagation.NewCompositeTextMapPropagator(propagators...)
+
+	ctx := composite.Extract(context.Background(), propagation.MapCarrier{
+		"x-b3-traceid": "0af7651916cd43dd8448eb211c80319c",
+		"x-b3-spanid":  "b7ad6b7169203331",
+		"x-b3-sampled": "1",
+	})
+	if sc := trace.SpanContextFromContext(ctx); sc.TraceID().String() != "0af7651916cd43dd8448eb211c80319c" {
+		t.Errorf("Expected the B3 trace to be extracted, got %s", sc.TraceID())
+	}
+
+	out := propagation.MapCarrier{}
+	composite.Inject(ctx, out)
+	if out.Get("x-b3-traceid") == "" || out.Get("traceparent") == "" {
+		t.Errorf("Expected both B3 and W3C headers downstream, got %v", out)
+	}
+}
+
+func TestPropagatorsFromEnv_Default(t *testing.T) {
+	propagators, err := propagatorsFromEnv()
+	if err != nil || len(propagators) != 2 {
+		t.Errorf("Expected tracecontext and baggage, got %v (%v)", propagators, err)
+	}
+
+	t.Setenv("OTEL_PROPAGATORS", "none")
+	if propagators, _ := propagatorsFromEnv(); len(propagators) != 0 {
+		t.Errorf("Expected no propagators, got %v", propagators)
+	}
+
+	t.Setenv("OTEL_PROPAGATORS", "tracecontext,ot")
+	if _, err := propagatorsFromEnv(); err == nil {
+		t.Error("Expected an error for an unknown propagator")
+	}
+}
@@ -0,0 +1,131 @@
+package observability
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go-observability-demo/internal/config"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// RedactedValue replaces masked attribute values
+const RedactedValue = "[REDACTED]"
+
+// RedactionConfig lists the log attribute keys to mask or hash. Keys match
+// case-insensitively at any group depth; Allow wins over Mask and Hash,
+// e.g. to keep user_id readable in a test environment.
+type RedactionConfig struct {
+	Mask  []string `json:"mask"`
+	Hash  []string `json:"hash"`
+	Allow []string `json:"allow"`
+}
+
+// RedactionConfigFromEnv reads LOG_REDACT_FILE, a JSON RedactionConfig, or
+// else LOG_REDACT_MASK, LOG_REDACT_HASH, and LOG_REDACT_ALLOW
+func RedactionConfigFromEnv() (RedactionConfig, error) {
+	if file := config.String("LOG_REDACT_FILE", ""); file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return RedactionConfig{}, fmt.Errorf("failed to read log redaction config: %w", err)
+		}
+		var cfg RedactionConfig
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return RedactionConfig{}, fmt.Errorf("invalid log redaction config: %w", err)
+		}
+		return cfg, nil
+	}
+	return RedactionConfig{
+		Mask:  config.List("LOG_REDACT_MASK", defaultRedaction.Mask),
+		Hash:  config.List("LOG_REDACT_HASH", defaultRedaction.Hash),
+		Allow: config.List("LOG_REDACT_ALLOW", nil),
+	}, nil
+}
+
+// defaultRedaction also applies when the configured redaction is invalid,
+// so a typo doesn't turn redaction off
+var defaultRedaction = RedactionConfig{
+	Mask: []string{"card_number", "email"},
+	Hash: []string{"user_id"},
+}
+
+type redactAction int
+
+const (
+	redactMask redactAction = iota + 1
+	redactHash
+)
+
+// NewRedactHandler masks or hashes the attributes cfg lists before next
+// sees them. Hashed values stay correlatable across log lines without
+// exposing the original.
+func NewRedactHandler(next slog.Handler, cfg RedactionConfig) slog.Handler {
+	actions := map[string]redactAction{}
+	for _, k := range cfg.Mask {
+		actions[strings.ToLower(k)] = redactMask
+	}
+	for _, k := range cfg.Hash {
+		actions[strings.ToLower(k)] = redactHash
+	}
+	for _, k := range cfg.Allow {
+		delete(actions, strings.ToLower(k))
+	}
+	if len(actions) == 0 {
+		return next
+	}
+	return redactHandler{next, actions}
+}
+
+type redactHandler struct {
+	slog.Handler
+	actions map[string]redactAction
+}
+
+func (h redactHandler) Handle(ctx context.Context, r slog.Record) error {
+	out := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(h.redact(a))
+		return true
+	})
+	return h.Handler.Handle(ctx, out)
+}
+
+func (h redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = h.redact(a)
+	}
+	return redactHandler{h.Handler.WithAttrs(redacted), h.actions}
+}
+
+func (h redactHandler) WithGroup(name string) slog.Handler {
+	return redactHandler{h.Handler.WithGroup(name), h.actions}
+}
+
+func (h redactHandler) redact(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() == slog.KindGroup {
+		group := a.Value.Group()
+		redacted := make([]slog.Attr, len(group))
+		for i, ga := range group {
+			redacted[i] = h.redact(ga)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(redacted...)}
+	}
+	switch h.actions[strings.ToLower(a.Key)] {
+	case redactMask:
+		return slog.String(a.Key, RedactedValue)
+	case redactHash:
+		return slog.String(a.Key, hashValue(a.Value.String()))
+	}
+	return a
+}
+
+// hashValue returns a short, stable digest of v
+func hashValue(v string) string {
+	sum := sha256.Sum256([]byte(v))
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,72 @@
+package observability
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRedactHandler(t *testing.T) {
+	var buf bytes.Buffer
+	handler := NewRedactHandler(slog.NewJSONHandler(&buf, nil), RedactionConfig{
+		Mask: []string{"card_number", "email"},
+		Hash: []string{"user_id"},
+	})
+	logger := slog.New(handler).With("email", "alice@example.com")
+	logger.Info("payment authorized",
+		"User_ID", "user-42",
+		slog.Group("payment", "card_number", "4111111111111111", "amount", 12.5),
+	)
+
+	out := buf.String()
+	for _, secret := range []string{"alice@example.com", "user-42", "4111111111111111"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("Expected %s to be redacted, got %s", secret, out)
+		}
+	}
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Failed to decode log line: %v", err)
+	}
+	if entry["email"] != RedactedValue {
+		t.Errorf("Expected email masked, got %v", entry["email"])
+	}
+	if entry["User_ID"] != hashValue("user-42") {
+		t.Errorf("Expected user id hashed, got %v", entry["User_ID"])
+	}
+	payment := entry["payment"].(map[string]any)
+	if payment["card_number"] != RedactedValue || payment["amount"] != 12.5 {
+		t.Errorf("Expected only the card number masked in the group, got %v", payment)
+	}
+}
+
+func TestNewRedactHandler_AllowOverridesDeny(t *testing.T) {
+	next := slog.NewJSONHandler(&bytes.Buffer{}, nil)
+	if NewRedactHandler(next, RedactionConfig{Hash: []string{"user_id"}, Allow: []string{"user_id"}}) != slog.Handler(next) {
+		t.Error("Expected nothing left to redact to return the handler unchanged")
+	}
+}
+
+func TestRedactionConfigFromEnv(t *testing.T) {
+	cfg, err := RedactionConfigFromEnv()
+	if err != nil || len(cfg.Mask) != 2 || cfg.Hash[0] != "user_id" {
+		t.Errorf("Expected the default keys, got %+v (%v)", cfg, err)
+	}
+
+	file := filepath.Join(t.TempDir(), "redact.json")
+	os.WriteFile(file, []byte(`{"mask": ["ssn"], "allow": ["user_id"]}`), 0o600)
+	t.Setenv("LOG_REDACT_FILE", file)
+	cfg, err = RedactionConfigFromEnv()
+	if err != nil || cfg.Mask[0] != "ssn" || cfg.Allow[0] != "user_id" {
+		t.Errorf("Expected the file's keys, got %+v (%v)", cfg, err)
+	}
+
+	os.WriteFile(file, []byte(`{"mask": "ssn"}`), 0o600)
+	if _, err := RedactionConfigFromEnv(); err == nil {
+		t.Error("Expected an error for an invalid file")
+	}
+}
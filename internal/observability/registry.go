@@ -0,0 +1,80 @@
+package observability
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+// Registry creates instruments on first use and hands back the cached one
+// afterwards, so call sites name an instrument instead of threading it
+// through. A failed creation returns a no-op instrument and is kept for Err,
+// which startup checks once instead of after every instrument.
+type Registry struct {
+	meter metric.Meter
+
+	mu          sync.Mutex
+	instruments map[string]any
+	errs        []error
+}
+
+func NewRegistry(meter metric.Meter) *Registry {
+	return &Registry{meter: meter, instruments: map[string]any{}}
+}
+
+// Counter returns the int64 counter called name
+func (r *Registry) Counter(name string, opts ...metric.Int64CounterOption) metric.Int64Counter {
+	return instrument[metric.Int64Counter](r, "counter", name, func() (metric.Int64Counter, error) {
+		return r.meter.Int64Counter(name, opts...)
+	}, noop.Int64Counter{})
+}
+
+// FloatCounter returns the float64 counter called name
+func (r *Registry) FloatCounter(name string, opts ...metric.Float64CounterOption) metric.Float64Counter {
+	return instrument[metric.Float64Counter](r, "float counter", name, func() (metric.Float64Counter, error) {
+		return r.meter.Float64Counter(name, opts...)
+	}, noop.Float64Counter{})
+}
+
+// UpDownCounter returns the int64 up-down counter called name
+func (r *Registry) UpDownCounter(name string, opts ...metric.Int64UpDownCounterOption) metric.Int64UpDownCounter {
+	return instrument[metric.Int64UpDownCounter](r, "up-down counter", name, func() (metric.Int64UpDownCounter, error) {
+		return r.meter.Int64UpDownCounter(name, opts...)
+	}, noop.Int64UpDownCounter{})
+}
+
+// Histogram returns the float64 histogram called name
+func (r *Registry) Histogram(name string, opts ...metric.Float64HistogramOption) metric.Float64Histogram {
+	return instrument[metric.Float64Histogram](r, "histogram", name, func() (metric.Float64Histogram, error) {
+		return r.meter.Float64Histogram(name, opts...)
+	}, noop.Float64Histogram{})
+}
+
+// Err reports every instrument that could not be created
+func (r *Registry) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return errors.Join(r.errs...)
+}
+
+// instrument caches per kind and name, so a counter and a histogram sharing a
+// name stay separate. Options only apply on the first call.
+func instrument[T any](r *Registry, kind, name string, create func() (T, error), fallback T) T {
+	key := kind + "/" + name
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if cached, ok := r.instruments[key]; ok {
+		return cached.(T)
+	}
+	inst, err := create()
+	if err != nil {
+		r.errs = append(r.errs, fmt.Errorf("%s %s: %w", kind, name, err))
+		inst = fallback
+	}
+	r.instruments[key] = inst
+	return inst
+}
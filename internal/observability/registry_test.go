@@ -0,0 +1,49 @@
+package observability
+
+import (
+	"context"
+	"go-observability-demo/internal/observability/observabilitytest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+)
+
+func TestRegistry_CachesInstruments(t *testing.T) {
+	recorder := observabilitytest.New(t)
+	registry := NewRegistry(otel.Meter("test"))
+
+	first := registry.Counter("jobs.done", metric.WithUnit("{job}"))
+	if second := registry.Counter("jobs.done"); second != first {
+		t.Errorf("Expected the cached counter, got a new one")
+	}
+	first.Add(context.Background(), 2)
+	registry.Counter("jobs.done").Add(context.Background(), 3)
+
+	if got := recorder.Int64Sum(t, "jobs.done"); got != 5 {
+		t.Errorf("Expected 5, got %d", got)
+	}
+	if m, _ := recorder.Metric(t, "jobs.done"); m.Unit != "{job}" {
+		t.Errorf("Expected the unit from the first call, got %q", m.Unit)
+	}
+	if err := registry.Err(); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestRegistry_CollectsErrors(t *testing.T) {
+	observabilitytest.New(t)
+	registry := NewRegistry(otel.Meter("test"))
+
+	counter := registry.Counter("1-invalid")
+	registry.Histogram("also invalid!")
+	counter.Add(context.Background(), 1)
+
+	if err := registry.Err(); err == nil {
+		t.Fatal("Expected an error for invalid instrument names")
+	}
+	registry.Counter("1-invalid")
+	if got := len(registry.errs); got != 2 {
+		t.Errorf("Expected each failure reported once, got %d", got)
+	}
+}
@@ -0,0 +1,109 @@
+package observability
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// SamplingRule sets the sampling ratio for spans whose name and route match.
+// Patterns use path.Match syntax, e.g. "/admin/*"; an empty one matches
+// anything.
+type SamplingRule struct {
+	SpanName string  `json:"span_name"`
+	Route    string  `json:"route"`
+	Ratio    float64 `json:"ratio"`
+}
+
+// ParseSamplingRules reads a JSON array of rules, e.g.
+// [{"route":"/health","ratio":0.01},{"span_name":"POST /orders","ratio":1}]
+func ParseSamplingRules(data []byte) ([]SamplingRule, error) {
+	var rules []SamplingRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("invalid sampling rules: %w", err)
+	}
+	for i, r := range rules {
+		if r.Ratio < 0 || r.Ratio > 1 {
+			return nil, fmt.Errorf("sampling rule %d: ratio must be between 0 and 1, got %v", i, r.Ratio)
+		}
+		for _, pattern := range []string{r.SpanName, r.Route} {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("sampling rule %d: bad pattern %q", i, pattern)
+			}
+		}
+	}
+	return rules, nil
+}
+
+func loadSamplingRules(inline, file string) ([]SamplingRule, error) {
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read sampling rules: %w", err)
+		}
+		return ParseSamplingRules(data)
+	}
+	if inline != "" {
+		return ParseSamplingRules([]byte(inline))
+	}
+	return nil, nil
+}
+
+// RuleSampler applies the first matching rule's ratio and falls back to
+// another sampler when none match. It is a head sampler: whether a span
+// will fail is not known yet, so keeping error traces is the tail
+// sampler's job.
+type RuleSampler struct {
+	rules    []SamplingRule
+	samplers []sdktrace.Sampler
+	fallback sdktrace.Sampler
+}
+
+func NewRuleSampler(rules []SamplingRule, fallback sdktrace.Sampler) *RuleSampler {
+	s := &RuleSampler{rules: rules, fallback: fallback}
+	for _, r := range rules {
+		s.samplers = append(s.samplers, sdktrace.TraceIDRatioBased(r.Ratio))
+	}
+	return s
+}
+
+func (s *RuleSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	route := spanRoute(p.Attributes)
+	for i, r := range s.rules {
+		if globMatch(r.SpanName, p.Name) && globMatch(r.Route, route) {
+			return s.samplers[i].ShouldSample(p)
+		}
+	}
+	return s.fallback.ShouldSample(p)
+}
+
+func (s *RuleSampler) Description() string {
+	return fmt.Sprintf("RuleSampler{rules:%d,fallback:%s}", len(s.rules), s.fallback.Description())
+}
+
+// spanRoute is the route template when the instrumentation knows it at
+// start, otherwise the request path
+func spanRoute(attrs []attribute.KeyValue) string {
+	var urlPath string
+	for _, kv := range attrs {
+		switch kv.Key {
+		case "http.route":
+			return kv.Value.AsString()
+		case "url.path", "http.target":
+			urlPath = kv.Value.AsString()
+		}
+	}
+	return urlPath
+}
+
+func globMatch(pattern, s string) bool {
+	if pattern == "" {
+		return true
+	}
+	ok, _ := path.Match(pattern, s)
+	return ok
+}
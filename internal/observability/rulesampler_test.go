@@ -0,0 +1,65 @@
+package observability
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestRuleSampler_FirstMatchingRuleWins(t *testing.T) {
+	rules, err := ParseSamplingRules([]byte(`[
+		{"route": "/health", "ratio": 0},
+		{"span_name": "POST /orders", "ratio": 1},
+		{"route": "/admin/*", "ratio": 0}
+	]`))
+	if err != nil {
+		t.Fatalf("ParseSamplingRules failed: %v", err)
+	}
+	sampler := NewRuleSampler(rules, sdktrace.AlwaysSample())
+
+	tests := []struct {
+		name  string
+		attrs []attribute.KeyValue
+		want  sdktrace.SamplingDecision
+	}{
+		{"GET", []attribute.KeyValue{attribute.String("url.path", "/health")}, sdktrace.Drop},
+		{"POST /orders", []attribute.KeyValue{attribute.String("http.route", "/orders")}, sdktrace.RecordAndSample},
+		{"GET", []attribute.KeyValue{attribute.String("http.route", "/admin/inventory")}, sdktrace.Drop},
+		{"ProcessPayment", nil, sdktrace.RecordAndSample},
+	}
+	for _, tt := range tests {
+		got := sampler.ShouldSample(sdktrace.SamplingParameters{Name: tt.name, Attributes: tt.attrs}).Decision
+		if got != tt.want {
+			t.Errorf("%s %v: expected %v, got %v", tt.name, tt.attrs, tt.want, got)
+		}
+	}
+
+	for _, bad := range []string{`{"route":"/health"}`, `[{"ratio":2}]`, `[{"route":"[","ratio":1}]`} {
+		if _, err := ParseSamplingRules([]byte(bad)); err == nil {
+			t.Errorf("Expected an error for %s", bad)
+		}
+	}
+}
+
+func TestRuleSampler_MatchesOtelhttpServerSpans(t *testing.T) {
+	spans := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithSyncer(spans),
+		sdktrace.WithSampler(NewRuleSampler([]SamplingRule{{Route: "/health", Ratio: 0}}, sdktrace.AlwaysSample())),
+	)
+	handler := func(name string) http.Handler {
+		return otelhttp.NewHandler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}), name, otelhttp.WithTracerProvider(tp))
+	}
+
+	handler("/health").ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+	handler("POST /orders").ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/orders", nil))
+
+	if got := spans.GetSpans(); len(got) != 1 || got[0].Name != "POST /orders" {
+		t.Errorf("Expected only the orders span to be sampled, got %v", got.Snapshots())
+	}
+}
@@ -0,0 +1,88 @@
+package observability
+
+import (
+	"fmt"
+	"go-observability-demo/internal/config"
+	"strconv"
+	"strings"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// SamplerConfig selects the head sampler using the OTEL_TRACES_SAMPLER names:
+// always_on, always_off, traceidratio, and their parentbased_ variants
+type SamplerConfig struct {
+	Name string
+	// Arg is the ratio for the traceidratio samplers, as a string so a typo
+	// is reported rather than silently replaced
+	Arg string
+	// IgnoreRemoteParent makes the parentbased_ samplers decide afresh for
+	// requests from other services, instead of following their sampled
+	// flag; parents within the process are still followed
+	IgnoreRemoteParent bool
+	// Rules (inline JSON) or RulesFile set per-route ratios ahead of the
+	// sampler above, which applies to spans no rule matches
+	Rules     string
+	RulesFile string
+}
+
+// SamplerConfigFromEnv reads OTEL_TRACES_SAMPLER, OTEL_TRACES_SAMPLER_ARG,
+// OTEL_TRACES_SAMPLER_REMOTE_PARENT (respect or ignore), and
+// OTEL_TRACES_SAMPLER_RULES or OTEL_TRACES_SAMPLER_RULES_FILE. Unset, it
+// follows the caller's decision and otherwise samples every trace in
+// development and 10% in production.
+func SamplerConfigFromEnv() SamplerConfig {
+	ratio := "1.0"
+	if getEnv("ENVIRONMENT", "development") == "production" {
+		ratio = "0.1"
+	}
+	return SamplerConfig{
+		Name:               config.String("OTEL_TRACES_SAMPLER", "parentbased_traceidratio"),
+		Arg:                config.String("OTEL_TRACES_SAMPLER_ARG", ratio),
+		IgnoreRemoteParent: config.String("OTEL_TRACES_SAMPLER_REMOTE_PARENT", "respect") == "ignore",
+		Rules:              config.String("OTEL_TRACES_SAMPLER_RULES", ""),
+		RulesFile:          config.String("OTEL_TRACES_SAMPLER_RULES_FILE", ""),
+	}
+}
+
+func (c SamplerConfig) parentBased() bool {
+	return strings.HasPrefix(c.Name, "parentbased_")
+}
+
+// Sampler builds the configured sampler
+func (c SamplerConfig) Sampler() (sdktrace.Sampler, error) {
+	name, parentBased := strings.CutPrefix(c.Name, "parentbased_")
+
+	var root sdktrace.Sampler
+	switch name {
+	case "always_on":
+		root = sdktrace.AlwaysSample()
+	case "always_off":
+		root = sdktrace.NeverSample()
+	case "traceidratio":
+		ratio, err := strconv.ParseFloat(c.Arg, 64)
+		if err != nil || ratio < 0 || ratio > 1 {
+			return nil, fmt.Errorf("sampler %s needs a ratio between 0 and 1, got %q", c.Name, c.Arg)
+		}
+		root = sdktrace.TraceIDRatioBased(ratio)
+	default:
+		return nil, fmt.Errorf("unsupported sampler %q", c.Name)
+	}
+
+	rules, err := loadSamplingRules(c.Rules, c.RulesFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(rules) > 0 {
+		root = NewRuleSampler(rules, root)
+	}
+	if !parentBased {
+		return root, nil
+	}
+
+	var opts []sdktrace.ParentBasedSamplerOption
+	if c.IgnoreRemoteParent {
+		opts = append(opts, sdktrace.WithRemoteParentSampled(root), sdktrace.WithRemoteParentNotSampled(root))
+	}
+	return sdktrace.ParentBased(root, opts...), nil
+}
@@ -0,0 +1,74 @@
+package observability
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestSamplerConfig_Sampler(t *testing.T) {
+	tests := []struct {
+		name, arg string
+		want      string
+	}{
+		{"always_on", "", "AlwaysOnSampler"},
+		{"always_off", "", "AlwaysOffSampler"},
+		{"traceidratio", "0.25", "TraceIDRatioBased{0.25}"},
+		{"parentbased_traceidratio", "0.5", "ParentBased{root:TraceIDRatioBased{0.5},remoteParentSampled:AlwaysOnSampler,remoteParentNotSampled:AlwaysOffSampler,localParentSampled:AlwaysOnSampler,localParentNotSampled:AlwaysOffSampler}"},
+		{"parentbased_always_on", "", "ParentBased{root:AlwaysOnSampler,remoteParentSampled:AlwaysOnSampler,remoteParentNotSampled:AlwaysOffSampler,localParentSampled:AlwaysOnSampler,localParentNotSampled:AlwaysOffSampler}"},
+	}
+	for _, tt := range tests {
+		sampler, err := SamplerConfig{Name: tt.name, Arg: tt.arg}.Sampler()
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if sampler.Description() != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.want, sampler.Description())
+		}
+	}
+
+	ignore, _ := SamplerConfig{Name: "parentbased_traceidratio", Arg: "0.5", IgnoreRemoteParent: true}.Sampler()
+	if want := "ParentBased{root:TraceIDRatioBased{0.5},remoteParentSampled:TraceIDRatioBased{0.5},remoteParentNotSampled:TraceIDRatioBased{0.5},localParentSampled:AlwaysOnSampler,localParentNotSampled:AlwaysOffSampler}"; ignore.Description() != want {
+		t.Errorf("Expected remote parents to be re-sampled, got %s", ignore.Description())
+	}
+
+	ruled, err := SamplerConfig{Name: "parentbased_always_on", Rules: `[{"route":"/health","ratio":0.01}]`}.Sampler()
+	if err != nil || !strings.HasPrefix(ruled.Description(), "ParentBased{root:RuleSampler{rules:1,fallback:AlwaysOnSampler}") {
+		t.Errorf("Expected the rules under the parent-based wrapper, got %v (%v)", ruled, err)
+	}
+
+	for _, bad := range []SamplerConfig{{Name: "jaeger_remote"}, {Name: "parentbased_jaeger_remote"}, {Name: "traceidratio", Arg: "1.5"}, {Name: "traceidratio", Arg: "ten percent"}, {Name: "always_on", Rules: "not json"}} {
+		if _, err := bad.Sampler(); err == nil {
+			t.Errorf("Expected an error for %+v", bad)
+		}
+	}
+}
+
+func TestSamplerConfigFromEnv_DefaultsByEnvironment(t *testing.T) {
+	t.Setenv("ENVIRONMENT", "production")
+	if cfg := SamplerConfigFromEnv(); cfg.Name != "parentbased_traceidratio" || cfg.Arg != "0.1" || cfg.IgnoreRemoteParent {
+		t.Errorf("Expected 10%% ratio sampling that follows the caller in production, got %+v", cfg)
+	}
+}
+
+func TestParentBasedSampler_FollowsRemoteParent(t *testing.T) {
+	sampler, _ := SamplerConfig{Name: "parentbased_traceidratio", Arg: "0"}.Sampler()
+	parent := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{1},
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	})
+	result := sampler.ShouldSample(sdktrace.SamplingParameters{
+		ParentContext: trace.ContextWithRemoteSpanContext(context.Background(), parent),
+		TraceID:       parent.TraceID(),
+		Name:          "POST /orders",
+	})
+	if result.Decision != sdktrace.RecordAndSample {
+		t.Errorf("Expected an upstream-sampled trace to be kept at ratio 0, got %v", result.Decision)
+	}
+}
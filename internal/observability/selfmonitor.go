@@ -0,0 +1,161 @@
+package observability
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// exportMonitor makes telemetry loss visible: for each span exporter it
+// counts exported and failed spans, export latency, spans dropped because
+// the queue was full, and the queue length
+type exportMonitor struct {
+	exported metric.Int64Counter
+	duration metric.Float64Histogram
+	dropped  metric.Int64Counter
+
+	queues []*monitoredBatcher
+	// lost counts dropped and failed spans across exporters, for warnLoop
+	lost     atomic.Int64
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+func newExportMonitor(meter metric.Meter) (*exportMonitor, error) {
+	m := &exportMonitor{stop: make(chan struct{}), done: make(chan struct{})}
+	var err error
+	if m.exported, err = meter.Int64Counter(
+		"otel.sdk.exporter.span.exported",
+		metric.WithDescription("Spans handed to each exporter, by outcome (success, failure)"),
+		metric.WithUnit("{span}"),
+	); err != nil {
+		return nil, err
+	}
+	if m.duration, err = meter.Float64Histogram(
+		"otel.sdk.exporter.operation.duration",
+		metric.WithDescription("Time to export one batch of spans, by exporter and outcome"),
+		metric.WithUnit("s"),
+	); err != nil {
+		return nil, err
+	}
+	if m.dropped, err = meter.Int64Counter(
+		"otel.sdk.processor.span.dropped",
+		metric.WithDescription("Spans dropped because the export queue was full"),
+		metric.WithUnit("{span}"),
+	); err != nil {
+		return nil, err
+	}
+	queueSize, err := meter.Int64ObservableGauge(
+		"otel.sdk.processor.span.queue.size",
+		metric.WithDescription("Spans waiting for export, by exporter"),
+		metric.WithUnit("{span}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		for _, q := range m.queues {
+			o.ObserveInt64(queueSize, q.pending.Load(), q.attrs)
+		}
+		return nil
+	}, queueSize)
+	return m, err
+}
+
+// batcher returns a batch span processor for exp that reports to m. The
+// wrapper bounds the queue itself, and gives the SDK processor room for a
+// batch more, so every drop is one it saw and counted.
+func (m *exportMonitor) batcher(exp sdktrace.SpanExporter, name string, cfg BatchConfig) sdktrace.SpanProcessor {
+	b := &monitoredBatcher{
+		monitor:  m,
+		maxQueue: int64(cfg.MaxQueueSize),
+		attrs:    metric.WithAttributes(attribute.String("exporter", name)),
+	}
+	opts := append(cfg.options(), sdktrace.WithMaxQueueSize(cfg.MaxQueueSize+cfg.MaxExportBatchSize))
+	b.SpanProcessor = sdktrace.NewBatchSpanProcessor(monitoredExporter{exp, b}, opts...)
+	m.queues = append(m.queues, b)
+	return b
+}
+
+type monitoredBatcher struct {
+	sdktrace.SpanProcessor
+	monitor  *exportMonitor
+	maxQueue int64
+	attrs    metric.MeasurementOption
+	// pending counts sampled spans that ended but haven't reached the
+	// exporter yet
+	pending atomic.Int64
+}
+
+func (b *monitoredBatcher) OnEnd(s sdktrace.ReadOnlySpan) {
+	if !s.SpanContext().IsSampled() {
+		return
+	}
+	if b.pending.Add(1) > b.maxQueue {
+		b.pending.Add(-1)
+		b.monitor.dropped.Add(context.Background(), 1, b.attrs)
+		b.monitor.lost.Add(1)
+		return
+	}
+	b.SpanProcessor.OnEnd(s)
+}
+
+type monitoredExporter struct {
+	sdktrace.SpanExporter
+	batcher *monitoredBatcher
+}
+
+func (e monitoredExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	b := e.batcher
+	b.pending.Add(-int64(len(spans)))
+	start := time.Now()
+	err := e.SpanExporter.ExportSpans(ctx, spans)
+
+	outcome := attribute.String("outcome", "success")
+	if err != nil {
+		outcome = attribute.String("outcome", "failure")
+		b.monitor.lost.Add(int64(len(spans)))
+	}
+	// Measurements must not be recorded with the export context, which may
+	// already be cancelled
+	b.monitor.exported.Add(context.Background(), int64(len(spans)), b.attrs, metric.WithAttributes(outcome))
+	b.monitor.duration.Record(context.Background(), time.Since(start).Seconds(), b.attrs, metric.WithAttributes(outcome))
+	return err
+}
+
+// warnLoop logs a warning every interval in which spans were lost, so the
+// loss shows up even where nobody watches the metrics
+func (m *exportMonitor) warnLoop(logger *slog.Logger, interval time.Duration) {
+	defer close(m.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var reported int64
+	for {
+		select {
+		case <-ticker.C:
+			if lost := m.lost.Load(); lost > reported {
+				logger.Warn("spans were dropped or failed to export",
+					"lost", lost-reported,
+					"lost_total", lost,
+					"interval", interval.String(),
+				)
+				reported = lost
+			}
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// close stops warnLoop and waits for it to return
+func (m *exportMonitor) close() {
+	m.stopOnce.Do(func() { close(m.stop) })
+	<-m.done
+}
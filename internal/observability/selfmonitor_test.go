@@ -0,0 +1,131 @@
+package observability
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+type failingExporter struct{}
+
+func (failingExporter) ExportSpans(context.Context, []sdktrace.ReadOnlySpan) error {
+	return errors.New("collector unavailable")
+}
+
+func (failingExporter) Shutdown(context.Context) error { return nil }
+
+// blockingExporter holds every export until release is closed
+type blockingExporter struct {
+	release chan struct{}
+}
+
+func (e blockingExporter) ExportSpans(context.Context, []sdktrace.ReadOnlySpan) error {
+	<-e.release
+	return nil
+}
+
+func (blockingExporter) Shutdown(context.Context) error { return nil }
+
+func newTestMonitor(t *testing.T) (*exportMonitor, *sdkmetric.ManualReader) {
+	t.Helper()
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	t.Cleanup(func() { mp.Shutdown(context.Background()) })
+	m, err := newExportMonitor(mp.Meter("test"))
+	if err != nil {
+		t.Fatalf("newExportMonitor failed: %v", err)
+	}
+	return m, reader
+}
+
+// sumByOutcome adds up a counter's points, keyed by their outcome attribute
+func sumByOutcome(t *testing.T, reader *sdkmetric.ManualReader, name string) map[string]int64 {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+	sums := map[string]int64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != name {
+				continue
+			}
+			for _, p := range m.Data.(metricdata.Sum[int64]).DataPoints {
+				outcome, _ := p.Attributes.Value("outcome")
+				sums[outcome.AsString()] += p.Value
+			}
+		}
+	}
+	return sums
+}
+
+func TestExportMonitor_CountsFailures(t *testing.T) {
+	m, reader := newTestMonitor(t)
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(
+		m.batcher(failingExporter{}, "otlp", BatchConfigFromEnv()),
+	))
+	for range 3 {
+		_, span := tp.Tracer("test").Start(context.Background(), "op")
+		span.End()
+	}
+	tp.ForceFlush(context.Background())
+
+	exported := sumByOutcome(t, reader, "otel.sdk.exporter.span.exported")
+	if exported["failure"] != 3 || exported["success"] != 0 {
+		t.Errorf("Expected 3 failed spans, got %v", exported)
+	}
+	if lost := m.lost.Load(); lost != 3 {
+		t.Errorf("Expected 3 lost spans, got %d", lost)
+	}
+}
+
+func TestExportMonitor_CountsDrops(t *testing.T) {
+	m, reader := newTestMonitor(t)
+	exp := blockingExporter{release: make(chan struct{})}
+	cfg := BatchConfig{MaxQueueSize: 2, ScheduleDelay: time.Hour, MaxExportBatchSize: 1, ExportTimeout: time.Minute}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(m.batcher(exp, "otlp", cfg)))
+	for range 10 {
+		_, span := tp.Tracer("test").Start(context.Background(), "op")
+		span.End()
+	}
+	close(exp.release)
+	tp.ForceFlush(context.Background())
+
+	dropped := sumByOutcome(t, reader, "otel.sdk.processor.span.dropped")[""]
+	exported := sumByOutcome(t, reader, "otel.sdk.exporter.span.exported")["success"]
+	if dropped == 0 {
+		t.Error("Expected spans past the queue size to be dropped")
+	}
+	if dropped+exported != 10 {
+		t.Errorf("Expected every span to be exported or counted as dropped, got %d dropped and %d exported", dropped, exported)
+	}
+	if pending := m.queues[0].pending.Load(); pending != 0 {
+		t.Errorf("Expected an empty queue after flushing, got %d", pending)
+	}
+}
+
+func TestExportMonitor_WarnLoop(t *testing.T) {
+	m, _ := newTestMonitor(t)
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	m.lost.Add(5)
+	go m.warnLoop(logger, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	m.close()
+
+	if n := strings.Count(buf.String(), "spans were dropped or failed to export"); n != 1 {
+		t.Errorf("Expected one warning for one burst of loss, got %d:\n%s", n, buf.String())
+	}
+	if !strings.Contains(buf.String(), "lost=5") {
+		t.Errorf("Expected the lost count in the warning, got %s", buf.String())
+	}
+}
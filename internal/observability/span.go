@@ -0,0 +1,54 @@
+package observability
+
+import (
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SetAttributesLazy only runs fn when the span is recording, so unsampled
+// requests never pay for expensive attribute values
+func SetAttributesLazy(span trace.Span, fn func() []attribute.KeyValue) {
+	if span.IsRecording() {
+		span.SetAttributes(fn()...)
+	}
+}
+
+// LazySpan defers attribute computation until End, so values reflect the
+// final state of the operation and are skipped entirely for unsampled spans
+type LazySpan struct {
+	trace.Span
+
+	mu  sync.Mutex
+	fns []func() []attribute.KeyValue
+}
+
+func NewLazySpan(span trace.Span) *LazySpan {
+	return &LazySpan{Span: span}
+}
+
+// SetAttributesLazy registers fn to be evaluated when the span ends
+func (s *LazySpan) SetAttributesLazy(fn func() []attribute.KeyValue) {
+	if !s.Span.IsRecording() {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fns = append(s.fns, fn)
+}
+
+func (s *LazySpan) End(options ...trace.SpanEndOption) {
+	s.mu.Lock()
+	fns := s.fns
+	s.fns = nil
+	s.mu.Unlock()
+
+	if s.Span.IsRecording() {
+		for _, fn := range fns {
+			s.Span.SetAttributes(fn()...)
+		}
+	}
+	s.Span.End(options...)
+}
@@ -0,0 +1,63 @@
+package observability
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestLazySpan_EvaluatesAtEnd(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer tp.Shutdown(context.Background())
+
+	_, raw := tp.Tracer("test").Start(context.Background(), "op")
+	span := NewLazySpan(raw)
+
+	status := "pending"
+	calls := 0
+	span.SetAttributesLazy(func() []attribute.KeyValue {
+		calls++
+		return []attribute.KeyValue{attribute.String("order.status", status)}
+	})
+	status = "created"
+
+	if calls != 0 {
+		t.Fatal("Lazy attributes evaluated before End")
+	}
+	span.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 || calls != 1 {
+		t.Fatalf("Expected 1 span and 1 evaluation, got %d spans and %d calls", len(spans), calls)
+	}
+	found := false
+	for _, attr := range spans[0].Attributes {
+		if attr.Key == "order.status" && attr.Value.AsString() == "created" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected order.status=created, got %v", spans[0].Attributes)
+	}
+}
+
+func TestLazyAttributes_SkippedWhenNotRecording(t *testing.T) {
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSampler(sdktrace.NeverSample()))
+	defer tp.Shutdown(context.Background())
+
+	_, raw := tp.Tracer("test").Start(context.Background(), "op")
+	expensive := func() []attribute.KeyValue {
+		t.Error("Attribute function called for an unsampled span")
+		return nil
+	}
+
+	SetAttributesLazy(raw, expensive)
+
+	span := NewLazySpan(raw)
+	span.SetAttributesLazy(expensive)
+	span.End()
+}
@@ -0,0 +1,157 @@
+package observability
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// overflowSpanName replaces the span name once MaxSeries distinct series
+// have been seen, the same way the collector's spanmetrics connector does
+const overflowSpanName = "otel_metrics_overflow"
+
+// SpanMetricsConfig bounds what the span-metrics processor records
+type SpanMetricsConfig struct {
+	// Kinds are the span kinds that produce metrics
+	Kinds []trace.SpanKind
+	// Dimensions are span attributes copied onto the metrics
+	Dimensions []attribute.Key
+	// MaxSeries caps the number of distinct attribute sets
+	MaxSeries int
+}
+
+func DefaultSpanMetricsConfig() SpanMetricsConfig {
+	return SpanMetricsConfig{
+		Kinds: []trace.SpanKind{trace.SpanKindServer},
+		Dimensions: []attribute.Key{
+			"http.request.method",
+			"http.response.status_code",
+			"http.route",
+		},
+		MaxSeries: 1000,
+	}
+}
+
+// SpanMetricsProcessor derives request, error, and duration (RED) metrics
+// from finished spans so dashboards work without a collector in between
+type SpanMetricsProcessor struct {
+	cfg      SpanMetricsConfig
+	calls    metric.Int64Counter
+	duration metric.Float64Histogram
+
+	mu     sync.Mutex
+	series map[attribute.Distinct]metric.MeasurementOption
+}
+
+var _ sdktrace.SpanProcessor = (*SpanMetricsProcessor)(nil)
+
+func NewSpanMetricsProcessor(meter metric.Meter, cfg SpanMetricsConfig) (*SpanMetricsProcessor, error) {
+	if cfg.MaxSeries <= 0 {
+		cfg.MaxSeries = DefaultSpanMetricsConfig().MaxSeries
+	}
+
+	calls, err := meter.Int64Counter(
+		"traces.span.metrics.calls",
+		metric.WithDescription("Spans finished, by name, kind, and status"),
+		metric.WithUnit("{call}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	duration, err := meter.Float64Histogram(
+		"traces.span.metrics.duration",
+		metric.WithDescription("Span duration, by name, kind, and status"),
+		metric.WithUnit("ms"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SpanMetricsProcessor{
+		cfg:      cfg,
+		calls:    calls,
+		duration: duration,
+		series:   make(map[attribute.Distinct]metric.MeasurementOption),
+	}, nil
+}
+
+func (p *SpanMetricsProcessor) OnStart(context.Context, sdktrace.ReadWriteSpan) {}
+
+func (p *SpanMetricsProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	if !p.tracksKind(s.SpanKind()) {
+		return
+	}
+
+	attrs := p.attributes(s)
+	// Carry the span context so exemplars point back at the span
+	ctx := trace.ContextWithSpanContext(context.Background(), s.SpanContext())
+	p.calls.Add(ctx, 1, attrs)
+	p.duration.Record(ctx, float64(s.EndTime().Sub(s.StartTime()).Microseconds())/1000, attrs)
+}
+
+func (p *SpanMetricsProcessor) Shutdown(context.Context) error   { return nil }
+func (p *SpanMetricsProcessor) ForceFlush(context.Context) error { return nil }
+
+func (p *SpanMetricsProcessor) tracksKind(kind trace.SpanKind) bool {
+	for _, k := range p.cfg.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// attributes returns a cached measurement option for the span's series, or
+// the overflow series once the cardinality limit is reached
+func (p *SpanMetricsProcessor) attributes(s sdktrace.ReadOnlySpan) metric.MeasurementOption {
+	kv := make([]attribute.KeyValue, 0, 3+len(p.cfg.Dimensions))
+	kv = append(kv,
+		attribute.String("span.name", s.Name()),
+		attribute.String("span.kind", s.SpanKind().String()),
+		attribute.String("status.code", statusCode(s.Status().Code)),
+	)
+	for _, attr := range s.Attributes() {
+		for _, dim := range p.cfg.Dimensions {
+			if attr.Key == dim {
+				kv = append(kv, attr)
+				break
+			}
+		}
+	}
+	set := attribute.NewSet(kv...)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if opt, ok := p.series[set.Equivalent()]; ok {
+		return opt
+	}
+	if len(p.series) >= p.cfg.MaxSeries {
+		return metric.WithAttributes(
+			attribute.String("span.name", overflowSpanName),
+			attribute.String("span.kind", s.SpanKind().String()),
+			attribute.String("status.code", statusCode(s.Status().Code)),
+		)
+	}
+
+	opt := metric.WithAttributeSet(set)
+	p.series[set.Equivalent()] = opt
+	return opt
+}
+
+func statusCode(code codes.Code) string {
+	switch code {
+	case codes.Error:
+		return "STATUS_CODE_ERROR"
+	case codes.Ok:
+		return "STATUS_CODE_OK"
+	default:
+		return "STATUS_CODE_UNSET"
+	}
+}
@@ -0,0 +1,103 @@
+package observability
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newSpanMetricsTest(t *testing.T, cfg SpanMetricsConfig) (trace.Tracer, *sdkmetric.ManualReader) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	processor, err := NewSpanMetricsProcessor(mp.Meter("test"), cfg)
+	if err != nil {
+		t.Fatalf("Failed to create processor: %v", err)
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(processor))
+	t.Cleanup(func() {
+		_ = tp.Shutdown(context.Background())
+		_ = mp.Shutdown(context.Background())
+	})
+	return tp.Tracer("test"), reader
+}
+
+func collectCalls(t *testing.T, reader *sdkmetric.ManualReader) []metricdata.DataPoint[int64] {
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Failed to collect metrics: %v", err)
+	}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == "traces.span.metrics.calls" {
+				return m.Data.(metricdata.Sum[int64]).DataPoints
+			}
+		}
+	}
+	return nil
+}
+
+func TestSpanMetricsProcessor_RecordsServerSpans(t *testing.T) {
+	tracer, reader := newSpanMetricsTest(t, DefaultSpanMetricsConfig())
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		_, span := tracer.Start(ctx, "POST /orders", trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(attribute.String("http.route", "/orders"), attribute.String("user.id", fmt.Sprint(i))))
+		if i == 0 {
+			span.SetStatus(codes.Error, "boom")
+		}
+		span.End()
+	}
+	_, internal := tracer.Start(ctx, "check_inventory")
+	internal.End()
+
+	points := collectCalls(t, reader)
+	if len(points) != 2 {
+		t.Fatalf("Expected error and unset series, got %d", len(points))
+	}
+	for _, dp := range points {
+		status, _ := dp.Attributes.Value("status.code")
+		want := int64(2)
+		if status.AsString() == "STATUS_CODE_ERROR" {
+			want = 1
+		}
+		if dp.Value != want {
+			t.Errorf("Expected %d calls for %s, got %d", want, status.AsString(), dp.Value)
+		}
+		if _, ok := dp.Attributes.Value("user.id"); ok {
+			t.Error("Attributes outside the configured dimensions must not be copied")
+		}
+		if route, _ := dp.Attributes.Value("http.route"); route.AsString() != "/orders" {
+			t.Errorf("Expected http.route dimension, got %v", route)
+		}
+	}
+}
+
+func TestSpanMetricsProcessor_BoundsCardinality(t *testing.T) {
+	cfg := DefaultSpanMetricsConfig()
+	cfg.MaxSeries = 2
+	tracer, reader := newSpanMetricsTest(t, cfg)
+
+	for i := 0; i < 5; i++ {
+		_, span := tracer.Start(context.Background(), fmt.Sprintf("GET /orders/%d", i), trace.WithSpanKind(trace.SpanKindServer))
+		span.End()
+	}
+
+	points := collectCalls(t, reader)
+	if len(points) != 3 {
+		t.Fatalf("Expected 2 series plus overflow, got %d", len(points))
+	}
+	for _, dp := range points {
+		if name, _ := dp.Attributes.Value("span.name"); name.AsString() == overflowSpanName && dp.Value != 3 {
+			t.Errorf("Expected 3 overflow calls, got %d", dp.Value)
+		}
+	}
+}
@@ -0,0 +1,339 @@
+package observability
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SplunkHECConfig configures the Splunk HTTP Event Collector sink
+type SplunkHECConfig struct {
+	URL           string
+	Token         string
+	Index         string
+	Source        string
+	SourceType    string
+	BatchSize     int
+	FlushInterval time.Duration
+	MaxRetries    int
+	// UseAck enables indexer acknowledgement: a batch only counts as
+	// delivered once Splunk confirms it was indexed
+	UseAck     bool
+	AckTimeout time.Duration
+	Client     *http.Client
+}
+
+// SplunkHECConfigFromEnv returns the config and whether SPLUNK_HEC_URL is set
+func SplunkHECConfigFromEnv() (SplunkHECConfig, bool) {
+	cfg := SplunkHECConfig{
+		URL:           getEnv("SPLUNK_HEC_URL", ""),
+		Token:         getEnv("SPLUNK_HEC_TOKEN", ""),
+		Index:         getEnv("SPLUNK_HEC_INDEX", ""),
+		Source:        getEnv("SPLUNK_HEC_SOURCE", getEnv("SERVICE_NAME", "order-service")),
+		SourceType:    getEnv("SPLUNK_HEC_SOURCETYPE", "_json"),
+		BatchSize:     100,
+		FlushInterval: 2 * time.Second,
+		MaxRetries:    3,
+		UseAck:        getEnv("SPLUNK_HEC_ACK", "false") == "true",
+		AckTimeout:    30 * time.Second,
+	}
+	if n, err := strconv.Atoi(getEnv("SPLUNK_HEC_BATCH_SIZE", "")); err == nil && n > 0 {
+		cfg.BatchSize = n
+	}
+	return cfg, cfg.URL != ""
+}
+
+// SplunkHECHandler is a slog.Handler that batches records and ships them to
+// Splunk HEC in the background. Records are formatted with the standard JSON
+// handler so groups and attributes look exactly like the stdout logs.
+type SplunkHECHandler struct {
+	sink  *hecSink
+	inner slog.Handler
+}
+
+func NewSplunkHECHandler(cfg SplunkHECConfig, level slog.Leveler) *SplunkHECHandler {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 2 * time.Second
+	}
+	if cfg.AckTimeout <= 0 {
+		cfg.AckTimeout = 30 * time.Second
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	host, _ := os.Hostname()
+	sink := &hecSink{
+		cfg:     cfg,
+		host:    host,
+		channel: uuid.NewString(),
+		events:  make(chan hecEvent, cfg.BatchSize*10),
+		flushCh: make(chan chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go sink.run()
+
+	return &SplunkHECHandler{
+		sink:  sink,
+		inner: slog.NewJSONHandler(&sink.fmtBuf, &slog.HandlerOptions{Level: level}),
+	}
+}
+
+func (h *SplunkHECHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+func (h *SplunkHECHandler) Handle(ctx context.Context, r slog.Record) error {
+	// The JSON handler writes into a shared buffer, so format under the lock
+	h.sink.fmtMu.Lock()
+	h.sink.fmtBuf.Reset()
+	err := h.inner.Handle(ctx, r)
+	line := bytes.TrimRight(h.sink.fmtBuf.Bytes(), "\n")
+	event := hecEvent{
+		Time:  float64(r.Time.UnixNano()) / 1e9,
+		Event: append(json.RawMessage(nil), line...),
+	}
+	h.sink.fmtMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	select {
+	case h.sink.events <- event:
+		return nil
+	default:
+		// Never block the request path on Splunk
+		h.sink.dropped.Add(1)
+		return nil
+	}
+}
+
+func (h *SplunkHECHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &SplunkHECHandler{sink: h.sink, inner: h.inner.WithAttrs(attrs)}
+}
+
+func (h *SplunkHECHandler) WithGroup(name string) slog.Handler {
+	return &SplunkHECHandler{sink: h.sink, inner: h.inner.WithGroup(name)}
+}
+
+// Flush sends everything queued so far
+func (h *SplunkHECHandler) Flush(ctx context.Context) error {
+	done := make(chan struct{})
+	select {
+	case h.sink.flushCh <- done:
+	case <-h.sink.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close flushes and stops the background sender
+func (h *SplunkHECHandler) Close(ctx context.Context) error {
+	err := h.Flush(ctx)
+	h.sink.closeOnce.Do(func() { close(h.sink.done) })
+	if n := h.sink.dropped.Load(); n > 0 {
+		err = errors.Join(err, fmt.Errorf("splunk hec: dropped %d log records", n))
+	}
+	return err
+}
+
+type hecEvent struct {
+	Time       float64         `json:"time"`
+	Host       string          `json:"host,omitempty"`
+	Source     string          `json:"source,omitempty"`
+	SourceType string          `json:"sourcetype,omitempty"`
+	Index      string          `json:"index,omitempty"`
+	Event      json.RawMessage `json:"event"`
+}
+
+type hecResponse struct {
+	Text  string `json:"text"`
+	Code  int    `json:"code"`
+	AckID *int64 `json:"ackId"`
+}
+
+type hecSink struct {
+	cfg     SplunkHECConfig
+	host    string
+	channel string
+
+	fmtMu  sync.Mutex
+	fmtBuf bytes.Buffer
+
+	events    chan hecEvent
+	flushCh   chan chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	dropped   atomic.Int64
+}
+
+func (s *hecSink) run() {
+	ticker := time.NewTicker(s.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]hecEvent, 0, s.cfg.BatchSize)
+	send := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := s.sendWithRetry(batch); err != nil {
+			fmt.Fprintf(os.Stderr, "splunk hec: %v\n", err)
+			s.dropped.Add(int64(len(batch)))
+		}
+		batch = batch[:0]
+	}
+	drain := func() {
+		for {
+			select {
+			case e := <-s.events:
+				batch = append(batch, e)
+				if len(batch) >= s.cfg.BatchSize {
+					send()
+				}
+			default:
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case e := <-s.events:
+			batch = append(batch, e)
+			if len(batch) >= s.cfg.BatchSize {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case done := <-s.flushCh:
+			drain()
+			send()
+			close(done)
+		case <-s.done:
+			drain()
+			send()
+			return
+		}
+	}
+}
+
+func (s *hecSink) sendWithRetry(batch []hecEvent) error {
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, e := range batch {
+		e.Host = s.host
+		e.Source = s.cfg.Source
+		e.SourceType = s.cfg.SourceType
+		e.Index = s.cfg.Index
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	backoff := 200 * time.Millisecond
+	for attempt := 0; attempt <= s.cfg.MaxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		var ackID *int64
+		var retryable bool
+		ackID, retryable, err = s.post(body.Bytes())
+		if err == nil && s.cfg.UseAck && ackID != nil {
+			// An unacknowledged batch may not have been indexed; resend it
+			err = s.waitForAck(*ackID)
+			retryable = true
+		}
+		if err == nil || !retryable {
+			return err
+		}
+	}
+	return fmt.Errorf("giving up after %d retries: %w", s.cfg.MaxRetries, err)
+}
+
+func (s *hecSink) post(body []byte) (*int64, bool, error) {
+	req, err := http.NewRequest(http.MethodPost, s.cfg.URL+"/services/collector/event", bytes.NewReader(body))
+	if err != nil {
+		return nil, false, err
+	}
+	s.setHeaders(req)
+
+	resp, err := s.cfg.Client.Do(req)
+	if err != nil {
+		return nil, true, err
+	}
+	defer resp.Body.Close()
+
+	var hr hecResponse
+	_ = json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&hr)
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return hr.AckID, false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return nil, true, fmt.Errorf("hec returned %d: %s", resp.StatusCode, hr.Text)
+	default:
+		// Bad token, bad index, malformed data: retrying will not help
+		return nil, false, fmt.Errorf("hec returned %d: %s", resp.StatusCode, hr.Text)
+	}
+}
+
+func (s *hecSink) waitForAck(ackID int64) error {
+	deadline := time.Now().Add(s.cfg.AckTimeout)
+	body, _ := json.Marshal(map[string][]int64{"acks": {ackID}})
+
+	for time.Now().Before(deadline) {
+		req, err := http.NewRequest(http.MethodPost, s.cfg.URL+"/services/collector/ack", bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		s.setHeaders(req)
+
+		resp, err := s.cfg.Client.Do(req)
+		if err == nil {
+			var ack struct {
+				Acks map[string]bool `json:"acks"`
+			}
+			_ = json.NewDecoder(resp.Body).Decode(&ack)
+			resp.Body.Close()
+			if ack.Acks[strconv.FormatInt(ackID, 10)] {
+				return nil
+			}
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	return fmt.Errorf("ack %d not confirmed within %s", ackID, s.cfg.AckTimeout)
+}
+
+func (s *hecSink) setHeaders(req *http.Request) {
+	req.Header.Set("Authorization", "Splunk "+s.cfg.Token)
+	req.Header.Set("Content-Type", "application/json")
+	if s.cfg.UseAck {
+		req.Header.Set("X-Splunk-Request-Channel", s.channel)
+	}
+}
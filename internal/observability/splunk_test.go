@@ -0,0 +1,129 @@
+package observability
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeHEC struct {
+	mu        sync.Mutex
+	failFirst int
+	posts     int
+	events    []map[string]any
+	ackPolls  int
+	authz     string
+	channel   string
+}
+
+func (f *fakeHEC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.URL.Path {
+	case "/services/collector/event":
+		f.posts++
+		f.authz = r.Header.Get("Authorization")
+		f.channel = r.Header.Get("X-Splunk-Request-Channel")
+		if f.posts <= f.failFirst {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"text":"Server is busy","code":9}`))
+			return
+		}
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var e map[string]any
+			json.Unmarshal(scanner.Bytes(), &e)
+			f.events = append(f.events, e)
+		}
+		w.Write([]byte(`{"text":"Success","code":0,"ackId":7}`))
+	case "/services/collector/ack":
+		f.ackPolls++
+		w.Write([]byte(`{"acks":{"7":true}}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestSplunkHECHandler_BatchesRetriesAndAcks(t *testing.T) {
+	hec := &fakeHEC{failFirst: 1}
+	server := httptest.NewServer(hec)
+	defer server.Close()
+
+	handler := NewSplunkHECHandler(SplunkHECConfig{
+		URL:           server.URL,
+		Token:         "secret",
+		SourceType:    "_json",
+		BatchSize:     10,
+		FlushInterval: time.Hour,
+		MaxRetries:    2,
+		UseAck:        true,
+	}, slog.LevelInfo)
+
+	logger := slog.New(handler).With("service", "order-service")
+	logger.Info("order created", slog.String("order_id", "order-1"))
+	logger.WithGroup("payment").Error("payment failed", slog.Int("attempt", 2))
+	logger.Debug("below level")
+
+	if err := handler.Close(context.Background()); err != nil {
+		t.Fatalf("Close returned %v", err)
+	}
+
+	hec.mu.Lock()
+	defer hec.mu.Unlock()
+
+	if hec.posts != 2 {
+		t.Errorf("Expected one failed and one successful post, got %d", hec.posts)
+	}
+	if hec.authz != "Splunk secret" {
+		t.Errorf("Expected Splunk auth header, got %q", hec.authz)
+	}
+	if hec.channel == "" || hec.ackPolls == 0 {
+		t.Error("Expected a request channel and at least one ack poll")
+	}
+	if len(hec.events) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(hec.events))
+	}
+
+	first := hec.events[0]["event"].(map[string]any)
+	if first["msg"] != "order created" || first["order_id"] != "order-1" || first["service"] != "order-service" {
+		t.Errorf("Unexpected first event %v", first)
+	}
+	second := hec.events[1]["event"].(map[string]any)
+	if payment, _ := second["payment"].(map[string]any); payment["attempt"] != float64(2) {
+		t.Errorf("Expected grouped attribute, got %v", second)
+	}
+	if hec.events[0]["sourcetype"] != "_json" || hec.events[0]["time"] == nil {
+		t.Errorf("Expected HEC metadata, got %v", hec.events[0])
+	}
+}
+
+func TestSplunkHECHandler_DoesNotRetryClientErrors(t *testing.T) {
+	var posts int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posts++
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"text":"Invalid token","code":4}`))
+	}))
+	defer server.Close()
+
+	handler := NewSplunkHECHandler(SplunkHECConfig{
+		URL:           server.URL,
+		FlushInterval: time.Hour,
+		MaxRetries:    3,
+	}, slog.LevelInfo)
+	slog.New(handler).Info("hello")
+
+	if err := handler.Close(context.Background()); err == nil {
+		t.Error("Expected Close to report the dropped record")
+	}
+	if posts != 1 {
+		t.Errorf("Expected a single attempt for a 403, got %d", posts)
+	}
+}
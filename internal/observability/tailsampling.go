@@ -0,0 +1,197 @@
+package observability
+
+import (
+	"context"
+	"errors"
+	"go-observability-demo/internal/config"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TailSamplingConfig keeps traces with an error even when the head sampler
+// dropped them
+type TailSamplingConfig struct {
+	// Window is how long the spans of an unsampled trace are held waiting
+	// for an error; spans that end after it are lost
+	Window time.Duration
+	// MaxTraces bounds memory; when full, new unsampled traces are not held
+	MaxTraces int
+	// MaxSpansPerTrace stops a runaway trace from taking the whole buffer
+	MaxSpansPerTrace int
+}
+
+// TailSamplingConfigFromEnv returns the config and whether
+// OTEL_TAIL_SAMPLING_ENABLED is set; also OTEL_TAIL_SAMPLING_WINDOW (10s)
+// and OTEL_TAIL_SAMPLING_MAX_TRACES (5000)
+func TailSamplingConfigFromEnv() (TailSamplingConfig, bool) {
+	return TailSamplingConfig{
+		Window:           config.Duration("OTEL_TAIL_SAMPLING_WINDOW", 10*time.Second),
+		MaxTraces:        config.Int("OTEL_TAIL_SAMPLING_MAX_TRACES", 5000),
+		MaxSpansPerTrace: 1000,
+	}, config.Bool("OTEL_TAIL_SAMPLING_ENABLED", false)
+}
+
+// RecordUnsampled turns the head sampler's drops into record-only
+// decisions, so the spans still reach the tail sampler. They stay unsampled
+// in the propagated trace flags.
+func RecordUnsampled(s sdktrace.Sampler) sdktrace.Sampler {
+	return recordUnsampled{s}
+}
+
+type recordUnsampled struct {
+	sdktrace.Sampler
+}
+
+func (r recordUnsampled) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	result := r.Sampler.ShouldSample(p)
+	if result.Decision == sdktrace.Drop {
+		result.Decision = sdktrace.RecordOnly
+	}
+	return result
+}
+
+func (r recordUnsampled) Description() string {
+	return "RecordUnsampled{" + r.Sampler.Description() + "}"
+}
+
+// TailSamplingProcessor passes sampled spans straight to its processors and
+// holds the spans of unsampled traces for a window. If one of them ends
+// with an error, the trace's held spans and any that end later in the
+// window are passed on as sampled. Use it with RecordUnsampled, and give it
+// the batchers instead of registering them with the provider.
+type TailSamplingProcessor struct {
+	cfg  TailSamplingConfig
+	next []sdktrace.SpanProcessor
+
+	mu     sync.Mutex
+	traces map[trace.TraceID]*heldTrace
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+type heldTrace struct {
+	started time.Time
+	keep    bool
+	spans   []sdktrace.ReadOnlySpan
+}
+
+func NewTailSamplingProcessor(cfg TailSamplingConfig, next ...sdktrace.SpanProcessor) *TailSamplingProcessor {
+	if cfg.Window <= 0 {
+		cfg.Window = 10 * time.Second
+	}
+	p := &TailSamplingProcessor{
+		cfg:    cfg,
+		next:   next,
+		traces: make(map[trace.TraceID]*heldTrace),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go p.expireLoop()
+	return p
+}
+
+func (p *TailSamplingProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	for _, sp := range p.next {
+		sp.OnStart(parent, s)
+	}
+}
+
+func (p *TailSamplingProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	if s.SpanContext().IsSampled() {
+		p.forward(s)
+		return
+	}
+
+	id := s.SpanContext().TraceID()
+	p.mu.Lock()
+	t, ok := p.traces[id]
+	if !ok {
+		if len(p.traces) >= p.cfg.MaxTraces {
+			p.mu.Unlock()
+			return
+		}
+		t = &heldTrace{started: time.Now()}
+		p.traces[id] = t
+	}
+
+	var release []sdktrace.ReadOnlySpan
+	switch {
+	case t.keep:
+		release = []sdktrace.ReadOnlySpan{s}
+	case s.Status().Code == codes.Error:
+		t.keep = true
+		release = append(t.spans, s)
+		t.spans = nil
+	case len(t.spans) < p.cfg.MaxSpansPerTrace:
+		t.spans = append(t.spans, s)
+	}
+	p.mu.Unlock()
+
+	for _, held := range release {
+		p.forward(keptSpan{held})
+	}
+}
+
+func (p *TailSamplingProcessor) forward(s sdktrace.ReadOnlySpan) {
+	for _, sp := range p.next {
+		sp.OnEnd(s)
+	}
+}
+
+// expireLoop forgets traces once their window has passed
+func (p *TailSamplingProcessor) expireLoop() {
+	defer close(p.done)
+	ticker := time.NewTicker(p.cfg.Window / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			cutoff := time.Now().Add(-p.cfg.Window)
+			p.mu.Lock()
+			for id, t := range p.traces {
+				if t.started.Before(cutoff) {
+					delete(p.traces, id)
+				}
+			}
+			p.mu.Unlock()
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+func (p *TailSamplingProcessor) ForceFlush(ctx context.Context) error {
+	var errs []error
+	for _, sp := range p.next {
+		errs = append(errs, sp.ForceFlush(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+func (p *TailSamplingProcessor) Shutdown(ctx context.Context) error {
+	select {
+	case <-p.stop:
+	default:
+		close(p.stop)
+		<-p.done
+	}
+	var errs []error
+	for _, sp := range p.next {
+		errs = append(errs, sp.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// keptSpan marks a held span as sampled so the batchers export it
+type keptSpan struct {
+	sdktrace.ReadOnlySpan
+}
+
+func (s keptSpan) SpanContext() trace.SpanContext {
+	sc := s.ReadOnlySpan.SpanContext()
+	return sc.WithTraceFlags(sc.TraceFlags().WithSampled(true))
+}
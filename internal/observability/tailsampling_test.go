@@ -0,0 +1,97 @@
+package observability
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTailSamplingProcessor_KeepsTracesWithErrors(t *testing.T) {
+	spans := tracetest.NewInMemoryExporter()
+	tail := NewTailSamplingProcessor(TailSamplingConfig{Window: time.Minute, MaxTraces: 10, MaxSpansPerTrace: 10},
+		sdktrace.NewSimpleSpanProcessor(spans))
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(RecordUnsampled(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(0)))),
+		sdktrace.WithSpanProcessor(tail),
+	)
+	defer tp.Shutdown(context.Background())
+	tracer := tp.Tracer("test")
+
+	// Dropped by the head sampler, but the payment fails
+	ctx, root := tracer.Start(context.Background(), "POST /orders")
+	_, fetch := tracer.Start(ctx, "CheckInventory")
+	fetch.End()
+	_, payment := tracer.Start(ctx, "ProcessPayment")
+	payment.SetStatus(codes.Error, "card declined")
+	payment.End()
+	root.End()
+
+	// Dropped and healthy
+	ctx, ok := tracer.Start(context.Background(), "POST /orders healthy")
+	_, child := tracer.Start(ctx, "ProcessPayment ok")
+	child.End()
+	ok.End()
+
+	// Sampled upstream, passed straight through
+	parent := trace.NewSpanContext(trace.SpanContextConfig{TraceID: trace.TraceID{9}, SpanID: trace.SpanID{9}, TraceFlags: trace.FlagsSampled, Remote: true})
+	_, sampled := tracer.Start(trace.ContextWithRemoteSpanContext(context.Background(), parent), "GET /health")
+	sampled.End()
+
+	var names []string
+	for _, s := range spans.GetSpans() {
+		if !s.SpanContext.IsSampled() {
+			t.Errorf("Expected %s to be exported as sampled", s.Name)
+		}
+		names = append(names, s.Name)
+	}
+	sort.Strings(names)
+	want := []string{"CheckInventory", "GET /health", "POST /orders", "ProcessPayment"}
+	if len(names) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, names)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Errorf("Expected %v, got %v", want, names)
+			break
+		}
+	}
+}
+
+func TestTailSamplingProcessor_ForgetsTracesAfterWindow(t *testing.T) {
+	spans := tracetest.NewInMemoryExporter()
+	tail := NewTailSamplingProcessor(TailSamplingConfig{Window: 20 * time.Millisecond, MaxTraces: 10, MaxSpansPerTrace: 10},
+		sdktrace.NewSimpleSpanProcessor(spans))
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSampler(RecordUnsampled(sdktrace.NeverSample())), sdktrace.WithSpanProcessor(tail))
+	defer tp.Shutdown(context.Background())
+
+	ctx, root := tp.Tracer("test").Start(context.Background(), "POST /orders")
+	_, child := tp.Tracer("test").Start(ctx, "CheckInventory")
+	child.End()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		tail.mu.Lock()
+		held := len(tail.traces)
+		tail.mu.Unlock()
+		if held == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the trace to be forgotten after the window")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// The error comes too late to bring back the expired child
+	root.SetStatus(codes.Error, "timeout")
+	root.End()
+	if got := spans.GetSpans(); len(got) != 1 || got[0].Name != "POST /orders" {
+		t.Errorf("Expected only the late root span, got %v", got.Snapshots())
+	}
+}
@@ -0,0 +1,44 @@
+package observability
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// traceURLTemplate turns a trace ID into a link to the tracing UI, e.g.
+//
+//	http://localhost:16686/trace/{trace_id}                  (Jaeger)
+//	http://localhost:3000/explore?left=...{trace_id}...      (Grafana Explore/Tempo)
+//
+// {trace_id} and {span_id} are substituted; the rest is used verbatim.
+var traceURLTemplate = getEnv("TRACE_URL_TEMPLATE", "")
+
+// TraceURL returns a clickable link for the span in ctx, or "" when no
+// template is configured or there is no valid span
+func TraceURL(ctx context.Context) string {
+	if traceURLTemplate == "" {
+		return ""
+	}
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return ""
+	}
+	return renderTraceURL(traceURLTemplate, sc)
+}
+
+// TraceURLForID links a hex trace ID, such as one taken from an exemplar
+func TraceURLForID(traceID string) string {
+	if traceURLTemplate == "" {
+		return ""
+	}
+	return strings.NewReplacer("{trace_id}", traceID, "{span_id}", "").Replace(traceURLTemplate)
+}
+
+func renderTraceURL(template string, sc trace.SpanContext) string {
+	return strings.NewReplacer(
+		"{trace_id}", sc.TraceID().String(),
+		"{span_id}", sc.SpanID().String(),
+	).Replace(template)
+}
@@ -2,49 +2,246 @@ package observability
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"go-observability-demo/internal/config"
+	"go-observability-demo/internal/observability/baggage"
+	"log/slog"
 	"os"
+	"slices"
 	"time"
 
+	"go.opentelemetry.io/contrib/instrumentation/runtime"
 	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
 	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
 )
 
-// InitObservability initializes tracing, metrics, and returns a shutdown function
-func InitObservability(ctx context.Context, serviceName, endpoint string) (func(context.Context) error, error) {
-	res, err := newResource(ctx, serviceName)
+const metricExportInterval = 10 * time.Second
+
+// defaultCardinalityLimit is the number of attribute sets each instrument
+// may report per collection; 0 turns the limit off
+const defaultCardinalityLimit = 2000
+
+// flushTimeout bounds the flush that runs before shutdown
+const flushTimeout = 5 * time.Second
+
+// Option customizes InitObservability
+type Option func(*options)
+
+type options struct {
+	logger          *slog.Logger
+	metricReaders   []metric.Reader
+	spanExporters   []sdktrace.SpanExporter
+	metricExporters []metric.Exporter
+	runtimeMetrics  bool
+	sampler         sdktrace.Sampler
+}
+
+// WithLogger sets the logger for startup messages; it defaults to slog.Default
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithMetricReader attaches an extra reader to the meter provider, e.g. to
+// evaluate metrics in-process alongside the OTLP export
+func WithMetricReader(r metric.Reader) Option {
+	return func(o *options) {
+		o.metricReaders = append(o.metricReaders, r)
+	}
+}
+
+// WithSpanExporter sends spans to exp as well as to the OTLP endpoint, through
+// its own batcher, so a custom backend can be added without touching this file
+func WithSpanExporter(exp sdktrace.SpanExporter) Option {
+	return func(o *options) {
+		o.spanExporters = append(o.spanExporters, exp)
+	}
+}
+
+// WithMetricExporter pushes metrics to exp on the same interval as the OTLP
+// export; exp chooses its own temporality and aggregation
+func WithMetricExporter(exp metric.Exporter) Option {
+	return func(o *options) {
+		o.metricExporters = append(o.metricExporters, exp)
+	}
+}
+
+// WithSampler replaces the head sampler from OTEL_TRACES_SAMPLER, e.g.
+// with a DynamicSampler the admin API can adjust. Tail sampling still
+// applies on top.
+func WithSampler(s sdktrace.Sampler) Option {
+	return func(o *options) {
+		o.sampler = s
+	}
+}
+
+// WithRuntimeMetrics reports Go runtime metrics (goroutines, heap, GC,
+// scheduler latency) next to the business metrics
+func WithRuntimeMetrics() Option {
+	return func(o *options) {
+		o.runtimeMetrics = true
+	}
+}
+
+// Disabled reports whether OBSERVABILITY_DISABLED is set, for CI and
+// air-gapped environments without a collector. InitObservability then
+// installs no-op providers and creates no exporters.
+func Disabled() bool {
+	return config.Bool("OBSERVABILITY_DISABLED", false)
+}
+
+// InitObservability initializes tracing, metrics, and optionally logs, and
+// returns a shutdown function. The logger provider is flushed by
+// CloseLogSinks instead.
+func InitObservability(ctx context.Context, serviceName, endpoint string, opts ...Option) (func(context.Context) error, error) {
+	o := options{logger: slog.Default()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	profile := profileFromEnv()
+	configured, err := propagatorsFromEnv()
 	if err != nil {
+		return nil, err
+	}
+	if Disabled() {
+		// Nothing is exported, so there is nothing to connect to or warn
+		// about; trace context still passes through to downstream calls
+		otel.SetTracerProvider(tracenoop.NewTracerProvider())
+		otel.SetMeterProvider(metricnoop.NewMeterProvider())
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(configured...))
+		o.logger.Info("observability disabled, telemetry is not exported")
+		return func(context.Context) error { return nil }, nil
+	}
+
+	// Check the collector up front: the exporters only report a bad
+	// endpoint through otel.Handle, long after startup
+	if exportCfg := ExportConfigFromEnv(); exportCfg.Exporter == ExporterOTLP {
+		_, probeEndpoint, _ := exportCfg.forSignal(exportCfg.Traces, endpoint)
+		probe := ProbeConfigFromEnv()
+		if probe.Require {
+			if err := probeCollector(ctx, probeEndpoint, probe, o.logger); err != nil {
+				return nil, fmt.Errorf("OTEL_REQUIRE_COLLECTOR is set: %w", err)
+			}
+		} else {
+			go probeCollector(context.WithoutCancel(ctx), probeEndpoint, probe, o.logger)
+		}
+	}
+
+	res, err := newResource(ctx, serviceName, profile)
+	if errors.Is(err, resource.ErrPartialResource) {
+		// A detector that finds nothing (no container, say) is not fatal
+		o.logger.Warn("some resource attributes could not be detected", "error", err.Error())
+	} else if err != nil {
 		return nil, fmt.Errorf("failed to create resource: %w", err)
 	}
 
 	// Initialize tracing
-	tracerProvider, err := newTracerProvider(ctx, res, endpoint)
+	sampler := o.sampler
+	if sampler == nil {
+		if sampler, err = SamplerConfigFromEnv().Sampler(); err != nil {
+			return nil, fmt.Errorf("invalid trace sampler: %w", err)
+		}
+	}
+	var tail *TailSamplingConfig
+	if cfg, ok := TailSamplingConfigFromEnv(); ok {
+		tail = &cfg
+	}
+	spanMetrics := getEnv("SPAN_METRICS_ENABLED", "false") == "true"
+	if tail != nil || spanMetrics {
+		// Unsampled spans are recorded so traces with errors can be kept,
+		// and so span metrics count every request, not just sampled ones
+		sampler = RecordUnsampled(sampler)
+	}
+	o.logger.Info("trace sampler configured", "sampler", sampler.Description(), "tail_sampling", tail != nil)
+	// The monitor's instruments record through the global meter provider,
+	// which delegates to the SDK one once it is installed below
+	monitor, err := newExportMonitor(otel.Meter("order-service/selfmonitor"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create export monitor: %w", err)
+	}
+	tracerProvider, err := newTracerProvider(ctx, res, endpoint, profile, sampler, tail, o.spanExporters, monitor)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create tracer provider: %w", err)
 	}
+	go monitor.warnLoop(o.logger, config.Duration("OTEL_SELF_MONITOR_WARN_INTERVAL", time.Minute))
 	otel.SetTracerProvider(tracerProvider)
 
 	// Initialize metrics
-	meterProvider, err := newMeterProvider(ctx, res, endpoint)
+	readers := o.metricReaders
+	for _, exp := range o.metricExporters {
+		readers = append(readers, metric.NewPeriodicReader(exp, metric.WithInterval(metricExportInterval)))
+	}
+	metricsExport, err := MetricsExportFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	// OTLP push, unless OTEL_METRICS_EXPORTER leaves it to a Prometheus scrape
+	if metricsExport.Push {
+		exporter, err := newMetricExporter(ctx, ExportConfigFromEnv(), endpoint, profile.temporality())
+		if err != nil {
+			return nil, fmt.Errorf("failed to create metric exporter: %w", err)
+		}
+		readers = append(readers, metric.NewPeriodicReader(exporter, metric.WithInterval(metricExportInterval)))
+	}
+	meterProvider, err := newMeterProvider(res, readers)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create meter provider: %w", err)
 	}
 	otel.SetMeterProvider(meterProvider)
+	if o.runtimeMetrics {
+		if err := runtime.Start(runtime.WithMeterProvider(meterProvider)); err != nil {
+			return nil, fmt.Errorf("failed to start runtime metrics: %w", err)
+		}
+	}
+
+	// Initialize logs; the slog bridge in NewLogger starts delivering
+	// once the provider is installed
+	if LogsExportEnabled() {
+		if _, err := newLoggerProvider(ctx, res, endpoint); err != nil {
+			return nil, fmt.Errorf("failed to create logger provider: %w", err)
+		}
+	}
+
+	// Derive RED metrics from server spans when no collector can do it
+	if spanMetrics {
+		spanMetrics, err := NewSpanMetricsProcessor(meterProvider.Meter("order-service/spanmetrics"), DefaultSpanMetricsConfig())
+		if err != nil {
+			return nil, fmt.Errorf("failed to create span metrics processor: %w", err)
+		}
+		tracerProvider.RegisterSpanProcessor(spanMetrics)
+	}
 
 	// Set global propagator
-	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
-		propagation.TraceContext{},
-		propagation.Baggage{},
-	))
+	propagators := profile.propagators()
+	if getEnv("MESH_COMPAT", "false") == "true" {
+		propagators = append(propagators, meshPropagators()...)
+	}
+	// The configured propagators go last so W3C traceparent wins by default
+	propagators = append(propagators, configured...)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagators...))
 
 	// Return shutdown function
 	shutdown := func(ctx context.Context) error {
+		// Export what is queued on a budget of its own first, so a shutdown
+		// context that is nearly spent doesn't lose the last spans
+		flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), flushTimeout)
+		if err := Flush(flushCtx); err != nil {
+			o.logger.Warn("telemetry flush before shutdown failed", "error", err.Error())
+		}
+		cancel()
+		monitor.close()
 		if err := tracerProvider.Shutdown(ctx); err != nil {
 			return fmt.Errorf("failed to shutdown tracer provider: %w", err)
 		}
@@ -57,63 +254,198 @@ func InitObservability(ctx context.Context, serviceName, endpoint string) (func(
 	return shutdown, nil
 }
 
-func newResource(ctx context.Context, serviceName string) (*resource.Resource, error) {
-	return resource.New(ctx,
+// Flush exports the spans and metrics the global providers are holding,
+// e.g. before a test inspects what reached the collector
+func Flush(ctx context.Context) error {
+	var errs []error
+	if tp, ok := otel.GetTracerProvider().(interface{ ForceFlush(context.Context) error }); ok {
+		if err := tp.ForceFlush(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to flush spans: %w", err))
+		}
+	}
+	if mp, ok := otel.GetMeterProvider().(interface{ ForceFlush(context.Context) error }); ok {
+		if err := mp.ForceFlush(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to flush metrics: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func newResource(ctx context.Context, serviceName string, profile Profile) (*resource.Resource, error) {
+	environment := getEnv("ENVIRONMENT", "development")
+	build := ServiceBuildInfo()
+	detectors, err := resourceDetectorOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	// Detectors go first so they can't override the service identity
+	opts := append(detectors,
 		resource.WithAttributes(
 			semconv.ServiceNameKey.String(serviceName),
-			semconv.ServiceVersionKey.String("1.0.0"),
-			semconv.DeploymentEnvironmentKey.String(getEnv("ENVIRONMENT", "development")),
+			semconv.ServiceVersionKey.String(build.Version),
+			semconv.DeploymentEnvironmentKey.String(environment),
 		),
+		resource.WithAttributes(profile.resourceAttributes(serviceName, build.Version, environment)...),
 	)
+	if build.Revision != "" {
+		opts = append(opts, resource.WithAttributes(attribute.String("vcs.ref.head.revision", build.Revision)))
+	}
+	return resource.New(ctx, opts...)
 }
 
-func newTracerProvider(ctx context.Context, res *resource.Resource, endpoint string) (*sdktrace.TracerProvider, error) {
-	exporter, err := otlptracehttp.New(ctx,
-		otlptracehttp.WithEndpoint(endpoint),
-		otlptracehttp.WithInsecure(),
-	)
+func newTracerProvider(ctx context.Context, res *resource.Resource, endpoint string, profile Profile, sampler sdktrace.Sampler, tail *TailSamplingConfig, extra []sdktrace.SpanExporter, monitor *exportMonitor) (*sdktrace.TracerProvider, error) {
+	cfg := ExportConfigFromEnv()
+	exporter, err := newSpanExporter(ctx, cfg, endpoint)
 	if err != nil {
 		return nil, err
 	}
-
-	// Get sampling rate from environment (default 1.0 for development)
-	samplingRate := 1.0
-	if getEnv("ENVIRONMENT", "development") == "production" {
-		samplingRate = 0.1 // 10% sampling in production
+	exporters := append(slices.Clone(extra), exporter)
+	// names label each exporter's self-monitoring metrics
+	names := make([]string, len(extra), len(exporters))
+	for i := range extra {
+		names[i] = fmt.Sprintf("custom-%d", i)
+	}
+	names = append(names, cfg.Exporter)
+	if cfg.Exporter == ExporterOTLP {
+		fanout, err := newFanoutExporters(ctx, cfg)
+		if err != nil {
+			return nil, err
+		}
+		exporters = append(exporters, fanout...)
+		for _, raw := range cfg.FanoutTraceEndpoints {
+			names = append(names, "fanout:"+raw)
+		}
 	}
 
-	tp := sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(exporter,
-			sdktrace.WithMaxExportBatchSize(512),
-			sdktrace.WithBatchTimeout(5*time.Second),
-			sdktrace.WithMaxQueueSize(2048),
-		),
+	limits := SpanLimitsFromEnv()
+	opts := []sdktrace.TracerProviderOption{
 		sdktrace.WithResource(res),
-		sdktrace.WithSampler(sdktrace.TraceIDRatioBased(samplingRate)),
-	)
+		sdktrace.WithSampler(sampler),
+		sdktrace.WithRawSpanLimits(limits),
+		// Tenant, user, and request source from baggage on every span
+		sdktrace.WithSpanProcessor(baggage.NewSpanProcessor()),
+	}
+	if ids := profile.idGenerator(); ids != nil {
+		opts = append(opts, sdktrace.WithIDGenerator(ids))
+	}
+	// Profile processors adjust spans before they reach the batcher
+	for _, sp := range profile.spanProcessors() {
+		opts = append(opts, sdktrace.WithSpanProcessor(sp))
+	}
+	// Custom exporters go first and fan-out endpoints last: ForceFlush stops
+	// at the first failing processor, so an unreachable collector or vendor
+	// shouldn't hold the others back
+	batch := BatchConfigFromEnv()
+	var batchers []sdktrace.SpanProcessor
+	for i, exp := range exporters {
+		bsp := monitor.batcher(exp, names[i], batch)
+		if limits.AttributeValueLengthLimit >= 0 {
+			bsp = eventLimitProcessor{bsp, limits.AttributeValueLengthLimit}
+		}
+		batchers = append(batchers, bsp)
+	}
+	if tail != nil {
+		batchers = []sdktrace.SpanProcessor{NewTailSamplingProcessor(*tail, batchers...)}
+	}
+	for _, b := range batchers {
+		opts = append(opts, sdktrace.WithSpanProcessor(b))
+	}
+
+	tp := sdktrace.NewTracerProvider(opts...)
 
 	return tp, nil
 }
 
-func newMeterProvider(ctx context.Context, res *resource.Resource, endpoint string) (*metric.MeterProvider, error) {
-	exporter, err := otlpmetrichttp.New(ctx,
-		otlpmetrichttp.WithEndpoint(endpoint),
-		otlpmetrichttp.WithInsecure(),
-	)
+func newMeterProvider(res *resource.Resource, readers []metric.Reader) (*metric.MeterProvider, error) {
+	views, err := MetricViewsFromEnv()
 	if err != nil {
 		return nil, err
 	}
-
-	mp := metric.NewMeterProvider(
+	mpOpts := []metric.Option{
 		metric.WithResource(res),
-		metric.WithReader(metric.NewPeriodicReader(exporter,
-			metric.WithInterval(10*time.Second),
-		)),
-	)
+		metric.WithView(views...),
+		// Past the limit an instrument's new attribute sets are folded into
+		// one otel.metric.overflow=true series, so a user ID slipped into
+		// an attribute can't flood the backend
+		metric.WithCardinalityLimit(config.Int("OTEL_METRICS_CARDINALITY_LIMIT", defaultCardinalityLimit)),
+	}
+	for _, r := range readers {
+		mpOpts = append(mpOpts, metric.WithReader(r))
+	}
+
+	mp := metric.NewMeterProvider(mpOpts...)
 
 	return mp, nil
 }
 
+func newSpanExporter(ctx context.Context, cfg ExportConfig, endpoint string) (sdktrace.SpanExporter, error) {
+	if cfg.Exporter == ExporterStdout {
+		return stdouttrace.New(stdouttrace.WithPrettyPrint())
+	}
+	if cfg.Exporter != ExporterOTLP {
+		return nil, fmt.Errorf("unsupported exporter %q, want %s or %s", cfg.Exporter, ExporterOTLP, ExporterStdout)
+	}
+
+	client, err := cfg.traceClient(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	// Buffer failed batches on disk so collector restarts don't drop spans
+	if walCfg, ok := WALConfigFromEnv(); ok {
+		wal, err := NewWALClient(client, walCfg)
+		if err != nil {
+			return nil, err
+		}
+		client = wal
+	}
+	return otlptrace.New(ctx, client)
+}
+
+// newFanoutExporters returns an OTLP exporter per fan-out endpoint. Each
+// gets its own batcher, so a slow backend only drops its own spans; the
+// WAL stays with the main endpoint. Headers are not sent to them, since
+// they are usually credentials for the main backend.
+func newFanoutExporters(ctx context.Context, cfg ExportConfig) ([]sdktrace.SpanExporter, error) {
+	cfg.Headers, cfg.Traces = nil, SignalConfig{}
+	var exporters []sdktrace.SpanExporter
+	for _, raw := range cfg.FanoutTraceEndpoints {
+		epCfg, endpoint := cfg.forEndpoint(raw)
+		client, err := epCfg.traceClient(endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("fan-out endpoint %s: %w", raw, err)
+		}
+		exp, err := otlptrace.New(ctx, client)
+		if err != nil {
+			return nil, fmt.Errorf("fan-out endpoint %s: %w", raw, err)
+		}
+		exporters = append(exporters, exp)
+	}
+	return exporters, nil
+}
+
+func newMetricExporter(ctx context.Context, cfg ExportConfig, endpoint string, temporality metric.TemporalitySelector) (metric.Exporter, error) {
+	switch cfg.Exporter {
+	case ExporterOTLP:
+		return cfg.metricExporter(ctx, endpoint, temporality)
+	case ExporterStdout:
+		opts := []stdoutmetric.Option{stdoutmetric.WithPrettyPrint()}
+		if temporality != nil {
+			opts = append(opts, stdoutmetric.WithTemporalitySelector(temporality))
+		}
+		aggregation, err := cfg.histogramSelector()
+		if err != nil {
+			return nil, err
+		}
+		if aggregation != nil {
+			opts = append(opts, stdoutmetric.WithAggregationSelector(aggregation))
+		}
+		return stdoutmetric.New(opts...)
+	default:
+		return nil, fmt.Errorf("unsupported exporter %q, want %s or %s", cfg.Exporter, ExporterOTLP, ExporterStdout)
+	}
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -0,0 +1,247 @@
+package observability
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordingMetricExporter keeps the names of the metrics it is given; the
+// reader reuses ResourceMetrics between exports
+type recordingMetricExporter struct {
+	mu    sync.Mutex
+	names map[string]bool
+}
+
+func (e *recordingMetricExporter) Temporality(k sdkmetric.InstrumentKind) metricdata.Temporality {
+	return sdkmetric.DefaultTemporalitySelector(k)
+}
+
+func (e *recordingMetricExporter) Aggregation(k sdkmetric.InstrumentKind) sdkmetric.Aggregation {
+	return sdkmetric.DefaultAggregationSelector(k)
+}
+
+func (e *recordingMetricExporter) Export(_ context.Context, rm *metricdata.ResourceMetrics) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			e.names[m.Name] = true
+		}
+	}
+	return nil
+}
+
+func (e *recordingMetricExporter) ForceFlush(context.Context) error { return nil }
+func (e *recordingMetricExporter) Shutdown(context.Context) error   { return nil }
+
+func TestInitObservability_CustomExporters(t *testing.T) {
+	prevTP, prevMP, prevProp := otel.GetTracerProvider(), otel.GetMeterProvider(), otel.GetTextMapPropagator()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevTP)
+		otel.SetMeterProvider(prevMP)
+		otel.SetTextMapPropagator(prevProp)
+	})
+	// Nothing listens here; keep the OTLP exporters from retrying
+	t.Setenv("OTEL_EXPORTER_OTLP_RETRY_ENABLED", "false")
+	t.Setenv("OTEL_EXPORTER_OTLP_TIMEOUT", "100")
+
+	spans := tracetest.NewInMemoryExporter()
+	metrics := &recordingMetricExporter{names: map[string]bool{}}
+
+	shutdown, err := InitObservability(context.Background(), "test-service", "127.0.0.1:1",
+		WithSpanExporter(spans),
+		WithMetricExporter(metrics),
+		WithRuntimeMetrics(),
+	)
+	if err != nil {
+		t.Fatalf("InitObservability failed: %v", err)
+	}
+
+	_, span := otel.Tracer("test").Start(context.Background(), "CustomBackend")
+	span.End()
+	counter, _ := otel.Meter("test").Int64Counter("custom.backend.calls")
+	counter.Add(context.Background(), 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_ = Flush(ctx)
+	// The in-memory exporter forgets its spans on shutdown
+	if got := spans.GetSpans(); len(got) != 1 || got[0].Name != "CustomBackend" {
+		t.Errorf("Expected the span at the custom exporter, got %v", got)
+	}
+	metrics.mu.Lock()
+	flushed := metrics.names["custom.backend.calls"]
+	metrics.mu.Unlock()
+	if !flushed {
+		t.Error("Expected Flush to export metrics before shutdown")
+	}
+	_ = shutdown(ctx)
+
+	if !metrics.names["go.goroutine.count"] {
+		t.Errorf("Expected runtime metrics, got %v", metrics.names)
+	}
+	if !metrics.names["custom.backend.calls"] {
+		t.Errorf("Expected custom.backend.calls at the custom exporter, got %v", metrics.names)
+	}
+}
+
+func TestInitObservability_SpanMetricsCountUnsampledSpans(t *testing.T) {
+	prevTP, prevMP, prevProp := otel.GetTracerProvider(), otel.GetMeterProvider(), otel.GetTextMapPropagator()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevTP)
+		otel.SetMeterProvider(prevMP)
+		otel.SetTextMapPropagator(prevProp)
+	})
+	t.Setenv("OTEL_EXPORTER_OTLP_RETRY_ENABLED", "false")
+	t.Setenv("OTEL_EXPORTER_OTLP_TIMEOUT", "100")
+	t.Setenv("SPAN_METRICS_ENABLED", "true")
+
+	spans := tracetest.NewInMemoryExporter()
+	reader := sdkmetric.NewManualReader()
+	shutdown, err := InitObservability(context.Background(), "test-service", "127.0.0.1:1",
+		WithSampler(sdktrace.TraceIDRatioBased(0.25)),
+		WithSpanExporter(spans),
+		WithMetricReader(reader),
+	)
+	if err != nil {
+		t.Fatalf("InitObservability failed: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	defer shutdown(ctx)
+
+	for range 40 {
+		_, span := otel.Tracer("test").Start(context.Background(), "POST /orders", trace.WithSpanKind(trace.SpanKindServer))
+		span.End()
+	}
+	_ = Flush(ctx)
+
+	var calls int64
+	for _, dp := range collectCalls(t, reader) {
+		calls += dp.Value
+	}
+	if calls != 40 {
+		t.Errorf("Expected span metrics to count all 40 requests, got %d", calls)
+	}
+	if got := len(spans.GetSpans()); got == 0 || got == 40 {
+		t.Errorf("Expected only a share of the spans to be exported, got %d", got)
+	}
+}
+
+func TestNewExporters_Stdout(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultExportConfig()
+	cfg.Exporter = ExporterStdout
+
+	spans, err := newSpanExporter(ctx, cfg, "")
+	if _, ok := spans.(*stdouttrace.Exporter); !ok || err != nil {
+		t.Errorf("Expected a stdout span exporter, got %T (%v)", spans, err)
+	}
+	metrics, err := newMetricExporter(ctx, cfg, "", deltaTemporality)
+	if err != nil {
+		t.Fatalf("Failed to create stdout metric exporter: %v", err)
+	}
+	if metrics.Temporality(sdkmetric.InstrumentKindCounter) != metricdata.DeltaTemporality {
+		t.Error("Expected the profile temporality on the stdout exporter")
+	}
+
+	cfg.Exporter = "zipkin"
+	if _, err := newSpanExporter(ctx, cfg, ""); err == nil {
+		t.Error("Expected an error for an unknown exporter")
+	}
+}
+
+func TestInitObservability_FansOutSpans(t *testing.T) {
+	prevTP, prevMP, prevProp := otel.GetTracerProvider(), otel.GetMeterProvider(), otel.GetTextMapPropagator()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevTP)
+		otel.SetMeterProvider(prevMP)
+		otel.SetTextMapPropagator(prevProp)
+	})
+
+	var mu sync.Mutex
+	received := map[string]int{}
+	collector := func(name string) *httptest.Server {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/v1/traces" {
+				mu.Lock()
+				received[name]++
+				mu.Unlock()
+			}
+		}))
+		t.Cleanup(server.Close)
+		return server
+	}
+	local, vendor := collector("local"), collector("vendor")
+	t.Setenv("OTEL_EXPORTER_OTLP_RETRY_ENABLED", "false")
+	t.Setenv("OTEL_TRACES_FANOUT_ENDPOINTS", vendor.URL)
+
+	shutdown, err := InitObservability(context.Background(), "test-service", local.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("InitObservability failed: %v", err)
+	}
+	_, span := otel.Tracer("test").Start(context.Background(), "FannedOut")
+	span.End()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := Flush(ctx); err != nil {
+		t.Fatalf("ForceFlush failed: %v", err)
+	}
+	_ = shutdown(ctx)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if received["local"] != 1 || received["vendor"] != 1 {
+		t.Errorf("Expected one export at each endpoint, got %v", received)
+	}
+}
+
+func TestExportConfig_ForEndpoint(t *testing.T) {
+	cfg := DefaultExportConfig()
+	if c, ep := cfg.forEndpoint("https://api.honeycomb.io"); c.TLS.Insecure || ep != "api.honeycomb.io" {
+		t.Errorf("Expected TLS to api.honeycomb.io, got insecure=%v %s", c.TLS.Insecure, ep)
+	}
+	if c, ep := cfg.forEndpoint("collector:4318"); !c.TLS.Insecure || ep != "collector:4318" {
+		t.Errorf("Expected the default TLS setting for a bare endpoint, got insecure=%v %s", c.TLS.Insecure, ep)
+	}
+}
+
+func TestInitObservability_Disabled(t *testing.T) {
+	prevTP, prevMP, prevProp := otel.GetTracerProvider(), otel.GetMeterProvider(), otel.GetTextMapPropagator()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevTP)
+		otel.SetMeterProvider(prevMP)
+		otel.SetTextMapPropagator(prevProp)
+	})
+	t.Setenv("OBSERVABILITY_DISABLED", "true")
+	// Would fail startup if the collector were checked
+	t.Setenv("OTEL_REQUIRE_COLLECTOR", "true")
+	t.Setenv("OTEL_METRICS_EXPORTER", "prometheus")
+
+	shutdown, err := InitObservability(context.Background(), "test-service", "127.0.0.1:1")
+	if err != nil {
+		t.Fatalf("InitObservability failed: %v", err)
+	}
+	defer shutdown(context.Background())
+
+	_, span := otel.Tracer("test").Start(context.Background(), "CreateOrder")
+	if span.IsRecording() {
+		t.Error("Expected a no-op span")
+	}
+	if m, _ := MetricsExportFromEnv(); m.Prometheus || m.Push {
+		t.Errorf("Expected no metrics export, got %+v", m)
+	}
+}
@@ -0,0 +1,49 @@
+package observability
+
+import (
+	"runtime/debug"
+)
+
+// BuildInfo identifies the running build
+type BuildInfo struct {
+	// Version is SERVICE_VERSION if set, else the module version the binary
+	// was built at ("dev" for a local build)
+	Version string
+	// Revision is the VCS commit, with a -dirty suffix when the tree had
+	// uncommitted changes; empty when built without VCS info
+	Revision string
+}
+
+// ServiceBuildInfo reads the version and revision that go build stamped
+// into the binary
+func ServiceBuildInfo() BuildInfo {
+	info := BuildInfo{Version: "dev"}
+	bi, ok := debug.ReadBuildInfo()
+	if ok {
+		info = buildInfoFrom(bi)
+	}
+	if v := getEnv("SERVICE_VERSION", ""); v != "" {
+		info.Version = v
+	}
+	return info
+}
+
+func buildInfoFrom(bi *debug.BuildInfo) BuildInfo {
+	info := BuildInfo{Version: "dev"}
+	if v := bi.Main.Version; v != "" && v != "(devel)" {
+		info.Version = v
+	}
+	modified := false
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			info.Revision = s.Value
+		case "vcs.modified":
+			modified = s.Value == "true"
+		}
+	}
+	if modified && info.Revision != "" {
+		info.Revision += "-dirty"
+	}
+	return info
+}
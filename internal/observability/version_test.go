@@ -0,0 +1,31 @@
+package observability
+
+import (
+	"runtime/debug"
+	"testing"
+)
+
+func TestBuildInfoFrom(t *testing.T) {
+	info := buildInfoFrom(&debug.BuildInfo{
+		Main: debug.Module{Version: "v1.4.2"},
+		Settings: []debug.BuildSetting{
+			{Key: "vcs.revision", Value: "4f2f25d"},
+			{Key: "vcs.modified", Value: "true"},
+		},
+	})
+	if info.Version != "v1.4.2" || info.Revision != "4f2f25d-dirty" {
+		t.Errorf("Expected v1.4.2 at 4f2f25d-dirty, got %+v", info)
+	}
+
+	info = buildInfoFrom(&debug.BuildInfo{Main: debug.Module{Version: "(devel)"}})
+	if info.Version != "dev" || info.Revision != "" {
+		t.Errorf("Expected dev with no revision, got %+v", info)
+	}
+}
+
+func TestServiceBuildInfo_EnvOverride(t *testing.T) {
+	t.Setenv("SERVICE_VERSION", "2024.06.1")
+	if got := ServiceBuildInfo().Version; got != "2024.06.1" {
+		t.Errorf("Expected 2024.06.1, got %s", got)
+	}
+}
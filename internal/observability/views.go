@@ -0,0 +1,124 @@
+package observability
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-observability-demo/internal/config"
+	"os"
+	"slices"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric"
+)
+
+// MetricView changes how matching instruments are exported, so a
+// cardinality problem can be fixed from config rather than code
+type MetricView struct {
+	// Instrument is the instrument name, optionally with * and ? wildcards
+	Instrument string `json:"instrument"`
+	// Meter limits the view to one meter (instrumentation scope)
+	Meter string `json:"meter"`
+	// Rename exports the instrument under another name; it needs an exact
+	// Instrument
+	Rename string `json:"rename"`
+	// KeepAttributes, if set, drops every attribute not listed;
+	// DropAttributes drops the ones listed
+	KeepAttributes []string `json:"keep_attributes"`
+	DropAttributes []string `json:"drop_attributes"`
+	// Aggregation is drop, sum, last_value, histogram (with Buckets), or
+	// exponential_histogram; empty keeps the instrument's default
+	Aggregation string    `json:"aggregation"`
+	Buckets     []float64 `json:"buckets"`
+}
+
+// exponentialHistogram uses the SDK's default size and scale
+var exponentialHistogram = metric.AggregationBase2ExponentialHistogram{MaxSize: 160, MaxScale: 20}
+
+// ParseMetricViews reads a JSON array of views, e.g.
+// [{"instrument":"http.server.*","drop_attributes":["user_agent.original"]}]
+func ParseMetricViews(data []byte) ([]metric.View, error) {
+	var specs []MetricView
+	if err := json.Unmarshal(data, &specs); err != nil {
+		return nil, fmt.Errorf("invalid metric views: %w", err)
+	}
+	views := make([]metric.View, 0, len(specs))
+	for i, spec := range specs {
+		view, err := spec.view()
+		if err != nil {
+			return nil, fmt.Errorf("metric view %d: %w", i, err)
+		}
+		views = append(views, view)
+	}
+	return views, nil
+}
+
+// MetricViewsFromEnv reads OTEL_METRIC_VIEWS (inline JSON) or the file at
+// OTEL_METRIC_VIEWS_FILE
+func MetricViewsFromEnv() ([]metric.View, error) {
+	if file := config.String("OTEL_METRIC_VIEWS_FILE", ""); file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read metric views: %w", err)
+		}
+		return ParseMetricViews(data)
+	}
+	if inline := config.String("OTEL_METRIC_VIEWS", ""); inline != "" {
+		return ParseMetricViews([]byte(inline))
+	}
+	return nil, nil
+}
+
+func (v MetricView) view() (metric.View, error) {
+	if v.Instrument == "" {
+		return nil, fmt.Errorf("instrument is required")
+	}
+	// The SDK turns a bad view into a silent no-op, so check here instead
+	if v.Rename != "" && strings.ContainsAny(v.Instrument, "*?") {
+		return nil, fmt.Errorf("cannot rename wildcard instrument %q", v.Instrument)
+	}
+	if len(v.KeepAttributes) > 0 && len(v.DropAttributes) > 0 {
+		return nil, fmt.Errorf("set keep_attributes or drop_attributes, not both")
+	}
+
+	stream := metric.Stream{Name: v.Rename}
+	switch {
+	case len(v.KeepAttributes) > 0:
+		keys := make([]attribute.Key, len(v.KeepAttributes))
+		for i, k := range v.KeepAttributes {
+			keys[i] = attribute.Key(k)
+		}
+		stream.AttributeFilter = attribute.NewAllowKeysFilter(keys...)
+	case len(v.DropAttributes) > 0:
+		keys := make([]attribute.Key, len(v.DropAttributes))
+		for i, k := range v.DropAttributes {
+			keys[i] = attribute.Key(k)
+		}
+		stream.AttributeFilter = attribute.NewDenyKeysFilter(keys...)
+	}
+
+	switch v.Aggregation {
+	case "":
+	case "drop":
+		stream.Aggregation = metric.AggregationDrop{}
+	case "sum":
+		stream.Aggregation = metric.AggregationSum{}
+	case "last_value":
+		stream.Aggregation = metric.AggregationLastValue{}
+	case "histogram":
+		if !slices.IsSorted(v.Buckets) {
+			return nil, fmt.Errorf("buckets must be in increasing order")
+		}
+		stream.Aggregation = metric.AggregationExplicitBucketHistogram{Boundaries: v.Buckets}
+	case "exponential_histogram":
+		stream.Aggregation = exponentialHistogram
+	default:
+		return nil, fmt.Errorf("unknown aggregation %q", v.Aggregation)
+	}
+
+	criteria := metric.Instrument{Name: v.Instrument}
+	if v.Meter != "" {
+		criteria.Scope.Name = v.Meter
+	}
+	return metric.NewView(criteria, stream), nil
+}
@@ -0,0 +1,66 @@
+package observability
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	otelmetric "go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestParseMetricViews(t *testing.T) {
+	views, err := ParseMetricViews([]byte(`[
+		{"instrument":"orders.created","rename":"orders.placed"},
+		{"instrument":"orders.*","drop_attributes":["customer.id"]}
+	]`))
+	if err != nil {
+		t.Fatalf("ParseMetricViews failed: %v", err)
+	}
+	reader := metric.NewManualReader()
+	mp := metric.NewMeterProvider(metric.WithReader(reader), metric.WithView(views...))
+	meter := mp.Meter("test")
+	created, _ := meter.Int64Counter("orders.created")
+	failed, _ := meter.Int64Counter("orders.failed")
+	attrs := otelmetric.WithAttributes(attribute.String("customer.id", "c-1"), attribute.String("region", "eu"))
+	created.Add(context.Background(), 1, attrs)
+	failed.Add(context.Background(), 1, attrs)
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+	got := map[string]metricdata.Metrics{}
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		got[m.Name] = m
+	}
+	if _, ok := got["orders.placed"]; !ok {
+		t.Errorf("Expected orders.created renamed to orders.placed, got %v", got)
+	}
+	sum, ok := got["orders.failed"].Data.(metricdata.Sum[int64])
+	if !ok {
+		t.Fatalf("Expected orders.failed to be exported, got %v", got)
+	}
+	if _, ok := sum.DataPoints[0].Attributes.Value("customer.id"); ok {
+		t.Error("Expected customer.id to be dropped")
+	}
+	if _, ok := sum.DataPoints[0].Attributes.Value("region"); !ok {
+		t.Error("Expected region to be kept")
+	}
+}
+
+func TestParseMetricViews_Invalid(t *testing.T) {
+	for _, data := range []string{
+		`[{"rename":"x"}]`,
+		`[{"instrument":"orders.*","rename":"x"}]`,
+		`[{"instrument":"orders.duration","aggregation":"median"}]`,
+		`[{"instrument":"orders.duration","aggregation":"histogram","buckets":[5,1]}]`,
+		`[{"instrument":"orders.duration","keep_attributes":["a"],"drop_attributes":["b"]}]`,
+		`{`,
+	} {
+		if _, err := ParseMetricViews([]byte(data)); err == nil {
+			t.Errorf("Expected an error for %s", data)
+		}
+	}
+}
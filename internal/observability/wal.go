@@ -0,0 +1,254 @@
+package observability
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// WALConfig configures the on-disk buffer for trace exports
+type WALConfig struct {
+	Dir string
+	// MaxBytes caps the buffer; the oldest batches are dropped beyond it
+	MaxBytes int64
+	// MinBackoff and MaxBackoff bound the delay between replay attempts
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// WALConfigFromEnv returns the config and whether OTEL_WAL_DIR is set
+func WALConfigFromEnv() (WALConfig, bool) {
+	cfg := WALConfig{
+		Dir:        getEnv("OTEL_WAL_DIR", ""),
+		MaxBytes:   64 << 20,
+		MinBackoff: time.Second,
+		MaxBackoff: time.Minute,
+	}
+	if n, err := strconv.ParseInt(getEnv("OTEL_WAL_MAX_BYTES", ""), 10, 64); err == nil && n > 0 {
+		cfg.MaxBytes = n
+	}
+	return cfg, cfg.Dir != ""
+}
+
+// WALClient wraps an OTLP trace client. Batches that fail to upload (after
+// the client's own retries) are written to disk and replayed with backoff
+// once the collector is reachable again, so a collector restart no longer
+// loses whatever was in the export queue.
+type WALClient struct {
+	inner otlptrace.Client
+	cfg   WALConfig
+
+	mu  sync.Mutex // guards the directory
+	seq atomic.Uint64
+
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+var _ otlptrace.Client = (*WALClient)(nil)
+
+func NewWALClient(inner otlptrace.Client, cfg WALConfig) (*WALClient, error) {
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = time.Second
+	}
+	if cfg.MaxBackoff < cfg.MinBackoff {
+		cfg.MaxBackoff = cfg.MinBackoff
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create WAL directory: %w", err)
+	}
+	return &WALClient{
+		inner: inner,
+		cfg:   cfg,
+		wake:  make(chan struct{}, 1),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}, nil
+}
+
+func (c *WALClient) Start(ctx context.Context) error {
+	if err := c.inner.Start(ctx); err != nil {
+		return err
+	}
+	go c.replayLoop()
+	// Batches left over from a previous run are replayed right away
+	c.signal()
+	return nil
+}
+
+func (c *WALClient) Stop(ctx context.Context) error {
+	close(c.stop)
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+	}
+	return c.inner.Stop(ctx)
+}
+
+func (c *WALClient) UploadTraces(ctx context.Context, spans []*tracepb.ResourceSpans) error {
+	err := c.inner.UploadTraces(ctx, spans)
+	if err == nil {
+		return nil
+	}
+	if werr := c.persist(spans); werr != nil {
+		return fmt.Errorf("%w (WAL write failed: %v)", err, werr)
+	}
+	c.signal()
+	return nil
+}
+
+// Pending returns the number of batches waiting on disk
+func (c *WALClient) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	files, _ := c.files()
+	return len(files)
+}
+
+func (c *WALClient) signal() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (c *WALClient) persist(spans []*tracepb.ResourceSpans) error {
+	data, err := proto.Marshal(&tracepb.TracesData{ResourceSpans: spans})
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Zero-padded names keep lexical order equal to write order
+	name := fmt.Sprintf("%020d-%06d.pb", time.Now().UnixNano(), c.seq.Add(1)%1e6)
+	tmp := filepath.Join(c.cfg.Dir, name+".tmp")
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(c.cfg.Dir, name)); err != nil {
+		return err
+	}
+	c.enforceLimit()
+	return nil
+}
+
+// enforceLimit drops the oldest batches once the buffer exceeds MaxBytes.
+// Callers must hold c.mu.
+func (c *WALClient) enforceLimit() {
+	if c.cfg.MaxBytes <= 0 {
+		return
+	}
+	files, sizes := c.files()
+	var total int64
+	for _, size := range sizes {
+		total += size
+	}
+	for i := 0; total > c.cfg.MaxBytes && i < len(files); i++ {
+		if os.Remove(files[i]) == nil {
+			total -= sizes[i]
+			otel.Handle(fmt.Errorf("trace WAL full, dropped %s", filepath.Base(files[i])))
+		}
+	}
+}
+
+// files lists buffered batches oldest first. Callers must hold c.mu.
+func (c *WALClient) files() ([]string, []int64) {
+	entries, err := os.ReadDir(c.cfg.Dir)
+	if err != nil {
+		return nil, nil
+	}
+	var files []string
+	var sizes []int64
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".pb" {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, filepath.Join(c.cfg.Dir, e.Name()))
+		sizes = append(sizes, info.Size())
+	}
+	sort.Strings(files)
+	return files, sizes
+}
+
+func (c *WALClient) replayLoop() {
+	defer close(c.done)
+
+	backoff := c.cfg.MinBackoff
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-c.wake:
+		case <-timer.C:
+		}
+
+		if c.replay() {
+			// Drained: new failures wake the loop, so only poll occasionally
+			backoff = c.cfg.MinBackoff
+			timer.Reset(c.cfg.MaxBackoff)
+			continue
+		}
+		timer.Reset(backoff)
+		backoff = min(backoff*2, c.cfg.MaxBackoff)
+	}
+}
+
+// replay uploads buffered batches oldest first and stops at the first
+// failure. It reports whether the buffer was fully drained.
+func (c *WALClient) replay() bool {
+	c.mu.Lock()
+	files, _ := c.files()
+	c.mu.Unlock()
+
+	for _, file := range files {
+		select {
+		case <-c.stop:
+			return false
+		default:
+		}
+
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		var td tracepb.TracesData
+		if err := proto.Unmarshal(data, &td); err != nil {
+			otel.Handle(fmt.Errorf("discarding corrupt trace WAL entry %s: %w", filepath.Base(file), err))
+			os.Remove(file)
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err = c.inner.UploadTraces(ctx, td.ResourceSpans)
+		cancel()
+		if err != nil {
+			return false
+		}
+
+		c.mu.Lock()
+		os.Remove(file)
+		c.mu.Unlock()
+	}
+	return true
+}
@@ -0,0 +1,115 @@
+package observability
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+type flakyClient struct {
+	mu       sync.Mutex
+	down     bool
+	uploaded []string
+}
+
+func (c *flakyClient) Start(context.Context) error { return nil }
+func (c *flakyClient) Stop(context.Context) error  { return nil }
+
+func (c *flakyClient) UploadTraces(_ context.Context, spans []*tracepb.ResourceSpans) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.down {
+		return errors.New("connection refused")
+	}
+	for _, rs := range spans {
+		for _, ss := range rs.ScopeSpans {
+			for _, s := range ss.Spans {
+				c.uploaded = append(c.uploaded, s.Name)
+			}
+		}
+	}
+	return nil
+}
+
+func (c *flakyClient) setDown(down bool) {
+	c.mu.Lock()
+	c.down = down
+	c.mu.Unlock()
+}
+
+func (c *flakyClient) names() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.uploaded...)
+}
+
+func batch(name string) []*tracepb.ResourceSpans {
+	return []*tracepb.ResourceSpans{{
+		ScopeSpans: []*tracepb.ScopeSpans{{Spans: []*tracepb.Span{{Name: name}}}},
+	}}
+}
+
+func TestWALClient_ReplaysInOrderAfterOutage(t *testing.T) {
+	inner := &flakyClient{down: true}
+	wal, err := NewWALClient(inner, WALConfig{
+		Dir:        t.TempDir(),
+		MinBackoff: 10 * time.Millisecond,
+		MaxBackoff: 20 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create WAL client: %v", err)
+	}
+	ctx := context.Background()
+	if err := wal.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer wal.Stop(ctx)
+
+	for _, name := range []string{"first", "second"} {
+		if err := wal.UploadTraces(ctx, batch(name)); err != nil {
+			t.Fatalf("Expected the batch to be buffered, got %v", err)
+		}
+	}
+	if wal.Pending() != 2 {
+		t.Fatalf("Expected 2 pending batches, got %d", wal.Pending())
+	}
+
+	inner.setDown(false)
+	deadline := time.Now().Add(2 * time.Second)
+	for wal.Pending() > 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	got := inner.names()
+	if len(got) != 2 || got[0] != "first" || got[1] != "second" {
+		t.Errorf("Expected batches replayed in order, got %v", got)
+	}
+}
+
+func TestWALClient_DropsOldestWhenFull(t *testing.T) {
+	dir := t.TempDir()
+	inner := &flakyClient{down: true}
+	size := int64(proto.Size(&tracepb.TracesData{ResourceSpans: batch("old")}))
+	wal, err := NewWALClient(inner, WALConfig{Dir: dir, MaxBytes: size})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wal.UploadTraces(context.Background(), batch("old"))
+	wal.UploadTraces(context.Background(), batch("new"))
+
+	if wal.Pending() != 1 {
+		t.Fatalf("Expected the size limit to keep 1 batch, got %d pending", wal.Pending())
+	}
+
+	inner.setDown(false)
+	wal.replay()
+	if got := inner.names(); len(got) != 1 || got[0] != "new" {
+		t.Errorf("Expected only the newest batch to survive, got %v", got)
+	}
+}
@@ -0,0 +1,140 @@
+package orders
+
+import (
+	"container/list"
+	"context"
+	"go-observability-demo/internal/config"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// CacheConfig bounds the order cache
+type CacheConfig struct {
+	Size int
+	TTL  time.Duration
+}
+
+// CacheConfigFromEnv reads ORDER_CACHE_SIZE and ORDER_CACHE_TTL; a size of
+// 0 turns the cache off
+func CacheConfigFromEnv() CacheConfig {
+	return CacheConfig{
+		Size: config.Int("ORDER_CACHE_SIZE", 1000),
+		TTL:  config.Duration("ORDER_CACHE_TTL", 30*time.Second),
+	}
+}
+
+type cached struct {
+	order   Order
+	expires time.Time
+}
+
+// CachedStore keeps recently read orders in front of another Store. Get
+// is an "orders cache get" span with cache.hit, followed by the store's own
+// span on a miss. Writes through the cache drop the cached copy; lists and
+// ranges always go to the store.
+type CachedStore struct {
+	Store
+	cfg    CacheConfig
+	tracer trace.Tracer
+
+	mu    sync.Mutex
+	lru   *list.List // of string IDs, most recent first
+	items map[string]*list.Element
+	data  map[string]cached
+}
+
+// NewCachedStore caches store, or returns it unchanged when cfg.Size is 0
+func NewCachedStore(store Store, cfg CacheConfig) Store {
+	if cfg.Size <= 0 {
+		return store
+	}
+	return &CachedStore{
+		Store:  store,
+		cfg:    cfg,
+		tracer: otel.Tracer("order-service/orders"),
+		lru:    list.New(),
+		items:  make(map[string]*list.Element),
+		data:   make(map[string]cached),
+	}
+}
+
+func (c *CachedStore) Get(ctx context.Context, id string) (Order, error) {
+	ctx, span := c.tracer.Start(ctx, "orders cache get", trace.WithAttributes(
+		attribute.String("cache.name", "orders"),
+	))
+	defer span.End()
+
+	if o, ok := c.lookup(id); ok {
+		span.SetAttributes(attribute.Bool("cache.hit", true))
+		return o, nil
+	}
+	span.SetAttributes(attribute.Bool("cache.hit", false))
+
+	o, err := c.Store.Get(ctx, id)
+	if err != nil {
+		return Order{}, err
+	}
+	c.add(o)
+	return o, nil
+}
+
+// Save drops the cached copy after writing, so the next Get reads the
+// order back from the store
+func (c *CachedStore) Save(ctx context.Context, o Order) error {
+	err := c.Store.Save(ctx, o)
+	c.remove(o.ID)
+	return err
+}
+
+func (c *CachedStore) Delete(ctx context.Context, id string) error {
+	err := c.Store.Delete(ctx, id)
+	c.remove(id)
+	return err
+}
+
+func (c *CachedStore) lookup(id string) (Order, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.data[id]
+	if !ok {
+		return Order{}, false
+	}
+	if time.Now().After(e.expires) {
+		c.removeLocked(id)
+		return Order{}, false
+	}
+	c.lru.MoveToFront(c.items[id])
+	return e.order, true
+}
+
+func (c *CachedStore) add(o Order) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[o.ID]; ok {
+		c.lru.MoveToFront(el)
+	} else {
+		c.items[o.ID] = c.lru.PushFront(o.ID)
+	}
+	c.data[o.ID] = cached{order: o, expires: time.Now().Add(c.cfg.TTL)}
+	for c.lru.Len() > c.cfg.Size {
+		c.removeLocked(c.lru.Back().Value.(string))
+	}
+}
+
+func (c *CachedStore) remove(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.removeLocked(id)
+}
+
+func (c *CachedStore) removeLocked(id string) {
+	if el, ok := c.items[id]; ok {
+		c.lru.Remove(el)
+		delete(c.items, id)
+	}
+	delete(c.data, id)
+}
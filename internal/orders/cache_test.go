@@ -0,0 +1,85 @@
+package orders
+
+import (
+	"context"
+	"errors"
+	"go-observability-demo/internal/observability/observabilitytest"
+	"testing"
+	"time"
+)
+
+func cacheHits(recorder *observabilitytest.Recorder) []bool {
+	var hits []bool
+	for _, span := range recorder.SpansNamed("orders cache get") {
+		for _, attr := range span.Attributes {
+			if attr.Key == "cache.hit" {
+				hits = append(hits, attr.Value.AsBool())
+			}
+		}
+	}
+	return hits
+}
+
+func TestCachedStore_ServesRepeatReads(t *testing.T) {
+	recorder := observabilitytest.New(t)
+	ctx := context.Background()
+	store := NewCachedStore(NewMemoryStore(), CacheConfig{Size: 10, TTL: time.Minute})
+	if err := store.Save(ctx, Order{ID: "o1", UserID: "alice", Status: StatusPlaced}); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	for range 2 {
+		if o, err := store.Get(ctx, "o1"); err != nil || o.UserID != "alice" {
+			t.Fatalf("Expected alice's order, got %+v (%v)", o, err)
+		}
+	}
+	if hits := cacheHits(recorder); len(hits) != 2 || hits[0] || !hits[1] {
+		t.Errorf("Expected a miss then a hit, got %v", hits)
+	}
+	if got := len(recorder.SpansNamed("orders select")); got != 1 {
+		t.Errorf("Expected one store read, got %d", got)
+	}
+
+	// Writes drop the cached copy
+	if err := store.Save(ctx, Order{ID: "o1", UserID: "alice", Status: "cancelled"}); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if o, _ := store.Get(ctx, "o1"); o.Status != "cancelled" {
+		t.Errorf("Expected the saved status, got %q", o.Status)
+	}
+	if err := store.Delete(ctx, "o1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := store.Get(ctx, "o1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound after delete, got %v", err)
+	}
+}
+
+func TestCachedStore_EvictsAndExpires(t *testing.T) {
+	ctx := context.Background()
+	cache := NewCachedStore(NewMemoryStore(), CacheConfig{Size: 2, TTL: time.Minute}).(*CachedStore)
+	for _, id := range []string{"a", "b", "c"} {
+		cache.Save(ctx, Order{ID: id})
+		cache.Get(ctx, id)
+	}
+	if _, ok := cache.lookup("a"); ok {
+		t.Error("Expected the least recently read order to be evicted")
+	}
+	if _, ok := cache.lookup("c"); !ok {
+		t.Error("Expected the latest order to be cached")
+	}
+
+	cache.cfg.TTL = -time.Second
+	cache.Save(ctx, Order{ID: "d"})
+	cache.Get(ctx, "d")
+	if _, ok := cache.lookup("d"); ok {
+		t.Error("Expected an expired order to be dropped")
+	}
+}
+
+func TestNewCachedStore_Disabled(t *testing.T) {
+	mem := NewMemoryStore()
+	if store := NewCachedStore(mem, CacheConfig{}); store != Store(mem) {
+		t.Errorf("Expected the store itself with a zero size, got %T", store)
+	}
+}
@@ -0,0 +1,296 @@
+package orders
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"go-observability-demo/internal/config"
+	"iter"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	ErrNotFound      = errors.New("order not found")
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// Order is a placed order as stored
+type Order struct {
+	ID        string    `json:"order_id"`
+	UserID    string    `json:"user_id"`
+	ProductID string    `json:"product_id"`
+	Quantity  int       `json:"quantity"`
+	Amount    float64   `json:"amount"`
+	Currency  string    `json:"currency"`
+	Status    string    `json:"status"`
+	TraceID   string    `json:"trace_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// CancelledAt is set once the order is cancelled
+	CancelledAt time.Time `json:"cancelled_at,omitzero"`
+}
+
+const (
+	StatusPlaced    = "placed"
+	StatusCancelled = "cancelled"
+)
+
+// Page asks for up to Limit orders after Cursor, which is the NextCursor
+// of the previous page or empty for the first one
+type Page struct {
+	Limit  int
+	Cursor string
+}
+
+// Filter selects orders created in [From, To) with the given status; zero
+// values match everything
+type Filter struct {
+	From, To time.Time
+	Status   string
+}
+
+func (f Filter) matches(o Order) bool {
+	return (f.From.IsZero() || !o.CreatedAt.Before(f.From)) &&
+		(f.To.IsZero() || o.CreatedAt.Before(f.To)) &&
+		(f.Status == "" || o.Status == f.Status)
+}
+
+// Store keeps orders with an index by user. List and ListByUser return the
+// newest orders first and a cursor for the next page, empty on the last one.
+// Range yields matching orders oldest first. Orders without a user ID, such
+// as anonymized ones, are not in the user index.
+type Store interface {
+	Save(ctx context.Context, o Order) error
+	Delete(ctx context.Context, id string) error
+	Get(ctx context.Context, id string) (Order, error)
+	List(ctx context.Context, page Page) (orders []Order, next string, err error)
+	ListByUser(ctx context.Context, userID string, page Page) (orders []Order, next string, err error)
+	Range(ctx context.Context, f Filter) iter.Seq2[Order, error]
+}
+
+type entry struct {
+	seq   uint64
+	order Order
+}
+
+// MemoryStore keeps the most recent MaxOrders orders in process, indexed by
+// ID and by user. Each call is a client span like a database query would
+// be, so the store can be swapped without changing the traces.
+type MemoryStore struct {
+	maxOrders int
+	tracer    trace.Tracer
+
+	mu     sync.RWMutex
+	seq    uint64
+	byID   map[string]*entry
+	byUser map[string][]*entry // oldest first
+	all    []*entry            // oldest first, for eviction
+}
+
+// NewMemoryStore keeps up to ORDER_STORE_MAX_ORDERS orders
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		maxOrders: config.Int("ORDER_STORE_MAX_ORDERS", 100000),
+		tracer:    otel.Tracer("order-service/orders"),
+		byID:      make(map[string]*entry),
+		byUser:    make(map[string][]*entry),
+	}
+}
+
+func (s *MemoryStore) startSpan(ctx context.Context, op string) (context.Context, trace.Span) {
+	return s.tracer.Start(ctx, "orders "+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system.name", "memory"),
+			attribute.String("db.collection.name", "orders"),
+			attribute.String("db.operation.name", op),
+		),
+	)
+}
+
+func (s *MemoryStore) Save(ctx context.Context, o Order) error {
+	_, span := s.startSpan(ctx, "insert")
+	defer span.End()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.byID[o.ID]; ok {
+		if e.order.UserID != o.UserID {
+			s.unindexUser(e)
+			e.order = o
+			s.indexUser(e)
+		}
+		e.order = o
+		return nil
+	}
+	s.seq++
+	e := &entry{seq: s.seq, order: o}
+	s.byID[o.ID] = e
+	s.indexUser(e)
+	s.all = append(s.all, e)
+
+	for s.maxOrders > 0 && len(s.all) > s.maxOrders {
+		oldest := s.all[0]
+		s.all[0] = nil
+		s.all = s.all[1:]
+		delete(s.byID, oldest.order.ID)
+		s.unindexUser(oldest)
+	}
+	return nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, id string) error {
+	_, span := s.startSpan(ctx, "delete")
+	defer span.End()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.byID[id]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(s.byID, id)
+	s.unindexUser(e)
+	if i, found := search(s.all, e.seq); found {
+		s.all = slices.Delete(s.all, i, i+1)
+	}
+	return nil
+}
+
+// indexUser and unindexUser keep each user's entries in sequence order
+func (s *MemoryStore) indexUser(e *entry) {
+	userID := e.order.UserID
+	if userID == "" {
+		return
+	}
+	index := s.byUser[userID]
+	i, _ := search(index, e.seq)
+	s.byUser[userID] = slices.Insert(index, i, e)
+}
+
+func (s *MemoryStore) unindexUser(e *entry) {
+	userID := e.order.UserID
+	index := s.byUser[userID]
+	i, found := search(index, e.seq)
+	if !found {
+		return
+	}
+	if len(index) == 1 {
+		delete(s.byUser, userID)
+		return
+	}
+	s.byUser[userID] = slices.Delete(index, i, i+1)
+}
+
+func search(entries []*entry, seq uint64) (int, bool) {
+	i := sort.Search(len(entries), func(i int) bool { return entries[i].seq >= seq })
+	return i, i < len(entries) && entries[i].seq == seq
+}
+
+func (s *MemoryStore) Get(ctx context.Context, id string) (Order, error) {
+	_, span := s.startSpan(ctx, "select")
+	defer span.End()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	e, ok := s.byID[id]
+	if !ok {
+		return Order{}, ErrNotFound
+	}
+	return e.order, nil
+}
+
+func (s *MemoryStore) ListByUser(ctx context.Context, userID string, page Page) ([]Order, string, error) {
+	_, span := s.startSpan(ctx, "select")
+	defer span.End()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return paginate(span, s.byUser[userID], page)
+}
+
+func (s *MemoryStore) List(ctx context.Context, page Page) ([]Order, string, error) {
+	_, span := s.startSpan(ctx, "select")
+	defer span.End()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return paginate(span, s.all, page)
+}
+
+// paginate returns the page of index, which is in sequence order, newest
+// first
+func paginate(span trace.Span, index []*entry, page Page) ([]Order, string, error) {
+	before := uint64(0)
+	if page.Cursor != "" {
+		var err error
+		if before, err = decodeCursor(page.Cursor); err != nil {
+			return nil, "", err
+		}
+	}
+
+	// The page ends just before the cursor
+	end := len(index)
+	if before > 0 {
+		end, _ = search(index, before)
+	}
+
+	orders := make([]Order, 0, min(page.Limit, end))
+	for i := end - 1; i >= 0 && len(orders) < page.Limit; i-- {
+		orders = append(orders, index[i].order)
+	}
+	span.SetAttributes(attribute.Int("db.response.returned_rows", len(orders)))
+
+	var next string
+	if start := end - len(orders); start > 0 && len(orders) > 0 {
+		next = encodeCursor(index[start].seq)
+	}
+	return orders, next, nil
+}
+
+func (s *MemoryStore) Range(ctx context.Context, f Filter) iter.Seq2[Order, error] {
+	return func(yield func(Order, error) bool) {
+		_, span := s.startSpan(ctx, "select")
+		defer span.End()
+
+		// Copy the matches so the lock is not held while the caller works
+		s.mu.RLock()
+		var matched []Order
+		for _, e := range s.all {
+			if f.matches(e.order) {
+				matched = append(matched, e.order)
+			}
+		}
+		s.mu.RUnlock()
+		span.SetAttributes(attribute.Int("db.response.returned_rows", len(matched)))
+
+		for _, o := range matched {
+			if !yield(o, nil) {
+				return
+			}
+		}
+	}
+}
+
+func encodeCursor(seq uint64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatUint(seq, 10)))
+}
+
+func decodeCursor(cursor string) (uint64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	seq, err := strconv.ParseUint(string(raw), 10, 64)
+	if err != nil || seq == 0 {
+		return 0, ErrInvalidCursor
+	}
+	return seq, nil
+}
@@ -0,0 +1,134 @@
+package orders
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func seed(t *testing.T, s *MemoryStore, userID string, n int) {
+	t.Helper()
+	for i := range n {
+		if err := s.Save(context.Background(), Order{ID: fmt.Sprintf("%s-%d", userID, i), UserID: userID}); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+}
+
+func TestMemoryStore_PaginatesNewestFirst(t *testing.T) {
+	s := NewMemoryStore()
+	seed(t, s, "alice", 5)
+	seed(t, s, "bob", 2)
+	ctx := context.Background()
+
+	var got []string
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatal("Pagination did not terminate")
+		}
+		list, next, err := s.ListByUser(ctx, "alice", Page{Limit: 2, Cursor: cursor})
+		if err != nil {
+			t.Fatalf("ListByUser failed: %v", err)
+		}
+		for _, o := range list {
+			got = append(got, o.ID)
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	want := "[alice-4 alice-3 alice-2 alice-1 alice-0]"
+	if fmt.Sprint(got) != want {
+		t.Errorf("Expected %s, got %v", want, got)
+	}
+	if _, _, err := s.ListByUser(ctx, "alice", Page{Limit: 2, Cursor: "not-a-cursor"}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+func TestMemoryStore_EvictsOldest(t *testing.T) {
+	s := NewMemoryStore()
+	s.maxOrders = 3
+	seed(t, s, "alice", 2)
+	seed(t, s, "bob", 2)
+	ctx := context.Background()
+
+	if _, err := s.Get(ctx, "alice-0"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected the oldest order to be evicted, got %v", err)
+	}
+	list, _, _ := s.ListByUser(ctx, "alice", Page{Limit: 10})
+	if len(list) != 1 || list[0].ID != "alice-1" {
+		t.Errorf("Expected only alice-1 to remain, got %+v", list)
+	}
+}
+
+func TestMemoryStore_RangeFilters(t *testing.T) {
+	s := NewMemoryStore()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+	for i := range 4 {
+		status := StatusPlaced
+		if i == 2 {
+			status = "cancelled"
+		}
+		s.Save(ctx, Order{ID: fmt.Sprint(i), UserID: "u", Status: status, CreatedAt: base.Add(time.Duration(i) * time.Hour)})
+	}
+
+	var got []string
+	for o, err := range s.Range(ctx, Filter{From: base.Add(time.Hour), To: base.Add(4 * time.Hour), Status: StatusPlaced}) {
+		if err != nil {
+			t.Fatalf("Range failed: %v", err)
+		}
+		got = append(got, o.ID)
+	}
+	if fmt.Sprint(got) != "[1 3]" {
+		t.Errorf("Expected [1 3], got %v", got)
+	}
+}
+
+func TestMemoryStore_AnonymizeAndDeleteUpdateIndexes(t *testing.T) {
+	s := NewMemoryStore()
+	seed(t, s, "alice", 3)
+	ctx := context.Background()
+
+	o, _ := s.Get(ctx, "alice-1")
+	o.UserID = ""
+	s.Save(ctx, o)
+	if err := s.Delete(ctx, "alice-2"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	list, _, _ := s.ListByUser(ctx, "alice", Page{Limit: 10})
+	if len(list) != 1 || list[0].ID != "alice-0" {
+		t.Errorf("Expected only alice-0 left in alice's orders, got %+v", list)
+	}
+	if _, err := s.Get(ctx, "alice-1"); err != nil {
+		t.Errorf("Expected the anonymized order to be kept, got %v", err)
+	}
+	if err := s.Delete(ctx, "alice-2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestMemoryStore_ListsEveryUser(t *testing.T) {
+	s := NewMemoryStore()
+	seed(t, s, "alice", 2)
+	seed(t, s, "bob", 1)
+
+	first, next, err := s.List(context.Background(), Page{Limit: 2})
+	if err != nil || len(first) != 2 || next == "" {
+		t.Fatalf("Expected a page of 2 with a cursor, got %v %q (%v)", first, next, err)
+	}
+	rest, next, _ := s.List(context.Background(), Page{Limit: 2, Cursor: next})
+	if len(rest) != 1 || next != "" {
+		t.Fatalf("Expected a last page of 1, got %v %q", rest, next)
+	}
+	if got := []string{first[0].ID, first[1].ID, rest[0].ID}; got[0] != "bob-0" || got[1] != "alice-1" || got[2] != "alice-0" {
+		t.Errorf("Expected newest first across users, got %v", got)
+	}
+}
@@ -0,0 +1,282 @@
+package pricing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-observability-demo/internal/config"
+	"go-observability-demo/internal/fx"
+	"math"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	ErrUnknownProduct = errors.New("unknown product")
+	ErrInvalidPromo   = errors.New("invalid promo code")
+)
+
+// Promo is a discount code, either a percentage or a fixed amount off
+type Promo struct {
+	Percent float64
+	Off     float64
+}
+
+// Config holds the catalog, promo codes, and tax rate
+type Config struct {
+	Prices  map[string]float64
+	Promos  map[string]Promo
+	TaxRate float64
+}
+
+// Prices of the products the load generator and Makefile order
+var defaultCatalog = []string{"prod-123=29.99", "prod-456=49.50", "prod-789=79.95", "prod-321=129.00", "prod-vip=999.99"}
+
+// ConfigFromEnv reads PRICING_CATALOG ("prod-123=29.99,..."), PRICING_PROMOS
+// ("SAVE10=10%,FIVEOFF=5"), and PRICING_TAX_RATE (e.g. 0.08)
+func ConfigFromEnv() Config {
+	cfg := Config{
+		Prices:  map[string]float64{},
+		Promos:  map[string]Promo{},
+		TaxRate: config.Float("PRICING_TAX_RATE", 0),
+	}
+	for _, entry := range config.List("PRICING_CATALOG", defaultCatalog) {
+		id, raw, ok := strings.Cut(entry, "=")
+		if price, err := strconv.ParseFloat(strings.TrimSpace(raw), 64); ok && err == nil && price > 0 {
+			cfg.Prices[strings.TrimSpace(id)] = price
+		}
+	}
+	for _, entry := range config.List("PRICING_PROMOS", []string{"SAVE10=10%"}) {
+		code, raw, ok := strings.Cut(entry, "=")
+		if !ok {
+			continue
+		}
+		raw = strings.TrimSpace(raw)
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if pct, found := strings.CutSuffix(raw, "%"); found {
+			if p, err := strconv.ParseFloat(pct, 64); err == nil && p > 0 && p <= 100 {
+				cfg.Promos[code] = Promo{Percent: p}
+			}
+		} else if off, err := strconv.ParseFloat(raw, 64); err == nil && off > 0 {
+			cfg.Promos[code] = Promo{Off: off}
+		}
+	}
+	return cfg
+}
+
+// Order is what the client asked for. Amount is the client's own total in
+// Currency; it is never charged, only compared with ours. Quote compares it
+// only when Currency is empty or fx.ReportingCurrency, the catalog's
+// currency; for other currencies see CheckClientAmount.
+type Order struct {
+	ProductID string
+	Quantity  int
+	PromoCode string
+	Amount    float64
+	Currency  string
+}
+
+// Quote is the server-side price of an order
+type Quote struct {
+	UnitPrice float64
+	Subtotal  float64
+	Discount  float64
+	Tax       float64
+	Total     float64
+	PromoCode string
+}
+
+// Rule is one step of pricing, applied to the quote in order
+type Rule interface {
+	Name() string
+	Apply(ctx context.Context, o Order, q *Quote) error
+}
+
+// Engine prices orders by running its rules, each in its own span, so a
+// trace shows how the total was reached. A nil *Engine charges the
+// client-provided amount.
+type Engine struct {
+	rules  []Rule
+	tracer trace.Tracer
+
+	discounts      metric.Int64Counter
+	discountAmount metric.Float64Counter
+	mismatches     metric.Int64Counter
+}
+
+func New(cfg Config, meter metric.Meter) (*Engine, error) {
+	discounts, err := meter.Int64Counter(
+		"pricing.discounts",
+		metric.WithDescription("Promo code uses, by code and outcome (applied, rejected)"),
+		metric.WithUnit("{use}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	discountAmount, err := meter.Float64Counter(
+		"pricing.discount_amount",
+		metric.WithDescription("Total discount given, by promo code"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	mismatches, err := meter.Int64Counter(
+		"pricing.client_amount_mismatches",
+		metric.WithDescription("Orders whose client-provided amount differed from the server price"),
+		metric.WithUnit("{order}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &Engine{
+		tracer:         otel.Tracer("order-service/pricing"),
+		discounts:      discounts,
+		discountAmount: discountAmount,
+		mismatches:     mismatches,
+	}
+	e.rules = []Rule{
+		basePrice{prices: cfg.Prices},
+		promoCode{promos: cfg.Promos, engine: e},
+		tax{rate: cfg.TaxRate},
+	}
+	return e, nil
+}
+
+// Quote prices the order. Errors wrap ErrUnknownProduct or ErrInvalidPromo.
+func (e *Engine) Quote(ctx context.Context, o Order) (Quote, error) {
+	if e == nil {
+		return Quote{Subtotal: o.Amount, Total: o.Amount}, nil
+	}
+
+	ctx, span := e.tracer.Start(ctx, "PriceOrder", trace.WithAttributes(
+		attribute.String("product.id", o.ProductID),
+		attribute.Int("order.quantity", o.Quantity),
+	))
+	defer span.End()
+
+	var q Quote
+	for _, rule := range e.rules {
+		if err := e.apply(ctx, rule, o, &q); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "pricing failed")
+			return Quote{}, err
+		}
+	}
+	q.Total = round(q.Subtotal - q.Discount + q.Tax)
+
+	span.SetAttributes(attribute.Float64("pricing.total", q.Total))
+	if o.Currency == "" || strings.EqualFold(o.Currency, fx.ReportingCurrency) {
+		e.CheckClientAmount(ctx, o.Amount, q.Total)
+	}
+	return q, nil
+}
+
+// CheckClientAmount counts a client amount that differs from ours, both in
+// the same currency, in pricing.client_amount_mismatches. A zero client
+// amount is not compared.
+func (e *Engine) CheckClientAmount(ctx context.Context, client, ours float64) {
+	if e == nil || client <= 0 || math.Abs(client-ours) < 0.005 {
+		return
+	}
+	trace.SpanFromContext(ctx).AddEvent("client_amount_mismatch", trace.WithAttributes(
+		attribute.Float64("pricing.client_amount", client),
+		attribute.Float64("pricing.amount", ours),
+	))
+	e.mismatches.Add(ctx, 1)
+}
+
+func (e *Engine) apply(ctx context.Context, rule Rule, o Order, q *Quote) error {
+	ctx, span := e.tracer.Start(ctx, "PricingRule "+rule.Name(), trace.WithAttributes(
+		attribute.String("pricing.rule", rule.Name()),
+	))
+	defer span.End()
+
+	before := q.Subtotal - q.Discount + q.Tax
+	if err := rule.Apply(ctx, o, q); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	span.SetAttributes(
+		attribute.Float64("pricing.amount_before", round(before)),
+		attribute.Float64("pricing.amount_after", round(q.Subtotal-q.Discount+q.Tax)),
+	)
+	return nil
+}
+
+type basePrice struct {
+	prices map[string]float64
+}
+
+func (basePrice) Name() string { return "base_price" }
+
+func (r basePrice) Apply(_ context.Context, o Order, q *Quote) error {
+	price, ok := r.prices[o.ProductID]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownProduct, o.ProductID)
+	}
+	q.UnitPrice = price
+	q.Subtotal = round(price * float64(o.Quantity))
+	return nil
+}
+
+type promoCode struct {
+	promos map[string]Promo
+	engine *Engine
+}
+
+func (promoCode) Name() string { return "promo_code" }
+
+func (r promoCode) Apply(ctx context.Context, o Order, q *Quote) error {
+	if o.PromoCode == "" {
+		return nil
+	}
+	code := strings.ToUpper(o.PromoCode)
+	promo, ok := r.promos[code]
+	if !ok {
+		// Unknown codes share one label so guessing can't grow the series
+		r.engine.discounts.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("promo.code", "unknown"),
+			attribute.String("outcome", "rejected"),
+		))
+		return fmt.Errorf("%w: %s", ErrInvalidPromo, o.PromoCode)
+	}
+
+	discount := promo.Off + q.Subtotal*promo.Percent/100
+	q.Discount = round(min(discount, q.Subtotal))
+	q.PromoCode = code
+
+	attrs := metric.WithAttributes(attribute.String("promo.code", code))
+	r.engine.discounts.Add(ctx, 1, attrs, metric.WithAttributes(attribute.String("outcome", "applied")))
+	r.engine.discountAmount.Add(ctx, q.Discount, attrs)
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.String("promo.code", code),
+		attribute.Float64("pricing.discount", q.Discount),
+	)
+	return nil
+}
+
+type tax struct {
+	rate float64
+}
+
+func (tax) Name() string { return "tax" }
+
+func (r tax) Apply(_ context.Context, _ Order, q *Quote) error {
+	q.Tax = round((q.Subtotal - q.Discount) * r.rate)
+	return nil
+}
+
+// round to cents
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
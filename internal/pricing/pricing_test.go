@@ -0,0 +1,122 @@
+package pricing
+
+import (
+	"context"
+	"errors"
+	"go-observability-demo/internal/observability/observabilitytest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func newTestEngine(t *testing.T) (*Engine, *observabilitytest.Recorder) {
+	t.Helper()
+	recorder := observabilitytest.New(t)
+	e, err := New(Config{
+		Prices:  map[string]float64{"prod-123": 29.99},
+		Promos:  map[string]Promo{"SAVE10": {Percent: 10}, "FIVEOFF": {Off: 5}},
+		TaxRate: 0.08,
+	}, otel.Meter("test"))
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	return e, recorder
+}
+
+func TestEngine_QuoteAppliesRulesInOrder(t *testing.T) {
+	e, recorder := newTestEngine(t)
+
+	q, err := e.Quote(context.Background(), Order{ProductID: "prod-123", Quantity: 2, PromoCode: "save10", Amount: 1})
+	if err != nil {
+		t.Fatalf("Quote failed: %v", err)
+	}
+	// 59.98 - 6.00 discount + 4.32 tax
+	if q.Subtotal != 59.98 || q.Discount != 6 || q.Tax != 4.32 || q.Total != 58.30 {
+		t.Errorf("Unexpected quote: %+v", q)
+	}
+
+	for _, name := range []string{"PricingRule base_price", "PricingRule promo_code", "PricingRule tax"} {
+		if got := len(recorder.SpansNamed(name)); got != 1 {
+			t.Errorf("Expected 1 %s span, got %d", name, got)
+		}
+	}
+	root := recorder.SpansNamed("PriceOrder")[0]
+	if len(root.Events) != 1 || root.Events[0].Name != "client_amount_mismatch" {
+		t.Errorf("Expected a client_amount_mismatch event, got %v", root.Events)
+	}
+
+	m, _ := recorder.Metric(t, "pricing.discounts")
+	points := m.Data.(metricdata.Sum[int64]).DataPoints
+	applied := attribute.NewSet(attribute.String("promo.code", "SAVE10"), attribute.String("outcome", "applied"))
+	if len(points) != 1 || !points[0].Attributes.Equals(&applied) {
+		t.Errorf("Expected one applied SAVE10 use, got %+v", points)
+	}
+}
+
+func TestEngine_QuoteComparesOnlyCatalogCurrency(t *testing.T) {
+	e, recorder := newTestEngine(t)
+
+	// 29.99 + 8% tax in USD; a EUR amount cannot be compared with it
+	if _, err := e.Quote(context.Background(), Order{ProductID: "prod-123", Quantity: 1, Amount: 29.81, Currency: "EUR"}); err != nil {
+		t.Fatalf("Quote failed: %v", err)
+	}
+	if _, err := e.Quote(context.Background(), Order{ProductID: "prod-123", Quantity: 1, Amount: 32.39, Currency: "usd"}); err != nil {
+		t.Fatalf("Quote failed: %v", err)
+	}
+	for _, span := range recorder.SpansNamed("PriceOrder") {
+		if len(span.Events) != 0 {
+			t.Errorf("Expected no mismatch, got %v", span.Events)
+		}
+	}
+
+	e.CheckClientAmount(context.Background(), 29.81, 27.6)
+	if got := recorder.Int64Sum(t, "pricing.client_amount_mismatches"); got != 1 {
+		t.Errorf("Expected 1 mismatch, got %d", got)
+	}
+}
+
+func TestEngine_QuoteRejectsUnknownProductAndPromo(t *testing.T) {
+	e, recorder := newTestEngine(t)
+	ctx := context.Background()
+
+	if _, err := e.Quote(ctx, Order{ProductID: "prod-999", Quantity: 1}); !errors.Is(err, ErrUnknownProduct) {
+		t.Errorf("Expected ErrUnknownProduct, got %v", err)
+	}
+	if _, err := e.Quote(ctx, Order{ProductID: "prod-123", Quantity: 1, PromoCode: "FREE100"}); !errors.Is(err, ErrInvalidPromo) {
+		t.Errorf("Expected ErrInvalidPromo, got %v", err)
+	}
+
+	m, _ := recorder.Metric(t, "pricing.discounts")
+	rejected := attribute.NewSet(attribute.String("promo.code", "unknown"), attribute.String("outcome", "rejected"))
+	if points := m.Data.(metricdata.Sum[int64]).DataPoints; len(points) != 1 || !points[0].Attributes.Equals(&rejected) {
+		t.Errorf("Expected the guessed code to be counted as unknown, got %+v", points)
+	}
+}
+
+func TestEngine_FixedDiscountNeverExceedsSubtotal(t *testing.T) {
+	e, _ := newTestEngine(t)
+	e.rules[0] = basePrice{prices: map[string]float64{"sticker": 2}}
+
+	q, err := e.Quote(context.Background(), Order{ProductID: "sticker", Quantity: 1, PromoCode: "FIVEOFF"})
+	if err != nil {
+		t.Fatalf("Quote failed: %v", err)
+	}
+	if q.Discount != 2 || q.Total != 0 {
+		t.Errorf("Expected the discount capped at the subtotal, got %+v", q)
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("PRICING_CATALOG", "a=1.50, b=oops")
+	t.Setenv("PRICING_PROMOS", "half=50%,TENOFF=10,bad=0%")
+
+	cfg := ConfigFromEnv()
+	if len(cfg.Prices) != 1 || cfg.Prices["a"] != 1.5 {
+		t.Errorf("Unexpected catalog: %v", cfg.Prices)
+	}
+	if len(cfg.Promos) != 2 || cfg.Promos["HALF"].Percent != 50 || cfg.Promos["TENOFF"].Off != 10 {
+		t.Errorf("Unexpected promos: %v", cfg.Promos)
+	}
+}
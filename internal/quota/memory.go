@@ -0,0 +1,58 @@
+package quota
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type window struct {
+	count   int64
+	resetAt time.Time
+}
+
+// MemoryStore keeps counters in process, so each instance enforces its own
+// share of the quota. Use a shared store when running more than one replica.
+type MemoryStore struct {
+	mu      sync.Mutex
+	windows map[string]*window
+	swept   time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{windows: map[string]*window{}}
+}
+
+func (s *MemoryStore) Incr(_ context.Context, key string, size time.Duration) (int64, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	// Drop expired windows now and then so idle users don't accumulate
+	if now.Sub(s.swept) >= size {
+		for k, w := range s.windows {
+			if !now.Before(w.resetAt) {
+				delete(s.windows, k)
+			}
+		}
+		s.swept = now
+	}
+
+	w, ok := s.windows[key]
+	if !ok || !now.Before(w.resetAt) {
+		w = &window{resetAt: now.Add(size)}
+		s.windows[key] = w
+	}
+	w.count++
+	return w.count, w.resetAt, nil
+}
+
+func (s *MemoryStore) Decr(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if w, ok := s.windows[key]; ok && time.Now().Before(w.resetAt) && w.count > 0 {
+		w.count--
+	}
+	return nil
+}
@@ -0,0 +1,285 @@
+package quota
+
+import (
+	"context"
+	"go-observability-demo/internal/config"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TenantHeader adds the tenant's quota to the ordering user's
+const TenantHeader = "X-Tenant-ID"
+
+// Store counts requests per key in fixed windows. Incr adds one to the
+// key's count in the current window and returns the new count and when
+// the window ends; Decr takes one back if the window is still open.
+// redisstore.Client shares the counts between replicas.
+type Store interface {
+	Incr(ctx context.Context, key string, window time.Duration) (count int64, resetAt time.Time, err error)
+	Decr(ctx context.Context, key string) error
+}
+
+// Config sets the default quota and per-key overrides
+type Config struct {
+	// Limit is the number of orders per Window; 0 disables quotas
+	Limit  int64
+	Window time.Duration
+	// Overrides replace Limit for specific keys; 0 means unlimited
+	Overrides map[string]int64
+}
+
+// ConfigFromEnv reads QUOTA_ORDERS_PER_WINDOW, QUOTA_WINDOW, and
+// QUOTA_OVERRIDES, a list of keys as returned by Keys, e.g.
+// "tenant:acme=600,user:load-test=0"
+func ConfigFromEnv() Config {
+	cfg := Config{
+		Limit:     int64(config.Int("QUOTA_ORDERS_PER_WINDOW", 0)),
+		Window:    config.Duration("QUOTA_WINDOW", time.Minute),
+		Overrides: map[string]int64{},
+	}
+	for _, entry := range config.List("QUOTA_OVERRIDES", nil) {
+		key, raw, ok := strings.Cut(entry, "=")
+		if n, err := strconv.ParseInt(strings.TrimSpace(raw), 10, 64); ok && err == nil {
+			cfg.Overrides[strings.TrimSpace(key)] = n
+		}
+	}
+	return cfg
+}
+
+func (c Config) Enabled() bool {
+	return c.Limit > 0 || len(c.Overrides) > 0
+}
+
+func (c Config) limitFor(key string) int64 {
+	if n, ok := c.Overrides[key]; ok {
+		return n
+	}
+	return c.Limit
+}
+
+// Decision is the outcome of a quota check
+type Decision struct {
+	Allowed   bool
+	Limit     int64
+	Remaining int64
+	ResetAt   time.Time
+}
+
+// SetHeaders writes the RateLimit-* headers, plus Retry-After when rejected
+func (d Decision) SetHeaders(w http.ResponseWriter) {
+	if d.Limit <= 0 {
+		return
+	}
+	reset := max(0, int64(time.Until(d.ResetAt).Round(time.Second).Seconds()))
+	h := w.Header()
+	h.Set("RateLimit-Limit", strconv.FormatInt(d.Limit, 10))
+	h.Set("RateLimit-Remaining", strconv.FormatInt(d.Remaining, 10))
+	h.Set("RateLimit-Reset", strconv.FormatInt(reset, 10))
+	if !d.Allowed {
+		h.Set("Retry-After", strconv.FormatInt(max(1, reset), 10))
+	}
+}
+
+// Quota enforces orders per window per user or tenant. Store errors fail
+// open: an unavailable counter should not stop order creation. A nil *Quota
+// allows everything.
+type Quota struct {
+	cfg    Config
+	store  Store
+	logger *slog.Logger
+
+	decisions metric.Int64Counter
+
+	// Usage seen in the current window, for the consumption gauges. With a
+	// shared store the counts are global even though only keys that hit
+	// this instance are tracked.
+	mu          sync.Mutex
+	usage       map[string]float64
+	windowStart time.Time
+}
+
+func New(cfg Config, store Store, meter metric.Meter, logger *slog.Logger) (*Quota, error) {
+	if cfg.Window <= 0 {
+		cfg.Window = time.Minute
+	}
+
+	decisions, err := meter.Int64Counter(
+		"quota.decisions",
+		metric.WithDescription("Quota checks by outcome (allowed, rejected, error)"),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	activeKeys, err := meter.Int64ObservableGauge(
+		"quota.keys.active",
+		metric.WithDescription("Users or tenants that placed orders in the current quota window"),
+		metric.WithUnit("{key}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	exhaustedKeys, err := meter.Int64ObservableGauge(
+		"quota.keys.exhausted",
+		metric.WithDescription("Users or tenants that used their whole quota in the current window"),
+		metric.WithUnit("{key}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	maxUtilization, err := meter.Float64ObservableGauge(
+		"quota.utilization.max",
+		metric.WithDescription("Highest fraction of its quota any user or tenant has used in the current window"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &Quota{
+		cfg:       cfg,
+		store:     store,
+		logger:    logger,
+		decisions: decisions,
+		usage:     map[string]float64{},
+	}
+
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		active, exhausted, highest := q.snapshot()
+		o.ObserveInt64(activeKeys, int64(active))
+		o.ObserveInt64(exhaustedKeys, int64(exhausted))
+		o.ObserveFloat64(maxUtilization, highest)
+		return nil
+	}, activeKeys, exhaustedKeys, maxUtilization)
+	if err != nil {
+		return nil, err
+	}
+
+	return q, nil
+}
+
+// Keys returns the user's key, followed by the tenant's from TenantHeader
+// if there is one. The header is set by the client, so the tenant quota is
+// only ever enforced on top of the user's, never instead of it.
+func Keys(r *http.Request, userID string) []string {
+	keys := []string{"user:" + userID}
+	if tenant := r.Header.Get(TenantHeader); tenant != "" {
+		keys = append(keys, "tenant:"+tenant)
+	}
+	return keys
+}
+
+// Allow counts one order against each key in turn and reports whether it
+// fits all their quotas. It stops at the first key over quota and refunds
+// the keys already charged, so no key pays for a rejected order; otherwise
+// it returns the decision with the least remaining.
+func (q *Quota) Allow(ctx context.Context, keys ...string) Decision {
+	if q == nil {
+		return Decision{Allowed: true}
+	}
+	tightest := Decision{Allowed: true}
+	for i, key := range keys {
+		d := q.allow(ctx, key)
+		if !d.Allowed {
+			q.refund(ctx, keys[:i])
+			return d
+		}
+		if d.Limit > 0 && (tightest.Limit <= 0 || d.Remaining < tightest.Remaining) {
+			tightest = d
+		}
+	}
+	return tightest
+}
+
+func (q *Quota) allow(ctx context.Context, key string) Decision {
+	limit := q.cfg.limitFor(key)
+	if limit <= 0 {
+		return Decision{Allowed: true}
+	}
+
+	count, resetAt, err := q.store.Incr(ctx, key, q.cfg.Window)
+	if err != nil {
+		q.decisions.Add(ctx, 1, metric.WithAttributes(attribute.String("outcome", "error")))
+		q.logger.WarnContext(ctx, "quota store unavailable, allowing request",
+			slog.String("key", key),
+			slog.String("error", err.Error()),
+		)
+		return Decision{Allowed: true}
+	}
+	q.observe(key, float64(count)/float64(limit))
+
+	d := Decision{
+		Allowed:   count <= limit,
+		Limit:     limit,
+		Remaining: max(0, limit-count),
+		ResetAt:   resetAt,
+	}
+
+	outcome := "allowed"
+	if !d.Allowed {
+		outcome = "rejected"
+		trace.SpanFromContext(ctx).AddEvent("quota_exceeded", trace.WithAttributes(
+			attribute.String("quota.key", key),
+			attribute.Int64("quota.limit", limit),
+			attribute.Int64("quota.count", count),
+		))
+	}
+	q.decisions.Add(ctx, 1, metric.WithAttributes(attribute.String("outcome", outcome)))
+	return d
+}
+
+// refund gives back the order charged to keys. A failed refund only leaves
+// the key one order short until its window ends, so it is logged and dropped.
+func (q *Quota) refund(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		if q.cfg.limitFor(key) <= 0 {
+			continue
+		}
+		if err := q.store.Decr(ctx, key); err != nil {
+			q.logger.WarnContext(ctx, "quota refund failed",
+				slog.String("key", key),
+				slog.String("error", err.Error()),
+			)
+		}
+	}
+}
+
+func (q *Quota) observe(key string, utilization float64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.rollLocked()
+	q.usage[key] = utilization
+}
+
+func (q *Quota) snapshot() (active, exhausted int, highest float64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.rollLocked()
+	for _, u := range q.usage {
+		active++
+		if u >= 1 {
+			exhausted++
+		}
+		highest = max(highest, u)
+	}
+	return active, exhausted, highest
+}
+
+// rollLocked forgets usage from earlier windows. Callers must hold q.mu.
+func (q *Quota) rollLocked() {
+	if now := time.Now(); now.Sub(q.windowStart) >= q.cfg.Window {
+		clear(q.usage)
+		q.windowStart = now.Truncate(q.cfg.Window)
+	}
+}
@@ -0,0 +1,145 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"go-observability-demo/internal/observability/observabilitytest"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+type failingStore struct{}
+
+func (failingStore) Incr(context.Context, string, time.Duration) (int64, time.Time, error) {
+	return 0, time.Time{}, errors.New("connection refused")
+}
+
+func (failingStore) Decr(context.Context, string) error {
+	return errors.New("connection refused")
+}
+
+func newTestQuota(t *testing.T, cfg Config, store Store) (*Quota, *observabilitytest.Recorder) {
+	recorder := observabilitytest.New(t)
+	q, err := New(cfg, store, otel.Meter("test"), recorder.Logger)
+	if err != nil {
+		t.Fatalf("Failed to create quota: %v", err)
+	}
+	return q, recorder
+}
+
+func TestQuota_RejectsOverLimitWithHeaders(t *testing.T) {
+	q, recorder := newTestQuota(t, Config{Limit: 2, Window: time.Minute}, NewMemoryStore())
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if d := q.Allow(ctx, "user:alice"); !d.Allowed {
+			t.Fatalf("Expected order %d to be allowed", i+1)
+		}
+	}
+	d := q.Allow(ctx, "user:alice")
+	if d.Allowed || d.Remaining != 0 {
+		t.Fatalf("Expected third order to be rejected, got %+v", d)
+	}
+	if !q.Allow(ctx, "user:bob").Allowed {
+		t.Error("Quotas should be tracked per key")
+	}
+
+	rec := httptest.NewRecorder()
+	d.SetHeaders(rec)
+	if rec.Header().Get("RateLimit-Limit") != "2" || rec.Header().Get("RateLimit-Remaining") != "0" || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("Unexpected quota headers %v", rec.Header())
+	}
+
+	if got := recorder.GaugeValue(t, "quota.keys.exhausted"); got != 1 {
+		t.Errorf("Expected 1 exhausted key, got %v", got)
+	}
+	if got := recorder.GaugeValue(t, "quota.utilization.max"); got != 1.5 {
+		t.Errorf("Expected max utilization 1.5, got %v", got)
+	}
+}
+
+func TestQuota_OverridesAndFailOpen(t *testing.T) {
+	cfg := Config{Limit: 1, Window: time.Minute, Overrides: map[string]int64{"tenant:acme": 0}}
+	q, recorder := newTestQuota(t, cfg, failingStore{})
+
+	r := httptest.NewRequest(http.MethodPost, "/orders", nil)
+	r.Header.Set(TenantHeader, "acme")
+	if keys := Keys(r, "alice"); len(keys) != 2 || keys[0] != "user:alice" || keys[1] != "tenant:acme" {
+		t.Fatalf("Expected the user and tenant keys, got %v", keys)
+	}
+	if d := q.Allow(context.Background(), "tenant:acme"); !d.Allowed || d.Limit != 0 {
+		t.Errorf("Expected an unlimited tenant, got %+v", d)
+	}
+
+	if !q.Allow(context.Background(), "user:alice").Allowed {
+		t.Error("Expected a store failure to allow the order")
+	}
+	m, ok := recorder.Metric(t, "quota.decisions")
+	if !ok {
+		t.Fatal("Decision metric not recorded")
+	}
+	outcome, _ := m.Data.(metricdata.Sum[int64]).DataPoints[0].Attributes.Value("outcome")
+	if outcome.AsString() != "error" {
+		t.Errorf("Expected outcome=error, got %s", outcome.AsString())
+	}
+}
+
+func TestQuota_TenantDoesNotReplaceUser(t *testing.T) {
+	cfg := Config{Limit: 2, Window: time.Minute, Overrides: map[string]int64{"tenant:acme": 0, "tenant:small": 3}}
+	q, _ := newTestQuota(t, cfg, NewMemoryStore())
+	ctx := context.Background()
+
+	// Claiming an unlimited tenant does not lift the user's own quota
+	for i, want := range []bool{true, true, false} {
+		if d := q.Allow(ctx, "user:alice", "tenant:acme"); d.Allowed != want {
+			t.Errorf("Order %d: expected allowed=%v, got %+v", i+1, want, d)
+		}
+	}
+
+	// The tighter of the two quotas is reported, and a rejected user is not
+	// charged to the tenant
+	if d := q.Allow(ctx, "user:bob", "tenant:small"); !d.Allowed || d.Limit != 2 || d.Remaining != 1 {
+		t.Errorf("Expected bob's quota to be the tighter one, got %+v", d)
+	}
+	q.Allow(ctx, "user:bob", "tenant:small")
+	q.Allow(ctx, "user:bob", "tenant:small")
+	if d := q.Allow(ctx, "user:carol", "tenant:small"); !d.Allowed || d.Remaining != 0 {
+		t.Errorf("Expected the tenant's last order for carol, got %+v", d)
+	}
+}
+
+func TestQuota_TenantRejectionRefundsUser(t *testing.T) {
+	cfg := Config{Limit: 2, Window: time.Minute, Overrides: map[string]int64{"tenant:tiny": 1}}
+	q, _ := newTestQuota(t, cfg, NewMemoryStore())
+	ctx := context.Background()
+
+	if d := q.Allow(ctx, "user:alice", "tenant:tiny"); !d.Allowed {
+		t.Fatalf("Expected the tenant's only order to be allowed, got %+v", d)
+	}
+	if d := q.Allow(ctx, "user:alice", "tenant:tiny"); d.Allowed || d.Limit != 1 {
+		t.Errorf("Expected the tenant's quota to reject, got %+v", d)
+	}
+
+	// The rejected order was refunded, so alice still has one left
+	if d := q.Allow(ctx, "user:alice"); !d.Allowed || d.Remaining != 0 {
+		t.Errorf("Expected alice's second order to fit, got %+v", d)
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("QUOTA_ORDERS_PER_WINDOW", "30")
+	t.Setenv("QUOTA_OVERRIDES", "tenant:acme=600, user:load-test=0, broken")
+
+	cfg := ConfigFromEnv()
+	if cfg.Limit != 30 || cfg.Window != time.Minute {
+		t.Errorf("Unexpected defaults %+v", cfg)
+	}
+	if len(cfg.Overrides) != 2 || cfg.Overrides["tenant:acme"] != 600 {
+		t.Errorf("Unexpected overrides %v", cfg.Overrides)
+	}
+}
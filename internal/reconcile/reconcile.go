@@ -0,0 +1,354 @@
+package reconcile
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-observability-demo/internal/config"
+	"go-observability-demo/internal/orders"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Transaction is a payment as the gateway recorded it
+type Transaction struct {
+	ID       string  `json:"id"`
+	OrderID  string  `json:"order_id"`
+	Amount   float64 `json:"amount"`
+	Currency string  `json:"currency"`
+}
+
+// Gateway lists the payment gateway's transactions created in [from, to)
+type Gateway interface {
+	Transactions(ctx context.Context, from, to time.Time) ([]Transaction, error)
+}
+
+// HTTPGateway GETs URL?from=&to= (RFC 3339) and decodes a JSON array of
+// transactions
+type HTTPGateway struct {
+	URL    string
+	Client *http.Client
+}
+
+func (g HTTPGateway) Transactions(ctx context.Context, from, to time.Time) ([]Transaction, error) {
+	q := url.Values{"from": {from.Format(time.RFC3339)}, "to": {to.Format(time.RFC3339)}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.URL+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("gateway returned %d", resp.StatusCode)
+	}
+
+	var txns []Transaction
+	if err := json.NewDecoder(resp.Body).Decode(&txns); err != nil {
+		return nil, fmt.Errorf("failed to decode transactions: %w", err)
+	}
+	return txns, nil
+}
+
+// Simulated stands in for the gateway in the local demo by echoing the
+// local orders, with MismatchRate of them dropped or charged differently
+type Simulated struct {
+	Store        orders.Store
+	MismatchRate float64
+}
+
+func (g Simulated) Transactions(ctx context.Context, from, to time.Time) ([]Transaction, error) {
+	var txns []Transaction
+	for o, err := range g.Store.Range(ctx, orders.Filter{From: from, To: to}) {
+		if err != nil {
+			return nil, err
+		}
+		txn := Transaction{ID: "txn-" + o.ID, OrderID: o.ID, Amount: o.Amount, Currency: o.Currency}
+		if rand.Float64() < g.MismatchRate {
+			if rand.IntN(2) == 0 {
+				continue
+			}
+			txn.Amount += 1
+		}
+		txns = append(txns, txn)
+	}
+	return txns, nil
+}
+
+// Mismatch kinds
+const (
+	MissingAtGateway = "missing_at_gateway"
+	MissingLocally   = "missing_locally"
+	AmountMismatch   = "amount_mismatch"
+)
+
+// Mismatch is a local order and gateway transaction that do not agree
+type Mismatch struct {
+	Kind          string  `json:"kind"`
+	OrderID       string  `json:"order_id"`
+	TransactionID string  `json:"transaction_id,omitempty"`
+	LocalAmount   float64 `json:"local_amount,omitempty"`
+	GatewayAmount float64 `json:"gateway_amount,omitempty"`
+	Currency      string  `json:"currency,omitempty"`
+}
+
+// Report is the outcome of one reconciliation run
+type Report struct {
+	RunAt      time.Time  `json:"run_at"`
+	From       time.Time  `json:"from"`
+	To         time.Time  `json:"to"`
+	Local      int        `json:"local"`
+	Gateway    int        `json:"gateway"`
+	Matched    int        `json:"matched"`
+	Mismatches []Mismatch `json:"mismatches"`
+	Error      string     `json:"error,omitempty"`
+	TraceID    string     `json:"trace_id,omitempty"`
+}
+
+// Config sets the reconciliation schedule and window
+type Config struct {
+	Interval time.Duration
+	// Lookback is how far back each run compares; runs overlap so late
+	// gateway records are caught on the next one
+	Lookback time.Duration
+	// SettleDelay skips orders too recent for the gateway to have listed
+	SettleDelay time.Duration
+	// GatewayURL is the transaction list endpoint; the simulated gateway
+	// is used when empty
+	GatewayURL string
+	// KeepReports bounds the report history served by the admin endpoint
+	KeepReports int
+}
+
+// ConfigFromEnv reads RECONCILE_INTERVAL, RECONCILE_LOOKBACK,
+// RECONCILE_SETTLE_DELAY, RECONCILE_GATEWAY_URL, and RECONCILE_KEEP_REPORTS
+func ConfigFromEnv() Config {
+	return Config{
+		Interval:    config.Duration("RECONCILE_INTERVAL", 5*time.Minute),
+		Lookback:    config.Duration("RECONCILE_LOOKBACK", time.Hour),
+		SettleDelay: config.Duration("RECONCILE_SETTLE_DELAY", time.Minute),
+		GatewayURL:  config.String("RECONCILE_GATEWAY_URL", ""),
+		KeepReports: config.Int("RECONCILE_KEEP_REPORTS", 10),
+	}
+}
+
+// Reconciler compares local orders with the gateway's transactions. Each
+// run is a ReconcilePayments trace, every mismatch is an audit log entry
+// (event.name=reconciliation.mismatch) and a count in
+// reconciliation.mismatches, and recent reports are kept for the admin API.
+type Reconciler struct {
+	cfg     Config
+	store   orders.Store
+	gateway Gateway
+	logger  *slog.Logger
+	tracer  trace.Tracer
+
+	runs       metric.Int64Counter
+	mismatches metric.Int64Counter
+	duration   metric.Float64Histogram
+
+	mu      sync.Mutex
+	reports []Report // newest first
+}
+
+func New(cfg Config, store orders.Store, gateway Gateway, meter metric.Meter, logger *slog.Logger) (*Reconciler, error) {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 5 * time.Minute
+	}
+	cfg.KeepReports = max(1, cfg.KeepReports)
+
+	r := &Reconciler{
+		cfg:     cfg,
+		store:   store,
+		gateway: gateway,
+		logger:  logger,
+		tracer:  otel.Tracer("order-service/reconcile"),
+	}
+
+	var err error
+	r.runs, err = meter.Int64Counter(
+		"reconciliation.runs",
+		metric.WithDescription("Reconciliation runs, by outcome"),
+		metric.WithUnit("{run}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	r.mismatches, err = meter.Int64Counter(
+		"reconciliation.mismatches",
+		metric.WithDescription("Orders and gateway transactions that disagree, by kind"),
+		metric.WithUnit("{mismatch}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	r.duration, err = meter.Float64Histogram(
+		"reconciliation.duration",
+		metric.WithDescription("Time taken by a reconciliation run"),
+		metric.WithUnit("ms"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Loop runs a reconciliation every interval until ctx is cancelled. Each
+// replica runs it over its own store; a shared store needs only the leader.
+func (r *Reconciler) Loop(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.Run(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Run reconciles the lookback window ending SettleDelay ago
+func (r *Reconciler) Run(ctx context.Context) Report {
+	start := time.Now()
+	to := start.Add(-r.cfg.SettleDelay)
+	report := Report{RunAt: start, From: to.Add(-r.cfg.Lookback), To: to, Mismatches: []Mismatch{}}
+
+	ctx, span := r.tracer.Start(ctx, "ReconcilePayments", trace.WithAttributes(
+		attribute.String("reconciliation.from", report.From.Format(time.RFC3339)),
+		attribute.String("reconciliation.to", report.To.Format(time.RFC3339)),
+	))
+	defer span.End()
+	report.TraceID = span.SpanContext().TraceID().String()
+
+	err := r.compare(ctx, &report)
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+		report.Error = err.Error()
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "reconciliation failed")
+		r.logger.ErrorContext(ctx, "reconciliation failed", slog.String("error", err.Error()))
+	} else {
+		span.SetAttributes(
+			attribute.Int("reconciliation.local", report.Local),
+			attribute.Int("reconciliation.gateway", report.Gateway),
+			attribute.Int("reconciliation.mismatches", len(report.Mismatches)),
+		)
+		r.logger.InfoContext(ctx, "reconciliation finished",
+			slog.String("event.name", "reconciliation.finished"),
+			slog.Int("matched", report.Matched),
+			slog.Int("mismatches", len(report.Mismatches)),
+		)
+	}
+	attrs := metric.WithAttributes(attribute.String("outcome", outcome))
+	r.runs.Add(ctx, 1, attrs)
+	r.duration.Record(ctx, float64(time.Since(start).Milliseconds()), attrs)
+
+	r.mu.Lock()
+	r.reports = append([]Report{report}, r.reports...)
+	if len(r.reports) > r.cfg.KeepReports {
+		r.reports = r.reports[:r.cfg.KeepReports]
+	}
+	r.mu.Unlock()
+	return report
+}
+
+func (r *Reconciler) compare(ctx context.Context, report *Report) error {
+	fetchCtx, fetch := r.tracer.Start(ctx, "FetchGatewayTransactions", trace.WithSpanKind(trace.SpanKindClient))
+	txns, err := r.gateway.Transactions(fetchCtx, report.From, report.To)
+	if err != nil {
+		fetch.RecordError(err)
+		fetch.SetStatus(codes.Error, "gateway unavailable")
+	}
+	fetch.End()
+	if err != nil {
+		return fmt.Errorf("failed to list gateway transactions: %w", err)
+	}
+	report.Gateway = len(txns)
+
+	byOrder := make(map[string]Transaction, len(txns))
+	for _, t := range txns {
+		byOrder[t.OrderID] = t
+	}
+
+	for o, err := range r.store.Range(ctx, orders.Filter{From: report.From, To: report.To}) {
+		if err != nil {
+			return fmt.Errorf("failed to list orders: %w", err)
+		}
+		report.Local++
+		t, ok := byOrder[o.ID]
+		delete(byOrder, o.ID)
+		switch {
+		case !ok:
+			r.record(ctx, report, Mismatch{Kind: MissingAtGateway, OrderID: o.ID, LocalAmount: o.Amount, Currency: o.Currency})
+		case math.Abs(t.Amount-o.Amount) >= 0.005 || (t.Currency != "" && t.Currency != o.Currency):
+			r.record(ctx, report, Mismatch{Kind: AmountMismatch, OrderID: o.ID, TransactionID: t.ID,
+				LocalAmount: o.Amount, GatewayAmount: t.Amount, Currency: o.Currency})
+		default:
+			report.Matched++
+		}
+	}
+	for _, t := range byOrder {
+		r.record(ctx, report, Mismatch{Kind: MissingLocally, OrderID: t.OrderID, TransactionID: t.ID,
+			GatewayAmount: t.Amount, Currency: t.Currency})
+	}
+	return nil
+}
+
+func (r *Reconciler) record(ctx context.Context, report *Report, m Mismatch) {
+	report.Mismatches = append(report.Mismatches, m)
+	r.mismatches.Add(ctx, 1, metric.WithAttributes(attribute.String("kind", m.Kind)))
+	r.logger.WarnContext(ctx, "payment mismatch",
+		slog.String("event.name", "reconciliation.mismatch"),
+		slog.Bool("audit", true),
+		slog.String("kind", m.Kind),
+		slog.String("order_id", m.OrderID),
+		slog.String("transaction_id", m.TransactionID),
+		slog.Float64("local_amount", m.LocalAmount),
+		slog.Float64("gateway_amount", m.GatewayAmount),
+		slog.String("currency", m.Currency),
+	)
+}
+
+// Reports returns the most recent reports, newest first
+func (r *Reconciler) Reports() []Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Report(nil), r.reports...)
+}
+
+// ReportsHandler serves GET /admin/reconciliation with the recent reports,
+// and POST to run one now
+func (r *Reconciler) ReportsHandler(w http.ResponseWriter, req *http.Request) {
+	var v any
+	switch req.Method {
+	case http.MethodGet:
+		reports := r.Reports()
+		if reports == nil {
+			reports = []Report{}
+		}
+		v = reports
+	case http.MethodPost:
+		v = r.Run(req.Context())
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,103 @@
+package reconcile
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go-observability-demo/internal/observability/observabilitytest"
+	"go-observability-demo/internal/orders"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+)
+
+type fakeGateway struct {
+	txns []Transaction
+	err  error
+}
+
+func (g fakeGateway) Transactions(context.Context, time.Time, time.Time) ([]Transaction, error) {
+	return g.txns, g.err
+}
+
+func newTestReconciler(t *testing.T, gateway Gateway) (*Reconciler, *orders.MemoryStore, *observabilitytest.Recorder) {
+	t.Helper()
+	recorder := observabilitytest.New(t)
+	store := orders.NewMemoryStore()
+	r, err := New(Config{Lookback: time.Hour}, store, gateway, otel.Meter("test"), recorder.Logger)
+	if err != nil {
+		t.Fatalf("Failed to create reconciler: %v", err)
+	}
+	return r, store, recorder
+}
+
+func TestReconciler_ReportsMismatches(t *testing.T) {
+	gateway := fakeGateway{txns: []Transaction{
+		{ID: "t1", OrderID: "o1", Amount: 10, Currency: "USD"},
+		{ID: "t2", OrderID: "o2", Amount: 21, Currency: "USD"},
+		{ID: "t9", OrderID: "o9", Amount: 5, Currency: "USD"},
+	}}
+	r, store, recorder := newTestReconciler(t, gateway)
+	ctx := context.Background()
+	created := time.Now().Add(-time.Minute)
+	for id, amount := range map[string]float64{"o1": 10, "o2": 20, "o3": 30} {
+		store.Save(ctx, orders.Order{ID: id, UserID: "u", Amount: amount, Currency: "USD", CreatedAt: created})
+	}
+
+	report := r.Run(ctx)
+	if report.Error != "" || report.Local != 3 || report.Gateway != 3 || report.Matched != 1 {
+		t.Fatalf("Expected 3 local, 3 gateway, 1 matched, got %+v", report)
+	}
+	kinds := map[string]string{}
+	for _, m := range report.Mismatches {
+		kinds[m.OrderID] = m.Kind
+	}
+	if kinds["o2"] != AmountMismatch || kinds["o3"] != MissingAtGateway || kinds["o9"] != MissingLocally {
+		t.Errorf("Unexpected mismatches %v", kinds)
+	}
+
+	if got := recorder.Int64Sum(t, "reconciliation.mismatches"); got != 3 {
+		t.Errorf("Expected 3 mismatches counted, got %d", got)
+	}
+	audit := 0
+	for _, l := range recorder.Logs() {
+		if l.Attrs["event.name"] == "reconciliation.mismatch" && l.TraceID == report.TraceID {
+			audit++
+		}
+	}
+	if audit != 3 {
+		t.Errorf("Expected 3 audit log entries in the run's trace, got %d", audit)
+	}
+}
+
+func TestReconciler_GatewayErrorIsReported(t *testing.T) {
+	r, _, recorder := newTestReconciler(t, fakeGateway{err: errors.New("503")})
+
+	if report := r.Run(context.Background()); report.Error == "" {
+		t.Error("Expected the gateway error in the report")
+	}
+	if spans := recorder.SpansNamed("FetchGatewayTransactions"); len(spans) != 1 || spans[0].Status.Description != "gateway unavailable" {
+		t.Errorf("Expected a failed FetchGatewayTransactions span, got %+v", spans)
+	}
+
+	rec := httptest.NewRecorder()
+	r.ReportsHandler(rec, httptest.NewRequest(http.MethodGet, "/admin/reconciliation", nil))
+	var reports []Report
+	json.NewDecoder(rec.Body).Decode(&reports)
+	if len(reports) != 1 || reports[0].Error == "" {
+		t.Errorf("Expected the failed run in the report history, got %+v", reports)
+	}
+}
+
+func TestSimulatedGateway_EchoesOrders(t *testing.T) {
+	store := orders.NewMemoryStore()
+	store.Save(context.Background(), orders.Order{ID: "o1", Amount: 10, CreatedAt: time.Now()})
+
+	txns, err := Simulated{Store: store}.Transactions(context.Background(), time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	if err != nil || len(txns) != 1 || txns[0].Amount != 10 {
+		t.Errorf("Expected the order echoed as a transaction, got %+v (%v)", txns, err)
+	}
+}
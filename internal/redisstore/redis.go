@@ -0,0 +1,204 @@
+package redisstore
+
+import (
+	"context"
+	"errors"
+	"go-observability-demo/internal/config"
+	"go-observability-demo/internal/locks"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Config points at the Redis shared by all replicas
+type Config struct {
+	Addr     string
+	Password string
+	DB       int
+	// Prefix namespaces keys when the instance is shared with other services
+	Prefix  string
+	Timeout time.Duration
+}
+
+// ConfigFromEnv reads REDIS_ADDR, REDIS_PASSWORD, REDIS_DB, REDIS_KEY_PREFIX,
+// and REDIS_TIMEOUT
+func ConfigFromEnv() Config {
+	return Config{
+		Addr:     config.String("REDIS_ADDR", ""),
+		Password: config.String("REDIS_PASSWORD", ""),
+		DB:       config.Int("REDIS_DB", 0),
+		Prefix:   config.String("REDIS_KEY_PREFIX", "order-service:"),
+		Timeout:  config.Duration("REDIS_TIMEOUT", 200*time.Millisecond),
+	}
+}
+
+func (c Config) Enabled() bool {
+	return c.Addr != ""
+}
+
+// incrScript counts a request in the key's fixed window, starting the
+// window on first use, atomically so replicas agree on when it ends
+var incrScript = redis.NewScript(`
+local n = redis.call('INCR', KEYS[1])
+if n == 1 then
+  redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return {n, redis.call('PTTL', KEYS[1])}
+`)
+
+// decrScript takes a request back out of the key's window, leaving an
+// expired window alone rather than creating a counter without a TTL
+var decrScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+  return redis.call('DECR', KEYS[1])
+end
+return 0
+`)
+
+// unlockScript deletes the lock only while it still holds our token
+var unlockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+  return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// refreshScript extends the lock only while it still holds our token
+var refreshScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+  return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// Client backs quota counters and locks with Redis, so limits and
+// idempotency hold across replicas. It implements quota.Store and
+// locks.Locker; every operation is traced as a client span.
+type Client struct {
+	rdb    *redis.Client
+	prefix string
+	tracer trace.Tracer
+	attrs  []attribute.KeyValue
+}
+
+var _ locks.Locker = (*Client)(nil)
+
+func New(cfg Config) *Client {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 200 * time.Millisecond
+	}
+	rdb := redis.NewClient(&redis.Options{
+		Addr:         cfg.Addr,
+		Password:     cfg.Password,
+		DB:           cfg.DB,
+		DialTimeout:  cfg.Timeout,
+		ReadTimeout:  cfg.Timeout,
+		WriteTimeout: cfg.Timeout,
+	})
+
+	attrs := []attribute.KeyValue{attribute.String("db.system.name", "redis")}
+	if host, port, err := net.SplitHostPort(cfg.Addr); err == nil {
+		attrs = append(attrs, attribute.String("server.address", host))
+		if p, err := strconv.Atoi(port); err == nil {
+			attrs = append(attrs, attribute.Int("server.port", p))
+		}
+	}
+	if cfg.DB != 0 {
+		attrs = append(attrs, attribute.Int("db.namespace", cfg.DB))
+	}
+
+	return &Client{
+		rdb:    rdb,
+		prefix: cfg.Prefix,
+		tracer: otel.Tracer("order-service/redis"),
+		attrs:  attrs,
+	}
+}
+
+// Ping checks the connection, so a misconfigured address fails at startup
+func (c *Client) Ping(ctx context.Context) error {
+	ctx, span := c.start(ctx, "PING")
+	defer span.End()
+	return c.end(span, c.rdb.Ping(ctx).Err())
+}
+
+// Close releases the connection pool
+func (c *Client) Close(context.Context) error {
+	return c.rdb.Close()
+}
+
+func (c *Client) Incr(ctx context.Context, key string, window time.Duration) (int64, time.Time, error) {
+	ctx, span := c.start(ctx, "EVALSHA", attribute.String("db.operation.name", "quota.incr"))
+	defer span.End()
+
+	res, err := incrScript.Run(ctx, c.rdb, []string{c.prefix + "quota:" + key}, window.Milliseconds()).Int64Slice()
+	if err == nil && len(res) != 2 {
+		err = errors.New("unexpected reply from quota script")
+	}
+	if err = c.end(span, err); err != nil {
+		return 0, time.Time{}, err
+	}
+	return res[0], time.Now().Add(time.Duration(res[1]) * time.Millisecond), nil
+}
+
+func (c *Client) Decr(ctx context.Context, key string) error {
+	ctx, span := c.start(ctx, "EVALSHA", attribute.String("db.operation.name", "quota.decr"))
+	defer span.End()
+	return c.end(span, decrScript.Run(ctx, c.rdb, []string{c.prefix + "quota:" + key}).Err())
+}
+
+func (c *Client) TryLock(ctx context.Context, key string, ttl time.Duration) (string, bool, error) {
+	ctx, span := c.start(ctx, "SET", attribute.String("db.operation.name", "lock.acquire"))
+	defer span.End()
+
+	token := locks.NewToken()
+	ok, err := c.rdb.SetNX(ctx, c.prefix+"lock:"+key, token, ttl).Result()
+	if err = c.end(span, err); err != nil {
+		return "", false, err
+	}
+	span.SetAttributes(attribute.Bool("lock.acquired", ok))
+	if !ok {
+		return "", false, nil
+	}
+	return token, true, nil
+}
+
+// Refresh extends the lock for ttl if the token still owns it
+func (c *Client) Refresh(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	ctx, span := c.start(ctx, "EVALSHA", attribute.String("db.operation.name", "lock.refresh"))
+	defer span.End()
+
+	n, err := refreshScript.Run(ctx, c.rdb, []string{c.prefix + "lock:" + key}, token, ttl.Milliseconds()).Int64()
+	if err = c.end(span, err); err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+func (c *Client) Unlock(ctx context.Context, key, token string) error {
+	ctx, span := c.start(ctx, "EVALSHA", attribute.String("db.operation.name", "lock.release"))
+	defer span.End()
+	return c.end(span, unlockScript.Run(ctx, c.rdb, []string{c.prefix + "lock:" + key}, token).Err())
+}
+
+func (c *Client) start(ctx context.Context, command string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return c.tracer.Start(ctx, "redis "+command,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(c.attrs...),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+func (c *Client) end(span trace.Span, err error) error {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "redis command failed")
+	}
+	return err
+}
@@ -0,0 +1,143 @@
+package redisstore
+
+import (
+	"context"
+	"go-observability-demo/internal/observability/observabilitytest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+func newTestClient(t *testing.T) (*Client, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := New(Config{Addr: server.Addr(), Prefix: "test:"})
+	t.Cleanup(func() { client.Close(context.Background()) })
+	return client, server
+}
+
+func TestClient_IncrCountsFixedWindow(t *testing.T) {
+	client, server := newTestClient(t)
+	ctx := context.Background()
+
+	for want := int64(1); want <= 3; want++ {
+		n, resetAt, err := client.Incr(ctx, "user:alice", time.Minute)
+		if err != nil {
+			t.Fatalf("Incr failed: %v", err)
+		}
+		if n != want {
+			t.Errorf("Expected count %d, got %d", want, n)
+		}
+		if until := time.Until(resetAt); until <= 0 || until > time.Minute {
+			t.Errorf("Expected the window to end within a minute, got %v", until)
+		}
+	}
+
+	server.FastForward(time.Minute)
+	if n, _, _ := client.Incr(ctx, "user:alice", time.Minute); n != 1 {
+		t.Errorf("Expected a new window after expiry, got count %d", n)
+	}
+	if !server.Exists("test:quota:user:alice") {
+		t.Error("Expected the counter under the configured prefix")
+	}
+}
+
+func TestClient_DecrRefundsOpenWindowOnly(t *testing.T) {
+	client, server := newTestClient(t)
+	ctx := context.Background()
+
+	client.Incr(ctx, "user:alice", time.Minute)
+	client.Incr(ctx, "user:alice", time.Minute)
+	if err := client.Decr(ctx, "user:alice"); err != nil {
+		t.Fatalf("Decr failed: %v", err)
+	}
+	if n, _, _ := client.Incr(ctx, "user:alice", time.Minute); n != 2 {
+		t.Errorf("Expected count 2 after a refund, got %d", n)
+	}
+
+	server.FastForward(time.Minute)
+	if err := client.Decr(ctx, "user:alice"); err != nil {
+		t.Fatalf("Decr failed: %v", err)
+	}
+	if server.Exists("test:quota:user:alice") {
+		t.Error("Expected no counter to be created for an expired window")
+	}
+}
+
+func TestClient_LockIsExclusiveAndTokenChecked(t *testing.T) {
+	client, server := newTestClient(t)
+	ctx := context.Background()
+
+	token, ok, err := client.TryLock(ctx, "order", time.Minute)
+	if err != nil || !ok {
+		t.Fatalf("Expected the first lock to succeed, got ok=%v err=%v", ok, err)
+	}
+	if _, ok, _ := client.TryLock(ctx, "order", time.Minute); ok {
+		t.Error("Expected a second lock on a held key to fail")
+	}
+
+	if ok, err := client.Refresh(ctx, "order", token, time.Hour); err != nil || !ok {
+		t.Errorf("Expected the holder to refresh the lock, got ok=%v err=%v", ok, err)
+	}
+	if ttl := server.TTL("test:lock:order"); ttl != time.Hour {
+		t.Errorf("Expected the refreshed TTL to be 1h, got %v", ttl)
+	}
+	if ok, _ := client.Refresh(ctx, "order", "stale-token", time.Hour); ok {
+		t.Error("Expected a refresh with the wrong token to fail")
+	}
+
+	// A stale holder must not release the lock
+	if err := client.Unlock(ctx, "order", "stale-token"); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if !server.Exists("test:lock:order") {
+		t.Error("Expected the lock to survive an unlock with the wrong token")
+	}
+
+	if err := client.Unlock(ctx, "order", token); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if _, ok, _ := client.TryLock(ctx, "order", time.Minute); !ok {
+		t.Error("Expected the lock to be free after unlock")
+	}
+}
+
+func TestClient_TracesOperations(t *testing.T) {
+	recorder := observabilitytest.New(t)
+	client, server := newTestClient(t)
+	ctx := context.Background()
+
+	client.TryLock(ctx, "order", time.Minute)
+	server.Close()
+	if _, _, err := client.Incr(ctx, "user:alice", time.Minute); err == nil {
+		t.Fatal("Expected Incr to fail with Redis down")
+	}
+
+	set := recorder.SpansNamed("redis SET")
+	if len(set) != 1 {
+		t.Fatalf("Expected 1 redis SET span, got %d", len(set))
+	}
+	want := map[attribute.KeyValue]bool{
+		attribute.String("db.system.name", "redis"):           false,
+		attribute.String("db.operation.name", "lock.acquire"): false,
+		attribute.Bool("lock.acquired", true):                 false,
+	}
+	for _, attr := range set[0].Attributes {
+		if _, ok := want[attr]; ok {
+			want[attr] = true
+		}
+	}
+	for attr, found := range want {
+		if !found {
+			t.Errorf("Expected %v on the SET span", attr)
+		}
+	}
+
+	failed := recorder.SpansNamed("redis EVALSHA")
+	if len(failed) != 1 || failed[0].Status.Code != codes.Error {
+		t.Errorf("Expected a failed EVALSHA span, got %+v", failed)
+	}
+}
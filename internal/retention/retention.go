@@ -0,0 +1,201 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-observability-demo/internal/config"
+	"go-observability-demo/internal/orders"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Action is what happens to orders past the retention period
+type Action string
+
+const (
+	// Anonymize keeps the order for reporting but drops the user ID and
+	// the trace ID that links it to request logs
+	Anonymize Action = "anonymize"
+	Delete    Action = "delete"
+)
+
+// Config is the retention policy
+type Config struct {
+	Action   Action
+	MaxAge   time.Duration
+	Interval time.Duration
+	// DryRun logs and counts what would be purged without changing anything
+	DryRun bool
+}
+
+// ConfigFromEnv reads RETENTION_ACTION (off by default), RETENTION_MAX_AGE,
+// RETENTION_INTERVAL, and RETENTION_DRY_RUN
+func ConfigFromEnv() Config {
+	return Config{
+		Action:   Action(config.String("RETENTION_ACTION", "")),
+		MaxAge:   config.Duration("RETENTION_MAX_AGE", 30*24*time.Hour),
+		Interval: config.Duration("RETENTION_INTERVAL", time.Hour),
+		DryRun:   config.Bool("RETENTION_DRY_RUN", false),
+	}
+}
+
+func (c Config) Enabled() bool {
+	return (c.Action == Anonymize || c.Action == Delete) && c.MaxAge > 0
+}
+
+// Result is the outcome of one retention run
+type Result struct {
+	Scanned int
+	Purged  int
+}
+
+// Job applies the retention policy to the order store. Each run is an
+// ApplyRetention trace, each purged order an audit log entry
+// (event.name=retention.purged), and purged orders are counted in
+// retention.purged{action,dry_run}.
+type Job struct {
+	cfg    Config
+	store  orders.Store
+	logger *slog.Logger
+	tracer trace.Tracer
+
+	purged metric.Int64Counter
+	runs   metric.Int64Counter
+}
+
+func New(cfg Config, store orders.Store, meter metric.Meter, logger *slog.Logger) (*Job, error) {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Hour
+	}
+	if !cfg.Enabled() {
+		return nil, fmt.Errorf("retention needs RETENTION_ACTION anonymize or delete and a positive max age, got %q", cfg.Action)
+	}
+
+	purged, err := meter.Int64Counter(
+		"retention.purged",
+		metric.WithDescription("Orders anonymized or deleted by the retention policy"),
+		metric.WithUnit("{order}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	runs, err := meter.Int64Counter(
+		"retention.runs",
+		metric.WithDescription("Retention runs, by outcome"),
+		metric.WithUnit("{run}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Job{
+		cfg:    cfg,
+		store:  store,
+		logger: logger,
+		tracer: otel.Tracer("order-service/retention"),
+		purged: purged,
+		runs:   runs,
+	}, nil
+}
+
+// Loop applies the policy every interval until ctx is cancelled. Each
+// replica runs it over its own store; a shared store needs only the leader.
+func (j *Job) Loop(ctx context.Context) {
+	ticker := time.NewTicker(j.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			j.Run(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Run purges the orders created before MaxAge ago
+func (j *Job) Run(ctx context.Context) (Result, error) {
+	cutoff := time.Now().Add(-j.cfg.MaxAge)
+	ctx, span := j.tracer.Start(ctx, "ApplyRetention", trace.WithAttributes(
+		attribute.String("retention.action", string(j.cfg.Action)),
+		attribute.Bool("retention.dry_run", j.cfg.DryRun),
+		attribute.String("retention.cutoff", cutoff.Format(time.RFC3339)),
+	))
+	defer span.End()
+
+	result, err := j.purge(ctx, cutoff)
+	span.SetAttributes(
+		attribute.Int("retention.scanned", result.Scanned),
+		attribute.Int("retention.purged", result.Purged),
+	)
+
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "retention run failed")
+		j.logger.ErrorContext(ctx, "retention run failed", slog.String("error", err.Error()))
+	} else {
+		j.logger.InfoContext(ctx, "retention run finished",
+			slog.String("event.name", "retention.finished"),
+			slog.Int("purged", result.Purged),
+			slog.Bool("dry_run", j.cfg.DryRun),
+		)
+	}
+	j.runs.Add(ctx, 1, metric.WithAttributes(attribute.String("outcome", outcome)))
+	return result, err
+}
+
+func (j *Job) purge(ctx context.Context, cutoff time.Time) (Result, error) {
+	var result Result
+	attrs := metric.WithAttributes(
+		attribute.String("action", string(j.cfg.Action)),
+		attribute.Bool("dry_run", j.cfg.DryRun),
+	)
+	for o, err := range j.store.Range(ctx, orders.Filter{To: cutoff}) {
+		if err != nil {
+			return result, err
+		}
+		result.Scanned++
+		if j.cfg.Action == Anonymize && o.UserID == "" {
+			continue // already anonymized
+		}
+
+		if !j.cfg.DryRun {
+			err := j.apply(ctx, o)
+			if errors.Is(err, orders.ErrNotFound) {
+				continue // evicted or deleted since the scan started
+			}
+			if err != nil {
+				return result, fmt.Errorf("failed to %s order %s: %w", j.cfg.Action, o.ID, err)
+			}
+		}
+		result.Purged++
+		j.purged.Add(ctx, 1, attrs)
+		j.logger.InfoContext(ctx, "order purged by retention policy",
+			slog.String("event.name", "retention.purged"),
+			slog.Bool("audit", true),
+			slog.String("order_id", o.ID),
+			slog.String("action", string(j.cfg.Action)),
+			slog.Bool("dry_run", j.cfg.DryRun),
+			slog.Time("created_at", o.CreatedAt),
+		)
+	}
+	return result, nil
+}
+
+func (j *Job) apply(ctx context.Context, o orders.Order) error {
+	if j.cfg.Action == Delete {
+		return j.store.Delete(ctx, o.ID)
+	}
+	o.UserID = ""
+	o.TraceID = ""
+	return j.store.Save(ctx, o)
+}
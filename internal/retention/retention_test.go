@@ -0,0 +1,87 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"go-observability-demo/internal/observability/observabilitytest"
+	"go-observability-demo/internal/orders"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+)
+
+func newTestJob(t *testing.T, cfg Config) (*Job, *orders.MemoryStore, *observabilitytest.Recorder) {
+	t.Helper()
+	recorder := observabilitytest.New(t)
+	store := orders.NewMemoryStore()
+	ctx := context.Background()
+	store.Save(ctx, orders.Order{ID: "old", UserID: "alice", TraceID: "abc", CreatedAt: time.Now().Add(-48 * time.Hour)})
+	store.Save(ctx, orders.Order{ID: "new", UserID: "alice", CreatedAt: time.Now()})
+
+	job, err := New(cfg, store, otel.Meter("test"), recorder.Logger)
+	if err != nil {
+		t.Fatalf("Failed to create job: %v", err)
+	}
+	return job, store, recorder
+}
+
+func TestJob_Anonymize(t *testing.T) {
+	job, store, recorder := newTestJob(t, Config{Action: Anonymize, MaxAge: 24 * time.Hour})
+	ctx := context.Background()
+
+	if res, err := job.Run(ctx); err != nil || res.Purged != 1 {
+		t.Fatalf("Expected 1 order anonymized, got %+v (%v)", res, err)
+	}
+	o, _ := store.Get(ctx, "old")
+	if o.UserID != "" || o.TraceID != "" {
+		t.Errorf("Expected user and trace IDs to be removed, got %+v", o)
+	}
+	if list, _, _ := store.ListByUser(ctx, "alice", orders.Page{Limit: 10}); len(list) != 1 {
+		t.Errorf("Expected the anonymized order to leave alice's history, got %+v", list)
+	}
+
+	// A second run has nothing left to do
+	if res, _ := job.Run(ctx); res.Purged != 0 {
+		t.Errorf("Expected nothing to purge, got %+v", res)
+	}
+	if got := recorder.Int64Sum(t, "retention.purged"); got != 1 {
+		t.Errorf("Expected 1 purged order counted, got %d", got)
+	}
+	if len(recorder.SpansNamed("ApplyRetention")) != 2 {
+		t.Error("Expected an ApplyRetention span per run")
+	}
+}
+
+func TestJob_DryRunChangesNothing(t *testing.T) {
+	job, store, recorder := newTestJob(t, Config{Action: Delete, MaxAge: 24 * time.Hour, DryRun: true})
+	ctx := context.Background()
+
+	if res, _ := job.Run(ctx); res.Purged != 1 {
+		t.Errorf("Expected 1 order reported, got %+v", res)
+	}
+	if _, err := store.Get(ctx, "old"); err != nil {
+		t.Errorf("Expected the order to survive a dry run, got %v", err)
+	}
+	audit := 0
+	for _, l := range recorder.Logs() {
+		if l.Attrs["event.name"] == "retention.purged" && l.Attrs["dry_run"] == true {
+			audit++
+		}
+	}
+	if audit != 1 {
+		t.Errorf("Expected 1 dry-run audit entry, got %d", audit)
+	}
+
+	job.cfg.DryRun = false
+	job.Run(ctx)
+	if _, err := store.Get(ctx, "old"); !errors.Is(err, orders.ErrNotFound) {
+		t.Errorf("Expected the order to be deleted, got %v", err)
+	}
+}
+
+func TestNew_RequiresAnAction(t *testing.T) {
+	if _, err := New(Config{Action: "shred", MaxAge: time.Hour}, orders.NewMemoryStore(), otel.Meter("test"), nil); err == nil {
+		t.Error("Expected an unknown action to be rejected")
+	}
+}
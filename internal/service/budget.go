@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go-observability-demo/internal/config"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrBudgetExceeded is the cancellation cause of a step that overran its
+// latency budget while BUDGET_ABORT is set
+var ErrBudgetExceeded = errors.New("latency budget exceeded")
+
+// Budgets are the latency allowances of the order steps
+type Budgets struct {
+	Steps map[string]time.Duration
+	// Abort cancels a step once its budget is spent, which fails the order
+	// and stops the steps still running next to it
+	Abort bool
+}
+
+var defaultBudgets = map[string]time.Duration{
+	"inventory": 100 * time.Millisecond,
+	"payment":   time.Second,
+	"reserve":   150 * time.Millisecond,
+}
+
+// BudgetsFromEnv applies BUDGET_<STEP> overrides (0 disables a budget) and
+// BUDGET_ABORT to the defaults
+func BudgetsFromEnv() Budgets {
+	steps := make(map[string]time.Duration, len(defaultBudgets))
+	for step, d := range defaultBudgets {
+		steps[step] = config.Duration("BUDGET_"+strings.ToUpper(step), d)
+	}
+	return Budgets{Steps: steps, Abort: config.Bool("BUDGET_ABORT", false)}
+}
+
+// watchBudget times step against its budget. If the step is still running
+// when the budget is spent, the span in ctx gets a latency_budget_exceeded
+// event and the overrun is counted; with Abort the returned context is also
+// cancelled with ErrBudgetExceeded. Call stop when the step returns.
+func (s *OrderService) watchBudget(ctx context.Context, step string) (_ context.Context, stop func()) {
+	budget := s.budgets.Steps[step]
+	if budget <= 0 {
+		return ctx, func() {}
+	}
+
+	span := trace.SpanFromContext(ctx)
+	ctx, cancel := context.WithCancelCause(ctx)
+	timer := time.AfterFunc(budget, func() {
+		span.AddEvent("latency_budget_exceeded", trace.WithAttributes(
+			attribute.String("step", step),
+			attribute.Int64("budget_ms", budget.Milliseconds()),
+			attribute.Bool("aborted", s.budgets.Abort),
+		))
+		span.SetAttributes(attribute.Bool("budget.exceeded", true))
+		s.metrics.BudgetExceeded.Add(ctx, 1, attributeSet(attribute.String("step", step)))
+		if s.budgets.Abort {
+			cancel(ErrBudgetExceeded)
+		}
+	})
+
+	return ctx, func() {
+		timer.Stop()
+		cancel(nil)
+	}
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go-observability-demo/internal/apperr"
+	"go-observability-demo/internal/middleware"
+	"go-observability-demo/internal/observability"
+	"go-observability-demo/internal/orders"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// cancelLockTTL bounds how long a cancellation holds its order, so a
+// crashed request cannot block the order for good
+const cancelLockTTL = 30 * time.Second
+
+// CancelOrderHandler serves POST /orders/{id}/cancel. It undoes an order
+// in the reverse of the order it was placed: RefundPayment, then
+// ReleaseInventory, each a child span of CancelOrder. The refund is the
+// step that can fail, and a failed refund leaves the order placed with its
+// stock still reserved, so the cancellation can simply be retried. Each
+// step runs at most once per order, so a retry after a failed save does not
+// refund or release again. Cancelled orders are counted in orders.cancelled.
+func (s *OrderService) CancelOrderHandler(w http.ResponseWriter, r *http.Request) {
+	orderID := r.PathValue("id")
+	ctx, span := s.tracer.Start(r.Context(), "CancelOrder",
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attribute.String("order.id", orderID)),
+	)
+	defer span.End()
+	logger := s.logger.With(slog.String("order_id", orderID))
+	ctx = observability.ContextWithLogger(ctx, logger)
+
+	caller := r.Header.Get(UserIDHeader)
+	admin := middleware.HasAdminToken(r, s.adminToken)
+	span.SetAttributes(attribute.Bool("auth.admin", admin))
+	if caller == "" && !admin {
+		span.SetStatus(codes.Error, "missing "+UserIDHeader)
+		writeError(ctx, w, http.StatusUnauthorized, "missing "+UserIDHeader)
+		return
+	}
+
+	// Two cancellations of one order must not refund it twice
+	key := "cancel:" + orderID
+	token, ok, err := s.locker.TryLock(ctx, key, cancelLockTTL)
+	switch {
+	case err != nil:
+		span.AddEvent("cancel_lock_skipped")
+		logger.WarnContext(ctx, "cancellation lock unavailable, continuing without it",
+			slog.String("error", err.Error()),
+		)
+	case !ok:
+		s.fail(ctx, w, "order cancellation rejected", apperr.New(apperr.NotCancellable, "order is already being cancelled"))
+		return
+	default:
+		defer func() {
+			if err := s.locker.Unlock(context.WithoutCancel(ctx), key, token); err != nil {
+				logger.WarnContext(ctx, "failed to release cancellation lock", slog.String("error", err.Error()))
+			}
+		}()
+	}
+
+	order, err := s.orders.Get(ctx, orderID)
+	if err == nil && order.UserID != caller && !admin {
+		err = orders.ErrNotFound
+	}
+	switch {
+	case errors.Is(err, orders.ErrNotFound):
+		s.fail(ctx, w, "order to cancel not found", apperr.Wrap(apperr.OrderNotFound, err, "order not found"))
+		return
+	case err != nil:
+		s.fail(ctx, w, "failed to read order", apperr.Wrap(apperr.Internal, err, "failed to read order"))
+		return
+	}
+	span.SetAttributes(
+		attribute.String("user.id", order.UserID),
+		attribute.String("order.status", order.Status),
+	)
+	if order.Status != orders.StatusPlaced {
+		s.fail(ctx, w, "order cancellation rejected", apperr.New(apperr.NotCancellable, "order is "+order.Status))
+		return
+	}
+
+	err = s.cancelStepOnce(ctx, order.ID, "refund", func() error {
+		return s.refundPayment(ctx, order.UserID, order.Amount, order.Currency)
+	})
+	if err != nil {
+		s.fail(ctx, w, "order cancellation failed", err)
+		return
+	}
+	// A failed release is recorded on its span; the refund has already gone
+	// out, so the order is cancelled regardless
+	_ = s.cancelStepOnce(ctx, order.ID, "release", func() error {
+		s.releaseInventory(ctx, order.ProductID, order.Quantity)
+		return nil
+	})
+
+	order.Status = orders.StatusCancelled
+	order.CancelledAt = time.Now()
+	if err := s.orders.Save(ctx, order); err != nil {
+		s.fail(ctx, w, "failed to save cancelled order", apperr.Wrap(apperr.Internal, err, "failed to save order"))
+		return
+	}
+
+	s.metrics.OrdersCancelled.Add(ctx, 1)
+	span.AddEvent("order_cancelled")
+	logger.InfoContext(ctx, "order cancelled",
+		slog.Float64("refunded", order.Amount),
+		slog.String("currency", order.Currency),
+	)
+	writeJSON(w, http.StatusOK, order)
+}
+
+// cancelStepOnce runs one step of an order's cancellation unless it already
+// ran, by holding "cancel:<order>:<step>" in the locker for IDEMPOTENCY_TTL.
+// A failed step gives the key back so it can be retried. Locker errors fail
+// open, like idempotency keys.
+func (s *OrderService) cancelStepOnce(ctx context.Context, orderID, step string, run func() error) error {
+	span := trace.SpanFromContext(ctx)
+	key := "cancel:" + orderID + ":" + step
+	token, ok, err := s.locker.TryLock(ctx, key, s.idempotencyTTL)
+	switch {
+	case err != nil:
+		span.AddEvent("cancel_step_check_skipped", trace.WithAttributes(attribute.String("cancel.step", step)))
+		observability.LoggerFromContext(ctx).WarnContext(ctx, "cancellation step lock unavailable, continuing without it",
+			slog.String("step", step),
+			slog.String("error", err.Error()),
+		)
+		return run()
+	case !ok:
+		span.AddEvent("cancel_step_skipped", trace.WithAttributes(attribute.String("cancel.step", step)))
+		return nil
+	}
+
+	if err := run(); err != nil {
+		if err := s.locker.Unlock(context.WithoutCancel(ctx), key, token); err != nil {
+			observability.LoggerFromContext(ctx).WarnContext(ctx, "failed to release cancellation step lock",
+				slog.String("step", step),
+				slog.String("error", err.Error()),
+			)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *OrderService) refundPayment(ctx context.Context, userID string, amount float64, currency string) error {
+	ctx, span := s.tracer.Start(ctx, "RefundPayment", trace.WithAttributes(
+		attribute.String("user.id", userID),
+		attribute.Float64("payment.amount", amount),
+		attribute.String("payment.currency", currency),
+	))
+	defer span.End()
+
+	// Simulate the gateway call
+	if _, err := s.faults.Delay(ctx, "refund"); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "refund interrupted")
+		return err
+	}
+	if s.faults.ShouldFail("refund") {
+		err := apperr.New(apperr.RefundFailed, "payment could not be refunded, try again")
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "refund failed")
+		return err
+	}
+
+	span.AddEvent("payment_refunded")
+	return nil
+}
+
+func (s *OrderService) releaseInventory(ctx context.Context, productID string, quantity int) {
+	ctx, span := s.tracer.Start(ctx, "ReleaseInventory", trace.WithAttributes(
+		attribute.String("product.id", productID),
+		attribute.Int("quantity", quantity),
+	))
+	defer span.End()
+
+	// The same update as a reservation, so it takes as long. It is not
+	// interrupted by the request: the refund has already gone out.
+	ctx = context.WithoutCancel(ctx)
+	duration, _ := s.faults.Delay(ctx, "reserve")
+	span.SetAttributes(
+		attribute.Int64("db.duration_ms", duration.Milliseconds()),
+		attribute.String("db.operation", "UPDATE"),
+		attribute.String("db.table", "inventory"),
+	)
+	if err := s.inventory.Release(ctx, productID, quantity); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "inventory release failed")
+		observability.LoggerFromContext(ctx).ErrorContext(ctx, "failed to release inventory of a cancelled order",
+			slog.String("product_id", productID),
+			slog.Int("quantity", quantity),
+			slog.String("error", err.Error()),
+		)
+		return
+	}
+	span.AddEvent("inventory_released")
+}
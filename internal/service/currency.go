@@ -0,0 +1,27 @@
+package service
+
+import (
+	"context"
+	"go-observability-demo/internal/fx"
+	"go-observability-demo/internal/pricing"
+)
+
+// WithFX accepts orders in currencies other than fx.ReportingCurrency
+func WithFX(c *fx.Converter) Option {
+	return func(s *OrderService) {
+		s.fx = c
+	}
+}
+
+// chargeAmounts returns what to charge in the order's currency and the same
+// amount in the reporting currency, which payment metrics are kept in.
+// Catalog prices are in the reporting currency; without pricing the client's
+// amount is taken to be in the order's currency.
+func (s *OrderService) chargeAmounts(ctx context.Context, req CreateOrderRequest, quote pricing.Quote) (charge, reported float64, err error) {
+	if s.pricing == nil {
+		reported, err = s.fx.Convert(ctx, req.Amount, req.Currency, fx.ReportingCurrency)
+		return req.Amount, reported, err
+	}
+	charge, err = s.fx.Convert(ctx, quote.Total, fx.ReportingCurrency, req.Currency)
+	return charge, quote.Total, err
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"go-observability-demo/internal/config"
+	"go-observability-demo/internal/observability"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DuplicateMode is what happens to an order that looks like a repeat of
+// one placed moments ago
+type DuplicateMode string
+
+const (
+	DuplicatesOff   DuplicateMode = "off"
+	DuplicatesFlag  DuplicateMode = "flag"
+	DuplicatesBlock DuplicateMode = "block"
+)
+
+// DuplicateConfig controls the duplicate order heuristic. Orders from the
+// same user for the same product, quantity, and amount within Window are
+// likely double submits that no Idempotency-Key caught.
+type DuplicateConfig struct {
+	Mode   DuplicateMode
+	Window time.Duration
+}
+
+// DuplicateConfigFromEnv reads DUPLICATE_ORDER_MODE and
+// DUPLICATE_ORDER_WINDOW. Production blocks by default; other environments
+// only flag, so load tests that repeat orders keep working.
+func DuplicateConfigFromEnv() DuplicateConfig {
+	mode := DuplicatesFlag
+	if config.String("ENVIRONMENT", "development") == "production" {
+		mode = DuplicatesBlock
+	}
+	return DuplicateConfig{
+		Mode:   DuplicateMode(config.String("DUPLICATE_ORDER_MODE", string(mode))),
+		Window: config.Duration("DUPLICATE_ORDER_WINDOW", 2*time.Minute),
+	}
+}
+
+// WithDuplicateDetection overrides the duplicate order heuristic
+func WithDuplicateDetection(cfg DuplicateConfig) Option {
+	return func(s *OrderService) {
+		s.duplicates = cfg
+	}
+}
+
+// checkDuplicate claims the order's fingerprint for the window through the
+// same locker as idempotency keys, so it holds across replicas when that
+// is shared. A repeat within the window is flagged on the span and counted
+// in orders.duplicates.detected; blocked reports whether it should be
+// rejected. release gives the fingerprint back if the order fails.
+func (s *OrderService) checkDuplicate(ctx context.Context, req CreateOrderRequest) (release func(), blocked bool) {
+	if s.duplicates.Mode != DuplicatesFlag && s.duplicates.Mode != DuplicatesBlock {
+		return func() {}, false
+	}
+
+	key := fmt.Sprintf("duplicate:%s:%s:%d:%.2f:%s", req.UserID, req.ProductID, req.Quantity, req.Amount, req.Currency)
+	token, ok, err := s.locker.TryLock(ctx, key, s.duplicates.Window)
+	if err != nil {
+		observability.LoggerFromContext(ctx).WarnContext(ctx, "duplicate order check skipped",
+			slog.String("error", err.Error()),
+		)
+		return func() {}, false
+	}
+	if ok {
+		return func() {
+			s.locker.Unlock(context.WithoutCancel(ctx), key, token)
+		}, false
+	}
+
+	blocked = s.duplicates.Mode == DuplicatesBlock
+	action := "flagged"
+	if blocked {
+		action = "blocked"
+	}
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.Bool("order.duplicate_suspected", true),
+		attribute.String("order.duplicate_action", action),
+	)
+	s.metrics.DuplicatesDetected.Add(ctx, 1, metric.WithAttributes(attribute.String("action", action)))
+	observability.LoggerFromContext(ctx).WarnContext(ctx, "likely duplicate order",
+		slog.String("user_id", req.UserID),
+		slog.String("product_id", req.ProductID),
+		slog.String("action", action),
+	)
+	return func() {}, blocked
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"go-observability-demo/internal/orders"
+	"iter"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// Each flush must reach the client within this, so a stalled reader
+	// cannot hold an export open, while a slow one can take as long as it
+	// needs overall
+	exportWriteTimeout = 30 * time.Second
+	// A progress event is added to the span every this many rows
+	exportProgressRows = 10000
+)
+
+var exportColumns = []string{"order_id", "user_id", "product_id", "quantity", "amount", "currency", "status", "trace_id", "created_at"}
+
+// ExportOrdersHandler serves GET /admin/orders/export?format=csv|jsonl with
+// optional from/to (RFC 3339) and status filters. Rows are read from the
+// store and written as they are produced, so the export is paced by the
+// client and memory stays flat. Progress is in orders.export.rows and on
+// the ExportOrders span.
+func (s *OrderService) ExportOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, span := s.tracer.Start(r.Context(), "ExportOrders", trace.WithSpanKind(trace.SpanKindServer))
+	defer span.End()
+
+	q := r.URL.Query()
+	format := cmp.Or(q.Get("format"), "jsonl")
+	filter, err := parseExportFilter(q)
+	if err == nil && format != "csv" && format != "jsonl" {
+		err = fmt.Errorf("format must be csv or jsonl")
+	}
+	if err != nil {
+		span.SetStatus(codes.Error, "invalid export request")
+		writeError(ctx, w, http.StatusBadRequest, err.Error())
+		return
+	}
+	span.SetAttributes(
+		attribute.String("export.format", format),
+		attribute.String("export.status", filter.Status),
+	)
+
+	rows, err := s.streamExport(ctx, w, format, s.orders.Range(ctx, filter))
+	span.SetAttributes(attribute.Int("export.rows", rows))
+	if err != nil {
+		// Headers are sent, so the truncated body is all the client gets
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "export interrupted")
+		s.logger.WarnContext(ctx, "order export interrupted",
+			slog.Int("rows", rows),
+			slog.String("error", err.Error()),
+		)
+		return
+	}
+	s.logger.InfoContext(ctx, "orders exported",
+		slog.String("format", format),
+		slog.Int("rows", rows),
+	)
+}
+
+func parseExportFilter(q url.Values) (orders.Filter, error) {
+	f := orders.Filter{Status: q.Get("status")}
+	for name, dst := range map[string]*time.Time{"from": &f.From, "to": &f.To} {
+		if raw := q.Get(name); raw != "" {
+			t, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				return f, fmt.Errorf("%s must be an RFC 3339 time", name)
+			}
+			*dst = t
+		}
+	}
+	return f, nil
+}
+
+// streamExport writes rows in format, flushing every streamFlushBytes with
+// a fresh write deadline
+func (s *OrderService) streamExport(ctx context.Context, w http.ResponseWriter, format string, rows iter.Seq2[orders.Order, error]) (int, error) {
+	var buf bytes.Buffer
+	rc := http.NewResponseController(w)
+	attrs := metric.WithAttributes(attribute.String("format", format))
+	span := trace.SpanFromContext(ctx)
+
+	count := 0
+	var written int64
+	flush := func() error {
+		rc.SetWriteDeadline(time.Now().Add(exportWriteTimeout))
+		n, err := w.Write(buf.Bytes())
+		written += int64(n)
+		buf.Reset()
+		if err == nil {
+			err = rc.Flush()
+		}
+		return err
+	}
+	defer func() {
+		s.metrics.StreamedBytes.Add(ctx, written, attrs)
+		span.SetAttributes(attribute.Int64("export.bytes", written))
+	}()
+
+	var encode func(orders.Order) error
+	switch format {
+	case "csv":
+		cw := csv.NewWriter(&buf)
+		encode = func(o orders.Order) error {
+			cw.Write([]string{
+				o.ID, csvSafe(o.UserID), csvSafe(o.ProductID), strconv.Itoa(o.Quantity),
+				strconv.FormatFloat(o.Amount, 'f', 2, 64), o.Currency, o.Status, o.TraceID,
+				o.CreatedAt.UTC().Format(time.RFC3339),
+			})
+			cw.Flush()
+			return cw.Error()
+		}
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="orders.csv"`)
+		cw.Write(exportColumns)
+		cw.Flush()
+	default:
+		enc := json.NewEncoder(&buf)
+		encode = func(o orders.Order) error { return enc.Encode(o) }
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+	w.WriteHeader(http.StatusOK)
+
+	for o, err := range rows {
+		if err == nil {
+			err = ctx.Err()
+		}
+		if err == nil {
+			err = encode(o)
+		}
+		if err != nil {
+			flush()
+			return count, err
+		}
+		count++
+		s.metrics.ExportedRows.Add(ctx, 1, attrs)
+		if count%exportProgressRows == 0 {
+			span.AddEvent("export_progress", trace.WithAttributes(attribute.Int("export.rows", count)))
+		}
+
+		if buf.Len() >= streamFlushBytes {
+			if err := flush(); err != nil {
+				return count, err
+			}
+		}
+	}
+	return count, flush()
+}
+
+// csvSafe keeps client-supplied values from being read as formulas when
+// the export is opened in a spreadsheet
+func csvSafe(v string) string {
+	if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
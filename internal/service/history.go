@@ -0,0 +1,234 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"go-observability-demo/internal/apperr"
+	"go-observability-demo/internal/middleware"
+	"go-observability-demo/internal/orders"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// UserIDHeader identifies the caller, as set by the authenticating gateway
+// in front of the service
+const UserIDHeader = middleware.UserIDHeader
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// WithOrderStore keeps placed orders somewhere other than in process
+func WithOrderStore(store orders.Store) Option {
+	return func(s *OrderService) {
+		s.orders = store
+	}
+}
+
+// WithAdminToken lets requests with the admin bearer token read any user's
+// orders
+func WithAdminToken(token string) Option {
+	return func(s *OrderService) {
+		s.adminToken = token
+	}
+}
+
+// OrderHistoryResponse is a page of a user's orders, newest first
+type OrderHistoryResponse struct {
+	Orders     []orders.Order `json:"orders"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+// ListUserOrdersHandler serves GET /users/{id}/orders?limit=&cursor=. Users
+// may only list their own orders unless the request is an admin's.
+// Requests are counted in orders.history.requests with user.id bounded to
+// ORDER_HISTORY_MAX_USERS distinct values.
+func (s *OrderService) ListUserOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("id")
+	ctx, span := s.tracer.Start(r.Context(), "ListUserOrders",
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attribute.String("user.id", userID)),
+	)
+	defer span.End()
+
+	outcome := "success"
+	defer func() {
+		s.metrics.HistoryRequests.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("user.id", s.historyUsers.Value(userID)),
+			attribute.String("outcome", outcome),
+		))
+	}()
+	fail := func(status int, reason, msg string) {
+		outcome = reason
+		span.SetStatus(codes.Error, msg)
+		writeError(ctx, w, status, msg)
+	}
+
+	caller := r.Header.Get(UserIDHeader)
+	admin := middleware.HasAdminToken(r, s.adminToken)
+	span.SetAttributes(attribute.Bool("auth.admin", admin))
+	switch {
+	case caller == "" && !admin:
+		fail(http.StatusUnauthorized, "unauthorized", "missing "+UserIDHeader)
+		return
+	case caller != userID && !admin:
+		s.logger.WarnContext(ctx, "order history denied",
+			slog.String("user_id", userID),
+			slog.String("caller", caller),
+		)
+		fail(http.StatusForbidden, "forbidden", "not allowed to read this user's orders")
+		return
+	}
+
+	page, err := parsePage(r)
+	if err != nil {
+		fail(http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	list, next, err := s.orders.ListByUser(ctx, userID, page)
+	if err != nil {
+		if errors.Is(err, orders.ErrInvalidCursor) {
+			fail(http.StatusBadRequest, "invalid_request", err.Error())
+			return
+		}
+		span.RecordError(err)
+		s.logger.ErrorContext(ctx, "failed to list orders", slog.String("error", err.Error()))
+		fail(http.StatusInternalServerError, "error", "failed to list orders")
+		return
+	}
+
+	span.SetAttributes(
+		attribute.Int("orders.returned", len(list)),
+		attribute.Bool("orders.has_more", next != ""),
+	)
+	writeJSON(w, http.StatusOK, OrderHistoryResponse{Orders: list, NextCursor: next})
+}
+
+// ListOrdersHandler serves GET /orders?limit=&cursor=, the caller's orders
+// newest first, or every order for an admin. Each page is its own
+// ListOrders span with the page size, whether it continues from a cursor,
+// and how many orders it returned, so paging through a long list reads as a
+// series of traces that can be compared.
+func (s *OrderService) ListOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, span := s.tracer.Start(r.Context(), "ListOrders", trace.WithSpanKind(trace.SpanKindServer))
+	defer span.End()
+
+	caller := r.Header.Get(UserIDHeader)
+	admin := middleware.HasAdminToken(r, s.adminToken)
+	span.SetAttributes(attribute.Bool("auth.admin", admin))
+	if caller == "" && !admin {
+		s.fail(ctx, w, "order list rejected", apperr.New(apperr.Unauthorized, "missing "+UserIDHeader))
+		return
+	}
+
+	page, err := parsePage(r)
+	if err != nil {
+		s.fail(ctx, w, "invalid order list request", apperr.Wrap(apperr.InvalidRequest, err, err.Error()))
+		return
+	}
+	span.SetAttributes(
+		attribute.Int("page.size", page.Limit),
+		attribute.Bool("page.first", page.Cursor == ""),
+	)
+
+	var list []orders.Order
+	var next string
+	if admin {
+		list, next, err = s.orders.List(ctx, page)
+	} else {
+		span.SetAttributes(attribute.String("user.id", caller))
+		list, next, err = s.orders.ListByUser(ctx, caller, page)
+	}
+	switch {
+	case errors.Is(err, orders.ErrInvalidCursor):
+		s.fail(ctx, w, "invalid order list request", apperr.Wrap(apperr.InvalidRequest, err, err.Error()))
+		return
+	case err != nil:
+		s.fail(ctx, w, "failed to list orders", apperr.Wrap(apperr.Internal, err, "failed to list orders"))
+		return
+	}
+
+	span.SetAttributes(
+		attribute.Int("orders.returned", len(list)),
+		attribute.Bool("orders.has_more", next != ""),
+	)
+	writeJSON(w, http.StatusOK, OrderHistoryResponse{Orders: list, NextCursor: next})
+}
+
+// parsePage reads the limit and cursor query parameters
+func parsePage(r *http.Request) (orders.Page, error) {
+	page := orders.Page{Limit: defaultPageSize, Cursor: r.URL.Query().Get("cursor")}
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 || limit > maxPageSize {
+			return page, fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+		}
+		page.Limit = limit
+	}
+	return page, nil
+}
+
+// GetOrderHandler serves GET /orders/{id}. Orders of other users are
+// reported as not found, unless the request is an admin's, so IDs cannot be
+// probed. Reads go through the order store's cache and are counted in
+// orders.lookups by outcome.
+func (s *OrderService) GetOrderHandler(w http.ResponseWriter, r *http.Request) {
+	orderID := r.PathValue("id")
+	ctx, span := s.tracer.Start(r.Context(), "GetOrder",
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attribute.String("order.id", orderID)),
+	)
+	defer span.End()
+
+	outcome := "found"
+	defer func() {
+		s.metrics.OrderLookups.Add(ctx, 1, metric.WithAttributes(attribute.String("outcome", outcome)))
+	}()
+
+	caller := r.Header.Get(UserIDHeader)
+	admin := middleware.HasAdminToken(r, s.adminToken)
+	span.SetAttributes(attribute.Bool("auth.admin", admin))
+	if caller == "" && !admin {
+		outcome = "unauthorized"
+		span.SetStatus(codes.Error, "missing "+UserIDHeader)
+		writeError(ctx, w, http.StatusUnauthorized, "missing "+UserIDHeader)
+		return
+	}
+
+	order, err := s.orders.Get(ctx, orderID)
+	if err == nil && order.UserID != caller && !admin {
+		s.logger.WarnContext(ctx, "order read denied",
+			slog.String("order_id", orderID),
+			slog.String("caller", caller),
+		)
+		err = orders.ErrNotFound
+	}
+	switch {
+	case errors.Is(err, orders.ErrNotFound):
+		// A miss is an answer, not a failure of this span
+		outcome = "not_found"
+		writeCodedError(ctx, w, http.StatusNotFound, apperr.OrderNotFound, "order not found")
+		return
+	case err != nil:
+		outcome = "error"
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to read order")
+		s.logger.ErrorContext(ctx, "failed to read order", slog.String("error", err.Error()))
+		writeError(ctx, w, http.StatusInternalServerError, "failed to read order")
+		return
+	}
+
+	span.SetAttributes(
+		attribute.String("user.id", order.UserID),
+		attribute.String("order.status", order.Status),
+	)
+	writeJSON(w, http.StatusOK, order)
+}
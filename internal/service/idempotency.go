@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"go-observability-demo/internal/locks"
+	"go-observability-demo/internal/observability"
+	"log/slog"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// IdempotencyKeyHeader lets clients retry an order without placing it twice
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// WithLocker shares idempotency locks between replicas; the default only
+// covers requests served by this instance
+func WithLocker(l locks.Locker) Option {
+	return func(s *OrderService) {
+		s.locker = l
+	}
+}
+
+// claimIdempotencyKey locks the request's Idempotency-Key, scoped to the
+// user, for IDEMPOTENCY_TTL. It returns false if the key is held, i.e. the
+// same order is in flight or was placed within the TTL. release gives the
+// key back so a failed order can be retried. Locker errors fail open.
+func (s *OrderService) claimIdempotencyKey(ctx context.Context, r *http.Request, userID string) (release func(), ok bool) {
+	idemKey := r.Header.Get(IdempotencyKeyHeader)
+	if idemKey == "" {
+		return func() {}, true
+	}
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.String("order.idempotency_key", idemKey))
+
+	key := "idempotency:" + userID + ":" + idemKey
+	token, ok, err := s.locker.TryLock(ctx, key, s.idempotencyTTL)
+	if err != nil {
+		span.AddEvent("idempotency_check_skipped")
+		observability.LoggerFromContext(ctx).WarnContext(ctx, "idempotency lock unavailable, continuing without it",
+			slog.String("error", err.Error()),
+		)
+		return func() {}, true
+	}
+	if !ok {
+		return nil, false
+	}
+	return func() {
+		// The request context may already be cancelled
+		if err := s.locker.Unlock(context.WithoutCancel(ctx), key, token); err != nil {
+			observability.LoggerFromContext(ctx).WarnContext(ctx, "failed to release idempotency lock",
+				slog.String("error", err.Error()),
+			)
+		}
+	}, true
+}
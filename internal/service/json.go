@@ -0,0 +1,197 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-observability-demo/internal/apperr"
+	"go-observability-demo/internal/observability"
+	"iter"
+	"net/http"
+	"sync"
+
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	maxRequestBodyBytes = 1 << 20 // 1 MiB
+	maxJSONDepth        = 32
+
+	// Buffers that grew past this are dropped instead of pooled so one large
+	// payload doesn't pin memory for the life of the process
+	maxPooledBufferBytes = 64 << 10
+
+	// Streaming responses flush once this much is buffered
+	streamFlushBytes = 32 << 10
+)
+
+var errJSONTooDeep = errors.New("json nesting too deep")
+
+// jsonCodec pairs a buffer with an encoder writing into it so both are reused
+type jsonCodec struct {
+	buf bytes.Buffer
+	enc *json.Encoder
+}
+
+var codecPool = sync.Pool{
+	New: func() any {
+		c := &jsonCodec{}
+		c.enc = json.NewEncoder(&c.buf)
+		return c
+	},
+}
+
+func getCodec() *jsonCodec {
+	return codecPool.Get().(*jsonCodec)
+}
+
+func putCodec(c *jsonCodec) {
+	if c.buf.Cap() > maxPooledBufferBytes {
+		return
+	}
+	c.buf.Reset()
+	codecPool.Put(c)
+}
+
+// decodeJSON reads a size-limited request body into a pooled buffer, rejects
+// overly nested documents, and unmarshals it into v
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	c := getCodec()
+	defer putCodec(c)
+
+	if _, err := c.buf.ReadFrom(http.MaxBytesReader(w, r.Body, maxRequestBodyBytes)); err != nil {
+		return fmt.Errorf("read body: %w", err)
+	}
+	if err := checkJSONDepth(c.buf.Bytes(), maxJSONDepth); err != nil {
+		return err
+	}
+	return json.Unmarshal(c.buf.Bytes(), v)
+}
+
+// writeJSON encodes v into a pooled buffer first so a failed encode never
+// sends a partial body, then writes it with the given status
+func writeJSON(w http.ResponseWriter, status int, v any) error {
+	c := getCodec()
+	defer putCodec(c)
+
+	if err := c.enc.Encode(v); err != nil {
+		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, err := w.Write(c.buf.Bytes())
+	return err
+}
+
+// writeError sends a JSON error body carrying the trace ID and, when
+// TRACE_URL_TEMPLATE is set, a link to the trace
+func writeError(ctx context.Context, w http.ResponseWriter, status int, msg string) {
+	writeCodedError(ctx, w, status, "", msg)
+}
+
+func writeCodedError(ctx context.Context, w http.ResponseWriter, status int, code apperr.Code, msg string) {
+	resp := ErrorResponse{
+		Error:    msg,
+		Code:     code,
+		TraceURL: observability.TraceURL(ctx),
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		resp.TraceID = sc.TraceID().String()
+	}
+	writeJSON(w, status, resp)
+}
+
+// checkJSONDepth scans the raw document and fails once objects/arrays nest
+// deeper than maxDepth, before any allocation-heavy unmarshalling happens
+func checkJSONDepth(data []byte, maxDepth int) error {
+	depth := 0
+	inString := false
+	escaped := false
+
+	for _, b := range data {
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case b == '\\':
+				escaped = true
+			case b == '"':
+				inString = false
+			}
+			continue
+		}
+
+		switch b {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+			if depth > maxDepth {
+				return errJSONTooDeep
+			}
+		case '}', ']':
+			depth--
+		}
+	}
+	return nil
+}
+
+// streamJSONArray writes rows as a JSON array while they are produced, so
+// memory stays flat no matter how many rows there are. Once the first byte is
+// sent the status can no longer change; an error from rows stops the stream
+// and leaves the array unterminated so clients see a truncated document
+// rather than a silently short one. The bytes written are recorded on counter.
+func streamJSONArray[T any](ctx context.Context, w http.ResponseWriter, counter metric.Int64Counter, attrs metric.MeasurementOption, rows iter.Seq2[T, error]) (int, error) {
+	c := getCodec()
+	defer putCodec(c)
+
+	flusher, _ := w.(http.Flusher)
+	var written int64
+	flush := func() error {
+		n, err := w.Write(c.buf.Bytes())
+		written += int64(n)
+		c.buf.Reset()
+		if err == nil && flusher != nil {
+			flusher.Flush()
+		}
+		return err
+	}
+	defer func() {
+		counter.Add(ctx, written, attrs)
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	c.buf.WriteByte('[')
+
+	count := 0
+	for row, err := range rows {
+		if err != nil {
+			flush()
+			return count, err
+		}
+		if count > 0 {
+			c.buf.WriteByte(',')
+		}
+		// Encoder appends a newline, which keeps large arrays grep-friendly
+		if err := c.enc.Encode(row); err != nil {
+			flush()
+			return count, err
+		}
+		count++
+
+		if c.buf.Len() >= streamFlushBytes {
+			if err := flush(); err != nil {
+				return count, err
+			}
+		}
+	}
+
+	c.buf.WriteString("]\n")
+	return count, flush()
+}
@@ -1,206 +1,609 @@
 package service
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"go-observability-demo/internal/apperr"
+	"go-observability-demo/internal/archive"
+	"go-observability-demo/internal/config"
+	"go-observability-demo/internal/errorreport"
+	"go-observability-demo/internal/faults"
+	"go-observability-demo/internal/featureflags"
+	"go-observability-demo/internal/fx"
+	"go-observability-demo/internal/httpclient"
+	"go-observability-demo/internal/inventory"
+	"go-observability-demo/internal/locks"
+	"go-observability-demo/internal/middleware"
+	"go-observability-demo/internal/notifications"
 	"go-observability-demo/internal/observability"
+	"go-observability-demo/internal/observability/baggage"
+	"go-observability-demo/internal/orders"
+	"go-observability-demo/internal/pricing"
+	"go-observability-demo/internal/quota"
+	"go-observability-demo/internal/shipping"
 	"log/slog"
 	"math/rand"
 	"net/http"
+	"runtime"
+	"strings"
+	"sync/atomic"
 	"time"
 
-	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/errgroup"
 )
 
 type OrderService struct {
 	tracer          trace.Tracer
 	logger          *slog.Logger
 	metrics         *observability.Metrics
+	faults          *faults.Injector
+	errorReporter   *errorreport.Reporter
+	flags           *featureflags.Flags
+	archiver        *archive.Archiver
+	quota           *quota.Quota
+	pricing         *pricing.Engine
+	fx              *fx.Converter
+	shipping        *shipping.Client
+	inventory       *inventory.Stock
+	notifier        *notifications.Notifier
+	orders          orders.Store
+	historyUsers    *observability.BoundedValues
+	adminToken      string
+	shadowSecret    string
+	locker          locks.Locker
+	idempotencyTTL  time.Duration
+	duplicates      DuplicateConfig
+	budgets         Budgets
 	paymentClient   *http.Client
 	inventoryClient *http.Client
 }
 
+// Simulated downstream behaviour, overridable via FAULT_<STEP>_* env vars
+var defaultFaults = map[string]faults.Step{
+	"inventory": {Latency: faults.LogNormal{Median: 50 * time.Millisecond, Sigma: 0.35}, FailureRate: 0.1},
+	// One payment in ten takes the gateway's slow path, around 3s
+	"payment": {
+		Latency: faults.Bimodal{
+			Fast:      faults.LogNormal{Median: 120 * time.Millisecond, Sigma: 0.4},
+			Slow:      faults.LogNormal{Median: 3 * time.Second, Sigma: 0.2},
+			SlowRatio: 0.1,
+		},
+		FailureRate: 0.05,
+	},
+	"reserve": {Latency: faults.LogNormal{Median: 65 * time.Millisecond, Sigma: 0.3}},
+	"refund":  {Latency: faults.LogNormal{Median: 90 * time.Millisecond, Sigma: 0.4}, FailureRate: 0.02},
+}
+
+// Metric attributes for the status and each error code's error.type, built
+// once so recording a measurement does not allocate on every request
+var (
+	successAttrs   = attributeSet(attribute.String("status", "success"))
+	errorTypeAttrs = func() map[apperr.Code]metric.MeasurementOption {
+		attrs := map[apperr.Code]metric.MeasurementOption{}
+		for _, code := range apperr.Codes() {
+			attrs[code] = attributeSet(code.Attribute())
+		}
+		return attrs
+	}()
+)
+
+func attributeSet(attrs ...attribute.KeyValue) metric.MeasurementOption {
+	return metric.WithAttributeSet(attribute.NewSet(attrs...))
+}
+
+// CreateOrderRequest is the order as sent by the client. With pricing
+// enabled the amount is computed server-side and Amount is only compared.
+// Currency is an ISO 4217 code, fx.ReportingCurrency when empty.
 type CreateOrderRequest struct {
 	UserID    string  `json:"user_id"`
 	ProductID string  `json:"product_id"`
 	Quantity  int     `json:"quantity"`
-	Amount    float64 `json:"amount"`
+	Amount    float64 `json:"amount,omitempty"`
+	PromoCode string  `json:"promo_code,omitempty"`
+	Currency  string  `json:"currency,omitempty"`
+	// Shadow is set for mirrored requests (X-Shadow-Request): the order is
+	// priced and checked, but not charged, reserved, stored, or announced,
+	// and it spends no quota and claims no idempotency key
+	Shadow bool `json:"-"`
 }
 
 type CreateOrderResponse struct {
-	Status  string `json:"status"`
-	OrderID string `json:"order_id"`
-	TraceID string `json:"trace_id"`
+	Status   string             `json:"status"`
+	OrderID  string             `json:"order_id"`
+	Amount   float64            `json:"amount"`
+	Currency string             `json:"currency"`
+	Shipping *shipping.Estimate `json:"shipping,omitempty"`
+	TraceID  string             `json:"trace_id"`
 }
 
-func NewOrderService(logger *slog.Logger, metrics *observability.Metrics) *OrderService {
-	return &OrderService{
-		tracer:  otel.Tracer("order-service"),
-		logger:  logger,
-		metrics: metrics,
-		paymentClient: &http.Client{
-			Transport: otelhttp.NewTransport(http.DefaultTransport),
-			Timeout:   5 * time.Second,
-		},
-		inventoryClient: &http.Client{
-			Transport: otelhttp.NewTransport(http.DefaultTransport),
-			Timeout:   5 * time.Second,
-		},
+// placedOrder is the outcome of processOrder. Shipping is nil when no
+// estimate was available; it never fails the order.
+type placedOrder struct {
+	ID       string
+	Shipping *shipping.Estimate
+}
+
+type ErrorResponse struct {
+	Error string `json:"error"`
+	// Code is the apperr code, e.g. PAYMENT_DECLINED, when there is one
+	Code     apperr.Code `json:"code,omitempty"`
+	TraceID  string      `json:"trace_id,omitempty"`
+	TraceURL string      `json:"trace_url,omitempty"`
+}
+
+// Option configures optional OrderService dependencies
+type Option func(*OrderService)
+
+// WithErrorReporter reports processing failures to Sentry
+func WithErrorReporter(r *errorreport.Reporter) Option {
+	return func(s *OrderService) {
+		s.errorReporter = r
+	}
+}
+
+// WithArchiver archives completed orders to object storage
+func WithArchiver(a *archive.Archiver) Option {
+	return func(s *OrderService) {
+		s.archiver = a
+	}
+}
+
+// WithQuota limits orders per user or tenant
+func WithQuota(q *quota.Quota) Option {
+	return func(s *OrderService) {
+		s.quota = q
+	}
+}
+
+// WithPricing computes the order amount server-side instead of trusting
+// the client
+func WithPricing(p *pricing.Engine) Option {
+	return func(s *OrderService) {
+		s.pricing = p
+	}
+}
+
+// WithShipping adds a shipping estimate to each order
+func WithShipping(c *shipping.Client) Option {
+	return func(s *OrderService) {
+		s.shipping = c
+	}
+}
+
+// WithInventory checks and reserves against tracked stock levels
+func WithInventory(stock *inventory.Stock) Option {
+	return func(s *OrderService) {
+		s.inventory = stock
+	}
+}
+
+// WithNotifier notifies customers of placed orders on their preferred
+// channels
+func WithNotifier(n *notifications.Notifier) Option {
+	return func(s *OrderService) {
+		s.notifier = n
 	}
 }
 
+// WithDownstreamClients replaces the default payment and inventory clients,
+// e.g. with ones tuned by httpclient.ConfigFromEnv
+func WithDownstreamClients(payment, inventory *http.Client) Option {
+	return func(s *OrderService) {
+		s.paymentClient = payment
+		s.inventoryClient = inventory
+	}
+}
+
+// WithShadowSecret treats requests whose ShadowHeader carries secret, the
+// mirror's MIRROR_SECRET, as shadow orders
+func WithShadowSecret(secret string) Option {
+	return func(s *OrderService) {
+		s.shadowSecret = secret
+	}
+}
+
+// WithFeatureFlags evaluates rollout flags such as the payment provider
+func WithFeatureFlags(f *featureflags.Flags) Option {
+	return func(s *OrderService) {
+		s.flags = f
+	}
+}
+
+// NewOrderService fails when a FAULT_* override cannot be parsed
+func NewOrderService(logger *slog.Logger, metrics *observability.Metrics, opts ...Option) (*OrderService, error) {
+	injector, err := faults.FromEnv(defaultFaults)
+	if err != nil {
+		return nil, fmt.Errorf("invalid fault injection config: %w", err)
+	}
+
+	s := &OrderService{
+		tracer:          otel.Tracer("order-service"),
+		logger:          logger,
+		metrics:         metrics,
+		faults:          injector,
+		budgets:         BudgetsFromEnv(),
+		orders:          orders.NewMemoryStore(),
+		historyUsers:    observability.NewBoundedValues(config.Int("ORDER_HISTORY_MAX_USERS", 100)),
+		locker:          locks.NewMemoryLocker(),
+		idempotencyTTL:  config.Duration("IDEMPOTENCY_TTL", 24*time.Hour),
+		duplicates:      DuplicateConfigFromEnv(),
+		paymentClient:   httpclient.New("payment", httpclient.DefaultConfig(), metrics),
+		inventoryClient: httpclient.New("inventory", httpclient.DefaultConfig(), metrics),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s, nil
+}
+
 func (s *OrderService) CreateOrderHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	start := time.Now()
 
 	// Create main span
-	ctx, span := s.tracer.Start(ctx, "CreateOrder",
+	ctx, rawSpan := s.tracer.Start(ctx, "CreateOrder",
 		trace.WithSpanKind(trace.SpanKindServer),
 	)
+	span := observability.NewLazySpan(rawSpan)
 	defer span.End()
 
-	observability.InfoWithTrace(ctx, s.logger, "order creation started")
+	// Logs for this request, here and in processOrder, carry its fields
+	logger := s.logger
+	// Same tag Envoy uses, so mesh access logs can be joined to the trace
+	if id := observability.MeshRequestID(ctx); id != "" {
+		span.SetAttributes(attribute.String("guid:x-request-id", id))
+		logger = logger.With(slog.String("request_id", id))
+	}
+	ctx = observability.ContextWithLogger(ctx, logger)
+
+	logger.InfoContext(ctx, "order creation started")
 
 	// Parse request
 	var req CreateOrderRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		span.RecordError(err)
-		span.SetStatus(codes.Error, "invalid request body")
-		observability.ErrorWithTrace(ctx, s.logger, "failed to parse request", slog.String("error", err.Error()))
-		http.Error(w, "invalid request", http.StatusBadRequest)
-		s.metrics.ErrorCounter.Add(ctx, 1, metric.WithAttributes(
-			attribute.String("error.type", "invalid_request"),
-		))
+	if err := decodeJSON(w, r, &req); err != nil {
+		s.fail(ctx, w, "failed to parse request", apperr.Wrap(apperr.InvalidRequest, err, "invalid request"))
 		return
 	}
+	if middleware.IsShadow(r, s.shadowSecret) {
+		req.Shadow = true
+		span.SetAttributes(attribute.Bool("order.shadow", true))
+		logger = logger.With(slog.Bool("shadow", true))
+		ctx = observability.ContextWithLogger(ctx, logger)
+	}
 
 	// Validate request
 	if err := s.validateRequest(req); err != nil {
-		span.RecordError(err)
-		span.SetStatus(codes.Error, "validation failed")
-		observability.ErrorWithTrace(ctx, s.logger, "request validation failed", slog.String("error", err.Error()))
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		s.metrics.ErrorCounter.Add(ctx, 1, metric.WithAttributes(
-			attribute.String("error.type", "validation_error"),
-		))
+		s.fail(ctx, w, "request validation failed", err)
+		return
+	}
+
+	// Price the order; from here on the amount is ours, not the client's
+	req.Currency = strings.ToUpper(cmp.Or(req.Currency, fx.ReportingCurrency))
+	quote, err := s.pricing.Quote(ctx, pricing.Order{
+		ProductID: req.ProductID,
+		Quantity:  req.Quantity,
+		PromoCode: req.PromoCode,
+		Amount:    req.Amount,
+		Currency:  req.Currency,
+	})
+	if err != nil {
+		s.fail(ctx, w, "order could not be priced", apperr.Wrap(apperr.PricingFailed, err, ""))
 		return
 	}
 
+	// Charge in the order's currency, report in the reporting currency
+	charge, reportedAmount, err := s.chargeAmounts(ctx, req, quote)
+	if err != nil {
+		code := apperr.CurrencyUnavailable
+		if errors.Is(err, fx.ErrUnsupportedCurrency) {
+			code = apperr.UnsupportedCurrency
+		}
+		s.fail(ctx, w, "order amount could not be converted", apperr.Wrap(code, err, ""),
+			slog.String("currency", req.Currency),
+		)
+		return
+	}
+	if req.Currency != fx.ReportingCurrency {
+		// Quote could only compare amounts in the catalog's currency
+		s.pricing.CheckClientAmount(ctx, req.Amount, charge)
+	}
+	req.Amount = charge
+
+	// Downstream calls, child spans, and logs carry the user and tenant
+	ctx = baggage.WithUserID(ctx, req.UserID)
+	ctx = baggage.WithTenantID(ctx, r.Header.Get(quota.TenantHeader))
+	logger = logger.With(slog.String("user_id", req.UserID))
+	ctx = observability.ContextWithLogger(ctx, logger)
+
+	// A shadow copies an order the primary already admitted; charging its
+	// quota or claiming its keys would reject or block the real one
+	release, releaseFingerprint := func() {}, func() {}
+	if !req.Shadow {
+		// Enforce the user's quota, and the tenant's if any, before doing any work
+		decision := s.quota.Allow(ctx, quota.Keys(r, req.UserID)...)
+		decision.SetHeaders(w)
+		if !decision.Allowed {
+			s.fail(ctx, w, "order quota exceeded", apperr.New(apperr.QuotaExceeded, "order quota exceeded"),
+				slog.Int64("limit", decision.Limit),
+			)
+			return
+		}
+
+		// Reject a retry of an order that is in flight or already placed
+		var ok bool
+		release, ok = s.claimIdempotencyKey(ctx, r, req.UserID)
+		if !ok {
+			s.fail(ctx, w, "duplicate order rejected",
+				apperr.New(apperr.DuplicateRequest, "an order with this Idempotency-Key is in progress or already placed"))
+			return
+		}
+
+		// Catch double submits that came without an Idempotency-Key
+		var blocked bool
+		releaseFingerprint, blocked = s.checkDuplicate(ctx, req)
+		if blocked {
+			release()
+			s.fail(ctx, w, "duplicate order rejected", apperr.New(apperr.DuplicateOrder, "an identical order was placed moments ago"))
+			return
+		}
+	}
+
 	// Add request attributes to span
 	span.SetAttributes(
 		attribute.String("user.id", req.UserID),
 		attribute.String("product.id", req.ProductID),
 		attribute.Int("order.quantity", req.Quantity),
 		attribute.Float64("order.amount", req.Amount),
+		attribute.String("order.currency", req.Currency),
 	)
 
+	// The full request summary is only serialized for sampled requests
+	span.SetAttributesLazy(func() []attribute.KeyValue {
+		summary, _ := json.Marshal(req)
+		return []attribute.KeyValue{attribute.String("order.request_summary", string(summary))}
+	})
+
 	// Process order
-	orderID, err := s.processOrder(ctx, req)
+	s.metrics.OrdersInFlight.Add(ctx, 1)
+	order, err := s.processOrder(ctx, req)
+	s.metrics.OrdersInFlight.Add(ctx, -1)
 	if err != nil {
-		span.RecordError(err)
-		span.SetStatus(codes.Error, err.Error())
-		observability.ErrorWithTrace(ctx, s.logger, "order processing failed",
-			slog.String("error", err.Error()),
-			slog.String("user_id", req.UserID),
-		)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		s.metrics.ErrorCounter.Add(ctx, 1, metric.WithAttributes(
-			attribute.String("error.type", "processing_error"),
-		))
+		release()
+		releaseFingerprint()
+		s.fail(ctx, w, "order processing failed", err)
+		// Declined payments and empty stock are outcomes, not bugs
+		if code := apperr.CodeOf(err); code.HTTPStatus() >= http.StatusInternalServerError {
+			s.errorReporter.CaptureError(ctx, err, req.UserID, map[string]string{
+				"error.type": code.ErrorType(),
+				"product.id": req.ProductID,
+			})
+		}
 		return
 	}
 
 	// Record metrics
 	duration := time.Since(start).Milliseconds()
-	s.metrics.OrderDuration.Record(ctx, float64(duration), metric.WithAttributes(
-		attribute.String("status", "success"),
-	))
-	s.metrics.OrderCounter.Add(ctx, 1, metric.WithAttributes(
-		attribute.String("status", "success"),
-	))
-	s.metrics.PaymentAmount.Add(ctx, req.Amount)
+	traceID := span.SpanContext().TraceID().String()
+	if req.Shadow {
+		// Nothing was charged or reserved, so there is nothing to record
+		span.AddEvent("shadow_order_discarded")
+		span.SetStatus(codes.Ok, "shadow order checked")
+		logger.InfoContext(ctx, "shadow order checked", slog.Int64("duration_ms", duration))
+		writeJSON(w, http.StatusCreated, CreateOrderResponse{
+			Status:   "success",
+			OrderID:  order.ID,
+			Amount:   req.Amount,
+			Currency: req.Currency,
+			Shipping: order.Shipping,
+			TraceID:  traceID,
+		})
+		return
+	}
+	s.metrics.OrderDuration.Record(ctx, float64(duration), successAttrs)
+	s.metrics.OrderCounter.Add(ctx, 1, successAttrs)
+	s.metrics.PaymentAmount.Add(ctx, reportedAmount, metric.WithAttributes(attribute.String("payment.currency", req.Currency)))
+
+	completedAt := time.Now()
+	if err := s.orders.Save(ctx, orders.Order{
+		ID:        order.ID,
+		UserID:    req.UserID,
+		ProductID: req.ProductID,
+		Quantity:  req.Quantity,
+		Amount:    req.Amount,
+		Currency:  req.Currency,
+		Status:    orders.StatusPlaced,
+		TraceID:   traceID,
+		CreatedAt: completedAt,
+	}); err != nil {
+		// The customer has been charged; the order is still archived
+		logger.ErrorContext(ctx, "failed to store order",
+			slog.String("order_id", order.ID),
+			slog.String("error", err.Error()),
+		)
+	}
+
+	s.archiver.Record(archive.OrderEvent{
+		OrderID:     order.ID,
+		UserID:      req.UserID,
+		ProductID:   req.ProductID,
+		Quantity:    req.Quantity,
+		Amount:      req.Amount,
+		Currency:    req.Currency,
+		TraceID:     traceID,
+		CompletedAt: completedAt,
+	})
+	s.notifier.Notify(ctx, notifications.OrderPlaced(order.ID, req.UserID, req.Amount, req.Currency))
 
 	span.SetStatus(codes.Ok, "order created successfully")
-	observability.InfoWithTrace(ctx, s.logger, "order created successfully",
-		slog.String("order_id", orderID),
+	logger.InfoContext(ctx, "order created successfully",
+		slog.String("order_id", order.ID),
 		slog.Int64("duration_ms", duration),
 	)
 
 	// Return response
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(CreateOrderResponse{
-		Status:  "success",
-		OrderID: orderID,
-		TraceID: span.SpanContext().TraceID().String(),
+	writeJSON(w, http.StatusCreated, CreateOrderResponse{
+		Status:   "success",
+		OrderID:  order.ID,
+		Amount:   req.Amount,
+		Currency: req.Currency,
+		Shipping: order.Shipping,
+		TraceID:  traceID,
 	})
 }
 
+// fail reports err once for every signal: the span status, a log line,
+// the error counter, and the JSON response, all derived from err's apperr
+// code
+func (s *OrderService) fail(ctx context.Context, w http.ResponseWriter, msg string, err error, attrs ...any) {
+	code := apperr.CodeOf(err)
+	status := code.HTTPStatus()
+	public := apperr.PublicMessage(err)
+
+	span := trace.SpanFromContext(ctx)
+	span.RecordError(err)
+	span.SetStatus(codes.Error, public)
+
+	level := code.LogLevel()
+	if logger := observability.LoggerFromContext(ctx); logger.Enabled(ctx, level) {
+		// Report the handler line, not this helper, as the source
+		var pcs [1]uintptr
+		runtime.Callers(2, pcs[:])
+		record := slog.NewRecord(time.Now(), level, msg, pcs[0])
+		record.AddAttrs(
+			slog.String("error", err.Error()),
+			slog.String("error_code", string(code)),
+		)
+		record.Add(attrs...)
+		_ = logger.Handler().Handle(ctx, record)
+	}
+
+	s.metrics.ErrorCounter.Add(ctx, 1, errorTypeAttrs[code])
+	writeCodedError(ctx, w, status, code, public)
+}
+
 func (s *OrderService) validateRequest(req CreateOrderRequest) error {
 	if req.UserID == "" {
-		return fmt.Errorf("user_id is required")
+		return apperr.New(apperr.ValidationFailed, "user_id is required")
 	}
 	if req.ProductID == "" {
-		return fmt.Errorf("product_id is required")
+		return apperr.New(apperr.ValidationFailed, "product_id is required")
 	}
 	if req.Quantity <= 0 {
-		return fmt.Errorf("quantity must be positive")
+		return apperr.New(apperr.ValidationFailed, "quantity must be positive")
 	}
-	if req.Amount <= 0 {
-		return fmt.Errorf("amount must be positive")
+	if req.Amount < 0 || (req.Amount == 0 && s.pricing == nil) {
+		return apperr.New(apperr.ValidationFailed, "amount must be positive")
 	}
 	return nil
 }
 
-func (s *OrderService) processOrder(ctx context.Context, req CreateOrderRequest) (string, error) {
-	// Step 1: Check inventory
-	if err := s.checkInventory(ctx, req.ProductID, req.Quantity); err != nil {
-		return "", fmt.Errorf("inventory check failed: %w", err)
+func (s *OrderService) processOrder(ctx context.Context, req CreateOrderRequest) (placedOrder, error) {
+	var order placedOrder
+
+	// Steps 1 and 2 are independent, so check inventory and process payment
+	// concurrently. Both spans are siblings under CreateOrder, and the first
+	// failure cancels the other step. The shipping estimate runs alongside
+	// but is best effort. Shadow orders skip the payment.
+	g, gctx := errgroup.WithContext(ctx)
+	if s.shipping != nil {
+		g.Go(func() error {
+			order.Shipping = s.estimateShipping(gctx, req)
+			return nil
+		})
 	}
-
-	// Step 2: Process payment
-	if err := s.processPayment(ctx, req.UserID, req.Amount); err != nil {
-		return "", fmt.Errorf("payment failed: %w", err)
+	g.Go(func() error {
+		if err := s.checkInventory(gctx, req.ProductID, req.Quantity); err != nil {
+			return fmt.Errorf("inventory check failed: %w", err)
+		}
+		return nil
+	})
+	var charged atomic.Bool
+	if !req.Shadow {
+		g.Go(func() error {
+			if err := s.processPayment(gctx, req.UserID, req.Amount, req.Currency); err != nil {
+				return fmt.Errorf("payment failed: %w", err)
+			}
+			charged.Store(true)
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		// The payment may have gone through before the inventory check failed
+		if charged.Load() {
+			s.compensatePayment(ctx, req, "inventory_check_failed")
+		}
+		return placedOrder{}, err
+	}
+	if req.Shadow {
+		order.ID = fmt.Sprintf("shadow-%d", time.Now().UnixNano())
+		return order, nil
 	}
 
 	// Step 3: Reserve inventory
 	if err := s.reserveInventory(ctx, req.ProductID, req.Quantity); err != nil {
-		return "", fmt.Errorf("inventory reservation failed: %w", err)
+		// Stock can run out between the check and the reservation
+		s.compensatePayment(ctx, req, "inventory_reservation_failed")
+		return placedOrder{}, fmt.Errorf("inventory reservation failed: %w", err)
 	}
 
 	// Generate order ID
-	orderID := fmt.Sprintf("order-%d", time.Now().UnixNano())
-	return orderID, nil
+	order.ID = fmt.Sprintf("order-%d", time.Now().UnixNano())
+	return order, nil
+}
+
+// estimateShipping returns nil instead of failing the order when the
+// shipping service is down or its breaker is open
+func (s *OrderService) estimateShipping(ctx context.Context, req CreateOrderRequest) *shipping.Estimate {
+	est, err := s.shipping.Estimate(ctx, shipping.Request{ProductID: req.ProductID, Quantity: req.Quantity})
+	if err != nil {
+		if ctx.Err() == nil {
+			attrs := []any{slog.String("error", err.Error())}
+			if retryAfter, ok := shipping.RetryAfter(err); ok {
+				attrs = append(attrs, slog.Duration("retry_after", retryAfter))
+			}
+			observability.LoggerFromContext(ctx).WarnContext(ctx, "shipping estimate unavailable", attrs...)
+		}
+		return nil
+	}
+	return &est
 }
 
 func (s *OrderService) checkInventory(ctx context.Context, productID string, quantity int) error {
 	ctx, span := s.tracer.Start(ctx, "CheckInventory")
 	defer span.End()
+	ctx, stop := s.watchBudget(ctx, "inventory")
+	defer stop()
 
 	span.SetAttributes(
 		attribute.String("product.id", productID),
 		attribute.Int("requested.quantity", quantity),
 	)
 
-	observability.DebugWithTrace(ctx, s.logger, "checking inventory",
+	observability.LoggerFromContext(ctx).DebugContext(ctx, "checking inventory",
 		slog.String("product_id", productID),
 		slog.Int("quantity", quantity),
 	)
 
 	// Simulate inventory check (in real app, this would be an HTTP call)
-	time.Sleep(time.Duration(30+rand.Intn(50)) * time.Millisecond)
+	if _, err := s.faults.Delay(ctx, "inventory"); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "inventory check interrupted")
+		return err
+	}
 
 	s.metrics.InventoryRequests.Add(ctx, 1)
 
-	// Simulate occasional inventory issues
-	if rand.Float64() < 0.1 {
-		err := fmt.Errorf("insufficient inventory")
+	// Out of stock, or a simulated inventory issue
+	if !s.inventory.Available(productID, quantity) || s.faults.ShouldFail("inventory") {
+		err := apperr.New(apperr.InventoryExhausted, "insufficient inventory")
 		span.RecordError(err)
 		span.SetStatus(codes.Error, "insufficient inventory")
 		return err
@@ -210,66 +613,126 @@ func (s *OrderService) checkInventory(ctx context.Context, productID string, qua
 	return nil
 }
 
-func (s *OrderService) processPayment(ctx context.Context, userID string, amount float64) error {
+// declineReasons are the reasons the simulated gateway gives, kept to the
+// short list real gateways map their codes onto
+var declineReasons = []string{"insufficient_funds", "card_expired", "suspected_fraud", "do_not_honor"}
+
+func (s *OrderService) processPayment(ctx context.Context, userID string, amount float64, currency string) error {
 	ctx, span := s.tracer.Start(ctx, "ProcessPayment")
 	defer span.End()
+	ctx, stop := s.watchBudget(ctx, "payment")
+	defer stop()
 
 	span.SetAttributes(
 		attribute.String("user.id", userID),
 		attribute.Float64("payment.amount", amount),
+		attribute.String("payment.currency", currency),
 	)
 
-	observability.DebugWithTrace(ctx, s.logger, "processing payment",
-		slog.String("user_id", userID),
+	observability.LoggerFromContext(ctx).DebugContext(ctx, "processing payment",
 		slog.Float64("amount", amount),
 	)
 
+	gateway := "stripe"
+	if s.flags.Bool(ctx, featureflags.NewPaymentProvider, false, userID) {
+		gateway = "adyen"
+	}
+	start := time.Now()
+	outcome := "interrupted"
+	defer func() {
+		s.metrics.PaymentDuration.Record(ctx, float64(time.Since(start).Milliseconds()), metric.WithAttributes(
+			attribute.String("payment.gateway", gateway),
+			attribute.String("payment.outcome", outcome),
+		))
+	}()
+
 	// Simulate payment processing
-	time.Sleep(time.Duration(80+rand.Intn(100)) * time.Millisecond)
+	if _, err := s.faults.Delay(ctx, "payment"); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "payment interrupted")
+		return err
+	}
 
 	span.AddEvent("payment_gateway_called", trace.WithAttributes(
-		attribute.String("gateway", "stripe"),
+		attribute.String("gateway", gateway),
 		attribute.String("payment.method", "credit_card"),
 	))
 
-	// Simulate occasional slow payments (10% of time)
-	if rand.Intn(10) == 0 {
-		span.AddEvent("payment_slow_path")
-		observability.WarnWithTrace(ctx, s.logger, "payment processing slow")
-		time.Sleep(3 * time.Second)
-	}
-
 	// Simulate occasional payment failures
-	if rand.Float64() < 0.05 {
-		err := fmt.Errorf("payment declined")
+	if s.faults.ShouldFail("payment") {
+		outcome = "declined"
+		reason := declineReasons[rand.Intn(len(declineReasons))]
+		s.metrics.PaymentsDeclined.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("payment.gateway", gateway),
+			attribute.String("payment.decline_reason", reason),
+		))
+		span.SetAttributes(attribute.String("payment.decline_reason", reason))
+		err := apperr.New(apperr.PaymentDeclined, "payment declined")
 		span.RecordError(err)
 		span.SetStatus(codes.Error, "payment declined")
 		return err
 	}
 
+	outcome = "success"
 	span.AddEvent("payment_completed")
 	span.SetStatus(codes.Ok, "payment successful")
 	return nil
 }
 
+// compensatePayment refunds a charge for an order that could not be placed,
+// and records the attempt as a compensation event on the order span. The
+// refund runs even if the request was cancelled, since the charge stands.
+func (s *OrderService) compensatePayment(ctx context.Context, req CreateOrderRequest, reason string) {
+	ctx = context.WithoutCancel(ctx)
+	outcome := "refunded"
+	err := s.refundPayment(ctx, req.UserID, req.Amount, req.Currency)
+	if err != nil {
+		outcome = "failed"
+		observability.LoggerFromContext(ctx).ErrorContext(ctx, "refund of an unplaced order failed",
+			slog.String("error", err.Error()),
+			slog.String("reason", reason),
+			slog.Float64("amount", req.Amount),
+		)
+	}
+	trace.SpanFromContext(ctx).AddEvent("compensation", trace.WithAttributes(
+		attribute.String("compensation.step", "refund_payment"),
+		attribute.String("compensation.reason", reason),
+		attribute.String("compensation.outcome", outcome),
+	))
+}
+
 func (s *OrderService) reserveInventory(ctx context.Context, productID string, quantity int) error {
 	ctx, span := s.tracer.Start(ctx, "ReserveInventory")
 	defer span.End()
+	ctx, stop := s.watchBudget(ctx, "reserve")
+	defer stop()
 
 	span.SetAttributes(
 		attribute.String("product.id", productID),
 		attribute.Int("quantity", quantity),
 	)
 
-	observability.DebugWithTrace(ctx, s.logger, "reserving inventory",
+	observability.LoggerFromContext(ctx).DebugContext(ctx, "reserving inventory",
 		slog.String("product_id", productID),
 		slog.Int("quantity", quantity),
 	)
 
 	// Simulate database operation
-	start := time.Now()
-	time.Sleep(time.Duration(40+rand.Intn(60)) * time.Millisecond)
-	duration := time.Since(start)
+	duration, err := s.faults.Delay(ctx, "reserve")
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "inventory reservation interrupted")
+		return err
+	}
+
+	if err := s.inventory.Reserve(ctx, productID, quantity); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "insufficient inventory")
+		if errors.Is(err, inventory.ErrInsufficientStock) {
+			return apperr.Wrap(apperr.InventoryExhausted, err, "")
+		}
+		return err
+	}
 
 	span.SetAttributes(
 		attribute.Int64("db.duration_ms", duration.Milliseconds()),
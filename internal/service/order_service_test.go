@@ -3,37 +3,68 @@ package service
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"go-observability-demo/internal/apperr"
+	"go-observability-demo/internal/faults"
+	"go-observability-demo/internal/fx"
+	"go-observability-demo/internal/inventory"
+	"go-observability-demo/internal/middleware"
 	"go-observability-demo/internal/observability"
+	"go-observability-demo/internal/observability/observabilitytest"
+	"go-observability-demo/internal/orders"
+	"go-observability-demo/internal/pricing"
+	"go-observability-demo/internal/quota"
+	"go-observability-demo/internal/shipping"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
-	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
-func setupTestService(t *testing.T) (*OrderService, *tracetest.InMemoryExporter) {
-	// Create in-memory exporter for testing
-	exporter := tracetest.NewInMemoryExporter()
-	tp := trace.NewTracerProvider(
-		trace.WithSyncer(exporter),
-	)
-	otel.SetTracerProvider(tp)
+func setupTestService(t testing.TB) (*OrderService, *observabilitytest.Recorder) {
+	// Install in-memory exporters for spans, metrics, and logs
+	recorder := observabilitytest.New(t)
 
-	logger := observability.NewLogger()
 	metrics, err := observability.NewMetrics()
 	if err != nil {
 		t.Fatalf("Failed to create metrics: %v", err)
 	}
 
-	service := NewOrderService(logger, metrics)
-	return service, exporter
+	service, err := NewOrderService(recorder.Logger, metrics)
+	if err != nil {
+		t.Fatalf("Failed to create order service: %v", err)
+	}
+	// No simulated latency or random failures so assertions are deterministic
+	service.faults = faults.NewInjector(1, nil)
+	return service, recorder
+}
+
+func TestNewOrderService_RejectsInvalidFaults(t *testing.T) {
+	metrics, err := observability.NewMetrics()
+	if err != nil {
+		t.Fatalf("Failed to create metrics: %v", err)
+	}
+	t.Setenv("FAULT_PAYMENT_LATENCY", "lognormal:fast")
+	if _, err := NewOrderService(observabilitytest.New(t).Logger, metrics); err == nil {
+		t.Error("Expected an error for an invalid FAULT_PAYMENT_LATENCY")
+	}
 }
 
 func TestCreateOrderHandler_Success(t *testing.T) {
-	service, exporter := setupTestService(t)
+	service, recorder := setupTestService(t)
 
 	reqBody := CreateOrderRequest{
 		UserID:    "test-user",
@@ -63,7 +94,7 @@ func TestCreateOrderHandler_Success(t *testing.T) {
 	}
 
 	// Verify spans were created
-	spans := exporter.GetSpans()
+	spans := recorder.Spans()
 	if len(spans) < 4 {
 		t.Errorf("Expected at least 4 spans (CreateOrder, CheckInventory, ProcessPayment, ReserveInventory), got %d", len(spans))
 	}
@@ -160,6 +191,252 @@ func TestCreateOrderHandler_ValidationError(t *testing.T) {
 	}
 }
 
+func TestCreateOrderHandler_QuotaExceeded(t *testing.T) {
+	service, recorder := setupTestService(t)
+	q, err := quota.New(quota.Config{Limit: 1, Window: time.Minute}, quota.NewMemoryStore(), recorder.MeterProvider.Meter("test"), recorder.Logger)
+	if err != nil {
+		t.Fatalf("Failed to create quota: %v", err)
+	}
+	service.quota = q
+
+	body, _ := json.Marshal(CreateOrderRequest{UserID: "test-user", ProductID: "test-product", Quantity: 1, Amount: 10})
+	var statuses []int
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		service.CreateOrderHandler(rec, httptest.NewRequest(http.MethodPost, "/orders", bytes.NewReader(body)))
+		statuses = append(statuses, rec.Code)
+		if i == 1 && rec.Header().Get("Retry-After") == "" {
+			t.Error("Expected Retry-After on the rejected order")
+		}
+	}
+
+	if statuses[0] != http.StatusCreated || statuses[1] != http.StatusTooManyRequests {
+		t.Errorf("Expected 201 then 429, got %v", statuses)
+	}
+	if got := len(recorder.SpansNamed("CheckInventory")); got != 1 {
+		t.Errorf("Expected the rejected order to skip processing, got %d inventory checks", got)
+	}
+}
+
+func TestCreateOrderHandler_ErrorLogCorrelatesWithSpan(t *testing.T) {
+	service, recorder := setupTestService(t)
+
+	body, _ := json.Marshal(CreateOrderRequest{ProductID: "test-product", Quantity: 1, Amount: 10})
+	req := httptest.NewRequest(http.MethodPost, "/orders", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	service.CreateOrderHandler(rec, req)
+
+	spans := recorder.SpansNamed("CreateOrder")
+	if len(spans) != 1 {
+		t.Fatalf("Expected 1 CreateOrder span, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Status.Code != codes.Error {
+		t.Errorf("Expected error span status, got %v", span.Status.Code)
+	}
+
+	var errorLog *observabilitytest.LogRecord
+	for _, l := range recorder.LogsForTrace(span.SpanContext.TraceID()) {
+		if l.Level == slog.LevelError {
+			errorLog = &l
+			break
+		}
+	}
+	if errorLog == nil {
+		t.Fatal("No error log shares a trace_id with the error span")
+	}
+	if errorLog.SpanID != span.SpanContext.SpanID().String() {
+		t.Errorf("Expected error log span_id %s, got %s", span.SpanContext.SpanID(), errorLog.SpanID)
+	}
+
+	if got := recorder.Int64Sum(t, "errors.total"); got != 1 {
+		t.Errorf("Expected 1 error recorded, got %d", got)
+	}
+
+	var resp ErrorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode error response: %v", err)
+	}
+	if resp.TraceID != span.SpanContext.TraceID().String() || resp.Error != "user_id is required" || resp.Code != apperr.ValidationFailed {
+		t.Errorf("Unexpected error response: %+v", resp)
+	}
+}
+
+func TestCreateOrderHandler_DurationExemplarLinksTrace(t *testing.T) {
+	service, recorder := setupTestService(t)
+
+	body, _ := json.Marshal(CreateOrderRequest{UserID: "u", ProductID: "p", Quantity: 1, Amount: 10})
+	service.CreateOrderHandler(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/orders", bytes.NewReader(body)))
+
+	m, ok := recorder.Metric(t, "orders.duration")
+	if !ok {
+		t.Fatal("orders.duration not recorded")
+	}
+	points := m.Data.(metricdata.Histogram[float64]).DataPoints
+	if len(points) != 1 || len(points[0].Exemplars) == 0 {
+		t.Fatalf("Expected an exemplar on orders.duration, got %+v", points)
+	}
+
+	span := recorder.SpansNamed("CreateOrder")[0]
+	tid := span.SpanContext.TraceID()
+	if got := points[0].Exemplars[0].TraceID; !bytes.Equal(got, tid[:]) {
+		t.Errorf("Expected exemplar trace ID %s, got %x", tid, got)
+	}
+}
+
+func TestCreateOrderHandler_RejectsMalformedBodies(t *testing.T) {
+	service, _ := setupTestService(t)
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "not json", body: "not json"},
+		{name: "too deep", body: `{"user_id":` + strings.Repeat("[", maxJSONDepth+1) + strings.Repeat("]", maxJSONDepth+1) + `}`},
+		{name: "too large", body: `{"user_id":"` + strings.Repeat("a", maxRequestBodyBytes) + `"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+
+			service.CreateOrderHandler(rec, req)
+
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("Expected status 400, got %d", rec.Code)
+			}
+		})
+	}
+}
+
+func TestCheckJSONDepth_IgnoresBracketsInStrings(t *testing.T) {
+	doc := `{"note":"` + strings.Repeat("[{", maxJSONDepth) + `\"]"}`
+	if err := checkJSONDepth([]byte(doc), maxJSONDepth); err != nil {
+		t.Errorf("Brackets inside strings should not count towards depth: %v", err)
+	}
+}
+
+func TestStreamJSONArray(t *testing.T) {
+	_, recorder := setupTestService(t)
+	metrics, err := observability.NewMetrics()
+	if err != nil {
+		t.Fatalf("Failed to create metrics: %v", err)
+	}
+
+	rows := func(yield func(CreateOrderResponse, error) bool) {
+		for i := 0; i < 1000; i++ {
+			if !yield(CreateOrderResponse{Status: "success", OrderID: fmt.Sprintf("order-%d", i)}, nil) {
+				return
+			}
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	n, err := streamJSONArray(context.Background(), rec, metrics.StreamedBytes, attributeSet(), rows)
+	if err != nil || n != 1000 {
+		t.Fatalf("Expected 1000 rows without error, got %d (%v)", n, err)
+	}
+
+	var decoded []CreateOrderResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &decoded); err != nil {
+		t.Fatalf("Streamed body is not a valid JSON array: %v", err)
+	}
+	if len(decoded) != 1000 || decoded[999].OrderID != "order-999" {
+		t.Errorf("Unexpected decoded rows: %d", len(decoded))
+	}
+	if !rec.Flushed {
+		t.Error("Expected the stream to flush while writing")
+	}
+	if got := recorder.Int64Sum(t, "http.server.response.streamed_bytes"); got != int64(rec.Body.Len()) {
+		t.Errorf("Expected %d streamed bytes, got %d", rec.Body.Len(), got)
+	}
+}
+
+func TestProcessOrder_FailureCancelsConcurrentStep(t *testing.T) {
+	service, recorder := setupTestService(t)
+	service.faults = faults.NewInjector(1, map[string]faults.Step{
+		"inventory": {FailureRate: 1},
+		"payment":   {Latency: faults.Uniform{Min: time.Hour, Max: time.Hour}},
+	})
+
+	ctx, parent := recorder.TracerProvider.Tracer("test").Start(context.Background(), "CreateOrder")
+	start := time.Now()
+	_, err := service.processOrder(ctx, CreateOrderRequest{UserID: "u", ProductID: "p", Quantity: 1, Amount: 1})
+	parent.End()
+
+	if err == nil || !strings.Contains(err.Error(), "inventory check failed") {
+		t.Fatalf("Expected inventory failure, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("Payment step was not cancelled, took %v", time.Since(start))
+	}
+
+	for _, name := range []string{"CheckInventory", "ProcessPayment"} {
+		spans := recorder.SpansNamed(name)
+		if len(spans) != 1 {
+			t.Fatalf("Expected 1 %s span, got %d", name, len(spans))
+		}
+		if spans[0].Parent.SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("%s is not parented to CreateOrder", name)
+		}
+	}
+	if len(recorder.SpansNamed("ReserveInventory")) != 0 {
+		t.Error("ReserveInventory should not run after a failed check")
+	}
+}
+
+func TestProcessOrder_BudgetOverrunIsRecorded(t *testing.T) {
+	service, recorder := setupTestService(t)
+	service.budgets = Budgets{Steps: map[string]time.Duration{"reserve": 5 * time.Millisecond}}
+	service.faults = faults.NewInjector(1, map[string]faults.Step{
+		"reserve": {Latency: faults.Uniform{Min: 30 * time.Millisecond, Max: 30 * time.Millisecond}},
+	})
+
+	if _, err := service.processOrder(context.Background(), CreateOrderRequest{UserID: "u", ProductID: "p", Quantity: 1, Amount: 1}); err != nil {
+		t.Fatalf("Expected the order to finish without BUDGET_ABORT, got %v", err)
+	}
+
+	span := recorder.SpansNamed("ReserveInventory")[0]
+	found := false
+	for _, e := range span.Events {
+		found = found || e.Name == "latency_budget_exceeded"
+	}
+	if !found {
+		t.Errorf("Expected a latency_budget_exceeded event, got %v", span.Events)
+	}
+
+	m, ok := recorder.Metric(t, "orders.step.budget_exceeded")
+	if !ok {
+		t.Fatal("Budget metric not recorded")
+	}
+	dp := m.Data.(metricdata.Sum[int64]).DataPoints[0]
+	if step, _ := dp.Attributes.Value("step"); step.AsString() != "reserve" || dp.Value != 1 {
+		t.Errorf("Expected 1 overrun for reserve, got %d for %s", dp.Value, step.AsString())
+	}
+}
+
+func TestProcessOrder_BudgetAbortCancelsOrder(t *testing.T) {
+	service, recorder := setupTestService(t)
+	service.budgets = Budgets{Steps: map[string]time.Duration{"inventory": 10 * time.Millisecond}, Abort: true}
+	service.faults = faults.NewInjector(1, map[string]faults.Step{
+		"inventory": {Latency: faults.Uniform{Min: time.Hour, Max: time.Hour}},
+	})
+
+	start := time.Now()
+	_, err := service.processOrder(context.Background(), CreateOrderRequest{UserID: "u", ProductID: "p", Quantity: 1, Amount: 1})
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("Expected ErrBudgetExceeded, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("Inventory step was not aborted, took %v", time.Since(start))
+	}
+	if len(recorder.SpansNamed("ReserveInventory")) != 0 {
+		t.Error("ReserveInventory should not run after an aborted step")
+	}
+}
+
 func TestValidateRequest(t *testing.T) {
 	service, _ := setupTestService(t)
 
@@ -176,7 +453,7 @@ func TestValidateRequest(t *testing.T) {
 }
 
 func BenchmarkCreateOrderHandler(b *testing.B) {
-	service, _ := setupTestService(&testing.T{})
+	service, _ := setupTestService(b)
 
 	reqBody := CreateOrderRequest{
 		UserID:    "test-user",
@@ -187,6 +464,7 @@ func BenchmarkCreateOrderHandler(b *testing.B) {
 
 	body, _ := json.Marshal(reqBody)
 
+	b.ReportAllocs()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		req := httptest.NewRequest(http.MethodPost, "/orders", bytes.NewReader(body))
@@ -194,3 +472,800 @@ func BenchmarkCreateOrderHandler(b *testing.B) {
 		service.CreateOrderHandler(rec, req)
 	}
 }
+
+func BenchmarkErrorCounter(b *testing.B) {
+	_, _ = setupTestService(b)
+	metrics, err := observability.NewMetrics()
+	if err != nil {
+		b.Fatalf("Failed to create metrics: %v", err)
+	}
+	ctx := context.Background()
+
+	b.Run("WithAttributes", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			metrics.ErrorCounter.Add(ctx, 1, metric.WithAttributes(
+				attribute.String("error.type", "validation_error"),
+			))
+		}
+	})
+
+	b.Run("CachedAttributeSet", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			metrics.ErrorCounter.Add(ctx, 1, errorTypeAttrs[apperr.ValidationFailed])
+		}
+	})
+}
+
+func TestCreateOrderHandler_IdempotencyKeyRejectsDuplicate(t *testing.T) {
+	service, recorder := setupTestService(t)
+
+	body, _ := json.Marshal(CreateOrderRequest{UserID: "test-user", ProductID: "test-product", Quantity: 1, Amount: 10})
+	var statuses []int
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/orders", bytes.NewReader(body))
+		req.Header.Set(IdempotencyKeyHeader, "order-1")
+		rec := httptest.NewRecorder()
+		service.CreateOrderHandler(rec, req)
+		statuses = append(statuses, rec.Code)
+	}
+
+	if statuses[0] != http.StatusCreated || statuses[1] != http.StatusConflict {
+		t.Errorf("Expected 201 then 409, got %v", statuses)
+	}
+	if got := len(recorder.SpansNamed("CheckInventory")); got != 1 {
+		t.Errorf("Expected the duplicate to skip processing, got %d inventory checks", got)
+	}
+}
+
+func TestCreateOrderHandler_IdempotencyKeyReleasedOnFailure(t *testing.T) {
+	service, _ := setupTestService(t)
+	service.faults = faults.NewInjector(1, map[string]faults.Step{"inventory": {FailureRate: 1}})
+
+	body, _ := json.Marshal(CreateOrderRequest{UserID: "test-user", ProductID: "test-product", Quantity: 1, Amount: 10})
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/orders", bytes.NewReader(body))
+		req.Header.Set(IdempotencyKeyHeader, "order-1")
+		rec := httptest.NewRecorder()
+		service.CreateOrderHandler(rec, req)
+		if rec.Code != http.StatusConflict {
+			t.Errorf("Expected attempt %d to be processed and fail with 409, got %d", i+1, rec.Code)
+		}
+	}
+}
+
+func TestCreateOrderHandler_ChargesServerPrice(t *testing.T) {
+	service, recorder := setupTestService(t)
+	engine, err := pricing.New(pricing.Config{Prices: map[string]float64{"test-product": 20}}, recorder.MeterProvider.Meter("test"))
+	if err != nil {
+		t.Fatalf("Failed to create pricing engine: %v", err)
+	}
+	service.pricing = engine
+
+	// The client claims a lower amount and omits it entirely
+	for _, amount := range []float64{1, 0} {
+		body, _ := json.Marshal(CreateOrderRequest{UserID: "u", ProductID: "test-product", Quantity: 3, Amount: amount})
+		rec := httptest.NewRecorder()
+		service.CreateOrderHandler(rec, httptest.NewRequest(http.MethodPost, "/orders", bytes.NewReader(body)))
+
+		var resp CreateOrderResponse
+		json.NewDecoder(rec.Body).Decode(&resp)
+		if rec.Code != http.StatusCreated || resp.Amount != 60 {
+			t.Errorf("Expected 201 charging 60, got %d charging %v", rec.Code, resp.Amount)
+		}
+	}
+
+	body, _ := json.Marshal(CreateOrderRequest{UserID: "u", ProductID: "unlisted", Quantity: 1})
+	rec := httptest.NewRecorder()
+	service.CreateOrderHandler(rec, httptest.NewRequest(http.MethodPost, "/orders", bytes.NewReader(body)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unlisted product, got %d", rec.Code)
+	}
+}
+
+func TestCreateOrderHandler_ComparesClientAmountInOrderCurrency(t *testing.T) {
+	service, recorder := setupTestService(t)
+	meter := recorder.MeterProvider.Meter("test")
+	engine, _ := pricing.New(pricing.Config{Prices: map[string]float64{"test-product": 20}}, meter)
+	converter, err := fx.New(fx.Config{}, fx.StaticSource{"EUR": 0.5}, meter, recorder.Logger)
+	if err != nil {
+		t.Fatalf("Failed to create converter: %v", err)
+	}
+	service.pricing = engine
+	service.fx = converter
+
+	// 10 EUR is the right price; 20 is the USD price sent as EUR
+	for _, amount := range []float64{10, 20} {
+		body, _ := json.Marshal(CreateOrderRequest{UserID: "u", ProductID: "test-product", Quantity: 1, Amount: amount, Currency: "EUR"})
+		rec := httptest.NewRecorder()
+		service.CreateOrderHandler(rec, httptest.NewRequest(http.MethodPost, "/orders", bytes.NewReader(body)))
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d", rec.Code)
+		}
+	}
+	if got := recorder.Int64Sum(t, "pricing.client_amount_mismatches"); got != 1 {
+		t.Errorf("Expected only the 20 EUR order to mismatch, got %d", got)
+	}
+}
+
+func TestCreateOrderHandler_ChargesInOrderCurrencyReportsInUSD(t *testing.T) {
+	service, recorder := setupTestService(t)
+	meter := recorder.MeterProvider.Meter("test")
+	engine, _ := pricing.New(pricing.Config{Prices: map[string]float64{"test-product": 20}}, meter)
+	converter, err := fx.New(fx.Config{}, fx.StaticSource{"EUR": 0.5}, meter, recorder.Logger)
+	if err != nil {
+		t.Fatalf("Failed to create converter: %v", err)
+	}
+	service.pricing = engine
+	service.fx = converter
+
+	body, _ := json.Marshal(CreateOrderRequest{UserID: "u", ProductID: "test-product", Quantity: 1, Currency: "eur"})
+	rec := httptest.NewRecorder()
+	service.CreateOrderHandler(rec, httptest.NewRequest(http.MethodPost, "/orders", bytes.NewReader(body)))
+
+	var resp CreateOrderResponse
+	json.NewDecoder(rec.Body).Decode(&resp)
+	if rec.Code != http.StatusCreated || resp.Amount != 10 || resp.Currency != "EUR" {
+		t.Fatalf("Expected 201 charging 10 EUR, got %d charging %v %s", rec.Code, resp.Amount, resp.Currency)
+	}
+
+	m, _ := recorder.Metric(t, "payments.total_amount")
+	points := m.Data.(metricdata.Sum[float64]).DataPoints
+	want := attribute.NewSet(attribute.String("payment.currency", "EUR"))
+	if len(points) != 1 || points[0].Value != 20 || !points[0].Attributes.Equals(&want) {
+		t.Errorf("Expected 20 USD reported for the EUR payment, got %+v", points)
+	}
+
+	body, _ = json.Marshal(CreateOrderRequest{UserID: "u", ProductID: "test-product", Quantity: 1, Currency: "XYZ"})
+	rec = httptest.NewRecorder()
+	service.CreateOrderHandler(rec, httptest.NewRequest(http.MethodPost, "/orders", bytes.NewReader(body)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unsupported currency, got %d", rec.Code)
+	}
+}
+
+type downShippingService struct{}
+
+func (downShippingService) Estimate(context.Context, shipping.Request) (shipping.Estimate, error) {
+	return shipping.Estimate{}, errors.New("connection refused")
+}
+
+func TestCreateOrderHandler_ShippingEstimateIsBestEffort(t *testing.T) {
+	service, recorder := setupTestService(t)
+	client, err := shipping.New(shipping.Config{BreakerFailures: 5}, downShippingService{}, recorder.MeterProvider.Meter("test"), recorder.Logger)
+	if err != nil {
+		t.Fatalf("Failed to create shipping client: %v", err)
+	}
+	service.shipping = client
+
+	body, _ := json.Marshal(CreateOrderRequest{UserID: "u", ProductID: "p", Quantity: 1, Amount: 10})
+	rec := httptest.NewRecorder()
+	service.CreateOrderHandler(rec, httptest.NewRequest(http.MethodPost, "/orders", bytes.NewReader(body)))
+
+	var resp CreateOrderResponse
+	json.NewDecoder(rec.Body).Decode(&resp)
+	if rec.Code != http.StatusCreated || resp.Shipping != nil {
+		t.Errorf("Expected 201 without an estimate, got %d with %+v", rec.Code, resp.Shipping)
+	}
+
+	est := recorder.SpansNamed("EstimateShipping")
+	root := recorder.SpansNamed("CreateOrder")[0]
+	if len(est) != 1 || est[0].Parent.SpanID() != root.SpanContext.SpanID() {
+		t.Errorf("Expected EstimateShipping as a child of CreateOrder, got %d spans", len(est))
+	}
+}
+
+func TestCreateOrderHandler_ReservesTrackedStock(t *testing.T) {
+	service, recorder := setupTestService(t)
+	stock, err := inventory.New(inventory.Config{Stock: map[string]int{"p": 3}, LowStockThreshold: 1}, recorder.MeterProvider.Meter("test"), recorder.Logger)
+	if err != nil {
+		t.Fatalf("Failed to create stock: %v", err)
+	}
+	service.inventory = stock
+
+	order := func(quantity int) int {
+		body, _ := json.Marshal(CreateOrderRequest{UserID: "u", ProductID: "p", Quantity: quantity, Amount: 10})
+		rec := httptest.NewRecorder()
+		service.CreateOrderHandler(rec, httptest.NewRequest(http.MethodPost, "/orders", bytes.NewReader(body)))
+		return rec.Code
+	}
+	if code := order(2); code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d", code)
+	}
+	if code := order(2); code != http.StatusConflict {
+		t.Errorf("Expected the second order to run out of stock, got %d", code)
+	}
+	if levels := stock.Levels(); levels[0].Quantity != 1 || !levels[0].Low {
+		t.Errorf("Expected 1 unit left and low on stock, got %+v", levels[0])
+	}
+	if got := recorder.Int64Sum(t, "inventory.low_stock.events"); got != 1 {
+		t.Errorf("Expected 1 low-stock event, got %d", got)
+	}
+}
+
+func TestListUserOrdersHandler_AuthorizesAndPaginates(t *testing.T) {
+	service, recorder := setupTestService(t)
+	service.adminToken = "admin-token"
+	for range 3 {
+		body, _ := json.Marshal(CreateOrderRequest{UserID: "alice", ProductID: "p", Quantity: 1, Amount: 10})
+		service.CreateOrderHandler(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/orders", bytes.NewReader(body)))
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users/{id}/orders", service.ListUserOrdersHandler)
+
+	list := func(path, caller, token string) (*httptest.ResponseRecorder, OrderHistoryResponse) {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if caller != "" {
+			req.Header.Set(UserIDHeader, caller)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		var resp OrderHistoryResponse
+		json.NewDecoder(rec.Body).Decode(&resp)
+		return rec, resp
+	}
+
+	if rec, _ := list("/users/alice/orders", "", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a caller, got %d", rec.Code)
+	}
+	if rec, _ := list("/users/alice/orders", "mallory", ""); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for another user, got %d", rec.Code)
+	}
+	if rec, _ := list("/users/alice/orders?limit=1000", "alice", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an oversized page, got %d", rec.Code)
+	}
+
+	rec, page := list("/users/alice/orders?limit=2", "alice", "")
+	if rec.Code != http.StatusOK || len(page.Orders) != 2 || page.NextCursor == "" {
+		t.Fatalf("Expected a first page of 2 with a cursor, got %d %+v", rec.Code, page)
+	}
+	_, rest := list("/users/alice/orders?limit=2&cursor="+page.NextCursor, "", "admin-token")
+	if len(rest.Orders) != 1 || rest.NextCursor != "" {
+		t.Errorf("Expected the admin to get the last order, got %+v", rest)
+	}
+	if rest.Orders[0].Status != "placed" || rest.Orders[0].TraceID == "" {
+		t.Errorf("Expected a placed order with its trace ID, got %+v", rest.Orders[0])
+	}
+
+	if got := recorder.Int64Sum(t, "orders.history.requests"); got != 5 {
+		t.Errorf("Expected 5 history requests counted, got %d", got)
+	}
+}
+
+func TestListOrdersHandler_PagesWithSpans(t *testing.T) {
+	service, recorder := setupTestService(t)
+	service.adminToken = "admin-token"
+	for _, user := range []string{"alice", "bob", "alice"} {
+		body, _ := json.Marshal(CreateOrderRequest{UserID: user, ProductID: "p", Quantity: 1, Amount: 10})
+		service.CreateOrderHandler(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/orders", bytes.NewReader(body)))
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /orders", service.ListOrdersHandler)
+
+	list := func(query, caller, token string) (*httptest.ResponseRecorder, OrderHistoryResponse) {
+		req := httptest.NewRequest(http.MethodGet, "/orders"+query, nil)
+		if caller != "" {
+			req.Header.Set(UserIDHeader, caller)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		var resp OrderHistoryResponse
+		json.NewDecoder(rec.Body).Decode(&resp)
+		return rec, resp
+	}
+
+	if rec, _ := list("", "", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a caller, got %d", rec.Code)
+	}
+	if rec, _ := list("?cursor=bogus", "alice", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a bad cursor, got %d", rec.Code)
+	}
+	if _, own := list("", "alice", ""); len(own.Orders) != 2 || own.Orders[0].UserID != "alice" || own.Orders[1].UserID != "alice" {
+		t.Errorf("Expected alice's 2 orders, got %+v", own.Orders)
+	}
+
+	var all []string
+	for cursor, pages := "", 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("Pagination did not terminate")
+		}
+		rec, page := list("?limit=2&cursor="+cursor, "", "admin-token")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", rec.Code)
+		}
+		for _, o := range page.Orders {
+			all = append(all, o.UserID)
+		}
+		if cursor = page.NextCursor; cursor == "" {
+			break
+		}
+	}
+	if strings.Join(all, ",") != "alice,bob,alice" {
+		t.Errorf("Expected every order newest first, got %v", all)
+	}
+
+	spans := recorder.SpansNamed("ListOrders")
+	pages := spans[len(spans)-2:]
+	for i, want := range []struct {
+		first    bool
+		returned int64
+		hasMore  bool
+	}{{true, 2, true}, {false, 1, false}} {
+		attrs := map[attribute.Key]attribute.Value{}
+		for _, kv := range pages[i].Attributes {
+			attrs[kv.Key] = kv.Value
+		}
+		if attrs["page.size"].AsInt64() != 2 || attrs["page.first"].AsBool() != want.first ||
+			attrs["orders.returned"].AsInt64() != want.returned || attrs["orders.has_more"].AsBool() != want.hasMore {
+			t.Errorf("Expected page %d to be first=%v returned=%d has_more=%v, got %v", i, want.first, want.returned, want.hasMore, pages[i].Attributes)
+		}
+	}
+}
+
+func TestGetOrderHandler(t *testing.T) {
+	service, recorder := setupTestService(t)
+	service.adminToken = "admin-token"
+	body, _ := json.Marshal(CreateOrderRequest{UserID: "alice", ProductID: "p", Quantity: 1, Amount: 10})
+	rec := httptest.NewRecorder()
+	service.CreateOrderHandler(rec, httptest.NewRequest(http.MethodPost, "/orders", bytes.NewReader(body)))
+	var created CreateOrderResponse
+	json.NewDecoder(rec.Body).Decode(&created)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /orders/{id}", service.GetOrderHandler)
+
+	get := func(id, caller, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/orders/"+id, nil)
+		if caller != "" {
+			req.Header.Set(UserIDHeader, caller)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := get(created.OrderID, "", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a caller, got %d", rec.Code)
+	}
+	for _, tt := range []struct{ id, caller string }{{created.OrderID, "mallory"}, {"missing", "alice"}} {
+		rec := get(tt.id, tt.caller, "")
+		var resp ErrorResponse
+		json.NewDecoder(rec.Body).Decode(&resp)
+		if rec.Code != http.StatusNotFound || resp.Code != apperr.OrderNotFound {
+			t.Errorf("Expected 404 ORDER_NOT_FOUND for %s as %s, got %d %q", tt.id, tt.caller, rec.Code, resp.Code)
+		}
+	}
+	for _, rec := range []*httptest.ResponseRecorder{get(created.OrderID, "alice", ""), get(created.OrderID, "", "admin-token")} {
+		var order orders.Order
+		json.NewDecoder(rec.Body).Decode(&order)
+		if rec.Code != http.StatusOK || order.ID != created.OrderID || order.UserID != "alice" {
+			t.Errorf("Expected alice's order, got %d %+v", rec.Code, order)
+		}
+	}
+
+	spans := recorder.SpansNamed("GetOrder")
+	if len(spans) != 5 {
+		t.Fatalf("Expected 5 GetOrder spans, got %d", len(spans))
+	}
+	if spans[1].Status.Code == codes.Error {
+		t.Error("Expected a not found answer to leave the span unset")
+	}
+	if got := recorder.Int64Sum(t, "orders.lookups"); got != 5 {
+		t.Errorf("Expected 5 lookups counted, got %d", got)
+	}
+}
+
+// drainOnEnd empties a product's stock once every named span has ended
+type drainOnEnd struct {
+	sdktrace.SpanProcessor
+	stock   *inventory.Stock
+	product string
+
+	mu      sync.Mutex
+	pending map[string]bool
+}
+
+func (d *drainOnEnd) OnEnd(s sdktrace.ReadOnlySpan) {
+	d.mu.Lock()
+	drain := d.pending[s.Name()] && len(d.pending) == 1
+	delete(d.pending, s.Name())
+	d.mu.Unlock()
+	// Set ends a span of its own, so it must not run under the lock
+	if drain {
+		d.stock.Set(context.Background(), d.product, 0, nil)
+	}
+}
+
+func TestCreateOrderHandler_RefundsWhenStockRunsOutAfterPayment(t *testing.T) {
+	service, recorder := setupTestService(t)
+	stock, err := inventory.New(inventory.Config{Stock: map[string]int{"p": 5}, LowStockThreshold: 1}, recorder.MeterProvider.Meter("test"), recorder.Logger)
+	if err != nil {
+		t.Fatalf("Failed to create stock: %v", err)
+	}
+	service.inventory = stock
+	// Another order takes the last units after the check passed and the
+	// payment went through, but before the reservation
+	recorder.TracerProvider.RegisterSpanProcessor(&drainOnEnd{
+		SpanProcessor: sdktrace.NewSimpleSpanProcessor(tracetest.NewInMemoryExporter()),
+		stock:         stock,
+		product:       "p",
+		pending:       map[string]bool{"CheckInventory": true, "ProcessPayment": true},
+	})
+
+	body, _ := json.Marshal(CreateOrderRequest{UserID: "alice", ProductID: "p", Quantity: 2, Amount: 10})
+	rec := httptest.NewRecorder()
+	service.CreateOrderHandler(rec, httptest.NewRequest(http.MethodPost, "/orders", bytes.NewReader(body)))
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("Expected 409 for exhausted stock, got %d", rec.Code)
+	}
+
+	root := recorder.SpansNamed("CreateOrder")[0]
+	refunds := recorder.SpansNamed("RefundPayment")
+	if len(refunds) != 1 || refunds[0].Parent.SpanID() != root.SpanContext.SpanID() {
+		t.Fatalf("Expected one refund under CreateOrder, got %d", len(refunds))
+	}
+	var event *sdktrace.Event
+	for i := range root.Events {
+		if root.Events[i].Name == "compensation" {
+			event = &root.Events[i]
+		}
+	}
+	if event == nil {
+		t.Fatal("Expected a compensation event on CreateOrder")
+	}
+	attrs := attribute.NewSet(event.Attributes...)
+	if reason, _ := attrs.Value("compensation.reason"); reason.AsString() != "inventory_reservation_failed" {
+		t.Errorf("Expected the reservation as the reason, got %q", reason.AsString())
+	}
+	if outcome, _ := attrs.Value("compensation.outcome"); outcome.AsString() != "refunded" {
+		t.Errorf("Expected the refund to succeed, got %q", outcome.AsString())
+	}
+}
+
+func TestCreateOrderHandler_ShadowSkipsSideEffects(t *testing.T) {
+	service, recorder := setupTestService(t)
+	stock, err := inventory.New(inventory.Config{Stock: map[string]int{"p": 5}, LowStockThreshold: 1}, recorder.MeterProvider.Meter("test"), recorder.Logger)
+	if err != nil {
+		t.Fatalf("Failed to create stock: %v", err)
+	}
+	service.inventory = stock
+	q, err := quota.New(quota.Config{Limit: 1, Window: time.Minute}, quota.NewMemoryStore(), recorder.MeterProvider.Meter("test"), recorder.Logger)
+	if err != nil {
+		t.Fatalf("Failed to create quota: %v", err)
+	}
+	service.quota = q
+	service.shadowSecret = "s3cret"
+
+	body, _ := json.Marshal(CreateOrderRequest{UserID: "alice", ProductID: "p", Quantity: 2, Amount: 10})
+	place := func(shadow string) int {
+		req := httptest.NewRequest(http.MethodPost, "/orders", bytes.NewReader(body))
+		req.Header.Set(IdempotencyKeyHeader, "k1")
+		if shadow != "" {
+			req.Header.Set(middleware.ShadowHeader, shadow)
+		}
+		rec := httptest.NewRecorder()
+		service.CreateOrderHandler(rec, req)
+		return rec.Code
+	}
+
+	// The primary spends the quota and claims the key; its shadow follows
+	if got := place(""); got != http.StatusCreated {
+		t.Fatalf("Expected 201 for the primary order, got %d", got)
+	}
+	if got := place("s3cret"); got != http.StatusCreated {
+		t.Fatalf("Expected 201 for a shadow order despite the spent quota and claimed key, got %d", got)
+	}
+
+	if got := len(recorder.SpansNamed("ProcessPayment")); got != 1 {
+		t.Errorf("Expected only the primary to be charged, got %d payments", got)
+	}
+	if got := len(recorder.SpansNamed("ReserveInventory")); got != 1 {
+		t.Errorf("Expected only the primary to reserve stock, got %d reservations", got)
+	}
+	if got := len(recorder.SpansNamed("CheckInventory")); got != 2 {
+		t.Errorf("Expected the shadow to check the inventory too, got %d checks", got)
+	}
+	if got := stock.Levels()[0].Quantity; got != 3 {
+		t.Errorf("Expected only the primary's stock reserved, got %d left", got)
+	}
+	if stored, _, _ := service.orders.List(context.Background(), orders.Page{Limit: 10}); len(stored) != 1 {
+		t.Errorf("Expected only the primary stored, got %d orders", len(stored))
+	}
+	attrs := attribute.NewSet(recorder.SpansNamed("CreateOrder")[1].Attributes...)
+	if shadow, _ := attrs.Value("order.shadow"); !shadow.AsBool() {
+		t.Error("Expected CreateOrder to be marked order.shadow")
+	}
+
+	// A client can't claim to be the mirror
+	if got := place("true"); got != http.StatusTooManyRequests {
+		t.Errorf("Expected a forged shadow to be held to the quota, got %d", got)
+	}
+}
+
+func TestCancelOrderHandler_Compensates(t *testing.T) {
+	service, recorder := setupTestService(t)
+	stock, err := inventory.New(inventory.Config{Stock: map[string]int{"p": 5}, LowStockThreshold: 1}, recorder.MeterProvider.Meter("test"), recorder.Logger)
+	if err != nil {
+		t.Fatalf("Failed to create stock: %v", err)
+	}
+	service.inventory = stock
+	body, _ := json.Marshal(CreateOrderRequest{UserID: "alice", ProductID: "p", Quantity: 2, Amount: 10})
+	rec := httptest.NewRecorder()
+	service.CreateOrderHandler(rec, httptest.NewRequest(http.MethodPost, "/orders", bytes.NewReader(body)))
+	var created CreateOrderResponse
+	json.NewDecoder(rec.Body).Decode(&created)
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /orders/{id}/cancel", service.CancelOrderHandler)
+
+	cancel := func(caller string) (*httptest.ResponseRecorder, apperr.Code) {
+		req := httptest.NewRequest(http.MethodPost, "/orders/"+created.OrderID+"/cancel", nil)
+		req.Header.Set(UserIDHeader, caller)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		var resp ErrorResponse
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec, resp.Code
+	}
+
+	if rec, code := cancel("mallory"); rec.Code != http.StatusNotFound || code != apperr.OrderNotFound {
+		t.Errorf("Expected 404 for another user's order, got %d %q", rec.Code, code)
+	}
+
+	// A failed refund changes nothing, so the cancellation can be retried
+	service.faults = faults.NewInjector(1, map[string]faults.Step{"refund": {FailureRate: 1}})
+	if rec, code := cancel("alice"); rec.Code != http.StatusBadGateway || code != apperr.RefundFailed {
+		t.Errorf("Expected 502 REFUND_FAILED, got %d %q", rec.Code, code)
+	}
+	if got := stock.Levels()[0].Quantity; got != 3 {
+		t.Errorf("Expected stock to stay reserved after a failed refund, got %d", got)
+	}
+	service.faults = faults.NewInjector(1, nil)
+
+	rec, _ = cancel("alice")
+	var order orders.Order
+	json.NewDecoder(rec.Body).Decode(&order)
+	if rec.Code != http.StatusOK || order.Status != orders.StatusCancelled || order.CancelledAt.IsZero() {
+		t.Fatalf("Expected a cancelled order, got %d %+v", rec.Code, order)
+	}
+	if got := stock.Levels()[0].Quantity; got != 5 {
+		t.Errorf("Expected the 2 units back in stock, got %d", got)
+	}
+	if rec, code := cancel("alice"); rec.Code != http.StatusConflict || code != apperr.NotCancellable {
+		t.Errorf("Expected 409 NOT_CANCELLABLE the second time, got %d %q", rec.Code, code)
+	}
+
+	roots := recorder.SpansNamed("CancelOrder")
+	if len(roots) != 4 {
+		t.Fatalf("Expected 4 CancelOrder spans, got %d", len(roots))
+	}
+	root := roots[2]
+	for _, name := range []string{"RefundPayment", "ReleaseInventory"} {
+		spans := recorder.SpansNamed(name)
+		if len(spans) == 0 || spans[len(spans)-1].Parent.SpanID() != root.SpanContext.SpanID() {
+			t.Errorf("Expected %s as a child of the successful CancelOrder", name)
+		}
+	}
+	refunds := recorder.SpansNamed("RefundPayment")
+	if len(refunds) != 2 || refunds[0].Status.Code != codes.Error {
+		t.Errorf("Expected a failed then a successful refund, got %d spans", len(refunds))
+	}
+	if got := recorder.Int64Sum(t, "orders.cancelled"); got != 1 {
+		t.Errorf("Expected 1 cancelled order, got %d", got)
+	}
+}
+
+// failSaves fails the next n saves
+type failSaves struct {
+	orders.Store
+	n int
+}
+
+func (f *failSaves) Save(ctx context.Context, o orders.Order) error {
+	if f.n > 0 {
+		f.n--
+		return errors.New("store unavailable")
+	}
+	return f.Store.Save(ctx, o)
+}
+
+func TestCancelOrderHandler_RetryAfterFailedSave(t *testing.T) {
+	service, recorder := setupTestService(t)
+	stock, err := inventory.New(inventory.Config{Stock: map[string]int{"p": 5}, LowStockThreshold: 1}, recorder.MeterProvider.Meter("test"), recorder.Logger)
+	if err != nil {
+		t.Fatalf("Failed to create stock: %v", err)
+	}
+	service.inventory = stock
+	store := &failSaves{Store: service.orders}
+	service.orders = store
+
+	body, _ := json.Marshal(CreateOrderRequest{UserID: "alice", ProductID: "p", Quantity: 2, Amount: 10})
+	rec := httptest.NewRecorder()
+	service.CreateOrderHandler(rec, httptest.NewRequest(http.MethodPost, "/orders", bytes.NewReader(body)))
+	var created CreateOrderResponse
+	json.NewDecoder(rec.Body).Decode(&created)
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /orders/{id}/cancel", service.CancelOrderHandler)
+	cancel := func() int {
+		req := httptest.NewRequest(http.MethodPost, "/orders/"+created.OrderID+"/cancel", nil)
+		req.Header.Set(UserIDHeader, "alice")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	store.n = 1
+	if code := cancel(); code != http.StatusInternalServerError {
+		t.Fatalf("Expected 500 when the cancelled order cannot be saved, got %d", code)
+	}
+	if code := cancel(); code != http.StatusOK {
+		t.Fatalf("Expected the retry to succeed, got %d", code)
+	}
+
+	if got := len(recorder.SpansNamed("RefundPayment")); got != 1 {
+		t.Errorf("Expected the order refunded once, got %d refunds", got)
+	}
+	if got := stock.Levels()[0].Quantity; got != 5 {
+		t.Errorf("Expected the 2 units released once, got %d in stock", got)
+	}
+	roots := recorder.SpansNamed("CancelOrder")
+	if len(roots) != 2 {
+		t.Fatalf("Expected 2 CancelOrder spans, got %d", len(roots))
+	}
+	skipped := 0
+	for _, e := range roots[1].Events {
+		if e.Name == "cancel_step_skipped" {
+			skipped++
+		}
+	}
+	if skipped != 2 {
+		t.Errorf("Expected the retry to skip the refund and release, got %d skipped steps", skipped)
+	}
+}
+
+func TestCreateOrderHandler_DuplicateOrders(t *testing.T) {
+	tests := []struct {
+		mode       DuplicateMode
+		wantStatus int
+	}{
+		{DuplicatesFlag, http.StatusCreated},
+		{DuplicatesBlock, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			service, recorder := setupTestService(t)
+			service.duplicates = DuplicateConfig{Mode: tt.mode, Window: time.Minute}
+
+			codes := make([]int, 3)
+			for i, amount := range []float64{10, 10, 11} {
+				body, _ := json.Marshal(CreateOrderRequest{UserID: "u", ProductID: "p", Quantity: 1, Amount: amount})
+				rec := httptest.NewRecorder()
+				service.CreateOrderHandler(rec, httptest.NewRequest(http.MethodPost, "/orders", bytes.NewReader(body)))
+				codes[i] = rec.Code
+			}
+			if codes[0] != http.StatusCreated || codes[1] != tt.wantStatus || codes[2] != http.StatusCreated {
+				t.Errorf("Expected 201, %d, 201 (different amount), got %v", tt.wantStatus, codes)
+			}
+
+			suspected := 0
+			for _, span := range recorder.SpansNamed("CreateOrder") {
+				for _, a := range span.Attributes {
+					if a.Key == "order.duplicate_suspected" {
+						suspected++
+					}
+				}
+			}
+			if suspected != 1 {
+				t.Errorf("Expected one span flagged as a duplicate, got %d", suspected)
+			}
+			if got := recorder.Int64Sum(t, "orders.duplicates.detected"); got != 1 {
+				t.Errorf("Expected 1 duplicate counted, got %d", got)
+			}
+		})
+	}
+}
+
+func TestExportOrdersHandler_FiltersAndFormats(t *testing.T) {
+	service, recorder := setupTestService(t)
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, user := range []string{"alice", "=cmd()", "bob"} {
+		service.orders.Save(context.Background(), orders.Order{
+			ID: fmt.Sprint("o", i), UserID: user, ProductID: "p", Quantity: 1, Amount: 10,
+			Currency: "USD", Status: orders.StatusPlaced, CreatedAt: base.Add(time.Duration(i) * time.Hour),
+		})
+	}
+
+	export := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		service.ExportOrdersHandler(rec, httptest.NewRequest(http.MethodGet, "/admin/orders/export?"+query, nil))
+		return rec
+	}
+
+	if rec := export("format=xml"); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown format, got %d", rec.Code)
+	}
+	if rec := export("from=yesterday"); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a bad date, got %d", rec.Code)
+	}
+
+	rec := export("format=csv&to=2024-01-01T02:00:00Z")
+	want := "order_id,user_id,product_id,quantity,amount,currency,status,trace_id,created_at\n" +
+		"o0,alice,p,1,10.00,USD,placed,,2024-01-01T00:00:00Z\n" +
+		"o1,'=cmd(),p,1,10.00,USD,placed,,2024-01-01T01:00:00Z\n"
+	if rec.Body.String() != want {
+		t.Errorf("Expected CSV:\n%s\ngot:\n%s", want, rec.Body.String())
+	}
+
+	rec = export("format=jsonl&from=2024-01-01T01:00:00Z&status=placed")
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	if rec.Header().Get("Content-Type") != "application/x-ndjson" || len(lines) != 2 {
+		t.Fatalf("Expected 2 JSON lines, got %q", rec.Body.String())
+	}
+	var last orders.Order
+	if err := json.Unmarshal([]byte(lines[1]), &last); err != nil || last.ID != "o2" {
+		t.Errorf("Expected o2 last, got %+v (%v)", last, err)
+	}
+
+	if got := recorder.Int64Sum(t, "orders.export.rows"); got != 4 {
+		t.Errorf("Expected 4 exported rows counted, got %d", got)
+	}
+	if spans := recorder.SpansNamed("ExportOrders"); len(spans) != 4 {
+		t.Errorf("Expected 4 ExportOrders spans, got %d", len(spans))
+	}
+}
+
+func TestCreateOrderHandler_TracksOrdersInFlight(t *testing.T) {
+	service, recorder := setupTestService(t)
+	service.faults = faults.NewInjector(1, map[string]faults.Step{"payment": {FailureRate: 1}})
+
+	for _, quantity := range []int{1, 2} {
+		body, _ := json.Marshal(CreateOrderRequest{UserID: "test-user", ProductID: "test-product", Quantity: quantity, Amount: 10})
+		service.CreateOrderHandler(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/orders", bytes.NewReader(body)))
+	}
+
+	if _, ok := recorder.Metric(t, "orders.in_flight"); !ok {
+		t.Fatal("Expected orders.in_flight to be recorded")
+	}
+	if got := recorder.Int64Sum(t, "orders.in_flight"); got != 0 {
+		t.Errorf("Expected failed orders to leave nothing in flight, got %d", got)
+	}
+}
+
+func TestProcessPayment_RecordsDeclineMetrics(t *testing.T) {
+	service, recorder := setupTestService(t)
+	service.faults = faults.NewInjector(1, map[string]faults.Step{"payment": {FailureRate: 1}})
+
+	err := service.processPayment(context.Background(), "test-user", 10, "USD")
+	if apperr.CodeOf(err) != apperr.PaymentDeclined {
+		t.Fatalf("Expected PAYMENT_DECLINED, got %v", err)
+	}
+
+	m, ok := recorder.Metric(t, "payments.declined")
+	if !ok {
+		t.Fatal("Expected payments.declined to be recorded")
+	}
+	dp := m.Data.(metricdata.Sum[int64]).DataPoints[0]
+	if gateway, _ := dp.Attributes.Value("payment.gateway"); gateway.AsString() != "stripe" {
+		t.Errorf("Expected gateway stripe, got %q", gateway.AsString())
+	}
+	if reason, ok := dp.Attributes.Value("payment.decline_reason"); !ok || reason.AsString() == "" {
+		t.Errorf("Expected a decline reason, got %v", dp.Attributes)
+	}
+
+	m, ok = recorder.Metric(t, "payments.duration")
+	if !ok {
+		t.Fatal("Expected payments.duration to be recorded")
+	}
+	hist := m.Data.(metricdata.Histogram[float64]).DataPoints[0]
+	if outcome, _ := hist.Attributes.Value("payment.outcome"); outcome.AsString() != "declined" {
+		t.Errorf("Expected outcome declined, got %q", outcome.AsString())
+	}
+}
@@ -0,0 +1,131 @@
+package shipping
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrBreakerOpen is returned without calling the service while the breaker
+// is open
+var ErrBreakerOpen = errors.New("circuit breaker open")
+
+// OpenError is how Allow rejects a call. It wraps ErrBreakerOpen and says
+// how long until the breaker lets a probe through, for Retry-After.
+type OpenError struct {
+	RetryAfter time.Duration
+}
+
+func (e *OpenError) Error() string {
+	return ErrBreakerOpen.Error()
+}
+
+func (e *OpenError) Unwrap() error {
+	return ErrBreakerOpen
+}
+
+// RetryAfter returns how long a caller rejected with err should wait
+// before trying again, or false if err is not a breaker rejection
+func RetryAfter(err error) (time.Duration, bool) {
+	var open *OpenError
+	if !errors.As(err, &open) {
+		return 0, false
+	}
+	return open.RetryAfter, true
+}
+
+// BreakerState is exported as the breaker.state gauge; alert on >= 1
+type BreakerState int
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half_open"
+	}
+	return "closed"
+}
+
+// Breaker opens after Threshold consecutive failures and, once Cooldown has
+// passed, lets a single probe through; its result closes or reopens it
+type Breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{threshold: max(1, threshold), cooldown: cooldown}
+}
+
+// Allow returns an *OpenError if the call should not be made. Its
+// RetryAfter is what is left of the cooldown, at least a second; while a
+// probe is in flight it is a second, since the probe decides soon.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if left := b.cooldown - time.Since(b.openedAt); left > 0 {
+			return &OpenError{RetryAfter: max(time.Second, left)}
+		}
+		b.state = BreakerHalfOpen
+		b.probing = true
+		return nil
+	case BreakerHalfOpen:
+		if b.probing {
+			return &OpenError{RetryAfter: time.Second}
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// Record reports the outcome of an allowed call and returns the state
+// before and after it
+func (b *Breaker) Record(success bool) (from, to BreakerState) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	from = b.state
+	b.probing = false
+	if success {
+		b.state = BreakerClosed
+		b.failures = 0
+		return from, b.state
+	}
+
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		b.state = BreakerOpen
+		b.openedAt = time.Now()
+	}
+	return from, b.state
+}
+
+// Release gives back an allowed call without an outcome, e.g. when the
+// caller gave up, so a half-open breaker can probe again
+func (b *Breaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
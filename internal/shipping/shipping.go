@@ -0,0 +1,285 @@
+package shipping
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-observability-demo/internal/config"
+	"go-observability-demo/internal/faults"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Request is what the shipping service needs to quote a delivery
+type Request struct {
+	ProductID string `json:"product_id"`
+	Quantity  int    `json:"quantity"`
+}
+
+// Estimate is the shipping service's quote
+type Estimate struct {
+	Cost    float64 `json:"cost"`
+	Days    int     `json:"days"`
+	Carrier string  `json:"carrier"`
+}
+
+// Estimator is the shipping service; swap it for a fake in tests
+type Estimator interface {
+	Estimate(ctx context.Context, req Request) (Estimate, error)
+}
+
+// StatusError is a non-2xx reply from the shipping service
+type StatusError struct {
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("shipping service returned %d", e.Code)
+}
+
+// retryable treats everything but 4xx replies (other than 429) as transient
+func retryable(err error) bool {
+	var status *StatusError
+	if errors.As(err, &status) {
+		return status.Code == http.StatusTooManyRequests || status.Code >= 500
+	}
+	return true
+}
+
+// HTTPEstimator POSTs the request as JSON to URL and decodes an Estimate
+type HTTPEstimator struct {
+	URL    string
+	Client *http.Client
+}
+
+func (h HTTPEstimator) Estimate(ctx context.Context, req Request) (Estimate, error) {
+	body, _ := json.Marshal(req)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return Estimate{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := h.Client.Do(httpReq)
+	if err != nil {
+		return Estimate{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return Estimate{}, &StatusError{Code: resp.StatusCode}
+	}
+
+	var est Estimate
+	if err := json.NewDecoder(resp.Body).Decode(&est); err != nil {
+		return Estimate{}, fmt.Errorf("failed to decode estimate: %w", err)
+	}
+	return est, nil
+}
+
+// Simulated stands in for the shipping service in the local demo, with
+// latency and failures from FAULT_SHIPPING_* like the other steps
+type Simulated struct {
+	faults *faults.Injector
+}
+
+func NewSimulated() (*Simulated, error) {
+	injector, err := faults.FromEnv(map[string]faults.Step{
+		"shipping": {Latency: faults.LogNormal{Median: 40 * time.Millisecond, Sigma: 0.5}, FailureRate: 0.05},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Simulated{faults: injector}, nil
+}
+
+func (s *Simulated) Estimate(ctx context.Context, req Request) (Estimate, error) {
+	if _, err := s.faults.Delay(ctx, "shipping"); err != nil {
+		return Estimate{}, err
+	}
+	if s.faults.ShouldFail("shipping") {
+		return Estimate{}, &StatusError{Code: http.StatusServiceUnavailable}
+	}
+	return Estimate{Cost: 4.99 + 1.5*float64(req.Quantity), Days: 3, Carrier: "demo-post"}, nil
+}
+
+// Config is the shipping step's resilience policy
+type Config struct {
+	// URL of the shipping service; the simulated service is used when empty
+	URL        string
+	Timeout    time.Duration // per attempt
+	MaxRetries int
+	Backoff    time.Duration // doubled after each retry
+	// The breaker opens after BreakerFailures consecutive failed estimates
+	// and probes again after BreakerCooldown
+	BreakerFailures int
+	BreakerCooldown time.Duration
+}
+
+// ConfigFromEnv reads SHIPPING_URL, SHIPPING_TIMEOUT, SHIPPING_MAX_RETRIES,
+// SHIPPING_RETRY_BACKOFF, SHIPPING_BREAKER_FAILURES, and
+// SHIPPING_BREAKER_COOLDOWN
+func ConfigFromEnv() Config {
+	return Config{
+		URL:             config.String("SHIPPING_URL", ""),
+		Timeout:         config.Duration("SHIPPING_TIMEOUT", 300*time.Millisecond),
+		MaxRetries:      config.Int("SHIPPING_MAX_RETRIES", 2),
+		Backoff:         config.Duration("SHIPPING_RETRY_BACKOFF", 50*time.Millisecond),
+		BreakerFailures: config.Int("SHIPPING_BREAKER_FAILURES", 5),
+		BreakerCooldown: config.Duration("SHIPPING_BREAKER_COOLDOWN", 30*time.Second),
+	}
+}
+
+// Client calls the shipping service with retries behind a circuit breaker.
+// Each estimate is an EstimateShipping span with an event per retry, and
+// its total time including retries is recorded in shipping.duration.
+type Client struct {
+	cfg       Config
+	estimator Estimator
+	breaker   *Breaker
+	logger    *slog.Logger
+	tracer    trace.Tracer
+
+	duration metric.Float64Histogram
+}
+
+func New(cfg Config, estimator Estimator, meter metric.Meter, logger *slog.Logger) (*Client, error) {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 300 * time.Millisecond
+	}
+	c := &Client{
+		cfg:       cfg,
+		estimator: estimator,
+		breaker:   NewBreaker(cfg.BreakerFailures, cfg.BreakerCooldown),
+		logger:    logger,
+		tracer:    otel.Tracer("order-service/shipping"),
+	}
+
+	var err error
+	c.duration, err = meter.Float64Histogram(
+		"shipping.duration",
+		metric.WithDescription("Shipping estimate latency including retries, by outcome"),
+		metric.WithUnit("ms"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := meter.Int64ObservableGauge(
+		"breaker.state",
+		metric.WithDescription("Circuit breaker state: 0 closed, 1 open, 2 half-open"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	breakerAttrs := metric.WithAttributes(attribute.String("breaker", "shipping"))
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveInt64(state, int64(c.breaker.State()), breakerAttrs)
+		return nil
+	}, state)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Estimate asks the shipping service for a quote. Errors wrap
+// ErrBreakerOpen when the call was not attempted; RetryAfter says when
+// to try again.
+func (c *Client) Estimate(ctx context.Context, req Request) (Estimate, error) {
+	start := time.Now()
+	ctx, span := c.tracer.Start(ctx, "EstimateShipping",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("product.id", req.ProductID),
+			attribute.Int("order.quantity", req.Quantity),
+		),
+	)
+	defer span.End()
+
+	est, attempts, err := c.call(ctx, req)
+	span.SetAttributes(
+		attribute.Int("shipping.attempts", attempts),
+		attribute.String("breaker.state", c.breaker.State().String()),
+	)
+
+	outcome := "success"
+	switch {
+	case errors.Is(err, ErrBreakerOpen):
+		outcome = "rejected"
+	case err != nil:
+		outcome = "error"
+	}
+	c.duration.Record(ctx, float64(time.Since(start).Milliseconds()), metric.WithAttributes(attribute.String("outcome", outcome)))
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "shipping estimate failed")
+		return Estimate{}, err
+	}
+	span.SetAttributes(
+		attribute.Float64("shipping.cost", est.Cost),
+		attribute.Int("shipping.days", est.Days),
+		attribute.String("shipping.carrier", est.Carrier),
+	)
+	return est, nil
+}
+
+func (c *Client) call(ctx context.Context, req Request) (Estimate, int, error) {
+	if err := c.breaker.Allow(); err != nil {
+		return Estimate{}, 0, err
+	}
+
+	var (
+		est      Estimate
+		err      error
+		attempts int
+	)
+	backoff := c.cfg.Backoff
+	for attempts = 1; ; attempts++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+		est, err = c.estimator.Estimate(attemptCtx, req)
+		cancel()
+		if err == nil || !retryable(err) || attempts > c.cfg.MaxRetries || ctx.Err() != nil {
+			break
+		}
+
+		trace.SpanFromContext(ctx).AddEvent("shipping_retry", trace.WithAttributes(
+			attribute.Int("attempt", attempts+1),
+			attribute.String("error", err.Error()),
+		))
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-ctx.Done():
+		}
+	}
+
+	// Neither our own cancellation nor a client error says anything about
+	// the service's health
+	if err != nil && ctx.Err() != nil {
+		c.breaker.Release()
+		return est, attempts, err
+	}
+	if from, to := c.breaker.Record(err == nil || !retryable(err)); from != to {
+		trace.SpanFromContext(ctx).AddEvent("breaker_state_changed", trace.WithAttributes(
+			attribute.String("breaker.from", from.String()),
+			attribute.String("breaker.to", to.String()),
+		))
+		c.logger.Warn("shipping circuit breaker state changed",
+			slog.String("event.name", "breaker.state_changed"),
+			slog.String("breaker", "shipping"),
+			slog.String("from", from.String()),
+			slog.String("to", to.String()),
+		)
+	}
+	return est, attempts, err
+}
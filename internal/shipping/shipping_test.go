@@ -0,0 +1,167 @@
+package shipping
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go-observability-demo/internal/observability/observabilitytest"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// fakeEstimator fails its first `failures` calls with err
+type fakeEstimator struct {
+	failures int32
+	err      error
+	calls    atomic.Int32
+}
+
+func (f *fakeEstimator) Estimate(context.Context, Request) (Estimate, error) {
+	if f.calls.Add(1) <= f.failures {
+		return Estimate{}, f.err
+	}
+	return Estimate{Cost: 7.5, Days: 2, Carrier: "fake"}, nil
+}
+
+func newTestClient(t *testing.T, cfg Config, estimator Estimator) (*Client, *observabilitytest.Recorder) {
+	t.Helper()
+	recorder := observabilitytest.New(t)
+	c, err := New(cfg, estimator, otel.Meter("test"), recorder.Logger)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	return c, recorder
+}
+
+func TestClient_RetriesTransientFailures(t *testing.T) {
+	fake := &fakeEstimator{failures: 2, err: &StatusError{Code: http.StatusServiceUnavailable}}
+	c, recorder := newTestClient(t, Config{MaxRetries: 2, Backoff: time.Millisecond, BreakerFailures: 5}, fake)
+
+	est, err := c.Estimate(context.Background(), Request{ProductID: "prod-123", Quantity: 1})
+	if err != nil || est.Carrier != "fake" {
+		t.Fatalf("Expected the third attempt to succeed, got %+v (%v)", est, err)
+	}
+
+	span := recorder.SpansNamed("EstimateShipping")[0]
+	retries := 0
+	for _, e := range span.Events {
+		if e.Name == "shipping_retry" {
+			retries++
+		}
+	}
+	if retries != 2 {
+		t.Errorf("Expected 2 retry events, got %d", retries)
+	}
+
+	m, _ := recorder.Metric(t, "shipping.duration")
+	points := m.Data.(metricdata.Histogram[float64]).DataPoints
+	success := attribute.NewSet(attribute.String("outcome", "success"))
+	if len(points) != 1 || !points[0].Attributes.Equals(&success) {
+		t.Errorf("Expected one successful estimate in shipping.duration, got %+v", points)
+	}
+}
+
+func TestClient_ClientErrorsAreNotRetriedAndDontTripBreaker(t *testing.T) {
+	fake := &fakeEstimator{failures: 100, err: &StatusError{Code: http.StatusBadRequest}}
+	c, _ := newTestClient(t, Config{MaxRetries: 2, Backoff: time.Millisecond, BreakerFailures: 1}, fake)
+
+	for i := 0; i < 3; i++ {
+		c.Estimate(context.Background(), Request{})
+	}
+	if got := fake.calls.Load(); got != 3 {
+		t.Errorf("Expected 1 call per estimate, got %d calls", got)
+	}
+	if c.breaker.State() != BreakerClosed {
+		t.Errorf("Expected the breaker to stay closed, got %v", c.breaker.State())
+	}
+}
+
+func TestClient_BreakerOpensAndRecovers(t *testing.T) {
+	fake := &fakeEstimator{failures: 2, err: errors.New("connection refused")}
+	c, recorder := newTestClient(t, Config{Backoff: time.Millisecond, BreakerFailures: 2, BreakerCooldown: 20 * time.Millisecond}, fake)
+	ctx := context.Background()
+
+	c.Estimate(ctx, Request{})
+	c.Estimate(ctx, Request{})
+	if _, err := c.Estimate(ctx, Request{}); !errors.Is(err, ErrBreakerOpen) {
+		t.Fatalf("Expected ErrBreakerOpen after 2 failures, got %v", err)
+	}
+	if got := fake.calls.Load(); got != 2 {
+		t.Errorf("Expected the open breaker to skip the call, got %d calls", got)
+	}
+	if got := recorder.GaugeValue(t, "breaker.state"); got != float64(BreakerOpen) {
+		t.Errorf("Expected breaker.state %d, got %v", BreakerOpen, got)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if _, err := c.Estimate(ctx, Request{}); err != nil {
+		t.Fatalf("Expected the half-open probe to succeed, got %v", err)
+	}
+	if got := recorder.GaugeValue(t, "breaker.state"); got != float64(BreakerClosed) {
+		t.Errorf("Expected the breaker to close after a good probe, got %v", got)
+	}
+}
+
+func TestBreaker_RetryAfterIsRemainingCooldown(t *testing.T) {
+	b := NewBreaker(1, time.Minute)
+	b.Record(false)
+	retryAfter, ok := RetryAfter(b.Allow())
+	if !ok || retryAfter <= 59*time.Second || retryAfter > time.Minute {
+		t.Errorf("Expected about a minute left of the cooldown, got %v (%v)", retryAfter, ok)
+	}
+
+	b = NewBreaker(1, time.Millisecond)
+	b.Record(false)
+	time.Sleep(2 * time.Millisecond)
+	b.Allow()
+	if retryAfter, ok := RetryAfter(b.Allow()); !ok || retryAfter != time.Second {
+		t.Errorf("Expected a second while the probe is in flight, got %v (%v)", retryAfter, ok)
+	}
+	if _, ok := RetryAfter(errors.New("connection refused")); ok {
+		t.Error("Expected no Retry-After for other errors")
+	}
+}
+
+func TestBreaker_HalfOpenAllowsOneProbe(t *testing.T) {
+	b := NewBreaker(1, 0)
+	b.Record(false)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("Expected the first probe after cooldown, got %v", err)
+	}
+	if err := b.Allow(); !errors.Is(err, ErrBreakerOpen) {
+		t.Errorf("Expected a second concurrent probe to be rejected, got %v", err)
+	}
+	if _, to := b.Record(false); to != BreakerOpen {
+		t.Errorf("Expected a failed probe to reopen the breaker, got %v", to)
+	}
+}
+
+func TestHTTPEstimator(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.ProductID == "oversized" {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			return
+		}
+		json.NewEncoder(w).Encode(Estimate{Cost: float64(req.Quantity), Days: 1, Carrier: "http"})
+	}))
+	defer server.Close()
+	h := HTTPEstimator{URL: server.URL, Client: server.Client()}
+
+	if est, err := h.Estimate(context.Background(), Request{ProductID: "p", Quantity: 3}); err != nil || est.Cost != 3 {
+		t.Errorf("Expected a cost of 3, got %+v (%v)", est, err)
+	}
+	_, err := h.Estimate(context.Background(), Request{ProductID: "oversized"})
+	var status *StatusError
+	if !errors.As(err, &status) || retryable(err) {
+		t.Errorf("Expected a permanent StatusError, got %v", err)
+	}
+}
@@ -0,0 +1,471 @@
+package slo
+
+import (
+	"context"
+	"encoding/json"
+	"go-observability-demo/internal/config"
+	"go-observability-demo/internal/observability"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// DurationMetric is the RED histogram objectives are computed from
+const DurationMetric = "http.server.request.duration"
+
+// DefaultWindows are the burn-rate windows of the usual multi-window
+// alerts: 1h with 5m, and 6h with 30m
+var DefaultWindows = []time.Duration{5 * time.Minute, 30 * time.Minute, time.Hour, 6 * time.Hour}
+
+// budgetSamples bounds the history kept for the budget period
+const budgetSamples = 720
+
+// Objective is a target share of good requests on one route. Availability
+// objectives count 5xx responses as bad. Latency objectives count requests
+// slower than Latency as bad, whatever their status.
+type Objective struct {
+	Name   string
+	Method string
+	Route  string
+	Target float64
+	// Latency should be a bucket boundary of DurationMetric; otherwise the
+	// next lower boundary is used, which errs towards spending budget
+	Latency time.Duration
+}
+
+// Config sets the objectives and how they are evaluated
+type Config struct {
+	Objectives []Objective
+	// Period is the error budget period, e.g. 30 days
+	Period time.Duration
+	// Windows are the burn-rate windows reported as slo.burn_rate
+	Windows  []time.Duration
+	Interval time.Duration
+	// Freeze rejects risky admin changes while any objective's budget is
+	// spent, see Tracker.FreezeGate
+	Freeze bool
+}
+
+// ConfigFromEnv reads SLO_ROUTE, SLO_METHOD, SLO_AVAILABILITY_TARGET,
+// SLO_LATENCY_TARGET, SLO_LATENCY_THRESHOLD, SLO_PERIOD, SLO_INTERVAL, and
+// SLO_FREEZE. A target of 0 drops that objective.
+func ConfigFromEnv() Config {
+	route := config.String("SLO_ROUTE", "/orders")
+	method := config.String("SLO_METHOD", http.MethodPost)
+
+	var objectives []Objective
+	if target := config.Float("SLO_AVAILABILITY_TARGET", 0.995); target > 0 {
+		objectives = append(objectives, Objective{Name: "availability", Method: method, Route: route, Target: target})
+	}
+	if target := config.Float("SLO_LATENCY_TARGET", 0.995); target > 0 {
+		objectives = append(objectives, Objective{
+			Name:    "latency",
+			Method:  method,
+			Route:   route,
+			Target:  target,
+			Latency: config.Duration("SLO_LATENCY_THRESHOLD", 500*time.Millisecond),
+		})
+	}
+	return Config{
+		Objectives: objectives,
+		Period:     config.Duration("SLO_PERIOD", 30*24*time.Hour),
+		Windows:    DefaultWindows,
+		Interval:   config.Duration("SLO_INTERVAL", 30*time.Second),
+		Freeze:     config.Bool("SLO_FREEZE", false),
+	}
+}
+
+// Enabled is true with at least one objective. With OBSERVABILITY_DISABLED
+// there are no RED metrics to evaluate, so it is always false.
+func (c Config) Enabled() bool {
+	return len(c.Objectives) > 0 && !observability.Disabled()
+}
+
+// sample is a cumulative count of requests and bad requests
+type sample struct {
+	at         time.Time
+	total, bad int64
+}
+
+// status is the latest evaluation of one objective
+type status struct {
+	burn      map[time.Duration]float64
+	remaining float64
+}
+
+// Tracker evaluates objectives against the service's own RED metrics on an
+// interval. Bad requests are counted in slo.errors (and all requests in
+// slo.requests), the error rate of each window divided by the allowed rate
+// is slo.burn_rate, and the share of the period's budget left is
+// slo.budget_remaining, negative once overspent. Like the alert watcher it
+// reads metrics through its own reader.
+type Tracker struct {
+	cfg    Config
+	reader *sdkmetric.ManualReader
+	logger *slog.Logger
+
+	requests metric.Int64Counter
+	errors   metric.Int64Counter
+
+	mu sync.Mutex
+	// recent covers the longest window at every interval; older holds
+	// thinned samples back to the start of the period
+	recent   map[string][]sample
+	older    map[string][]sample
+	statuses map[string]status
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+func New(cfg Config, meter metric.Meter, logger *slog.Logger) (*Tracker, error) {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 30 * time.Second
+	}
+	if cfg.Period <= 0 {
+		cfg.Period = 30 * 24 * time.Hour
+	}
+	if len(cfg.Windows) == 0 {
+		cfg.Windows = DefaultWindows
+	}
+
+	t := &Tracker{
+		cfg:      cfg,
+		reader:   sdkmetric.NewManualReader(),
+		logger:   logger,
+		recent:   make(map[string][]sample),
+		older:    make(map[string][]sample),
+		statuses: make(map[string]status),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	for _, o := range cfg.Objectives {
+		t.statuses[o.Name] = status{burn: map[time.Duration]float64{}, remaining: 1}
+	}
+
+	var err error
+	t.requests, err = meter.Int64Counter(
+		"slo.requests",
+		metric.WithDescription("Requests counted towards an objective"),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	t.errors, err = meter.Int64Counter(
+		"slo.errors",
+		metric.WithDescription("Requests that spent error budget, by objective"),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	burnRate, err := meter.Float64ObservableGauge(
+		"slo.burn_rate",
+		metric.WithDescription("Error rate over the window divided by the rate the objective allows"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	remaining, err := meter.Float64ObservableGauge(
+		"slo.budget_remaining",
+		metric.WithDescription("Share of the period's error budget left, negative once overspent"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		for _, obj := range t.cfg.Objectives {
+			st := t.statuses[obj.Name]
+			name := attribute.String("slo.objective", obj.Name)
+			for _, w := range t.cfg.Windows {
+				o.ObserveFloat64(burnRate, st.burn[w], metric.WithAttributes(name, attribute.String("slo.window", windowLabel(w))))
+			}
+			o.ObserveFloat64(remaining, st.remaining, metric.WithAttributes(name))
+		}
+		return nil
+	}, burnRate, remaining)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Reader must be registered with the meter provider, see
+// observability.WithMetricReader
+func (t *Tracker) Reader() sdkmetric.Reader {
+	return t.reader
+}
+
+func (t *Tracker) Start() {
+	go t.run()
+}
+
+// Stop ends the evaluation loop
+func (t *Tracker) Stop(ctx context.Context) error {
+	t.once.Do(func() { close(t.stop) })
+	select {
+	case <-t.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (t *Tracker) run() {
+	defer close(t.done)
+	ticker := time.NewTicker(t.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.stop:
+			return
+		case <-ticker.C:
+			t.Evaluate(context.Background(), time.Now())
+		}
+	}
+}
+
+// Evaluate collects metrics once and updates every objective as of now
+func (t *Tracker) Evaluate(ctx context.Context, now time.Time) {
+	var rm metricdata.ResourceMetrics
+	if err := t.reader.Collect(ctx, &rm); err != nil {
+		t.logger.Warn("slo tracker failed to collect metrics", slog.String("error", err.Error()))
+		return
+	}
+	hist, _ := findMetric(&rm, DurationMetric).(metricdata.Histogram[float64])
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, obj := range t.cfg.Objectives {
+		cur := count(hist, obj)
+		cur.at = now
+
+		recent := t.recent[obj.Name]
+		if n := len(recent); n > 0 {
+			attrs := metric.WithAttributes(attribute.String("slo.objective", obj.Name))
+			t.requests.Add(ctx, cur.total-recent[n-1].total, attrs)
+			t.errors.Add(ctx, cur.bad-recent[n-1].bad, attrs)
+		}
+		recent = append(recent, cur)
+		t.recent[obj.Name] = t.trim(obj.Name, recent, now)
+
+		st := status{burn: make(map[time.Duration]float64, len(t.cfg.Windows))}
+		allowed := 1 - obj.Target
+		for _, w := range t.cfg.Windows {
+			st.burn[w] = errorRate(t.since(obj.Name, now.Add(-w)), cur) / allowed
+		}
+		st.remaining = 1
+		if base := t.since(obj.Name, now.Add(-t.cfg.Period)); cur.total > base.total {
+			st.remaining = 1 - float64(cur.bad-base.bad)/(allowed*float64(cur.total-base.total))
+		}
+		t.statuses[obj.Name] = st
+	}
+}
+
+// Exhausted lists the objectives whose error budget is spent, in config
+// order
+func (t *Tracker) Exhausted() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var names []string
+	for _, obj := range t.cfg.Objectives {
+		if t.statuses[obj.Name].remaining <= 0 {
+			names = append(names, obj.Name)
+		}
+	}
+	return names
+}
+
+// Frozen reports whether freeze mode is on and some budget is spent. A nil
+// *Tracker is never frozen.
+func (t *Tracker) Frozen() bool {
+	return t != nil && t.cfg.Freeze && len(t.Exhausted()) > 0
+}
+
+// FreezeGate wraps an admin handler so that, while the tracker is Frozen,
+// anything but a read is rejected with 409. Reads stay open so operators
+// can still inspect the service during an incident.
+func (t *Tracker) FreezeGate(next http.Handler) http.Handler {
+	if t == nil || !t.cfg.Freeze {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if exhausted := t.Exhausted(); len(exhausted) > 0 {
+			t.logger.WarnContext(r.Context(), "admin change rejected by slo freeze",
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Any("slo.exhausted", exhausted),
+			)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]any{
+				"error":     "error budget exhausted, admin changes are frozen",
+				"exhausted": exhausted,
+			})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// objectiveStatus is one objective in the /slo/status response
+type objectiveStatus struct {
+	Name            string             `json:"name"`
+	Method          string             `json:"method,omitempty"`
+	Route           string             `json:"route"`
+	Target          float64            `json:"target"`
+	LatencySeconds  float64            `json:"latency_seconds,omitempty"`
+	BudgetRemaining float64            `json:"budget_remaining"`
+	BurnRates       map[string]float64 `json:"burn_rates"`
+}
+
+// StatusHandler serves /slo/status: each objective's remaining budget and
+// burn rates as of the last evaluation, and whether freeze mode is active
+func (t *Tracker) StatusHandler(w http.ResponseWriter, r *http.Request) {
+	t.mu.Lock()
+	objectives := make([]objectiveStatus, 0, len(t.cfg.Objectives))
+	for _, obj := range t.cfg.Objectives {
+		st := t.statuses[obj.Name]
+		burn := make(map[string]float64, len(t.cfg.Windows))
+		for _, w := range t.cfg.Windows {
+			burn[windowLabel(w)] = st.burn[w]
+		}
+		objectives = append(objectives, objectiveStatus{
+			Name:            obj.Name,
+			Method:          obj.Method,
+			Route:           obj.Route,
+			Target:          obj.Target,
+			LatencySeconds:  obj.Latency.Seconds(),
+			BudgetRemaining: st.remaining,
+			BurnRates:       burn,
+		})
+	}
+	t.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"period":     windowLabel(t.cfg.Period),
+		"objectives": objectives,
+		"freeze":     t.cfg.Freeze,
+		"frozen":     t.Frozen(),
+	})
+}
+
+// trim moves samples older than the longest window to the thinned history,
+// keeping one sample per Period/budgetSamples, and drops those older than
+// the period
+func (t *Tracker) trim(name string, recent []sample, now time.Time) []sample {
+	longest := t.cfg.Windows[0]
+	for _, w := range t.cfg.Windows {
+		longest = max(longest, w)
+	}
+	step := t.cfg.Period / budgetSamples
+
+	older := t.older[name]
+	// Keep one sample at or before the window start so it has a baseline
+	for len(recent) > 1 && !recent[1].at.After(now.Add(-longest)) {
+		if n := len(older); n == 0 || recent[0].at.Sub(older[n-1].at) >= step {
+			older = append(older, recent[0])
+		}
+		recent = recent[1:]
+	}
+	for len(older) > 1 && !older[1].at.After(now.Add(-t.cfg.Period)) {
+		older = older[1:]
+	}
+	t.older[name] = older
+	return recent
+}
+
+// since returns the latest sample at or before from, or the earliest one
+// when the tracker has not run that long
+func (t *Tracker) since(name string, from time.Time) sample {
+	var base sample
+	found := false
+	for _, samples := range [][]sample{t.older[name], t.recent[name]} {
+		for _, s := range samples {
+			if !found || !s.at.After(from) {
+				base, found = s, true
+			}
+		}
+	}
+	return base
+}
+
+// count totals the objective's requests in the histogram
+func count(hist metricdata.Histogram[float64], obj Objective) sample {
+	var s sample
+	threshold := obj.Latency.Seconds()
+	for _, dp := range hist.DataPoints {
+		if route, _ := dp.Attributes.Value("http.route"); route.AsString() != obj.Route {
+			continue
+		}
+		if method, _ := dp.Attributes.Value("http.request.method"); obj.Method != "" && method.AsString() != obj.Method {
+			continue
+		}
+		total := int64(dp.Count)
+		s.total += total
+		if obj.Latency <= 0 {
+			if code, _ := dp.Attributes.Value("http.response.status_code"); code.AsInt64() >= http.StatusInternalServerError {
+				s.bad += total
+			}
+			continue
+		}
+		var fast int64
+		for i, bound := range dp.Bounds {
+			if bound > threshold {
+				break
+			}
+			fast += int64(dp.BucketCounts[i])
+		}
+		s.bad += total - fast
+	}
+	return s
+}
+
+func errorRate(from, to sample) float64 {
+	if to.total <= from.total {
+		return 0
+	}
+	return float64(to.bad-from.bad) / float64(to.total-from.total)
+}
+
+func findMetric(rm *metricdata.ResourceMetrics, name string) metricdata.Aggregation {
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == name {
+				return m.Data
+			}
+		}
+	}
+	return nil
+}
+
+// windowLabel formats w the way PromQL writes ranges, e.g. 5m or 6h
+func windowLabel(w time.Duration) string {
+	s := w.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
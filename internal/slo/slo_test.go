@@ -0,0 +1,290 @@
+package slo
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+type harness struct {
+	tracker  *Tracker
+	out      *sdkmetric.ManualReader
+	duration metric.Float64Histogram
+}
+
+func newHarness(t *testing.T, cfg Config) *harness {
+	t.Helper()
+	out := sdkmetric.NewManualReader()
+	outProvider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(out))
+	t.Cleanup(func() { _ = outProvider.Shutdown(context.Background()) })
+
+	tracker, err := New(cfg, outProvider.Meter("test"), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("Failed to create tracker: %v", err)
+	}
+
+	// The service's provider, which the tracker reads the RED histogram from
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(tracker.Reader()))
+	t.Cleanup(func() { _ = provider.Shutdown(context.Background()) })
+	duration, err := provider.Meter("test").Float64Histogram(DurationMetric, metric.WithExplicitBucketBoundaries(0.1, 0.25, 0.5, 1))
+	if err != nil {
+		t.Fatalf("Failed to create histogram: %v", err)
+	}
+	return &harness{tracker: tracker, out: out, duration: duration}
+}
+
+func (h *harness) serve(n int, status int, seconds float64) {
+	attrs := metric.WithAttributes(
+		attribute.String("http.request.method", "POST"),
+		attribute.String("http.route", "/orders"),
+		attribute.Int("http.response.status_code", status),
+	)
+	for range n {
+		h.duration.Record(context.Background(), seconds, attrs)
+	}
+}
+
+func (h *harness) gauge(t *testing.T, name string, attrs ...attribute.KeyValue) float64 {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := h.out.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Failed to collect: %v", err)
+	}
+	want := attribute.NewSet(attrs...)
+	gauge, _ := findMetric(&rm, name).(metricdata.Gauge[float64])
+	for _, dp := range gauge.DataPoints {
+		if dp.Attributes.Equals(&want) {
+			return dp.Value
+		}
+	}
+	t.Fatalf("No %s point for %v", name, attrs)
+	return 0
+}
+
+func (h *harness) sum(t *testing.T, name, objective string) int64 {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := h.out.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Failed to collect: %v", err)
+	}
+	sum, _ := findMetric(&rm, name).(metricdata.Sum[int64])
+	for _, dp := range sum.DataPoints {
+		if v, _ := dp.Attributes.Value("slo.objective"); v.AsString() == objective {
+			return dp.Value
+		}
+	}
+	return 0
+}
+
+func TestTracker_Availability(t *testing.T) {
+	h := newHarness(t, Config{
+		Objectives: []Objective{{Name: "availability", Method: "POST", Route: "/orders", Target: 0.99}},
+		Period:     24 * time.Hour,
+		Windows:    []time.Duration{5 * time.Minute, time.Hour},
+	})
+	start := time.Now()
+	h.tracker.Evaluate(context.Background(), start)
+
+	// An hour at 1% errors burns exactly the allowed rate
+	h.serve(990, 200, 0.05)
+	h.serve(10, 503, 0.05)
+	h.tracker.Evaluate(context.Background(), start.Add(55*time.Minute))
+
+	// Then five minutes at 10% errors
+	h.serve(90, 200, 0.05)
+	h.serve(10, 500, 0.05)
+	h.serve(5, 404, 0.05)
+	now := start.Add(time.Hour)
+	h.tracker.Evaluate(context.Background(), now)
+
+	objective := attribute.String("slo.objective", "availability")
+	if got := h.gauge(t, "slo.burn_rate", objective, attribute.String("slo.window", "5m")); math.Abs(got-10/1.05) > 0.01 {
+		t.Errorf("Expected a 5m burn rate of %.2f, got %.2f", 10/1.05, got)
+	}
+	if got := h.gauge(t, "slo.burn_rate", objective, attribute.String("slo.window", "1h")); math.Abs(got-20/11.05) > 0.01 {
+		t.Errorf("Expected a 1h burn rate of %.2f, got %.2f", 20/11.05, got)
+	}
+	// 20 bad of 1105 against an allowance of 11.05
+	if got := h.gauge(t, "slo.budget_remaining", objective); math.Abs(got-(1-20/11.05)) > 0.01 {
+		t.Errorf("Expected %.2f of the budget left, got %.2f", 1-20/11.05, got)
+	}
+	if got := h.sum(t, "slo.errors", "availability"); got != 20 {
+		t.Errorf("Expected 20 errors, got %d", got)
+	}
+	if got := h.sum(t, "slo.requests", "availability"); got != 1105 {
+		t.Errorf("Expected 1105 requests, got %d", got)
+	}
+}
+
+func TestTracker_Latency(t *testing.T) {
+	h := newHarness(t, Config{
+		Objectives: []Objective{{Name: "latency", Method: "POST", Route: "/orders", Target: 0.9, Latency: 500 * time.Millisecond}},
+		Windows:    []time.Duration{5 * time.Minute},
+	})
+	start := time.Now()
+	h.tracker.Evaluate(context.Background(), start)
+
+	h.serve(8, 200, 0.2)
+	h.serve(1, 500, 0.5)
+	h.serve(1, 200, 0.9)
+	h.tracker.Evaluate(context.Background(), start.Add(time.Minute))
+
+	objective := attribute.String("slo.objective", "latency")
+	if got := h.gauge(t, "slo.burn_rate", objective, attribute.String("slo.window", "5m")); math.Abs(got-1) > 0.01 {
+		t.Errorf("Expected only the slow request to count, burning at 1, got %.2f", got)
+	}
+}
+
+func TestTracker_NoTraffic(t *testing.T) {
+	h := newHarness(t, Config{
+		Objectives: []Objective{{Name: "availability", Route: "/orders", Target: 0.99}},
+	})
+	h.tracker.Evaluate(context.Background(), time.Now())
+
+	objective := attribute.String("slo.objective", "availability")
+	if got := h.gauge(t, "slo.budget_remaining", objective); got != 1 {
+		t.Errorf("Expected the whole budget without traffic, got %v", got)
+	}
+	if got := h.gauge(t, "slo.burn_rate", objective, attribute.String("slo.window", "1h")); got != 0 {
+		t.Errorf("Expected no burn without traffic, got %v", got)
+	}
+}
+
+func TestTracker_TrimsHistory(t *testing.T) {
+	h := newHarness(t, Config{
+		Objectives: []Objective{{Name: "availability", Route: "/orders", Target: 0.99}},
+		Period:     12 * time.Hour,
+		Windows:    []time.Duration{time.Hour},
+		Interval:   time.Minute,
+	})
+	start := time.Now()
+	for i := range 24 * 60 {
+		h.tracker.Evaluate(context.Background(), start.Add(time.Duration(i)*time.Minute))
+	}
+
+	if got := len(h.tracker.recent["availability"]); got > 62 {
+		t.Errorf("Expected about an hour of recent samples, got %d", got)
+	}
+	if got := len(h.tracker.older["availability"]); got > budgetSamples+1 {
+		t.Errorf("Expected at most %d older samples, got %d", budgetSamples+1, got)
+	}
+}
+
+func TestTracker_StatusAndFreeze(t *testing.T) {
+	h := newHarness(t, Config{
+		Objectives: []Objective{{Name: "availability", Method: "POST", Route: "/orders", Target: 0.9}},
+		Windows:    []time.Duration{5 * time.Minute},
+		Freeze:     true,
+	})
+	admin := h.tracker.FreezeGate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	adjust := func() int {
+		rec := httptest.NewRecorder()
+		admin.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/inventory/widget/adjust", nil))
+		return rec.Code
+	}
+
+	start := time.Now()
+	h.tracker.Evaluate(context.Background(), start)
+	h.serve(10, 200, 0.05)
+	h.tracker.Evaluate(context.Background(), start.Add(time.Minute))
+	if code := adjust(); code != http.StatusNoContent {
+		t.Errorf("Expected changes within budget, got %d", code)
+	}
+
+	// 30% errors overspends a 10% budget
+	h.serve(7, 200, 0.05)
+	h.serve(3, 500, 0.05)
+	h.tracker.Evaluate(context.Background(), start.Add(2*time.Minute))
+	if code := adjust(); code != http.StatusConflict {
+		t.Errorf("Expected 409 once the budget is spent, got %d", code)
+	}
+	rec := httptest.NewRecorder()
+	admin.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/inventory", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("Expected reads to stay open, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.tracker.StatusHandler(rec, httptest.NewRequest(http.MethodGet, "/slo/status", nil))
+	var body struct {
+		Frozen     bool `json:"frozen"`
+		Objectives []struct {
+			Name            string             `json:"name"`
+			BudgetRemaining float64            `json:"budget_remaining"`
+			BurnRates       map[string]float64 `json:"burn_rates"`
+		} `json:"objectives"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode status: %v", err)
+	}
+	if !body.Frozen || len(body.Objectives) != 1 {
+		t.Fatalf("Expected one frozen objective, got %+v", body)
+	}
+	// 3 bad of 20 against an allowance of 2
+	if o := body.Objectives[0]; o.Name != "availability" || math.Abs(o.BudgetRemaining+0.5) > 0.01 || math.Abs(o.BurnRates["5m"]-1.5) > 0.01 {
+		t.Errorf("Unexpected status %+v", o)
+	}
+}
+
+func TestTracker_FreezeOff(t *testing.T) {
+	h := newHarness(t, Config{Objectives: []Objective{{Name: "availability", Route: "/orders", Target: 0.9}}})
+	start := time.Now()
+	h.tracker.Evaluate(context.Background(), start)
+	h.serve(10, 500, 0.05)
+	h.tracker.Evaluate(context.Background(), start.Add(time.Minute))
+
+	if h.tracker.Frozen() {
+		t.Error("Expected no freeze without SLO_FREEZE")
+	}
+	var nilTracker *Tracker
+	if nilTracker.Frozen() {
+		t.Error("Expected a nil tracker never to be frozen")
+	}
+}
+
+func TestWindowLabel(t *testing.T) {
+	for w, want := range map[time.Duration]string{
+		5 * time.Minute:  "5m",
+		time.Hour:        "1h",
+		6 * time.Hour:    "6h",
+		90 * time.Second: "1m30s",
+	} {
+		if got := windowLabel(w); got != want {
+			t.Errorf("Expected %q for %v, got %q", want, w, got)
+		}
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("SLO_LATENCY_TARGET", "0")
+	cfg := ConfigFromEnv()
+	if len(cfg.Objectives) != 1 || cfg.Objectives[0].Name != "availability" {
+		t.Fatalf("Expected only the availability objective, got %+v", cfg.Objectives)
+	}
+	if o := cfg.Objectives[0]; o.Route != "/orders" || o.Method != "POST" || o.Target != 0.995 {
+		t.Errorf("Expected POST /orders at 99.5%%, got %+v", o)
+	}
+}
+
+func TestConfig_DisabledWithoutObservability(t *testing.T) {
+	if !ConfigFromEnv().Enabled() {
+		t.Fatal("Expected the default objectives to be enabled")
+	}
+	t.Setenv("OBSERVABILITY_DISABLED", "true")
+	if ConfigFromEnv().Enabled() {
+		t.Error("Expected no SLO tracking with OBSERVABILITY_DISABLED")
+	}
+}